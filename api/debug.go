@@ -0,0 +1,46 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/kinjelom/watchdog_exporter/validator"
+)
+
+// DebugPath is where the debug switch handler is mounted.
+const DebugPath = "/api/v1/debug"
+
+// NewDebugHandler shows (GET) and flips (POST) per-endpoint detail logging.
+// POST takes enabled=true|false and one of endpoint, group or all=true, e.g. ?endpoint=example.com&enabled=true.
+func NewDebugHandler(d *validator.DebugSwitch) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+		case http.MethodPost:
+			q := r.URL.Query()
+			on, err := strconv.ParseBool(q.Get("enabled"))
+			if err != nil {
+				http.Error(w, "enabled must be true or false", http.StatusBadRequest)
+				return
+			}
+			switch {
+			case q.Get("endpoint") != "":
+				d.SetEndpoint(q.Get("endpoint"), on)
+			case q.Get("group") != "":
+				d.SetGroup(q.Get("group"), on)
+			case q.Get("all") == "true":
+				d.SetAll(on)
+			default:
+				http.Error(w, "one of endpoint, group or all=true is required", http.StatusBadRequest)
+				return
+			}
+		default:
+			w.Header().Set("Allow", "GET, HEAD, POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(d.State())
+	})
+}
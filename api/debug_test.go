@@ -0,0 +1,52 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+	"github.com/kinjelom/watchdog_exporter/validator"
+)
+
+func TestDebugHandler_FlipsSwitch(t *testing.T) {
+	d := validator.NewDebugSwitch(&config.WatchDogConfig{Endpoints: map[string]config.Endpoint{
+		"a": {Group: "g1"},
+		"b": {Group: "g2", Debug: true},
+	}})
+	h := NewDebugHandler(d)
+
+	do := func(method, target string) (int, validator.DebugState) {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(method, target, nil))
+		var st validator.DebugState
+		if rec.Code == http.StatusOK {
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &st))
+		}
+		return rec.Code, st
+	}
+
+	code, st := do(http.MethodGet, DebugPath)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []string{"b"}, st.Endpoints)
+	assert.False(t, d.Enabled("a"))
+
+	code, st = do(http.MethodPost, DebugPath+"?group=g1&enabled=true")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []string{"g1"}, st.Groups)
+	assert.True(t, d.Enabled("a"))
+
+	code, _ = do(http.MethodPost, DebugPath+"?endpoint=b&enabled=false")
+	assert.Equal(t, http.StatusOK, code)
+	assert.False(t, d.Enabled("b"))
+
+	code, _ = do(http.MethodPost, DebugPath+"?endpoint=b")
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = do(http.MethodPost, DebugPath+"?enabled=true")
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = do(http.MethodDelete, DebugPath)
+	assert.Equal(t, http.StatusMethodNotAllowed, code)
+}
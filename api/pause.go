@@ -0,0 +1,58 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/kinjelom/watchdog_exporter/prober"
+)
+
+// PausePath and ResumePath are where the pause handlers are mounted ({name} is the endpoint name).
+const (
+	PausePath  = "/api/v1/endpoints/{name}/pause"
+	ResumePath = "/api/v1/endpoints/{name}/resume"
+)
+
+// Pauser stops and restarts the probes of an endpoint (prober.Engine).
+type Pauser interface {
+	Pause(endpointName string) error
+	Resume(endpointName string) error
+}
+
+type pauseResponse struct {
+	Endpoint string `json:"endpoint"`
+	Paused   bool   `json:"paused"`
+}
+
+// NewPauseHandler stops probing the endpoint named in the path on POST, until it is resumed. Its series keep the
+// last values and watchdog_endpoint_probe_suppressed reports the pause.
+func NewPauseHandler(p Pauser) http.Handler {
+	return pauseHandler(p.Pause, true)
+}
+
+// NewResumeHandler probes the endpoint named in the path again on POST.
+func NewResumeHandler(p Pauser) http.Handler {
+	return pauseHandler(p.Resume, false)
+}
+
+func pauseHandler(apply func(string) error, paused bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			http.Error(w, "method not allowed, use POST", http.StatusMethodNotAllowed)
+			return
+		}
+		name := r.PathValue("name")
+		if err := apply(name); err != nil {
+			code := http.StatusInternalServerError
+			if errors.Is(err, prober.ErrUnknownEndpoint) {
+				code = http.StatusNotFound
+			}
+			http.Error(w, err.Error(), code)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(pauseResponse{Endpoint: name, Paused: paused})
+	})
+}
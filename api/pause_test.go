@@ -0,0 +1,57 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kinjelom/watchdog_exporter/prober"
+)
+
+type fakePauser map[string]bool
+
+func (p fakePauser) set(name string, paused bool) error {
+	if _, ok := p[name]; !ok {
+		return fmt.Errorf("%w %q", prober.ErrUnknownEndpoint, name)
+	}
+	p[name] = paused
+	return nil
+}
+
+func (p fakePauser) Pause(name string) error  { return p.set(name, true) }
+func (p fakePauser) Resume(name string) error { return p.set(name, false) }
+
+func TestPauseHandlers(t *testing.T) {
+	p := fakePauser{"shop": false}
+	mux := http.NewServeMux()
+	mux.Handle(PausePath, NewPauseHandler(p))
+	mux.Handle(ResumePath, NewResumeHandler(p))
+	do := func(method, target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(method, target, nil))
+		return rec
+	}
+
+	rec := do(http.MethodPost, "/api/v1/endpoints/shop/pause")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.True(t, p["shop"])
+	var out pauseResponse
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &out))
+	assert.Equal(t, pauseResponse{Endpoint: "shop", Paused: true}, out)
+
+	rec = do(http.MethodGet, "/api/v1/endpoints/shop/resume")
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	assert.True(t, p["shop"], "GET does not resume")
+
+	rec = do(http.MethodPost, "/api/v1/endpoints/shop/resume")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.False(t, p["shop"])
+
+	rec = do(http.MethodPost, "/api/v1/endpoints/cart/pause")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Contains(t, rec.Body.String(), `unknown endpoint "cart"`)
+}
@@ -0,0 +1,36 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+)
+
+// ReloadPath is where the reload handler is mounted (Prometheus lifecycle style).
+const ReloadPath = "/-/reload"
+
+// NewReloadHandler re-reads the config on POST or PUT. An invalid config is answered with 400 and one that needs
+// a restart with 409, both with the reason in the body; the running config stays active then.
+func NewReloadHandler(reload func() error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost && r.Method != http.MethodPut {
+			w.Header().Set("Allow", "POST, PUT")
+			http.Error(w, "method not allowed, use POST or PUT", http.StatusMethodNotAllowed)
+			return
+		}
+		if err := reload(); err != nil {
+			code := http.StatusInternalServerError
+			switch {
+			case errors.Is(err, config.ErrInvalidConfig):
+				code = http.StatusBadRequest
+			case errors.Is(err, config.ErrRestartRequired):
+				code = http.StatusConflict
+			}
+			http.Error(w, err.Error(), code)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, _ = w.Write([]byte("configuration reloaded\n"))
+	})
+}
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+)
+
+func TestReloadHandler(t *testing.T) {
+	var err error
+	calls := 0
+	h := NewReloadHandler(func() error {
+		calls++
+		return err
+	})
+	do := func(method string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(method, ReloadPath, nil))
+		return rec
+	}
+
+	rec := do(http.MethodGet)
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	assert.Equal(t, 0, calls, "GET does not reload")
+
+	rec = do(http.MethodPost)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, 1, calls)
+
+	err = fmt.Errorf("cannot load --config=c.yml: %w: endpoint \"a\": route \"x\" is not defined", config.ErrInvalidConfig)
+	rec = do(http.MethodPut)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), `route "x" is not defined`)
+
+	err = fmt.Errorf("cannot reload: %w: probe-all-ips", config.ErrRestartRequired)
+	assert.Equal(t, http.StatusConflict, do(http.MethodPost).Code)
+
+	err = errors.New("open c.yml: permission denied")
+	assert.Equal(t, http.StatusInternalServerError, do(http.MethodPost).Code)
+}
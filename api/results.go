@@ -0,0 +1,188 @@
+// Package api serves JSON views of the prober state and a few runtime controls.
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/kinjelom/watchdog_exporter/prober"
+	"github.com/kinjelom/watchdog_exporter/status"
+	"github.com/kinjelom/watchdog_exporter/validator"
+)
+
+// ResultsPath is where the results handler is mounted.
+const ResultsPath = "/api/v1/results"
+
+// ResultView is the JSON form of a prober.Result, including what metric labels cannot carry (error strings, TLS details).
+type ResultView struct {
+	Group    string `json:"group"`
+	Endpoint string `json:"endpoint"`
+	Protocol string `json:"protocol"`
+	URL      string `json:"url"`
+	Route    string `json:"route"`
+	IP       string `json:"ip,omitempty"` // probed address (probe-all-ips)
+
+	Status              status.Status      `json:"status"`
+	RawStatus           status.Status      `json:"raw_status,omitempty"`
+	PrevStatus          status.Status      `json:"prev_status,omitempty"`
+	StatusSince         time.Time          `json:"status_since"`
+	Error               string             `json:"error,omitempty"`
+	ErrorClass          status.ErrorClass  `json:"error_class,omitempty"`
+	DurationSeconds     float64            `json:"duration_seconds"`
+	ConsecutiveFailures int                `json:"consecutive_failures"`
+	Attempts            int                `json:"attempts,omitempty"`
+	Availability        map[string]float64 `json:"availability,omitempty"`
+	HeaderMatch         *bool              `json:"header_match,omitempty"`
+	BodyMatch           *bool              `json:"body_match,omitempty"`
+	PinMatch            *bool              `json:"pin_match,omitempty"`
+	SLOMet              *bool              `json:"slo_met,omitempty"`
+	Maintenance         bool               `json:"maintenance,omitempty"`
+	RemoteIP            string             `json:"remote_ip,omitempty"`
+	Redirects           int                `json:"redirects"`
+	IPv6Fallback        bool               `json:"ipv6_fallback,omitempty"`
+	Headers             map[string]string  `json:"captured_headers,omitempty"`
+	Answers             []string           `json:"dns_answers,omitempty"`
+	TLS                 *TLSView           `json:"tls,omitempty"`
+	At                  time.Time          `json:"at"`
+}
+
+type TLSView struct {
+	ChainValid   bool       `json:"chain_valid"`
+	Version      string     `json:"version,omitempty"`
+	CipherSuite  string     `json:"cipher_suite,omitempty"`
+	ALPN         string     `json:"alpn,omitempty"`
+	Certificates []CertView `json:"certificates"`
+}
+
+type CertView struct {
+	Position        int       `json:"position"`
+	SerialHex       string    `json:"serial"`
+	CommonName      string    `json:"common_name"`
+	IssuerCN        string    `json:"issuer_common_name"`
+	NotAfter        time.Time `json:"not_after"`
+	DaysLeft        float64   `json:"days_left"`
+	IsCA            bool      `json:"is_ca"`
+	SubjectAltNames []string  `json:"subject_alt_names,omitempty"`
+	SPKISHA256      string    `json:"spki_sha256,omitempty"`
+}
+
+type resultsResponse struct {
+	Results []ResultView `json:"results"`
+}
+
+// NewResultsHandler serves the latest result per endpoint+route from p.
+// Query parameters group, endpoint, status and error_class filter the list; each may be repeated (any value matches).
+func NewResultsHandler(p prober.Provider) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		q := r.URL.Query()
+		groups, endpoints, statuses, classes := set(q["group"]), set(q["endpoint"]), set(q["status"]), set(q["error_class"])
+
+		out := resultsResponse{Results: []ResultView{}}
+		for _, res := range p.Snapshot() {
+			if !matches(groups, res.Group) || !matches(endpoints, res.Endpoint) || !matches(statuses, string(res.Status)) ||
+				!matches(classes, string(res.ErrorClass)) {
+				continue
+			}
+			out.Results = append(out.Results, NewResultView(res))
+		}
+		sort.Slice(out.Results, func(i, j int) bool {
+			a, b := out.Results[i], out.Results[j]
+			if a.Group != b.Group {
+				return a.Group < b.Group
+			}
+			if a.Endpoint != b.Endpoint {
+				return a.Endpoint < b.Endpoint
+			}
+			if a.Route != b.Route {
+				return a.Route < b.Route
+			}
+			return a.IP < b.IP
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(out)
+	})
+}
+
+// NewResultView converts a result to its JSON form (also used by streaming outputs).
+func NewResultView(r prober.Result) ResultView {
+	v := ResultView{
+		Group:               r.Group,
+		Endpoint:            r.Endpoint,
+		Protocol:            r.Protocol,
+		URL:                 r.URL,
+		Route:               r.Route,
+		IP:                  r.IP,
+		Status:              r.Status,
+		RawStatus:           r.RawStatus,
+		PrevStatus:          r.PrevStatus,
+		StatusSince:         r.StatusSince,
+		DurationSeconds:     r.Duration,
+		ConsecutiveFailures: r.ConsecutiveFailures,
+		Attempts:            r.Attempts,
+		Availability:        r.Availability,
+		HeaderMatch:         r.HeaderMatch,
+		BodyMatch:           r.BodyMatch,
+		PinMatch:            r.PinMatch,
+		SLOMet:              r.SLOMet,
+		Maintenance:         r.Maintenance,
+		RemoteIP:            r.RemoteIP,
+		Redirects:           r.Redirects,
+		IPv6Fallback:        r.IPv6Fallback,
+		Headers:             r.Headers,
+		Answers:             r.Answers,
+		At:                  r.At,
+	}
+	v.Error, v.ErrorClass = r.ErrorText(), r.ErrorClass
+	if r.TLS != nil && r.TLS.HadTLS {
+		v.TLS = tlsViewOf(r.TLS)
+	}
+	return v
+}
+
+func tlsViewOf(rep *validator.CertsReport) *TLSView {
+	t := &TLSView{
+		ChainValid:   rep.ChainValid,
+		Version:      rep.Version,
+		CipherSuite:  rep.CipherSuite,
+		ALPN:         rep.ALPN,
+		Certificates: make([]CertView, 0, len(rep.Certificates)),
+	}
+	for _, c := range rep.Certificates {
+		t.Certificates = append(t.Certificates, CertView{
+			Position:        c.Position,
+			SerialHex:       c.SerialHex,
+			CommonName:      c.CommonName,
+			IssuerCN:        c.IssuerCN,
+			NotAfter:        c.NotAfter,
+			DaysLeft:        c.DaysLeft,
+			IsCA:            c.IsCA,
+			SubjectAltNames: c.SubjectAltNames,
+			SPKISHA256:      c.SPKISHA256,
+		})
+	}
+	return t
+}
+
+func set(values []string) map[string]bool {
+	if len(values) == 0 {
+		return nil
+	}
+	out := make(map[string]bool, len(values))
+	for _, v := range values {
+		out[v] = true
+	}
+	return out
+}
+
+// matches reports whether v passes the filter (a nil filter matches everything).
+func matches(filter map[string]bool, v string) bool {
+	return filter == nil || filter[v]
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kinjelom/watchdog_exporter/prober"
+	"github.com/kinjelom/watchdog_exporter/status"
+	"github.com/kinjelom/watchdog_exporter/validator"
+)
+
+type staticProvider []prober.Result
+
+func (p staticProvider) Snapshot() []prober.Result { return p }
+
+func get(t *testing.T, h http.Handler, target string) (int, resultsResponse) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	var out resultsResponse
+	if rec.Code == http.StatusOK {
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &out))
+		assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	}
+	return rec.Code, out
+}
+
+func TestResultsHandler_ListsAndSorts(t *testing.T) {
+	notAfter := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	h := NewResultsHandler(staticProvider{
+		{Group: "g2", Endpoint: "b", Route: "direct", Status: "valid"},
+		{Group: "g1", Endpoint: "a", Route: "direct", Status: "invalid-tls-chain", Err: errors.New("x509: unknown authority"), ErrorClass: "tls",
+			TLS: &validator.CertsReport{HadTLS: true, Version: "TLS 1.3", Certificates: []validator.CertInfo{{CommonName: "a", NotAfter: notAfter}}}},
+	})
+
+	code, out := get(t, h, ResultsPath)
+	assert.Equal(t, http.StatusOK, code)
+	if assert.Len(t, out.Results, 2) {
+		first := out.Results[0]
+		assert.Equal(t, "a", first.Endpoint)
+		assert.Equal(t, "x509: unknown authority", first.Error)
+		assert.Equal(t, status.ClassTLS, first.ErrorClass)
+		if assert.NotNil(t, first.TLS) {
+			assert.Equal(t, "TLS 1.3", first.TLS.Version)
+			assert.Equal(t, notAfter, first.TLS.Certificates[0].NotAfter)
+		}
+		assert.Equal(t, "b", out.Results[1].Endpoint)
+		assert.Empty(t, out.Results[1].Error)
+		assert.Nil(t, out.Results[1].TLS)
+	}
+}
+
+func TestResultsHandler_Filters(t *testing.T) {
+	h := NewResultsHandler(staticProvider{
+		{Group: "g1", Endpoint: "a", Status: "valid"},
+		{Group: "g1", Endpoint: "b", Status: "status-code-mismatch", ErrorClass: "http"},
+		{Group: "g2", Endpoint: "c", Status: "valid"},
+	})
+
+	_, out := get(t, h, ResultsPath+"?group=g1")
+	assert.Len(t, out.Results, 2)
+
+	_, out = get(t, h, ResultsPath+"?status=valid&group=g2")
+	if assert.Len(t, out.Results, 1) {
+		assert.Equal(t, "c", out.Results[0].Endpoint)
+	}
+
+	_, out = get(t, h, ResultsPath+"?error_class=http")
+	if assert.Len(t, out.Results, 1) {
+		assert.Equal(t, "b", out.Results[0].Endpoint)
+	}
+
+	_, out = get(t, h, ResultsPath+"?endpoint=a&endpoint=b")
+	assert.Len(t, out.Results, 2)
+
+	_, out = get(t, h, ResultsPath+"?endpoint=missing")
+	assert.NotNil(t, out.Results)
+	assert.Empty(t, out.Results)
+}
+
+func TestResultsHandler_RejectsPost(t *testing.T) {
+	rec := httptest.NewRecorder()
+	NewResultsHandler(staticProvider{}).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, ResultsPath, nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
@@ -0,0 +1,199 @@
+// Package bench runs the prober against synthetic endpoints served by a built-in test server and reports the
+// achievable probe throughput, scheduler lag and memory usage, for capacity planning before a large rollout.
+package bench
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+	"github.com/kinjelom/watchdog_exporter/prober"
+	"github.com/kinjelom/watchdog_exporter/validator"
+)
+
+// Options describe the simulated load.
+type Options struct {
+	Endpoints  int           // number of synthetic endpoints
+	MaxWorkers int           // settings.max-workers-count under test
+	Interval   time.Duration // settings.probe-interval
+	Duration   time.Duration // how long to run; at least one interval so every endpoint is probed
+	Latency    time.Duration // response delay of the test server
+	Timeout    time.Duration // request.timeout of every endpoint
+}
+
+// Report is the outcome of a run.
+type Report struct {
+	Options
+	Elapsed        time.Duration
+	Probes         uint64
+	Failures       uint64
+	ProbesPerSec   float64
+	ExpectedPerSec float64 // endpoints / interval: the rate the scheduler must sustain
+	LagP50         time.Duration
+	LagP99         time.Duration
+	LagMax         time.Duration
+	PeakHeapInuse  uint64 // bytes
+	PeakGoroutines int
+	SysMemory      uint64 // bytes obtained from the OS at the end of the run
+}
+
+// counter counts results as an engine subscriber.
+type counter struct {
+	probes   atomic.Uint64
+	failures atomic.Uint64
+}
+
+func (c *counter) OnResult(r prober.Result) {
+	c.probes.Add(1)
+	if r.Failed() {
+		c.failures.Add(1)
+	}
+}
+
+// Run probes opts.Endpoints endpoints of a local test server for opts.Duration (or until ctx is done).
+func Run(ctx context.Context, opts Options) (Report, error) {
+	if opts.Endpoints <= 0 {
+		return Report{}, fmt.Errorf("endpoints must be positive, got %d", opts.Endpoints)
+	}
+	if opts.Interval <= 0 {
+		return Report{}, fmt.Errorf("interval must be positive, got %s", opts.Interval)
+	}
+	if opts.Duration < opts.Interval {
+		return Report{}, fmt.Errorf("duration (%s) must cover at least one interval (%s)", opts.Duration, opts.Interval)
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 5 * time.Second
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if opts.Latency > 0 {
+			time.Sleep(opts.Latency)
+		}
+		_, _ = io.WriteString(w, "ok")
+	}))
+	defer server.Close()
+
+	cfg := syntheticConfig(opts, server.URL)
+	engine := prober.NewEngine(cfg, validator.NewWatchDogValidator(
+		validator.NewDefaultTLSChecker(false), validator.NewDefaultHTTPResponseChecker(false), false))
+	results := &counter{}
+	engine.Subscribe(results)
+
+	runCtx, cancel := context.WithTimeout(ctx, opts.Duration)
+	defer cancel()
+	started := time.Now()
+	done := make(chan struct{})
+	go func() {
+		engine.Start(runCtx)
+		close(done)
+	}()
+
+	rep := Report{Options: opts}
+	sample := func() {
+		var ms runtime.MemStats
+		runtime.ReadMemStats(&ms)
+		rep.PeakHeapInuse = max(rep.PeakHeapInuse, ms.HeapInuse)
+		rep.SysMemory = ms.Sys
+		rep.PeakGoroutines = max(rep.PeakGoroutines, runtime.NumGoroutine())
+		for _, lag := range engine.Stats().SchedulerLag {
+			rep.LagMax = max(rep.LagMax, lag)
+		}
+	}
+	ticker := time.NewTicker(sampleEvery(opts.Interval))
+	defer ticker.Stop()
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		case <-ticker.C:
+			sample()
+		}
+	}
+	sample()
+
+	rep.Elapsed = time.Since(started)
+	rep.Probes = results.probes.Load()
+	rep.Failures = results.failures.Load()
+	rep.ProbesPerSec = float64(rep.Probes) / rep.Elapsed.Seconds()
+	rep.ExpectedPerSec = float64(opts.Endpoints) / opts.Interval.Seconds()
+	// Percentiles over the last lag of every endpoint; the max covers the whole run.
+	lags := make([]time.Duration, 0, opts.Endpoints)
+	for _, lag := range engine.Stats().SchedulerLag {
+		lags = append(lags, lag)
+	}
+	rep.LagP50, rep.LagP99 = percentile(lags, 0.50), percentile(lags, 0.99)
+	return rep, ctx.Err()
+}
+
+// syntheticConfig defines the endpoints bench-0000... all pointing at target over a direct route.
+func syntheticConfig(opts Options, target string) *config.WatchDogConfig {
+	cfg := &config.WatchDogConfig{
+		Settings: config.ProgramSettings{
+			MaxWorkersCount:     opts.MaxWorkers,
+			ProbeInterval:       opts.Interval,
+			DefaultTimeout:      opts.Timeout,
+			AvailabilityWindows: []time.Duration{time.Hour},
+		},
+		Routes:    map[string]config.Route{"direct": {}},
+		Endpoints: make(map[string]config.Endpoint, opts.Endpoints),
+	}
+	for i := 0; i < opts.Endpoints; i++ {
+		name := fmt.Sprintf("bench-%05d", i)
+		cfg.Endpoints[name] = config.Endpoint{
+			Group:    "bench",
+			Protocol: "http",
+			Routes:   []string{"direct"},
+			Request: config.EndpointRequest{
+				Method:            http.MethodGet,
+				URL:               target + "/" + name,
+				Timeout:           opts.Timeout,
+				ResponseBodyLimit: 1024,
+			},
+			Validation: &config.EndpointValidation{StatusCode: http.StatusOK, BodyRegex: "^ok$"},
+		}
+	}
+	return cfg
+}
+
+// sampleEvery keeps the sampling overhead low while still seeing a few samples per interval.
+func sampleEvery(interval time.Duration) time.Duration {
+	return min(max(interval/10, 50*time.Millisecond), time.Second)
+}
+
+// percentile returns the q-quantile (nearest rank) of values, sorting them in place.
+func percentile(values []time.Duration, q float64) time.Duration {
+	if len(values) == 0 {
+		return 0
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+	idx := int(q*float64(len(values))+0.5) - 1
+	return values[min(max(idx, 0), len(values)-1)]
+}
+
+// WriteTo prints the report in a human-readable form.
+func (r Report) WriteTo(w io.Writer) (int64, error) {
+	n, err := fmt.Fprintf(w, `endpoints:        %d
+max workers:      %d
+interval:         %s
+server latency:   %s
+elapsed:          %s
+probes:           %d (%d failed)
+throughput:       %.1f probes/s (schedule needs %.1f probes/s)
+scheduler lag:    p50 %s, p99 %s, max %s
+peak heap in use: %.1f MiB
+sys memory:       %.1f MiB
+peak goroutines:  %d
+`,
+		r.Endpoints, r.MaxWorkers, r.Interval, r.Latency, r.Elapsed.Round(time.Millisecond),
+		r.Probes, r.Failures, r.ProbesPerSec, r.ExpectedPerSec,
+		r.LagP50.Round(time.Microsecond), r.LagP99.Round(time.Microsecond), r.LagMax.Round(time.Microsecond),
+		float64(r.PeakHeapInuse)/(1<<20), float64(r.SysMemory)/(1<<20), r.PeakGoroutines)
+	return int64(n), err
+}
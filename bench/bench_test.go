@@ -0,0 +1,63 @@
+package bench
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRun_ProbesSyntheticEndpoints(t *testing.T) {
+	rep, err := Run(context.Background(), Options{
+		Endpoints:  20,
+		MaxWorkers: 4,
+		Interval:   200 * time.Millisecond,
+		Duration:   time.Second,
+		Latency:    time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Every endpoint gets its first probe within the first interval.
+	if rep.Probes < 20 {
+		t.Fatalf("expected at least 20 probes, got %d", rep.Probes)
+	}
+	if rep.Failures != 0 {
+		t.Fatalf("expected no failures, got %d", rep.Failures)
+	}
+	if rep.ExpectedPerSec != 100 {
+		t.Fatalf("expected 100 probes/s needed, got %v", rep.ExpectedPerSec)
+	}
+	if rep.PeakHeapInuse == 0 || rep.PeakGoroutines == 0 {
+		t.Fatalf("expected memory and goroutine samples, got %+v", rep)
+	}
+
+	var out bytes.Buffer
+	if _, err := rep.WriteTo(&out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "endpoints:        20") {
+		t.Fatalf("unexpected report:\n%s", out.String())
+	}
+}
+
+func TestRun_RejectsDurationShorterThanInterval(t *testing.T) {
+	_, err := Run(context.Background(), Options{Endpoints: 1, Interval: time.Minute, Duration: time.Second})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	values := []time.Duration{5, 1, 4, 2, 3}
+	if got := percentile(values, 0.5); got != 3 {
+		t.Fatalf("p50: expected 3, got %v", got)
+	}
+	if got := percentile(values, 0.99); got != 5 {
+		t.Fatalf("p99: expected 5, got %v", got)
+	}
+	if got := percentile(nil, 0.5); got != 0 {
+		t.Fatalf("empty: expected 0, got %v", got)
+	}
+}
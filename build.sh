@@ -2,6 +2,9 @@
 
 PROGRAM_NAME="watchdog_exporter"
 VERSION="0.5.0"
+COMMIT="$(git rev-parse --short HEAD 2>/dev/null || echo unknown)"
+BUILD_DATE="$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+LDFLAGS="-X 'main.ProgramVersion=${VERSION}' -X 'main.ProgramCommit=${COMMIT}' -X 'main.ProgramBuildDate=${BUILD_DATE}'"
 DIST_DIR=".dist"
 LOG_DIR="$DIST_DIR/logs"
 
@@ -17,7 +20,7 @@ for OS in "${os_array[@]}"; do
         mkdir -p "${DIST_DIR}/${FULL_NAME}"
         DIST_PATH="${DIST_DIR}/${FULL_NAME}/${PROGRAM_NAME}"
         echo "build $DIST_PATH"
-        if GOOS=$OS GOARCH=$ARCH go build -o "$DIST_PATH" -ldflags="-X 'main.ProgramVersion=${VERSION}'" >> "${LOG_DIR}/${PROGRAM_NAME}.build.log"; then
+        if GOOS=$OS GOARCH=$ARCH go build -o "$DIST_PATH" -ldflags="$LDFLAGS" >> "${LOG_DIR}/${PROGRAM_NAME}.build.log"; then
             sha256sum "$DIST_PATH"  | awk '{print $1}' > "${DIST_DIR}/${FULL_NAME}.sum.txt"
         fi
         tar -czvf "${DIST_DIR}/${FULL_NAME}.tar.tgz" -C "$DIST_DIR" "$FULL_NAME"
@@ -0,0 +1,127 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"net"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// checkEndpoint reports the URL and regexes of an endpoint that would only fail at probe time.
+func checkEndpoint(name string, ep Endpoint) []string {
+	var problems []string
+	add := func(format string, args ...any) {
+		problems = append(problems, fmt.Sprintf("endpoint %q: ", name)+fmt.Sprintf(format, args...))
+	}
+
+	// templated URLs are rendered per probe; SQL endpoints are reached by their DSN
+	if raw := ep.Request.URL; raw != "" && !strings.Contains(raw, "{{") && !ep.SQLBased() {
+		schemes := []string{"http", "https"}
+		switch ep.Protocol {
+		case ProtocolDNS:
+			schemes = []string{"dns"}
+		case ProtocolSMTP:
+			schemes = []string{"smtp", "smtps"}
+		}
+		if err := checkURL(raw, schemes...); err != nil {
+			add("request.url: %v", err)
+		}
+	}
+
+	if ep.Schedule != "" {
+		if _, err := ParseCron(ep.Schedule); err != nil {
+			add("schedule: %v", err)
+		}
+	}
+	for i, w := range ep.Maintenance {
+		if _, err := ParseCron(w.Schedule); err != nil {
+			add("maintenance[%d].schedule: %v", i, err)
+		}
+		if w.Duration <= 0 {
+			add("maintenance[%d].duration must be positive", i)
+		}
+		if _, err := w.location(); err != nil {
+			add("maintenance[%d].time-zone: %v", i, err)
+		}
+		if w.Mode != MaintenanceSuppress && w.Mode != MaintenanceProbe {
+			add("maintenance[%d].mode must be %s or %s", i, MaintenanceSuppress, MaintenanceProbe)
+		}
+	}
+
+	v := ep.Validation
+	if v == nil {
+		return problems
+	}
+	regex := func(field, pattern string) *regexp.Regexp {
+		if pattern == "" {
+			return nil
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			add("validation.%s: %v", field, err)
+		}
+		return re
+	}
+	regex("body-regex", v.BodyRegex)
+	regex("body-not-regex", v.BodyNotRegex)
+	regex("cert-issuer-regex", v.CertIssuerRegex)
+	regex("cert-cn-regex", v.CertCNRegex)
+	regex("expected-final-url-regex", v.ExpectedFinalURLRegex)
+	for _, header := range slices.Sorted(maps.Keys(v.HeadersRegex)) {
+		regex("headers-regex."+header, v.HeadersRegex[header])
+	}
+	for i, a := range v.JSONPath {
+		if !strings.HasPrefix(a.Path, "$") {
+			add("validation.json-path[%d].path must start with $", i)
+		}
+		regex(fmt.Sprintf("json-path[%d].regex", i), a.Regex)
+	}
+	if f := v.Freshness; f != nil && f.Source == FreshnessBody {
+		if re := regex("freshness.body-regex", f.BodyRegex); re == nil && f.BodyRegex == "" {
+			add("validation.freshness.body-regex is required for source %s", FreshnessBody)
+		} else if re != nil && re.NumSubexp() < 1 {
+			add("validation.freshness.body-regex needs a capture group for the timestamp")
+		}
+	}
+	return problems
+}
+
+// checkRoute reports a proxy URL, target IP, target socket or canary URL that cannot be used.
+func checkRoute(name string, route Route) []string {
+	var problems []string
+	if route.ProxyUrl != "" {
+		if err := checkURL(route.ProxyUrl, "http", "https", "socks5", "socks5h"); err != nil {
+			problems = append(problems, fmt.Sprintf("route %q: proxy-url: %v", name, err))
+		}
+	}
+	if route.TargetIP != "" && net.ParseIP(route.TargetIP) == nil {
+		problems = append(problems, fmt.Sprintf("route %q: target-ip %q is not an IP address", name, route.TargetIP))
+	}
+	if route.TargetSocket != "" && (route.ProxyUrl != "" || route.TargetIP != "") {
+		problems = append(problems, fmt.Sprintf("route %q: target-socket cannot be combined with proxy-url or target-ip", name))
+	}
+	if c := route.Canary; c != nil {
+		if err := checkURL(c.URL, "http", "https"); err != nil {
+			problems = append(problems, fmt.Sprintf("route %q: canary.url: %v", name, err))
+		}
+	}
+	return problems
+}
+
+// checkURL requires an absolute URL with a host and one of schemes.
+func checkURL(raw string, schemes ...string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(schemes, strings.ToLower(u.Scheme)) {
+		return fmt.Errorf("%q: scheme must be one of %s", raw, strings.Join(schemes, ", "))
+	}
+	if u.Host == "" {
+		return fmt.Errorf("%q has no host", raw)
+	}
+	return nil
+}
@@ -1,9 +1,16 @@
 package config
 
 import (
+	"errors"
+	"maps"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 	"time"
+
+	"gopkg.in/yaml.v3"
 )
 
 func TestLoadConfig_FileNotFound(t *testing.T) {
@@ -53,6 +60,7 @@ settings:
 metrics:
   namespace: "testns"
   environment: "dev"
+  duration-summary-objectives: { 0.5: 0.05, 0.99: 0.001 }
 routes:
   r1:
     proxy-url: "http://proxy"
@@ -93,8 +101,11 @@ endpoints:
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
 	}
-	if cfg.Settings.ListenAddress != ":8080" {
-		t.Errorf("expected ListenAddress ':8080', got '%s'", cfg.Settings.ListenAddress)
+	if len(cfg.Hash) != 64 {
+		t.Errorf("expected sha256 hex Hash, got '%s'", cfg.Hash)
+	}
+	if len(cfg.Settings.ListenAddress) != 1 || cfg.Settings.ListenAddress[0] != ":8080" {
+		t.Errorf("expected ListenAddress [':8080'], got %v", cfg.Settings.ListenAddress)
 	}
 	if cfg.Settings.ProbeInterval != 2*time.Minute+30*time.Second {
 		t.Errorf("expected ProbeInterval 2m30s, got %d", cfg.Settings.ProbeInterval)
@@ -108,9 +119,15 @@ endpoints:
 	if !cfg.Settings.Debug {
 		t.Errorf("expected Debug true, got false")
 	}
+	if len(cfg.Settings.AvailabilityWindows) != 3 || cfg.Settings.AvailabilityWindows[2] != 720*time.Hour {
+		t.Errorf("expected default AvailabilityWindows [1h 24h 720h], got %v", cfg.Settings.AvailabilityWindows)
+	}
 	if cfg.Metrics.Namespace != "testns" {
 		t.Errorf("expected Metrics.Namespace 'testns', got '%s'", cfg.Metrics.Namespace)
 	}
+	if cfg.Metrics.DurationSummaryObjectives[0.99] != 0.001 {
+		t.Errorf("expected DurationSummaryObjectives[0.99] 0.001, got %v", cfg.Metrics.DurationSummaryObjectives)
+	}
 	route, ok := cfg.Routes["r1"]
 	if !ok {
 		t.Error("expected Routes['r1'] present")
@@ -161,3 +178,704 @@ endpoints:
 		}
 	}
 }
+
+func TestAddresses_ScalarOrList(t *testing.T) {
+	var s struct {
+		One  Addresses `yaml:"one"`
+		Many Addresses `yaml:"many"`
+	}
+	content := `
+one: "unix:///run/watchdog.sock"
+many: ["0.0.0.0:9321", "[::]:9321"]
+`
+	if err := yaml.Unmarshal([]byte(content), &s); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(s.One) != 1 || s.One[0] != "unix:///run/watchdog.sock" {
+		t.Errorf("expected single address, got %v", s.One)
+	}
+	if len(s.Many) != 2 || s.Many[1] != "[::]:9321" {
+		t.Errorf("expected two addresses, got %v", s.Many)
+	}
+}
+
+func TestEndpointRequest_DialFallbackDelay(t *testing.T) {
+	off := false
+	tests := []struct {
+		name string
+		req  EndpointRequest
+		want time.Duration
+	}{
+		{"default (Go's 300ms)", EndpointRequest{}, 0},
+		{"custom delay", EndpointRequest{FallbackDelay: 50 * time.Millisecond}, 50 * time.Millisecond},
+		{"disabled", EndpointRequest{HappyEyeballs: &off, FallbackDelay: 50 * time.Millisecond}, -1},
+	}
+	for _, tt := range tests {
+		if got := tt.req.DialFallbackDelay(); got != tt.want {
+			t.Errorf("%s: DialFallbackDelay() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWatchDogConfig_RestartRequired(t *testing.T) {
+	base := `
+settings:
+  probe-interval: 30s
+endpoints:
+  ep:
+    group: g
+    request: { url: "http://a" }
+`
+	old, err := Parse([]byte(base))
+	if err != nil {
+		t.Fatal(err)
+	}
+	next, _ := Parse([]byte(base))
+	next.Settings.ProbeInterval = time.Minute
+	next.Endpoints["other"] = Endpoint{Group: "g"}
+	if got := old.RestartRequired(next); len(got) != 0 {
+		t.Fatalf("endpoints and probe-interval are reloadable, got %v", got)
+	}
+
+	next.Settings.TelemetryPath = "/probes"
+	next.Metrics.Namespace = "other"
+	ep := next.Endpoints["ep"]
+	ep.ProbeAllIPs = true
+	ep.Labels = map[string]string{"team": "payments"}
+	next.Endpoints["ep"] = ep
+	got := old.RestartRequired(next)
+	want := []string{"settings.telemetry-path", "metrics", "endpoints.probe-all-ips", "endpoints.labels (names)"}
+	if len(got) != len(want) {
+		t.Fatalf("RestartRequired = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("RestartRequired = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestWatchDogConfig_Validate(t *testing.T) {
+	cfg, err := Parse([]byte(`
+routes:
+  direct: {}
+endpoints:
+  ok:
+    routes: [direct]
+    request: { url: "http://a" }
+  broken:
+    routes: [direct, missing]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cfg.Validate()
+	if !errors.Is(err, ErrInvalidConfig) {
+		t.Fatalf("expected ErrInvalidConfig, got %v", err)
+	}
+	want := `invalid config: endpoint "broken": request.url is required; endpoint "broken": route "missing" is not defined`
+	if err.Error() != want {
+		t.Fatalf("Validate() = %q, want %q", err, want)
+	}
+
+	if _, err = Parse([]byte("endpoints: [")); !errors.Is(err, ErrInvalidConfig) {
+		t.Fatalf("expected a YAML error to wrap ErrInvalidConfig, got %v", err)
+	}
+}
+
+func TestWatchDogConfig_SQLEndpoints(t *testing.T) {
+	cfg, err := Parse([]byte(`
+routes:
+  direct: {}
+endpoints:
+  pg:
+    protocol: postgres
+    routes: [direct]
+    sql: { dsn: "postgres://probe:s3cret@db:5432/app?sslmode=disable" }
+  no-dsn:
+    protocol: mysql
+    routes: [direct]
+    request: { url: "mysql://db" }
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pg := cfg.Endpoints["pg"]
+	if pg.Request.URL != "postgres://probe@db:5432/app" || pg.SQL.Query != "SELECT 1" {
+		t.Fatalf("unexpected defaults: url %q, query %q", pg.Request.URL, pg.SQL.Query)
+	}
+	want := `invalid config: endpoint "no-dsn": sql.dsn or sql.dsn-file is required for protocol mysql`
+	if err = cfg.Validate(); err == nil || err.Error() != want {
+		t.Fatalf("Validate() = %v, want %q", err, want)
+	}
+}
+
+func TestWatchDogConfig_EndpointLabels(t *testing.T) {
+	cfg, err := Parse([]byte(`
+routes:
+  direct: {}
+endpoints:
+  pay:
+    routes: [direct]
+    request: { url: "https://pay" }
+    labels: { tier: critical, team: payments }
+  web:
+    routes: [direct]
+    request: { url: "https://web" }
+    labels: { team: web, route: x, "1st": y }
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.EndpointLabelNames(); !slices.Equal(got, []string{"1st", "route", "team", "tier"}) {
+		t.Fatalf("EndpointLabelNames() = %v", got)
+	}
+	want := `invalid config: endpoint "web": labels: "1st" is not a valid label name; endpoint "web": labels: "route" is set by the exporter`
+	if err = cfg.Validate(); err == nil || err.Error() != want {
+		t.Fatalf("Validate() = %v, want %q", err, want)
+	}
+}
+
+func TestWatchDogConfig_GroupDefaults(t *testing.T) {
+	cfg, err := Parse([]byte(`
+settings: { probe-interval: 1m, default-timeout: 5s }
+routes:
+  direct: {}
+  proxy: {}
+groups:
+  payments:
+    interval: 15s
+    timeout: 3s
+    routes: [direct]
+    validation: { status-code: 204 }
+    labels: { team: payments, tier: standard }
+endpoints:
+  pay:
+    group: payments
+    request: { url: "https://pay" }
+  pay-admin:
+    group: payments
+    routes: [proxy]
+    request: { url: "https://pay/admin", timeout: 2s }
+    validation: { status-code: 200 }
+    labels: { tier: critical }
+  web:
+    group: web
+    request: { url: "https://web" }
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pay, admin, web := cfg.Endpoints["pay"], cfg.Endpoints["pay-admin"], cfg.Endpoints["web"]
+	if cfg.IntervalOf(pay) != 15*time.Second || pay.Request.Timeout != 3*time.Second || !slices.Equal(pay.Routes, []string{"direct"}) ||
+		pay.Validation == nil || pay.Validation.StatusCode != 204 || pay.Labels["team"] != "payments" {
+		t.Errorf("group defaults not applied: %+v", pay)
+	}
+	if admin.Request.Timeout != 2*time.Second || !slices.Equal(admin.Routes, []string{"proxy"}) || admin.Validation.StatusCode != 200 ||
+		admin.Labels["team"] != "payments" || admin.Labels["tier"] != "critical" {
+		t.Errorf("endpoint settings must win over group defaults: %+v", admin)
+	}
+	if cfg.IntervalOf(web) != time.Minute || web.Request.Timeout != 5*time.Second || web.Labels != nil {
+		t.Errorf("endpoint of a group without defaults changed: %+v", web)
+	}
+	if err = cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if w := cfg.Warnings(); len(w) != 0 {
+		t.Fatalf("Warnings() = %q, want none", w)
+	}
+	cfg.Groups["typo"] = Group{}
+	if err = cfg.Validate(); err != nil {
+		t.Fatalf("a group without endpoints must not invalidate the config: %v", err)
+	}
+	want := []string{`groups: "typo" has no endpoints`}
+	if w := cfg.Warnings(); !slices.Equal(w, want) {
+		t.Fatalf("Warnings() = %q, want %q", w, want)
+	}
+}
+
+func TestParse_ExpandsEndpointTemplates(t *testing.T) {
+	t.Setenv("WD_TEST_TOKEN", "s3cret")
+	cfg, err := Parse([]byte(`
+routes:
+  direct: {}
+templates:
+  tenant:
+    hosts: [a.example.com, b.example.com]
+    endpoint:
+      group: tenants
+      routes: [direct]
+      request:
+        url: "https://${host}/health"
+        headers: { Authorization: "Bearer ${WD_TEST_TOKEN}" }
+      labels: { tenant: "${host}" }
+  shop:
+    name: "shop ${host}"
+    hosts: [eu]
+    endpoint:
+      request: { url: "https://${host}.shop.example.com" }
+endpoints:
+  static:
+    routes: [direct]
+    request: { url: "https://static" }
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Endpoints) != 4 || cfg.Templates != nil {
+		t.Fatalf("unexpected endpoints %v, templates %v", slices.Sorted(maps.Keys(cfg.Endpoints)), cfg.Templates)
+	}
+	b := cfg.Endpoints["tenant-b.example.com"]
+	if b.Request.URL != "https://b.example.com/health" || b.Labels["tenant"] != "b.example.com" || b.Group != "tenants" ||
+		b.Request.Headers["Authorization"] != "Bearer s3cret" {
+		t.Errorf("unexpected expansion: %+v", b)
+	}
+	if cfg.Endpoints["tenant-a.example.com"].Request.URL != "https://a.example.com/health" {
+		t.Errorf("hosts must not share their endpoint: %+v", cfg.Endpoints["tenant-a.example.com"])
+	}
+	if cfg.Endpoints["shop eu"].Request.URL != "https://eu.shop.example.com" {
+		t.Errorf("named template not expanded: %v", slices.Sorted(maps.Keys(cfg.Endpoints)))
+	}
+
+	_, err = Parse([]byte(`
+templates:
+  static: { hosts: [x], name: static, endpoint: { request: { url: "https://${host}" } } }
+endpoints:
+  static: { request: { url: "https://static" } }
+`))
+	if !errors.Is(err, ErrInvalidConfig) || !strings.Contains(err.Error(), `endpoint "static" is already defined`) {
+		t.Fatalf("expected a name clash error, got %v", err)
+	}
+}
+
+func TestLoadConfig_MergesIncludesAndDirectories(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	main := write("main.yml", `
+settings: { probe-interval: 30s }
+include: ["teams/*.yml"]
+routes:
+  direct: {}
+endpoints:
+  core: { routes: [direct], request: { url: "https://core" } }
+`)
+	write("teams/payments.yml", `
+endpoints:
+  pay: { routes: [direct], request: { url: "https://pay" } }
+`)
+	write("teams/web.yml", `
+routes:
+  proxy: { proxy-url: "http://proxy:8080" }
+endpoints:
+  web: { routes: [proxy], request: { url: "https://web" } }
+`)
+	cfg, err := LoadConfig(main)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := slices.Sorted(maps.Keys(cfg.Endpoints)); !slices.Equal(got, []string{"core", "pay", "web"}) {
+		t.Fatalf("endpoints = %v", got)
+	}
+	if len(cfg.Routes) != 2 || cfg.Settings.ProbeInterval != 30*time.Second {
+		t.Fatalf("routes %v, probe-interval %v", cfg.Routes, cfg.Settings.ProbeInterval)
+	}
+	if err = cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	// a directory: its files are merged, duplicates are reported
+	cfg, err = LoadConfig(filepath.Join(dir, "teams"))
+	if err != nil || len(cfg.Endpoints) != 2 {
+		t.Fatalf("LoadConfig(dir) = %v, %v", cfg, err)
+	}
+	write("teams/dup.yml", `
+settings: { probe-interval: 1m }
+endpoints:
+  pay: { request: { url: "https://pay2" } }
+`)
+	_, err = LoadConfig(main)
+	if !errors.Is(err, ErrInvalidConfig) || !strings.Contains(err.Error(), `endpoint "pay" is defined in`) ||
+		!strings.Contains(err.Error(), "settings is set in") {
+		t.Fatalf("expected duplicate errors, got %v", err)
+	}
+}
+
+func TestWatchDogConfig_ProblemsCoverRegexesAndURLs(t *testing.T) {
+	cfg, err := Parse([]byte(`
+routes:
+  direct: {}
+  proxy: { proxy-url: "proxy:8080", target-ip: "10.0.0" }
+endpoints:
+  shop:
+    routes: [direct, missing]
+    request: { url: "shop.example.com" }
+    validation:
+      body-regex: "(ok"
+      headers-regex: { Server: "[" }
+      json-path: [{ path: "status", regex: "UP" }]
+  templated:
+    routes: [direct]
+    request: { url: "https://{{ env \"HOST\" }}/health" }
+  dns:
+    protocol: dns
+    routes: [direct]
+    request: { url: "https://resolver" }
+    dns: { query-name: example.com }
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		`route "proxy": proxy-url: "proxy:8080": scheme must be one of http, https, socks5, socks5h`,
+		`route "proxy": target-ip "10.0.0" is not an IP address`,
+		`endpoint "dns": request.url: "https://resolver": scheme must be one of dns`,
+		`endpoint "shop": route "missing" is not defined`,
+		`endpoint "shop": request.url: "shop.example.com": scheme must be one of http, https`,
+		"endpoint \"shop\": validation.body-regex: error parsing regexp: missing closing ): `(ok`",
+		"endpoint \"shop\": validation.headers-regex.Server: error parsing regexp: missing closing ]: `[`",
+		`endpoint "shop": validation.json-path[0].path must start with $`,
+	}
+	if got := cfg.Problems(); !slices.Equal(got, want) {
+		t.Fatalf("Problems() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestWatchDogConfig_ProblemsCoverDependsOn(t *testing.T) {
+	cfg, err := Parse([]byte(`
+routes:
+  direct: {}
+endpoints:
+  db: { routes: [direct], request: { url: "https://db" } }
+  app: { routes: [direct], request: { url: "https://app" }, depends-on: [db] }
+  a: { routes: [direct], request: { url: "https://a" }, depends-on: [b] }
+  b: { routes: [direct], request: { url: "https://b" }, depends-on: [c] }
+  c: { routes: [direct], request: { url: "https://c" }, depends-on: [a, c, missing] }
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		`endpoint "a": depends-on "b" is circular`,
+		`endpoint "b": depends-on "c" is circular`,
+		`endpoint "c": depends-on "a" is circular`,
+		`endpoint "c": depends-on "c" is not another defined endpoint`,
+		`endpoint "c": depends-on "missing" is not another defined endpoint`,
+	}
+	if got := cfg.Problems(); !slices.Equal(got, want) {
+		t.Fatalf("Problems() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestRedactDSN(t *testing.T) {
+	for dsn, want := range map[string]string{
+		"postgres://probe:s3cret@db:5432/app?sslmode=disable": "postgres://probe@db:5432/app",
+		"host=db port=5432 user=probe password=s3cret":        "host=db port=5432 user=probe",
+		"probe:s3cret@tcp(db:3306)/app?tls=true":              "probe@tcp(db:3306)/app",
+	} {
+		if got := RedactDSN(dsn); got != want {
+			t.Errorf("RedactDSN(%q) = %q, want %q", dsn, got, want)
+		}
+	}
+}
+
+func TestParse_ResolvesSecrets(t *testing.T) {
+	t.Setenv("WD_TEST_TOKEN", "t0ken")
+	t.Setenv("WD_TEST_HOST", "api.example.com")
+	keyFile := t.TempDir() + "/routing-key"
+	if err := os.WriteFile(keyFile, []byte("R0UT1NG\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	src := `
+routes:
+  direct: {}
+endpoints:
+  api:
+    routes: [direct]
+    request:
+      url: "https://${WD_TEST_HOST}/health"
+      headers: { Authorization: "Bearer ${WD_TEST_TOKEN}", X-Literal: "$${WD_TEST_TOKEN}" }
+notifications:
+  pagerduty:
+    - routing-key-file: ` + keyFile + `
+`
+	cfg, err := Parse([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := cfg.Endpoints["api"].Request
+	if req.URL != "https://api.example.com/health" {
+		t.Errorf("url = %q", req.URL)
+	}
+	if got := req.Headers["Authorization"]; got != "Bearer t0ken" {
+		t.Errorf("Authorization = %q", got)
+	}
+	if got := req.Headers["X-Literal"]; got != "${WD_TEST_TOKEN}" {
+		t.Errorf("X-Literal = %q, want the escaped reference", got)
+	}
+	if got := cfg.Notifications.PagerDuty[0].RoutingKey; got != "R0UT1NG" {
+		t.Errorf("routing-key = %q", got)
+	}
+
+	// a rotated secret changes the hash, so a reload is not skipped
+	t.Setenv("WD_TEST_TOKEN", "rotated")
+	next, err := Parse([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.Hash == cfg.Hash {
+		t.Error("hash did not change with the secret")
+	}
+
+	if _, err = Parse([]byte(`endpoints: { api: { request: { url: "https://${WD_TEST_UNSET}" } } }`)); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig for an unset variable, got %v", err)
+	}
+	if _, err = Parse([]byte("push: { influxdb: { token: a, token-file: " + keyFile + " } }")); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig for token and token-file, got %v", err)
+	}
+}
+
+func TestParse_ExpandsOnlyCredentialSettings(t *testing.T) {
+	cfg, err := Parse([]byte(`
+endpoints:
+  api:
+    request: { url: "https://a/", method: POST, body: '{"ref": "${WD_TEST_UNSET}"}' }
+    validation: { body-regex: '\$\{[A-Z]+\}' }
+`))
+	if err != nil {
+		t.Fatalf("references outside credential settings must be left alone: %v", err)
+	}
+	if got := cfg.Endpoints["api"].Request.Body; got != `{"ref": "${WD_TEST_UNSET}"}` {
+		t.Errorf("body = %q", got)
+	}
+	if got := cfg.Endpoints["api"].Validation.BodyRegex; got != `\$\{[A-Z]+\}` {
+		t.Errorf("body-regex = %q", got)
+	}
+}
+
+func TestParse_HashesKeyedSecretDigests(t *testing.T) {
+	t.Setenv("WD_TEST_TOKEN", "t0ken")
+	withSecret := []byte(`push: { influxdb: { url: "http://influx:8086", token: "${WD_TEST_TOKEN}" } }`)
+	withoutSecret := []byte(`push: { influxdb: { url: "http://influx:8086", token: "t0ken" } }`)
+	hashes := func() (string, string) {
+		a, err := Parse(withSecret)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := Parse(withoutSecret)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return a.Hash, b.Hash
+	}
+	secret, plain := hashes()
+	key := secretKey
+	t.Cleanup(func() { secretKey = key })
+	secretKey = []byte("another key")
+	otherSecret, otherPlain := hashes()
+	if secret == otherSecret {
+		t.Error("the hash of a resolved secret must depend on the key, not only on the secret")
+	}
+	if plain != otherPlain {
+		t.Error("the hash of a config without references must not depend on the key")
+	}
+}
+
+func TestCron_Next(t *testing.T) {
+	at := func(s string) time.Time {
+		tm, err := time.Parse("2006-01-02 15:04", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tm
+	}
+	tests := []struct {
+		expr, from, want string
+	}{
+		{"*/15 * * * *", "2026-03-02 10:07", "2026-03-02 10:15"},
+		{"0 2 * * 0", "2026-03-02 10:07", "2026-03-08 02:00"}, // next Sunday
+		{"0 2 * * 7", "2026-03-02 10:07", "2026-03-08 02:00"},
+		{"30 8-18/2 * * 1-5", "2026-03-06 18:30", "2026-03-09 08:30"},
+		{"0 0 13 * 5", "2026-03-02 10:07", "2026-03-06 00:00"}, // day-of-month or Friday
+		{"@monthly", "2026-12-31 23:59", "2027-01-01 00:00"},
+	}
+	for _, tt := range tests {
+		c, err := ParseCron(tt.expr)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.expr, err)
+		}
+		if got := c.Next(at(tt.from)); !got.Equal(at(tt.want)) {
+			t.Errorf("%s after %s = %s, want %s", tt.expr, tt.from, got, tt.want)
+		}
+	}
+	if c, _ := ParseCron("0 0 30 2 *"); !c.Next(at("2026-01-01 00:00")).IsZero() {
+		t.Error("February 30 must never match")
+	}
+	for _, bad := range []string{"* * * *", "60 * * * *", "*/0 * * * *", "5-1 * * * *", "x * * * *"} {
+		if _, err := ParseCron(bad); err == nil {
+			t.Errorf("%q: expected an error", bad)
+		}
+	}
+}
+
+func TestEndpoint_MaintenanceAt(t *testing.T) {
+	cfg, err := Parse([]byte(`
+groups:
+  g: { maintenance: [{ schedule: "0 2 * * 0", duration: 2h, time-zone: Europe/Warsaw }] }
+endpoints:
+  ep:
+    group: g
+    request: { url: "https://example.com" }
+    maintenance: [{ schedule: "@daily", duration: 15m, time-zone: UTC, mode: probe }]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ep := cfg.Endpoints["ep"]
+	if len(ep.Maintenance) != 2 || ep.Maintenance[0].Mode != MaintenanceSuppress {
+		t.Fatalf("maintenance = %+v, want the group window (mode suppress) and the endpoint window", ep.Maintenance)
+	}
+	tests := []struct {
+		at   string
+		want string // mode of the active window, "" = none
+	}{
+		{"2026-03-08T01:00:00Z", MaintenanceSuppress}, // Sunday 02:00 in Warsaw
+		{"2026-03-08T02:59:59Z", MaintenanceSuppress},
+		{"2026-03-08T03:00:00Z", ""},
+		{"2026-03-09T00:10:00Z", MaintenanceProbe},
+		{"2026-03-09T00:15:00Z", ""},
+	}
+	for _, tt := range tests {
+		now, _ := time.Parse(time.RFC3339, tt.at)
+		got := ""
+		if w := ep.MaintenanceAt(now); w != nil {
+			got = w.Mode
+		}
+		if got != tt.want {
+			t.Errorf("at %s: mode %q, want %q", tt.at, got, tt.want)
+		}
+	}
+
+	for _, src := range []string{
+		`{ schedule: "0 2 * *", duration: 1h }`,
+		`{ schedule: "@daily" }`,
+		`{ schedule: "@daily", duration: 1h, time-zone: Mars/Olympus }`,
+		`{ schedule: "@daily", duration: 1h, mode: skip }`,
+	} {
+		cfg, err := Parse([]byte(`endpoints: { ep: { request: { url: "https://example.com" }, maintenance: [` + src + `] } }`))
+		if err != nil {
+			t.Fatalf("%s: %v", src, err)
+		}
+		if err = cfg.Validate(); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("%s: expected ErrInvalidConfig, got %v", src, err)
+		}
+	}
+}
+
+func TestWatchDogConfig_Schedule(t *testing.T) {
+	cfg, err := Parse([]byte(`
+groups:
+  checkout: { interval: 15s }
+endpoints:
+  synthetic-checkout:
+    group: checkout
+    schedule: "*/5 8-18 * * 1-5"
+    request: { url: "https://shop.example.com/checkout" }
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if ep := cfg.Endpoints["synthetic-checkout"]; ep.Interval != 0 {
+		t.Errorf("interval = %s, the group interval must not apply to a scheduled endpoint", ep.Interval)
+	}
+
+	for src, want := range map[string]string{
+		`{ schedule: "*/5 8-18 * *", request: { url: "https://a" } }`:          `schedule: cron "*/5 8-18 * *": expected 5 fields`,
+		`{ schedule: "@hourly", interval: 1m, request: { url: "https://a" } }`: "set either interval or schedule",
+	} {
+		cfg, err := Parse([]byte(`endpoints: { ep: ` + src + ` }`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = cfg.Validate(); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: Validate() = %v, want %q", src, err, want)
+		}
+	}
+}
+
+func TestWatchDogConfig_ProbeSettings(t *testing.T) {
+	cfg, err := Parse([]byte(`settings: { rate-limit: { global: 50, per-host: 2 } }`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rl := cfg.Settings.RateLimit; rl.Burst != 1 || rl.PerHostBurst != 1 {
+		t.Errorf("rate-limit = %+v, want bursts defaulting to 1", *rl)
+	}
+	if cfg.Settings.MaxWorkersCount != 4 {
+		t.Errorf("max-workers-count = %d, want the default 4", cfg.Settings.MaxWorkersCount)
+	}
+	if cfg.Settings.ProbeSpread != SpreadHash {
+		t.Errorf("probe-spread = %q, want the default %q", cfg.Settings.ProbeSpread, SpreadHash)
+	}
+	for _, src := range []string{`settings: { rate-limit: { per-host: -1 } }`, `settings: { probe-spread: random }`} {
+		cfg, err = Parse([]byte(src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = cfg.Validate(); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("%s: expected ErrInvalidConfig, got %v", src, err)
+		}
+	}
+}
+
+func TestWatchDogConfig_MQTTKeepAlive(t *testing.T) {
+	for src, valid := range map[string]bool{
+		`push: { mqtt: { broker: "tcp://b:1883" } }`:                        true, // the default 30s
+		`push: { mqtt: { broker: "tcp://b:1883", keep-alive: 1s } }`:        true,
+		`push: { mqtt: { broker: "tcp://b:1883", keep-alive: 500ms } }`:     false,
+		`push: { mqtt: { broker: "tcp://b:1883", keep-alive: -1s } }`:       false,
+		`push: { mqtt: { broker: "tcp://b:1883", keep-alive: 18h12m16s } }`: false,
+	} {
+		cfg, err := Parse([]byte(src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = cfg.Validate(); (err == nil) != valid {
+			t.Errorf("%s: Validate() = %v, want valid=%v", src, err, valid)
+		}
+	}
+}
+
+func TestWatchDogConfig_MaxRedirects(t *testing.T) {
+	cfg, err := Parse([]byte(`
+endpoints:
+  default: { request: { url: "https://a", follow-redirects: true } }
+  none: { request: { url: "https://b", follow-redirects: true, max-redirects: 0 } }
+  three: { request: { url: "https://c", follow-redirects: true, max-redirects: 3 } }
+  negative: { request: { url: "https://d", follow-redirects: true, max-redirects: -1 } }
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]int{"default": 10, "none": 0, "three": 3} {
+		if got := cfg.Endpoints[name].Request.MaxRedirects; got == nil || *got != want {
+			t.Errorf("%s: max-redirects = %v, want %d", name, got, want)
+		}
+	}
+	want := `endpoint "negative": request.max-redirects must not be negative`
+	if problems := cfg.Problems(); !slices.Contains(problems, want) {
+		t.Errorf("Problems() = %q, want it to contain %q", problems, want)
+	}
+}
@@ -0,0 +1,157 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// mergedSections are the top-level maps whose entries may be spread over several files; any other top-level option
+// may be set in one file only.
+var mergedSections = map[string]string{"routes": "route", "endpoints": "endpoint", "groups": "group", "templates": "template"}
+
+// configFiles lists the files --config stands for: the *.yml and *.yaml files of a directory (sorted), or the file.
+func configFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+	var files []string
+	for _, pattern := range []string{"*.yml", "*.yaml"} {
+		matches, _ := filepath.Glob(filepath.Join(path, pattern))
+		files = append(files, matches...)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no *.yml or *.yaml files in %s", path)
+	}
+	slices.Sort(files)
+	return files, nil
+}
+
+// configMerger builds one YAML document out of several config files.
+type configMerger struct {
+	root     yaml.Node             // the merged top-level mapping
+	sections map[string]*yaml.Node // merged section -> its mapping in root
+	owners   map[string]string     // top-level key or "section\x00name" -> file that set it
+	loaded   map[string]bool
+	problems []string
+}
+
+// mergeConfigFiles merges files and the files their include globs match (relative to the including file).
+// Included files cannot include further files.
+func mergeConfigFiles(files []string) ([]byte, error) {
+	m := &configMerger{
+		root:     yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"},
+		sections: make(map[string]*yaml.Node),
+		owners:   make(map[string]string),
+		loaded:   make(map[string]bool),
+	}
+	for _, file := range files {
+		includes, err := m.add(file, true)
+		if err != nil {
+			return nil, err
+		}
+		for _, inc := range includes {
+			if _, err = m.add(inc, false); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if len(m.problems) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidConfig, strings.Join(m.problems, "; "))
+	}
+	return yaml.Marshal(&m.root)
+}
+
+// add merges one file; it returns the files its include globs match (not loaded yet).
+func (m *configMerger) add(file string, mayInclude bool) ([]string, error) {
+	if abs, err := filepath.Abs(file); err == nil {
+		if m.loaded[abs] {
+			return nil, nil
+		}
+		m.loaded[abs] = true
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var doc yaml.Node
+	if err = yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrInvalidConfig, file, err)
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil // empty file
+	}
+	top := doc.Content[0]
+	if top.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%w: %s: not a mapping", ErrInvalidConfig, file)
+	}
+
+	var includes []string
+	for i := 0; i+1 < len(top.Content); i += 2 {
+		key, value := top.Content[i], top.Content[i+1]
+		switch singular, merged := mergedSections[key.Value]; {
+		case key.Value == "include":
+			if !mayInclude {
+				m.problems = append(m.problems, fmt.Sprintf("%s: included files cannot include others", file))
+				continue
+			}
+			var patterns []string
+			if err = value.Decode(&patterns); err != nil {
+				return nil, fmt.Errorf("%w: %s: include: %v", ErrInvalidConfig, file, err)
+			}
+			for _, pattern := range patterns {
+				if !filepath.IsAbs(pattern) {
+					pattern = filepath.Join(filepath.Dir(file), pattern)
+				}
+				matches, err := filepath.Glob(pattern)
+				if err != nil {
+					return nil, fmt.Errorf("%w: %s: include %q: %v", ErrInvalidConfig, file, pattern, err)
+				}
+				slices.Sort(matches)
+				includes = append(includes, matches...)
+			}
+		case merged && value.Kind == yaml.MappingNode:
+			section := m.sections[key.Value]
+			if section == nil {
+				section = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+				m.sections[key.Value] = section
+				m.root.Content = append(m.root.Content, key, section)
+			}
+			for j := 0; j+1 < len(value.Content); j += 2 {
+				name := value.Content[j].Value
+				if owner, dup := m.owners[key.Value+"\x00"+name]; dup {
+					m.problems = append(m.problems, fmt.Sprintf("%s %q is defined in %s and %s", singular, name, owner, file))
+					continue
+				}
+				m.owners[key.Value+"\x00"+name] = file
+				section.Content = append(section.Content, value.Content[j], value.Content[j+1])
+			}
+		case merged && value.Tag == "!!null":
+			// an empty section
+		default:
+			if owner, dup := m.owners[key.Value]; dup {
+				m.problems = append(m.problems, fmt.Sprintf("%s is set in %s and %s", key.Value, owner, file))
+				continue
+			}
+			m.owners[key.Value] = file
+			m.root.Content = append(m.root.Content, key, value)
+		}
+	}
+	return includes, nil
+}
+
+// hasInclude reports whether a config file has include globs.
+func hasInclude(data []byte) bool {
+	var top struct {
+		Include []string `yaml:"include"`
+	}
+	return yaml.Unmarshal(data, &top) == nil && len(top.Include) > 0
+}
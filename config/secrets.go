@@ -0,0 +1,161 @@
+package config
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"os"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// envRef matches ${NAME}; $${NAME} stands for a literal ${NAME}.
+var envRef = regexp.MustCompile(`\$?\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// secretKey keys the digests of resolved secrets written to the config hash: the hash is exported (config_hash_info),
+// so it must not allow guessing a secret offline. It is random per process.
+var secretKey = func() []byte {
+	key := make([]byte, sha256.Size)
+	_, _ = rand.Read(key)
+	return key
+}()
+
+// secretResolver expands environment references and loads the *-file variants of credentials. A keyed digest of
+// everything it resolves is written to h, so the config hash changes when a secret does (and a reload is not skipped).
+type secretResolver struct {
+	h        hash.Hash
+	missing  []string
+	problems []string
+}
+
+// expand replaces the ${NAME} references of s; unset variables are collected as missing.
+func (r *secretResolver) expand(s string) string {
+	if !strings.Contains(s, "${") {
+		return s
+	}
+	return envRef.ReplaceAllStringFunc(s, func(ref string) string {
+		if strings.HasPrefix(ref, "$$") {
+			return ref[1:]
+		}
+		name := ref[2 : len(ref)-1]
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			if !slices.Contains(r.missing, name) {
+				r.missing = append(r.missing, name)
+			}
+			return ""
+		}
+		r.digest(value)
+		return value
+	})
+}
+
+// digest writes a keyed digest of a resolved secret to the hash, followed by a separator.
+func (r *secretResolver) digest(value string) {
+	mac := hmac.New(sha256.New, secretKey)
+	mac.Write([]byte(value))
+	r.h.Write(mac.Sum(nil))
+	r.h.Write([]byte{0})
+}
+
+// expandAll expands the strings reachable from v (struct fields, pointers, slice elements and map values) that are
+// in a field tagged expand:"env", the credential-ish ones; on reports whether v is in such a field.
+func (r *secretResolver) expandAll(v reflect.Value, on bool) {
+	switch v.Kind() {
+	case reflect.String:
+		if on && v.CanSet() {
+			v.SetString(r.expand(v.String()))
+		}
+	case reflect.Pointer:
+		if !v.IsNil() {
+			r.expandAll(v.Elem(), on)
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if f := v.Type().Field(i); f.IsExported() {
+				r.expandAll(v.Field(i), on || f.Tag.Get("expand") == "env")
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			r.expandAll(v.Index(i), on)
+		}
+	case reflect.Map:
+		// map values are not addressable: expand a copy and store it back
+		iter := v.MapRange()
+		for iter.Next() {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			elem.Set(iter.Value())
+			r.expandAll(elem, on)
+			v.SetMapIndex(iter.Key(), elem)
+		}
+	}
+}
+
+// file loads the *-file variant of a credential into value; setting both is a problem.
+func (r *secretResolver) file(field string, value *string, file string) {
+	if file == "" {
+		return
+	}
+	if *value != "" {
+		r.problems = append(r.problems, fmt.Sprintf("%s and %s-file are mutually exclusive", field, field))
+		return
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		r.problems = append(r.problems, fmt.Sprintf("%s-file: %v", field, err))
+		return
+	}
+	*value = strings.TrimRight(string(data), "\r\n")
+	r.digest(*value)
+}
+
+// resolveSecrets expands ${NAME} references in the credential-ish settings, then loads the *-file credentials.
+func (c *WatchDogConfig) resolveSecrets(h hash.Hash) error {
+	r := &secretResolver{h: h}
+	r.expandAll(reflect.ValueOf(c).Elem(), false)
+	if len(r.missing) > 0 {
+		return fmt.Errorf("%w: environment variables not set: %s", ErrInvalidConfig, strings.Join(r.missing, ", "))
+	}
+
+	if p := c.Push.InfluxDB; p != nil {
+		r.file("push.influxdb.token", &p.Token, p.TokenFile)
+	}
+	if p := c.Push.MQTT; p != nil {
+		r.file("push.mqtt.password", &p.Password, p.PasswordFile)
+	}
+	if p := c.Push.NATS; p != nil {
+		r.file("push.nats.password", &p.Password, p.PasswordFile)
+		r.file("push.nats.token", &p.Token, p.TokenFile)
+	}
+	if p := c.Push.History; p != nil {
+		r.file("push.history.dsn", &p.DSN, p.DSNFile)
+	}
+	if hb := c.Heartbeat; hb != nil {
+		r.file("heartbeat.url", &hb.URL, hb.URLFile)
+	}
+	n := &c.Notifications
+	for i := range n.Slack {
+		r.file(fmt.Sprintf("notifications.slack[%d].webhook-url", i), &n.Slack[i].WebhookURL, n.Slack[i].WebhookURLFile)
+	}
+	for i := range n.Teams {
+		r.file(fmt.Sprintf("notifications.teams[%d].webhook-url", i), &n.Teams[i].WebhookURL, n.Teams[i].WebhookURLFile)
+	}
+	for i := range n.PagerDuty {
+		r.file(fmt.Sprintf("notifications.pagerduty[%d].routing-key", i), &n.PagerDuty[i].RoutingKey, n.PagerDuty[i].RoutingKeyFile)
+	}
+	for i := range n.Opsgenie {
+		r.file(fmt.Sprintf("notifications.opsgenie[%d].api-key", i), &n.Opsgenie[i].APIKey, n.Opsgenie[i].APIKeyFile)
+	}
+	for i := range n.Email {
+		r.file(fmt.Sprintf("notifications.email[%d].password", i), &n.Email[i].Password, n.Email[i].PasswordFile)
+	}
+	if len(r.problems) > 0 {
+		return fmt.Errorf("%w: %s", ErrInvalidConfig, strings.Join(r.problems, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// hostRef is replaced by each host of an endpoint template.
+const hostRef = "${host}"
+
+// EndpointTemplate is one endpoint definition expanded into an endpoint per host: ${host} in any of its values (and in
+// name) is replaced by the host. Templates are expanded when the config is loaded, before environment references.
+type EndpointTemplate struct {
+	Name     string    `yaml:"name"` // endpoint name, default "<template>-${host}"
+	Hosts    []string  `yaml:"hosts"`
+	Endpoint yaml.Node `yaml:"endpoint"` // an endpoint, as under endpoints
+}
+
+// expandTemplates adds the endpoints of every template; a name already taken is an error.
+func (c *WatchDogConfig) expandTemplates() error {
+	var problems []string
+	for _, name := range slices.Sorted(maps.Keys(c.Templates)) {
+		t := c.Templates[name]
+		if len(t.Hosts) == 0 {
+			problems = append(problems, fmt.Sprintf("template %q: hosts is empty", name))
+			continue
+		}
+		pattern := t.Name
+		if pattern == "" {
+			pattern = name + "-" + hostRef
+		}
+		for _, host := range t.Hosts {
+			epName := strings.ReplaceAll(pattern, hostRef, host)
+			if _, taken := c.Endpoints[epName]; taken {
+				problems = append(problems, fmt.Sprintf("template %q: endpoint %q is already defined", name, epName))
+				continue
+			}
+			var endpoint Endpoint
+			if err := withHost(&t.Endpoint, host).Decode(&endpoint); err != nil {
+				problems = append(problems, fmt.Sprintf("template %q: %v", name, err))
+				break
+			}
+			if c.Endpoints == nil {
+				c.Endpoints = make(map[string]Endpoint)
+			}
+			c.Endpoints[epName] = endpoint
+		}
+	}
+	// expanded: the ${host} references must not be taken for environment variables
+	c.Templates = nil
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %s", ErrInvalidConfig, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// withHost copies node with ${host} replaced in every scalar.
+func withHost(node *yaml.Node, host string) *yaml.Node {
+	out := *node
+	if out.Kind == yaml.ScalarNode {
+		out.Value = strings.ReplaceAll(out.Value, hostRef, host)
+	}
+	out.Content = make([]*yaml.Node, len(node.Content))
+	for i, child := range node.Content {
+		out.Content[i] = withHost(child, host)
+	}
+	return &out
+}
@@ -0,0 +1,129 @@
+package generate
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+	"github.com/kinjelom/watchdog_exporter/prober"
+)
+
+type panel map[string]any
+
+// Dashboard renders a Grafana dashboard with one row per endpoint group.
+func Dashboard(cfg *config.WatchDogConfig) ([]byte, error) {
+	ds := map[string]any{"type": "prometheus", "uid": "${datasource}"}
+	env := `environment=~"$environment"`
+	validation := metricName(cfg, "endpoint_validation")
+
+	var panels []panel
+	id, y := 1, 0
+	add := func(p panel, w, h, x int) {
+		p["id"] = id
+		p["datasource"] = ds
+		p["gridPos"] = map[string]int{"h": h, "w": w, "x": x, "y": y}
+		panels = append(panels, p)
+		id++
+	}
+
+	for _, g := range groupsOf(cfg) {
+		sel := fmt.Sprintf(`%s, group=%s`, env, quote(g.Name))
+		add(panel{"type": "row", "title": fmt.Sprintf("Group: %s (%d endpoints)", g.Name, len(g.Endpoints)), "collapsed": false, "panels": []any{}}, 24, 1, 0)
+		y++
+
+		add(panel{
+			"type":    "stat",
+			"title":   "Failing checks",
+			"targets": []any{target("A", fmt.Sprintf(`count(%s{%s, status!~"valid|valid-insecure-tls|maintenance"}) or vector(0)`, validation, sel), "", true)},
+			"fieldConfig": map[string]any{"defaults": map[string]any{
+				"color": map[string]any{"mode": "thresholds"},
+				"thresholds": map[string]any{"mode": "absolute", "steps": []any{
+					map[string]any{"color": "green", "value": nil},
+					map[string]any{"color": "red", "value": 1},
+				}},
+			}},
+		}, 4, 8, 0)
+		add(panel{
+			"type":  "table",
+			"title": "Status",
+			"targets": []any{func() map[string]any {
+				t := target("A", fmt.Sprintf(`%s{%s}`, validation, sel), "", true)
+				t["format"] = "table"
+				return t
+			}()},
+			"transformations": []any{map[string]any{"id": "organize", "options": map[string]any{
+				"excludeByName": map[string]bool{"Time": true, "Value": true, "__name__": true, "environment": true, "group": true, "instance": true, "job": true},
+			}}},
+		}, 10, 8, 4)
+		add(panel{
+			"type":        "timeseries",
+			"title":       "Probe duration",
+			"targets":     []any{target("A", fmt.Sprintf(`%s{%s}`, metricName(cfg, "endpoint_duration_seconds"), sel), "{{endpoint}} via {{route}}", false)},
+			"fieldConfig": map[string]any{"defaults": map[string]any{"unit": "s"}},
+		}, 10, 8, 14)
+		y += 8
+
+		x := 0
+		if len(cfg.Settings.AvailabilityWindows) > 0 && cfg.Metrics.MetricEnabled("endpoint_availability_ratio", true) {
+			window := prober.WindowLabel(cfg.Settings.AvailabilityWindows[len(cfg.Settings.AvailabilityWindows)-1])
+			add(panel{
+				"type":  "bargauge",
+				"title": "Availability (" + window + ")",
+				"targets": []any{target("A", fmt.Sprintf(`min by (endpoint) (%s{%s, window=%s})`,
+					metricName(cfg, "endpoint_availability_ratio"), sel, quote(window)), "{{endpoint}}", true)},
+				"fieldConfig": map[string]any{"defaults": map[string]any{"unit": "percentunit", "min": 0, "max": 1}},
+			}, 12, 8, x)
+			x += 12
+		}
+		if g.InspectTLS && cfg.Metrics.MetricEnabled("endpoint_tls_cert_days_left", true) {
+			add(panel{
+				"type":  "bargauge",
+				"title": "Leaf certificate days left",
+				"targets": []any{target("A", fmt.Sprintf(`min by (endpoint) (%s{%s, cert_position="0"})`,
+					metricName(cfg, "endpoint_tls_cert_days_left"), sel), "{{endpoint}}", true)},
+				"fieldConfig": map[string]any{"defaults": map[string]any{
+					"unit":  "d",
+					"color": map[string]any{"mode": "thresholds"},
+					"thresholds": map[string]any{"mode": "absolute", "steps": []any{
+						map[string]any{"color": "red", "value": nil},
+						map[string]any{"color": "orange", "value": certCriticalDays},
+						map[string]any{"color": "green", "value": certWarningDays},
+					}},
+				}},
+			}, 12, 8, x)
+			x += 12
+		}
+		if x > 0 {
+			y += 8
+		}
+	}
+
+	dashboard := map[string]any{
+		"title":         "Watchdog (generated)",
+		"uid":           "watchdog-generated",
+		"tags":          []string{"watchdog", "generated"},
+		"editable":      true,
+		"schemaVersion": 39,
+		"refresh":       "1m",
+		"time":          map[string]string{"from": "now-6h", "to": "now"},
+		"templating": map[string]any{"list": []any{
+			map[string]any{"name": "datasource", "label": "Data source", "type": "datasource", "query": "prometheus"},
+			map[string]any{
+				"name": "environment", "label": "Environment", "type": "query", "datasource": ds,
+				"query":      fmt.Sprintf("label_values(%s, environment)", validation),
+				"includeAll": true, "refresh": 2,
+				"current": map[string]string{"text": "All", "value": "$__all"},
+			},
+		}},
+		"panels": panels,
+	}
+	return json.MarshalIndent(dashboard, "", "  ")
+}
+
+func target(refID, expr, legend string, instant bool) map[string]any {
+	t := map[string]any{"refId": refID, "expr": expr, "instant": instant, "range": !instant}
+	if legend != "" {
+		t["legendFormat"] = legend
+	}
+	return t
+}
@@ -0,0 +1,79 @@
+// Package generate derives monitoring artifacts (Grafana dashboard, Prometheus alerting rules)
+// from the probe configuration, so they stay in sync with the endpoints.
+package generate
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+)
+
+// Artifact names accepted by Generate.
+const (
+	GrafanaDashboard = "grafana-dashboard"
+	PrometheusRules  = "prometheus-rules"
+)
+
+// Generate renders the named artifact for cfg.
+func Generate(what string, cfg *config.WatchDogConfig) ([]byte, error) {
+	switch what {
+	case GrafanaDashboard:
+		return Dashboard(cfg)
+	case PrometheusRules:
+		return Rules(cfg)
+	default:
+		return nil, fmt.Errorf("unknown artifact %q (%s or %s)", what, GrafanaDashboard, PrometheusRules)
+	}
+}
+
+// metricName returns the exported name of a metric, honoring namespace, subsystem and renames.
+func metricName(cfg *config.WatchDogConfig, name string) string {
+	return prometheus.BuildFQName(cfg.Metrics.Namespace, cfg.Metrics.Subsystem, cfg.Metrics.MetricName(name))
+}
+
+// group is the part of the config a group's artifacts are derived from.
+type group struct {
+	Name       string
+	Endpoints  []string                 // sorted
+	Timeouts   map[string]time.Duration // endpoint -> request timeout
+	Interval   time.Duration            // longest probe interval of the endpoints
+	Scheduled  []string                 // sorted endpoints on a cron schedule, not probed at a steady pace
+	InspectTLS bool                     // any endpoint inspects certificates
+}
+
+// groupsOf returns the endpoint groups of cfg, sorted by name.
+func groupsOf(cfg *config.WatchDogConfig) []group {
+	byName := map[string]*group{}
+	for name, ep := range cfg.ActiveEndpoints() {
+		g, ok := byName[ep.Group]
+		if !ok {
+			g = &group{Name: ep.Group, Timeouts: map[string]time.Duration{}}
+			byName[ep.Group] = g
+		}
+		g.Endpoints = append(g.Endpoints, name)
+		if ep.Schedule != "" {
+			g.Scheduled = append(g.Scheduled, name)
+		}
+		g.Timeouts[name] = ep.Request.Timeout
+		g.Interval = max(g.Interval, cfg.IntervalOf(ep))
+		g.InspectTLS = g.InspectTLS || ep.InspectTLSCerts
+	}
+	out := make([]group, 0, len(byName))
+	for _, g := range byName {
+		sort.Strings(g.Endpoints)
+		sort.Strings(g.Scheduled)
+		out = append(out, *g)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// quote escapes v for a PromQL string literal.
+func quote(v string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(v) + `"`
+}
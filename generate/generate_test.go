@@ -0,0 +1,136 @@
+package generate
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+)
+
+func testConfig() *config.WatchDogConfig {
+	return &config.WatchDogConfig{
+		Settings: config.ProgramSettings{ProbeInterval: time.Minute, AvailabilityWindows: []time.Duration{time.Hour, 720 * time.Hour}},
+		Metrics:  config.MetricsContext{Namespace: "watchdog"},
+		Endpoints: map[string]config.Endpoint{
+			"b.example.com": {Group: "shop", Request: config.EndpointRequest{Timeout: 5 * time.Second}},
+			"a.example.com": {Group: "shop", InspectTLSCerts: true, Request: config.EndpointRequest{Timeout: 10 * time.Second}},
+			"internal":      {Group: "backoffice", Request: config.EndpointRequest{Timeout: 2 * time.Second}},
+		},
+	}
+}
+
+func TestRules(t *testing.T) {
+	out, err := Rules(testConfig())
+	assert.NoError(t, err)
+
+	var file ruleFile
+	assert.NoError(t, yaml.Unmarshal(out, &file))
+	if !assert.Len(t, file.Groups, 2) {
+		return
+	}
+	assert.Equal(t, "watchdog-backoffice", file.Groups[0].Name)
+	assert.Equal(t, "watchdog-shop", file.Groups[1].Name)
+
+	alerts := map[string]rule{}
+	for _, r := range file.Groups[1].Rules {
+		alerts[r.Alert] = r
+	}
+	assert.Equal(t, `watchdog_endpoint_validation{group="shop", status!~"valid|valid-insecure-tls|maintenance"} == 1`, alerts["WatchdogEndpointDown"].Expr)
+	assert.Equal(t, "2m", alerts["WatchdogEndpointDown"].For)
+	assert.Equal(t, `time() - watchdog_endpoint_last_probe_timestamp_seconds{group="shop"} > 180`, alerts["WatchdogProbeStale"].Expr)
+	assert.Equal(t, `watchdog_endpoint_duration_seconds{group="shop", endpoint="a.example.com"} > 8`+"\nor\n"+
+		`watchdog_endpoint_duration_seconds{group="shop", endpoint="b.example.com"} > 4`, alerts["WatchdogEndpointSlow"].Expr)
+	assert.Contains(t, alerts, "WatchdogCertExpiresIn14Days")
+	assert.Contains(t, alerts, "WatchdogCertExpiresIn7Days")
+
+	for _, r := range file.Groups[0].Rules {
+		assert.False(t, strings.HasPrefix(r.Alert, "WatchdogCert"), "backoffice does not inspect certificates")
+	}
+}
+
+func TestRules_StaleIgnoresScheduledEndpoints(t *testing.T) {
+	cfg := testConfig()
+	ep := cfg.Endpoints["a.example.com"]
+	ep.Schedule = "*/5 8-18 * * 1-5"
+	cfg.Endpoints["a.example.com"] = ep
+	ep = cfg.Endpoints["internal"]
+	ep.Schedule = "@daily"
+	cfg.Endpoints["internal"] = ep
+
+	out, err := Rules(cfg)
+	assert.NoError(t, err)
+	var file ruleFile
+	assert.NoError(t, yaml.Unmarshal(out, &file))
+	alerts := map[string]map[string]rule{}
+	for _, g := range file.Groups {
+		alerts[g.Name] = map[string]rule{}
+		for _, r := range g.Rules {
+			alerts[g.Name][r.Alert] = r
+		}
+	}
+	assert.Equal(t, `time() - watchdog_endpoint_last_probe_timestamp_seconds{group="shop", endpoint!~"a\\.example\\.com"} > 180`,
+		alerts["watchdog-shop"]["WatchdogProbeStale"].Expr)
+	assert.NotContains(t, alerts["watchdog-backoffice"], "WatchdogProbeStale", "every endpoint of the group is scheduled")
+}
+
+func TestRules_HonorsMetricSettings(t *testing.T) {
+	cfg := testConfig()
+	cfg.Metrics.Subsystem = "synthetics"
+	cfg.Metrics.Names = map[string]string{"endpoint_validation": "probe_status"}
+	cfg.Metrics.Enabled = map[string]bool{"endpoint_last_probe_timestamp_seconds": false}
+
+	out, err := Rules(cfg)
+	assert.NoError(t, err)
+	assert.Contains(t, string(out), "watchdog_synthetics_probe_status{")
+	assert.NotContains(t, string(out), "WatchdogProbeStale")
+}
+
+func TestDashboard(t *testing.T) {
+	out, err := Dashboard(testConfig())
+	assert.NoError(t, err)
+
+	var d struct {
+		Panels []struct {
+			ID      int    `json:"id"`
+			Type    string `json:"type"`
+			Title   string `json:"title"`
+			Targets []struct {
+				Expr string `json:"expr"`
+			} `json:"targets"`
+		} `json:"panels"`
+	}
+	assert.NoError(t, json.Unmarshal(out, &d))
+
+	var rows, certPanels []string
+	ids := map[int]bool{}
+	for _, p := range d.Panels {
+		assert.False(t, ids[p.ID], "panel ids are unique")
+		ids[p.ID] = true
+		switch {
+		case p.Type == "row":
+			rows = append(rows, p.Title)
+		case p.Title == "Leaf certificate days left":
+			certPanels = append(certPanels, p.Targets[0].Expr)
+		case strings.HasPrefix(p.Title, "Availability"):
+			assert.Equal(t, "Availability (30d)", p.Title)
+		}
+	}
+	assert.Equal(t, []string{"Group: backoffice (1 endpoints)", "Group: shop (2 endpoints)"}, rows)
+	assert.Equal(t, []string{`min by (endpoint) (watchdog_endpoint_tls_cert_days_left{environment=~"$environment", group="shop", cert_position="0"})`}, certPanels)
+}
+
+func TestGenerate_Unknown(t *testing.T) {
+	_, err := Generate("kibana", testConfig())
+	assert.Error(t, err)
+}
+
+func TestPromDuration(t *testing.T) {
+	assert.Equal(t, "2m", promDuration(2*time.Minute))
+	assert.Equal(t, "90s", promDuration(90*time.Second))
+	assert.Equal(t, "1h", promDuration(time.Hour))
+}
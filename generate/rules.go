@@ -0,0 +1,142 @@
+package generate
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+)
+
+// Certificate expiry thresholds of the generated rules, in days.
+const (
+	certWarningDays  = 14
+	certCriticalDays = 7
+)
+
+type ruleFile struct {
+	Groups []ruleGroup `yaml:"groups"`
+}
+
+type ruleGroup struct {
+	Name  string `yaml:"name"`
+	Rules []rule `yaml:"rules"`
+}
+
+type rule struct {
+	Alert       string            `yaml:"alert"`
+	Expr        string            `yaml:"expr"`
+	For         string            `yaml:"for,omitempty"`
+	Labels      map[string]string `yaml:"labels,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+// Rules renders a Prometheus rule file with one rule group per endpoint group.
+// The "for" durations follow the probe interval, slowness follows each endpoint's request timeout.
+func Rules(cfg *config.WatchDogConfig) ([]byte, error) {
+	var file ruleFile
+	for _, g := range groupsOf(cfg) {
+		rg := ruleGroup{Name: "watchdog-" + g.Name}
+		sel := fmt.Sprintf(`group=%s`, quote(g.Name))
+		interval := g.Interval
+
+		if cfg.Metrics.MetricEnabled("endpoint_validation", true) {
+			rg.Rules = append(rg.Rules, rule{
+				Alert:  "WatchdogEndpointDown",
+				Expr:   fmt.Sprintf(`%s{%s, status!~"valid|valid-insecure-tls|maintenance"} == 1`, metricName(cfg, "endpoint_validation"), sel),
+				For:    promDuration(2 * interval),
+				Labels: map[string]string{"severity": "critical"},
+				Annotations: map[string]string{
+					"summary":     "{{ $labels.endpoint }} via {{ $labels.route }} fails: {{ $labels.status }}",
+					"description": "Endpoint {{ $labels.url }} (group " + g.Name + ") has failed validation for more than two probe intervals.",
+				},
+			})
+		}
+		// endpoints on a cron schedule may rightly go unprobed for hours
+		if cfg.Metrics.MetricEnabled("endpoint_last_probe_timestamp_seconds", true) && len(g.Scheduled) < len(g.Endpoints) {
+			staleSel := sel
+			if len(g.Scheduled) > 0 {
+				names := make([]string, len(g.Scheduled))
+				for i, name := range g.Scheduled {
+					names[i] = regexp.QuoteMeta(name)
+				}
+				staleSel += ", endpoint!~" + quote(strings.Join(names, "|"))
+			}
+			rg.Rules = append(rg.Rules, rule{
+				Alert:  "WatchdogProbeStale",
+				Expr:   fmt.Sprintf(`time() - %s{%s} > %d`, metricName(cfg, "endpoint_last_probe_timestamp_seconds"), staleSel, int((3 * interval).Seconds())),
+				Labels: map[string]string{"severity": "warning"},
+				Annotations: map[string]string{
+					"summary": "{{ $labels.endpoint }} via {{ $labels.route }} was not probed for three intervals",
+				},
+			})
+		}
+		if cfg.Metrics.MetricEnabled("endpoint_duration_seconds", true) {
+			// Slow: above 80% of the endpoint's own timeout.
+			var parts []string
+			for _, ep := range g.Endpoints {
+				limit := 0.8 * g.Timeouts[ep].Seconds()
+				if limit <= 0 {
+					continue
+				}
+				parts = append(parts, fmt.Sprintf(`%s{%s, endpoint=%s} > %g`,
+					metricName(cfg, "endpoint_duration_seconds"), sel, quote(ep), limit))
+			}
+			if len(parts) > 0 {
+				rg.Rules = append(rg.Rules, rule{
+					Alert:  "WatchdogEndpointSlow",
+					Expr:   strings.Join(parts, "\nor\n"),
+					For:    promDuration(3 * interval),
+					Labels: map[string]string{"severity": "warning"},
+					Annotations: map[string]string{
+						"summary": "{{ $labels.endpoint }} via {{ $labels.route }} takes {{ $value | humanizeDuration }}, close to its timeout",
+					},
+				})
+			}
+		}
+		if g.InspectTLS && cfg.Metrics.MetricEnabled("endpoint_tls_cert_days_left", true) {
+			days := metricName(cfg, "endpoint_tls_cert_days_left")
+			for _, th := range []struct {
+				days     int
+				severity string
+			}{{certWarningDays, "warning"}, {certCriticalDays, "critical"}} {
+				rg.Rules = append(rg.Rules, rule{
+					Alert:  fmt.Sprintf("WatchdogCertExpiresIn%dDays", th.days),
+					Expr:   fmt.Sprintf(`%s{%s, cert_position="0"} < %d`, days, sel, th.days),
+					Labels: map[string]string{"severity": th.severity},
+					Annotations: map[string]string{
+						"summary": "Certificate of {{ $labels.endpoint }} ({{ $labels.cert_cn }}) expires in {{ $value | printf \"%.1f\" }} days",
+					},
+				})
+			}
+		}
+		if len(rg.Rules) > 0 {
+			file.Groups = append(file.Groups, rg)
+		}
+	}
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(file); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), enc.Close()
+}
+
+// promDuration formats d as a Prometheus duration (e.g. 2m, 90s).
+func promDuration(d time.Duration) string {
+	switch {
+	case d <= 0:
+		return ""
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	case d%time.Minute == 0:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	default:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	}
+}
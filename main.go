@@ -4,16 +4,35 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"io"
+	"log/slog"
+	"net"
 	"net/http"
-	"watchdog_exporter/config"
-	"watchdog_exporter/metrics"
-	"watchdog_exporter/prober"
-	"watchdog_exporter/validator"
+	"os"
+	"runtime"
+	"time"
 
-	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/exporter-toolkit/web"
+
+	"github.com/kinjelom/watchdog_exporter/api"
+	"github.com/kinjelom/watchdog_exporter/bench"
+	"github.com/kinjelom/watchdog_exporter/config"
+	"github.com/kinjelom/watchdog_exporter/generate"
+	"github.com/kinjelom/watchdog_exporter/metrics"
+	"github.com/kinjelom/watchdog_exporter/notify"
+	"github.com/kinjelom/watchdog_exporter/prober"
+	"github.com/kinjelom/watchdog_exporter/push"
+	"github.com/kinjelom/watchdog_exporter/validator"
+	"github.com/kinjelom/watchdog_exporter/vault"
 )
 
-var ProgramVersion = "dev"
+// Build metadata, set with -ldflags "-X main.ProgramVersion=... -X main.ProgramCommit=... -X main.ProgramBuildDate=...".
+var (
+	ProgramVersion   = "dev"
+	ProgramCommit    = "unknown"
+	ProgramBuildDate = "unknown"
+)
 
 const (
 	ProgramName = "watchdog_exporter"
@@ -22,38 +41,357 @@ const (
 var wdm *metrics.WDMetrics
 
 func main() {
-	configFile := flag.String("config", "config.yml", "Path to configuration YAML")
+	if len(os.Args) > 1 && os.Args[1] == "probe" {
+		os.Exit(runProbeCommand(os.Args[2:], os.Stdout, os.Stderr))
+	}
+	configFile := flag.String("config", "config.yml", "Path to configuration YAML file, or a directory of them")
+	webConfigFile := flag.String("web.config.file", "", "Path to an exporter-toolkit web config enabling TLS and/or authentication (https://github.com/prometheus/exporter-toolkit/blob/master/docs/web-configuration.md)")
+	enableLifecycle := flag.Bool("web.enable-lifecycle", false, "Serve POST /-/reload (config reload) and the endpoint pause/resume API on the admin listener")
+	enablePprof := flag.Bool("enable-pprof", false, "Serve net/http/pprof profiles on --pprof.listen-address")
+	pprofAddress := flag.String("pprof.listen-address", "127.0.0.1:6060", "Admin address for the pprof endpoints (kept off the metrics listener)")
+	logLevel := flag.String("log.level", "info", "Minimum log level: debug | info | warn | error")
+	logFormat := flag.String("log.format", "text", "Log output format: text | json")
+	generateWhat := flag.String("generate", "", "Print an artifact derived from the config and exit: grafana-dashboard | prometheus-rules")
+	checkConfig := flag.Bool("check-config", false, "Load and validate --config (routes, regexes, URLs), print the problems found and exit (non-zero when invalid)")
+	showVersion := flag.Bool("version", false, "Print version information and exit")
+	runBench := flag.Bool("bench", false, "Probe synthetic endpoints on a built-in test server, print throughput, scheduler lag and memory usage, and exit (no --config needed)")
+	benchOpts := bench.Options{}
+	flag.IntVar(&benchOpts.Endpoints, "bench.endpoints", 1000, "Number of synthetic endpoints for --bench")
+	flag.IntVar(&benchOpts.MaxWorkers, "bench.max-workers", 4, "max-workers-count for --bench")
+	flag.DurationVar(&benchOpts.Interval, "bench.interval", 10*time.Second, "Probe interval for --bench")
+	flag.DurationVar(&benchOpts.Duration, "bench.duration", 30*time.Second, "How long --bench runs (at least one interval)")
+	flag.DurationVar(&benchOpts.Latency, "bench.latency", 10*time.Millisecond, "Response delay of the --bench test server")
 	flag.Parse()
 
+	build := metrics.BuildInfo{
+		ProgramName: ProgramName,
+		Version:     ProgramVersion,
+		Commit:      ProgramCommit,
+		BuildDate:   ProgramBuildDate,
+		GoVersion:   runtime.Version(),
+	}
+	if *showVersion {
+		fmt.Printf("%s version %s (commit: %s, built: %s, %s %s/%s)\n",
+			build.ProgramName, build.Version, build.Commit, build.BuildDate, build.GoVersion, runtime.GOOS, runtime.GOARCH)
+		return
+	}
+
+	err := setupLogging(*logLevel, *logFormat)
+	if err != nil {
+		panic(err)
+	}
+
+	if *runBench {
+		rep, bErr := bench.Run(context.Background(), benchOpts)
+		if bErr != nil {
+			panic(fmt.Errorf("cannot run bench: %v", bErr))
+		}
+		_, _ = rep.WriteTo(os.Stdout)
+		return
+	}
+
+	if *checkConfig {
+		os.Exit(runCheckConfig(*configFile))
+	}
+
 	cfg, err := config.LoadConfig(*configFile)
 	if err != nil {
 		panic(fmt.Errorf("cannot load --config=%s: %v", *configFile, err))
 	}
+	if problems := cfg.Problems(); len(problems) > 0 {
+		writeProblems(os.Stderr, *configFile, problems)
+		os.Exit(1)
+	}
+	if *generateWhat != "" {
+		out, gErr := generate.Generate(*generateWhat, cfg)
+		if gErr != nil {
+			panic(fmt.Errorf("cannot generate %s: %v", *generateWhat, gErr))
+		}
+		fmt.Println(string(out))
+		return
+	}
 	cfg.LogSummary()
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	tlsChecker := validator.NewDefaultTLSChecker(cfg.Settings.Debug)
-	httpRespChecker := validator.NewDefaultHTTPResponseChecker(cfg.Settings.Debug)
-	wdv := validator.NewWatchDogValidator(tlsChecker, httpRespChecker, cfg.Settings.Debug)
+	wdv, debugSwitch, err := newValidator(ctx, cfg)
+	if err != nil {
+		panic(err)
+	}
 
 	engine := prober.NewEngine(cfg, wdv)
 	// Metrics exporter: passive (Prometheus pulls), updates on events.
-	wdm = metrics.NewWDMetrics(ProgramName, ProgramVersion, cfg, engine.Provider())
+	wdm = metrics.NewWDMetrics(build, cfg, engine.Provider())
 	// Subscribe metrics to live results
 	engine.Subscribe(wdm)
 	// Seed metrics from any pre-existing snapshot (optional).
 	wdm.RebuildAll()
+	// Exporter self-observability (read from the engine on scrape).
+	self := metrics.NewSelfMetrics(cfg, engine)
+	self.SetConfigLoaded(true, cfg.Hash, time.Now())
+	prometheus.MustRegister(self)
 
-	// Start probing loops.
+	// Optional push outputs.
+	if rw := cfg.Push.RemoteWrite; rw != nil {
+		writer := push.NewRemoteWriter(*rw, prometheus.DefaultGatherer)
+		engine.Subscribe(writer)
+		go writer.Run(ctx)
+	}
+	if otlp := cfg.Push.OTLP; otlp != nil {
+		exporter := push.NewOTLPExporter(*otlp, prometheus.DefaultGatherer, ProgramName, ProgramVersion)
+		engine.Subscribe(exporter)
+		go exporter.Run(ctx)
+	}
+	if sd := cfg.Push.StatsD; sd != nil {
+		sink, sErr := push.NewStatsDSink(*sd)
+		if sErr != nil {
+			panic(fmt.Errorf("cannot start statsd output: %v", sErr))
+		}
+		defer func() { _ = sink.Close() }()
+		engine.Subscribe(sink)
+	}
+	if influx := cfg.Push.InfluxDB; influx != nil {
+		writer, iErr := push.NewInfluxWriter(*influx)
+		if iErr != nil {
+			panic(fmt.Errorf("cannot start influxdb output: %v", iErr))
+		}
+		defer func() { _ = writer.Close() }()
+		engine.Subscribe(writer)
+		go writer.Run(ctx)
+	}
+	if gr := cfg.Push.Graphite; gr != nil {
+		writer := push.NewGraphiteWriter(*gr)
+		engine.Subscribe(writer)
+		go writer.Run(ctx)
+	}
+	if mq := cfg.Push.MQTT; mq != nil {
+		publisher, mErr := push.NewMQTTPublisher(*mq)
+		if mErr != nil {
+			panic(fmt.Errorf("cannot start mqtt output: %v", mErr))
+		}
+		engine.Subscribe(publisher)
+		self.AddQueue("mqtt", publisher)
+		go publisher.Run(ctx)
+	}
+	if h := cfg.Push.History; h != nil {
+		writer, hErr := push.NewHistoryWriter(*h)
+		if hErr != nil {
+			panic(fmt.Errorf("cannot start history output: %v", hErr))
+		}
+		defer func() { _ = writer.Close() }()
+		engine.Subscribe(writer)
+		self.AddBuffer("history", writer)
+		go writer.Run(ctx)
+	}
+	if al := cfg.Push.AuditLog; al != nil {
+		auditLog, aErr := push.NewAuditLog(*al)
+		if aErr != nil {
+			panic(fmt.Errorf("cannot start audit log: %v", aErr))
+		}
+		engine.Subscribe(auditLog)
+		self.AddQueue("audit-log", auditLog)
+		go auditLog.Run(ctx)
+	}
+	if nc := cfg.Push.NATS; nc != nil {
+		publisher, nErr := push.NewNATSPublisher(*nc)
+		if nErr != nil {
+			panic(fmt.Errorf("cannot start nats output: %v", nErr))
+		}
+		engine.Subscribe(publisher)
+		self.AddQueue("nats", publisher)
+		go publisher.Run(ctx)
+	}
+
+	// Dead man's switch.
+	var heartbeat *push.Heartbeat
+	if hb := cfg.Heartbeat; hb != nil {
+		heartbeat = push.NewHeartbeat(*hb, cfg.ActiveEndpoints())
+		engine.Subscribe(heartbeat)
+		go heartbeat.Run(ctx)
+	}
+
+	// Transition notifications.
+	notifier, err := notify.FromConfig(cfg.Notifications)
+	if err != nil {
+		panic(fmt.Errorf("cannot set up notifications: %v", err))
+	}
+	if notifier != nil {
+		engine.Subscribe(notifier)
+		self.AddQueue("notifications", notifier)
+		go notifier.Run(ctx)
+	}
+
+	// Start probing loops; SIGHUP reloads the config into them.
 	go engine.Start(ctx)
+	reload := &reloader{path: *configFile, engine: engine, metrics: wdm, self: self, debug: debugSwitch,
+		heartbeat: heartbeat, started: cfg, cfg: cfg}
+	go reload.watchSIGHUP(ctx)
 
-	// Start HTTP
-	http.Handle(cfg.Settings.TelemetryPath, promhttp.Handler())
-	fmt.Printf("Starting %s v%s on %s%s\n", ProgramName, ProgramVersion, cfg.Settings.ListenAddress, cfg.Settings.TelemetryPath)
-	err = http.ListenAndServe(cfg.Settings.ListenAddress, nil)
+	// HTTP: /metrics (and the landing page) on the listen address; ops endpoints on the admin listener when one
+	// is configured, otherwise next to the metrics.
+	scrapeStats := metrics.NewScrapeStats(cfg, prometheus.DefaultGatherer)
+	prometheus.MustRegister(scrapeStats)
+	mux, adminMux, err := newMuxes(cfg, build, scrapeStats.Handler(), engine, debugSwitch, reload.Reload,
+		handlerOptions{lifecycle: *enableLifecycle, pprof: *enablePprof})
+	if err != nil {
+		panic(err)
+	}
+
+	// Profiling never shares the (possibly public) metrics port.
+	if *enablePprof && adminMux == mux {
+		go func() {
+			slog.Info("serving pprof", "address", *pprofAddress, "path", "/debug/pprof/")
+			server := newServer(cfg.Settings.Server, "pprof", pprofHandler())
+			server.Addr = *pprofAddress
+			if pErr := server.ListenAndServe(); pErr != nil {
+				panic(fmt.Errorf("cannot start pprof server: %v", pErr))
+			}
+		}()
+	}
+
+	if adminMux != mux {
+		go func() {
+			if aErr := serveAdmin(cfg.Settings, adminMux, webConfigFile); aErr != nil {
+				panic(fmt.Errorf("cannot start admin server: %v", aErr))
+			}
+		}()
+	}
+
+	slog.Info("starting "+ProgramName, "version", ProgramVersion, "address", cfg.Settings.ListenAddress, "telemetry_path", cfg.Settings.TelemetryPath)
+	listeners := make([]net.Listener, 0, len(cfg.Settings.ListenAddress))
+	for _, address := range cfg.Settings.ListenAddress {
+		l, lErr := listen(address)
+		if lErr != nil {
+			panic(fmt.Errorf("cannot listen on %s: %v", address, lErr))
+		}
+		listeners = append(listeners, l)
+	}
+	server := newServer(cfg.Settings.Server, "metrics", mux)
+	err = web.ServeMultiple(listeners, server, &web.FlagConfig{WebConfigFile: webConfigFile}, slog.Default())
 	if err != nil {
 		panic(fmt.Errorf("cannot start server: %v", err))
 	}
 }
+
+// handlerOptions are the command line switches that add handlers.
+type handlerOptions struct {
+	lifecycle bool // --web.enable-lifecycle: POST /-/reload, /api/v1/endpoints/{name}/pause and resume
+	pprof     bool // --enable-pprof
+}
+
+// newMuxes builds the metrics mux (telemetry, /probe and the landing page) and the admin mux (results, debug and, with
+// --web.enable-lifecycle, reload and pause); without settings.admin-listen-address both are the same mux. pprof is mounted on a separate admin mux
+// only: next to the metrics it gets a listener of its own.
+func newMuxes(cfg *config.WatchDogConfig, build metrics.BuildInfo, telemetry http.Handler, engine *prober.Engine,
+	debugSwitch *validator.DebugSwitch, reload func() error, opts handlerOptions) (mux, adminMux *http.ServeMux, err error) {
+	mux = http.NewServeMux()
+	mux.Handle(cfg.Settings.TelemetryPath, telemetry)
+	mux.Handle(metrics.ProbePath, metrics.NewProbeHandler(build, engine.Config, engine))
+	adminMux = mux
+	if cfg.Settings.AdminListenAddress != "" {
+		adminMux = http.NewServeMux()
+	}
+	adminMux.Handle(api.ResultsPath, api.NewResultsHandler(engine.Provider()))
+	adminMux.Handle(api.DebugPath, api.NewDebugHandler(debugSwitch))
+	if opts.lifecycle {
+		adminMux.Handle(api.ReloadPath, api.NewReloadHandler(reload))
+		adminMux.Handle(api.PausePath, api.NewPauseHandler(engine))
+		adminMux.Handle(api.ResumePath, api.NewResumeHandler(engine))
+	}
+	if opts.pprof && adminMux != mux {
+		adminMux.Handle("/debug/pprof/", pprofHandler())
+	}
+
+	if cfg.Settings.TelemetryPath != "/" {
+		links := []web.LandingLinks{{Address: cfg.Settings.TelemetryPath, Text: "Metrics"}}
+		if adminMux == mux {
+			links = append(links, web.LandingLinks{Address: api.ResultsPath, Text: "Results", Description: "Latest result of every endpoint and route (JSON)"})
+		}
+		landing, lErr := web.NewLandingPage(web.LandingConfig{
+			Name:        ProgramName,
+			Description: "Synthetic HTTP(S) endpoint probes with TLS and response validation",
+			Version:     ProgramVersion,
+			Profiling:   "false", // pprof lives on its own listener
+			Links:       links,
+		})
+		if lErr != nil {
+			return nil, nil, fmt.Errorf("cannot build landing page: %v", lErr)
+		}
+		mux.Handle("/", landing)
+	}
+	return mux, adminMux, nil
+}
+
+// newValidator builds the validator the config asks for: debug switches, proxy environment, Vault (its token is
+// renewed until ctx is done) and the DNS cache.
+func newValidator(ctx context.Context, cfg *config.WatchDogConfig) (*validator.WatchDogValidator, *validator.DebugSwitch, error) {
+	tlsChecker := validator.NewDefaultTLSChecker(cfg.Settings.Debug)
+	httpRespChecker := validator.NewDefaultHTTPResponseChecker(false)
+	wdv := validator.NewWatchDogValidator(tlsChecker, httpRespChecker, false)
+	debugSwitch := validator.NewDebugSwitch(cfg)
+	wdv.SetDebugSwitch(debugSwitch)
+	wdv.SetUseProxyEnv(cfg.Settings.UseProxyEnv)
+	if vc := cfg.Vault; vc != nil {
+		secrets, err := vault.New(*vc)
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot set up vault: %v", err)
+		}
+		wdv.SetSecretSource(secrets)
+		go secrets.Run(ctx)
+	}
+	if dc := cfg.Settings.DNSCache; dc != nil {
+		dnsCache, err := validator.NewDNSCache(*dc)
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot set up dns-cache: %v", err)
+		}
+		wdv.SetDNSCache(dnsCache)
+	}
+	return wdv, debugSwitch, nil
+}
+
+// setupLogging installs the default slog logger (also used by the standard log package).
+func setupLogging(level, format string) error {
+	var lv slog.Level
+	if err := lv.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("invalid --log.level=%s: %v", level, err)
+	}
+	opts := &slog.HandlerOptions{Level: lv}
+	var handler slog.Handler
+	switch format {
+	case "text":
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	case "json":
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	default:
+		return fmt.Errorf("invalid --log.format=%s: expected text or json", format)
+	}
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+// runCheckConfig loads and validates the config for --check-config and returns the exit code.
+func runCheckConfig(path string) int {
+	cfg, err := config.LoadConfig(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cannot load --config=%s: %v\n", path, err)
+		return 1
+	}
+	if problems := cfg.Problems(); len(problems) > 0 {
+		writeProblems(os.Stderr, path, problems)
+		return 1
+	}
+	for _, w := range cfg.Warnings() {
+		fmt.Fprintf(os.Stderr, "warning: %s\n", w)
+	}
+	fmt.Printf("--config=%s is valid: %d endpoint(s), %d route(s)\n", path, len(cfg.Endpoints), len(cfg.Routes))
+	return 0
+}
+
+// writeProblems lists the problems that make a config invalid.
+func writeProblems(w io.Writer, path string, problems []string) {
+	fmt.Fprintf(w, "--config=%s has %d problem(s):\n", path, len(problems))
+	for _, p := range problems {
+		fmt.Fprintf(w, "  - %s\n", p)
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+	"github.com/kinjelom/watchdog_exporter/metrics"
+	"github.com/kinjelom/watchdog_exporter/prober"
+)
+
+const muxConfig = `
+routes:
+  direct: {}
+endpoints:
+  shop:
+    routes: [direct]
+    request: { url: "https://shop.example.com" }
+`
+
+// newTestMuxes builds the muxes of muxConfig with settings (a YAML flow mapping), counting reload calls.
+func newTestMuxes(t *testing.T, settings string, opts handlerOptions) (mux, adminMux *http.ServeMux, reloads *int) {
+	t.Helper()
+	cfg, err := config.Parse([]byte(muxConfig + "settings: " + settings + "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wdv, debugSwitch, err := newValidator(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	telemetry := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { _, _ = w.Write([]byte("metrics")) })
+	reloads = new(int)
+	reload := func() error {
+		*reloads++
+		return nil
+	}
+	mux, adminMux, err = newMuxes(cfg, metrics.BuildInfo{ProgramName: ProgramName}, telemetry, prober.NewEngine(cfg, wdv), debugSwitch, reload, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mux, adminMux, reloads
+}
+
+func serve(h http.Handler, method, target string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(method, target, nil))
+	return rec
+}
+
+func TestNewMuxes_Pprof(t *testing.T) {
+	mux, _, _ := newTestMuxes(t, `{ telemetry-path: /metrics }`, handlerOptions{pprof: true})
+	assert.NotContains(t, serve(mux, http.MethodGet, "/debug/pprof/").Body.String(), "goroutine",
+		"pprof never shares the metrics listener")
+
+	_, adminMux, _ := newTestMuxes(t, `{ telemetry-path: /metrics, admin-listen-address: "127.0.0.1:0" }`, handlerOptions{pprof: true})
+	assert.Contains(t, serve(adminMux, http.MethodGet, "/debug/pprof/").Body.String(), "goroutine")
+
+	_, adminMux, _ = newTestMuxes(t, `{ telemetry-path: /metrics, admin-listen-address: "127.0.0.1:0" }`, handlerOptions{})
+	assert.NotContains(t, serve(adminMux, http.MethodGet, "/debug/pprof/").Body.String(), "goroutine",
+		"no pprof without --enable-pprof")
+}
+
+func TestNewMuxes_LandingPage(t *testing.T) {
+	mux, _, _ := newTestMuxes(t, `{ telemetry-path: /metrics }`, handlerOptions{})
+	landing := serve(mux, http.MethodGet, "/")
+	assert.Equal(t, http.StatusOK, landing.Code)
+	assert.Contains(t, landing.Body.String(), ProgramName)
+	assert.Contains(t, landing.Body.String(), `href="/metrics"`)
+	assert.Contains(t, landing.Body.String(), `href="/api/v1/results"`)
+	assert.Equal(t, "metrics", serve(mux, http.MethodGet, "/metrics").Body.String())
+
+	mux, _, _ = newTestMuxes(t, `{ telemetry-path: / }`, handlerOptions{})
+	assert.Equal(t, "metrics", serve(mux, http.MethodGet, "/").Body.String(), "telemetry-path / replaces the landing page")
+}
+
+func TestNewMuxes_AdminListener(t *testing.T) {
+	mux, adminMux, _ := newTestMuxes(t, `{ telemetry-path: /metrics }`, handlerOptions{})
+	assert.Same(t, mux, adminMux, "without admin-listen-address the ops endpoints sit next to the metrics")
+	assert.Equal(t, http.StatusOK, serve(mux, http.MethodGet, "/api/v1/results").Code)
+
+	mux, adminMux, _ = newTestMuxes(t, `{ telemetry-path: /metrics, admin-listen-address: "127.0.0.1:0" }`, handlerOptions{})
+	assert.NotSame(t, mux, adminMux)
+	assert.Equal(t, http.StatusOK, serve(adminMux, http.MethodGet, "/api/v1/results").Code)
+	assert.NotContains(t, serve(mux, http.MethodGet, "/api/v1/results").Header().Get("Content-Type"), "json",
+		"the results are on the admin listener only")
+	assert.NotContains(t, serve(mux, http.MethodGet, "/").Body.String(), `href="/api/v1/results"`)
+	assert.Equal(t, "metrics", serve(mux, http.MethodGet, "/metrics").Body.String())
+	assert.Equal(t, http.StatusNotFound, serve(adminMux, http.MethodGet, "/metrics").Code)
+}
+
+func TestNewMuxes_Reload(t *testing.T) {
+	mux, _, reloads := newTestMuxes(t, `{ telemetry-path: /metrics }`, handlerOptions{})
+	serve(mux, http.MethodPost, "/-/reload")
+	assert.Zero(t, *reloads, "no reload without --web.enable-lifecycle")
+
+	_, adminMux, reloads := newTestMuxes(t, `{ telemetry-path: /metrics, admin-listen-address: "127.0.0.1:0" }`,
+		handlerOptions{lifecycle: true})
+	assert.Equal(t, http.StatusMethodNotAllowed, serve(adminMux, http.MethodGet, "/-/reload").Code)
+	assert.Equal(t, http.StatusOK, serve(adminMux, http.MethodPost, "/-/reload").Code)
+	assert.Equal(t, 1, *reloads)
+}
+
+func TestNewMuxes_PauseResume(t *testing.T) {
+	mux, _, _ := newTestMuxes(t, `{ telemetry-path: /metrics }`, handlerOptions{})
+	assert.Equal(t, http.StatusNotFound, serve(mux, http.MethodPost, "/api/v1/endpoints/shop/pause").Code,
+		"no pause without --web.enable-lifecycle")
+
+	mux, _, _ = newTestMuxes(t, `{ telemetry-path: /metrics }`, handlerOptions{lifecycle: true})
+	assert.Equal(t, http.StatusOK, serve(mux, http.MethodPost, "/api/v1/endpoints/shop/pause").Code)
+	assert.Equal(t, http.StatusNotFound, serve(mux, http.MethodPost, "/api/v1/endpoints/cart/pause").Code)
+	assert.Equal(t, http.StatusOK, serve(mux, http.MethodPost, "/api/v1/endpoints/shop/resume").Code)
+}
+
+func TestRunCheckConfig(t *testing.T) {
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "valid.yml")
+	invalid := filepath.Join(dir, "invalid.yml")
+	if err := os.WriteFile(valid, []byte(muxConfig), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(invalid, []byte(muxConfig+"\n    validation: { body-regex: \"(ok\" }\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 0, runCheckConfig(valid))
+	assert.Equal(t, 1, runCheckConfig(invalid))
+	assert.Equal(t, 1, runCheckConfig(filepath.Join(dir, "missing.yml")))
+}
+
+func TestWriteProblems(t *testing.T) {
+	var out bytes.Buffer
+	writeProblems(&out, "c.yml", []string{`endpoint "a": route "x" is not defined`, `endpoint "b": request.url is required`})
+	assert.Equal(t, "--config=c.yml has 2 problem(s):\n"+
+		"  - endpoint \"a\": route \"x\" is not defined\n"+
+		"  - endpoint \"b\": request.url is required\n", out.String())
+}
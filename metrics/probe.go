@@ -0,0 +1,63 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+	"github.com/kinjelom/watchdog_exporter/prober"
+)
+
+// ProbePath is where the on-demand probe handler is mounted (blackbox_exporter style).
+const ProbePath = "/probe"
+
+// OnDemandProber runs a probe outside the schedule and hands out its worker slots; prober.Engine implements it.
+type OnDemandProber interface {
+	ProbeEndpoint(ctx context.Context, endpointName, routeName string) ([]prober.Result, error)
+	AcquireWorker(ctx context.Context) (func(), bool)
+}
+
+// resultsProvider serves a fixed set of results.
+type resultsProvider []prober.Result
+
+func (p resultsProvider) Snapshot() []prober.Result { return p }
+
+// NewProbeHandler probes ?endpoint=<name>[&route=<name>] synchronously and answers with the endpoint metric families
+// of that probe only, in the exposition format. The scheduled results and /metrics are not affected. cfg returns the
+// current config (prober.Engine.Config), so labels follow reloads. The probe and the rendering of its metrics each
+// take a worker slot, so on-demand requests never run more than settings.max-workers-count at once.
+func NewProbeHandler(build BuildInfo, cfg func() *config.WatchDogConfig, p OnDemandProber) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		endpointName := q.Get("endpoint")
+		if endpointName == "" {
+			http.Error(w, "endpoint parameter is required", http.StatusBadRequest)
+			return
+		}
+		results, err := p.ProbeEndpoint(r.Context(), endpointName, q.Get("route"))
+		if err != nil {
+			code := http.StatusBadRequest
+			if r.Context().Err() != nil {
+				code = http.StatusServiceUnavailable // gave up waiting for a worker
+			}
+			http.Error(w, err.Error(), code)
+			return
+		}
+
+		release, ok := p.AcquireWorker(r.Context())
+		if !ok {
+			http.Error(w, "no free worker", http.StatusServiceUnavailable)
+			return
+		}
+		defer release()
+		reg := prometheus.NewRegistry()
+		m := newWDMetrics(reg, build, cfg(), resultsProvider(results))
+		for _, res := range results {
+			m.OnResult(res)
+		}
+		promhttp.HandlerFor(reg, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,93 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+	"github.com/kinjelom/watchdog_exporter/prober"
+	"github.com/kinjelom/watchdog_exporter/status"
+)
+
+type fakeOnDemand struct {
+	calls   int
+	workers chan struct{}
+}
+
+func (f *fakeOnDemand) AcquireWorker(ctx context.Context) (func(), bool) {
+	select {
+	case f.workers <- struct{}{}:
+		return func() { <-f.workers }, true
+	case <-ctx.Done():
+		return nil, false
+	}
+}
+
+func (f *fakeOnDemand) ProbeEndpoint(_ context.Context, endpointName, routeName string) ([]prober.Result, error) {
+	f.calls++
+	if endpointName != "ep" {
+		return nil, fmt.Errorf("unknown endpoint %q", endpointName)
+	}
+	return []prober.Result{{Group: "g", Endpoint: "ep", Protocol: "http", URL: "http://a", Route: routeName,
+		Status: status.UnexpectedStatusCode, Duration: 0.5}}, nil
+}
+
+func TestProbeHandler(t *testing.T) {
+	p := &fakeOnDemand{workers: make(chan struct{}, 1)}
+	cfg := makeBasicConfig()
+	h := NewProbeHandler(BuildInfo{ProgramName: "prog", Version: "ver"}, func() *config.WatchDogConfig { return cfg }, p)
+	get := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec
+	}
+
+	// Twice: every request gets its own registry.
+	for i := 0; i < 2; i++ {
+		rec := get(ProbePath + "?endpoint=ep&route=direct")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+		}
+		body := rec.Body.String()
+		want := `ns_endpoint_validation{endpoint="ep",environment="env",group="g",is_error="false",protocol="http",route="direct",status="unexpected-status-code",url="http://a"} 1`
+		if !strings.Contains(body, want) {
+			t.Fatalf("expected %s in\n%s", want, body)
+		}
+	}
+
+	if rec := get(ProbePath); rec.Code != http.StatusBadRequest {
+		t.Fatalf("missing endpoint: status = %d", rec.Code)
+	}
+	if rec := get(ProbePath + "?endpoint=nope"); rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "unknown endpoint") {
+		t.Fatalf("unknown endpoint: status = %d, body %s", rec.Code, rec.Body)
+	}
+
+	// A reloaded config applies to the next request.
+	cfg = makeBasicConfig()
+	cfg.Metrics.Environment = "prod"
+	if rec := get(ProbePath + "?endpoint=ep"); !strings.Contains(rec.Body.String(), `environment="prod"`) {
+		t.Fatalf("reloaded config not used:\n%s", rec.Body)
+	}
+
+	// Rendering waits for a worker.
+	p.workers <- struct{}{}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, ProbePath+"?endpoint=ep", nil).WithContext(ctx))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("all workers busy: status = %d", rec.Code)
+	}
+	<-p.workers
+	if len(p.workers) != 0 {
+		t.Fatal("worker not released")
+	}
+	if p.calls != 5 {
+		t.Fatalf("calls = %d, want 5", p.calls)
+	}
+}
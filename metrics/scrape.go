@@ -0,0 +1,81 @@
+package metrics
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+)
+
+// ScrapeStats instruments the telemetry handler: scrapes by status code, scrape duration, series exposed,
+// and optionally logs each scrape (remote address, duration, series count).
+type ScrapeStats struct {
+	gatherer   prometheus.Gatherer
+	logScrapes bool
+
+	scrapes  *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	series   prometheus.Gauge
+}
+
+func NewScrapeStats(cfg *config.WatchDogConfig, gatherer prometheus.Gatherer) *ScrapeStats {
+	fqName := func(name string) string {
+		return prometheus.BuildFQName(cfg.Metrics.Namespace, cfg.Metrics.Subsystem, cfg.Metrics.MetricName(name))
+	}
+	constLabels := prometheus.Labels{"environment": cfg.Metrics.Environment}
+	return &ScrapeStats{
+		gatherer:   gatherer,
+		logScrapes: cfg.Settings.Server.ScrapeLog,
+		scrapes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: fqName("exporter_scrapes_total"), Help: "Number of scrapes of the telemetry endpoint by HTTP status code",
+			ConstLabels: constLabels,
+		}, []string{"code"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name: fqName("exporter_scrape_duration_seconds"), Help: "Time spent serving a scrape of the telemetry endpoint",
+			ConstLabels: constLabels, Buckets: []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+		}, nil),
+		series: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: fqName("exporter_scrape_series"), Help: "Number of series exposed by the last scrape",
+			ConstLabels: constLabels,
+		}),
+	}
+}
+
+func (s *ScrapeStats) Describe(ch chan<- *prometheus.Desc) {
+	s.scrapes.Describe(ch)
+	s.duration.Describe(ch)
+	s.series.Describe(ch)
+}
+
+func (s *ScrapeStats) Collect(ch chan<- prometheus.Metric) {
+	s.scrapes.Collect(ch)
+	s.duration.Collect(ch)
+	s.series.Collect(ch)
+}
+
+// Handler serves the gatherer's metrics with the stats above.
+func (s *ScrapeStats) Handler() http.Handler {
+	serve := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		var series int
+		counting := prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+			mfs, err := s.gatherer.Gather()
+			for _, mf := range mfs {
+				series += len(mf.GetMetric())
+			}
+			return mfs, err
+		})
+		promhttp.HandlerFor(counting, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+		s.series.Set(float64(series))
+		if s.logScrapes {
+			slog.Info("scrape", "remote_addr", r.RemoteAddr, "user_agent", r.UserAgent(),
+				"duration", time.Since(start), "series", series)
+		}
+	})
+	return promhttp.InstrumentHandlerCounter(s.scrapes, promhttp.InstrumentHandlerDuration(s.duration, serve))
+}
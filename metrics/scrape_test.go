@@ -0,0 +1,39 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestScrapeStats_CountsScrapesAndSeries(t *testing.T) {
+	cfg := makeBasicConfig()
+	reg := prometheus.NewRegistry()
+	g := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "sample"}, []string{"k"})
+	g.WithLabelValues("a").Set(1)
+	g.WithLabelValues("b").Set(2)
+	reg.MustRegister(g)
+
+	s := NewScrapeStats(cfg, reg)
+	h := s.Handler()
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", rec.Code)
+		}
+	}
+
+	if got := testutil.ToFloat64(s.scrapes.WithLabelValues("200")); got != 2 {
+		t.Errorf("expected 2 scrapes, got %v", got)
+	}
+	if got := testutil.ToFloat64(s.series); got != 2 {
+		t.Errorf("expected 2 series, got %v", got)
+	}
+	if got := testutil.CollectAndCount(s.duration); got != 1 {
+		t.Errorf("expected the duration histogram, got %d series", got)
+	}
+}
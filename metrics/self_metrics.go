@@ -0,0 +1,183 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+	"github.com/kinjelom/watchdog_exporter/prober"
+)
+
+// BufferSource is an in-memory result buffer kept within a memory budget (e.g. the SQL history writer's).
+type BufferSource interface {
+	BufferUsage() (results int, bytes int64, evicted uint64)
+}
+
+// QueueSource is a subscriber that drops results when its queue is full (e.g. the MQTT publisher's).
+type QueueSource interface {
+	QueueDrops() uint64
+}
+
+// SelfMetrics exposes the exporter's own health: scheduler lag, worker usage,
+// store size, subscriber failures and config loads. It reads engine stats on scrape.
+// Goroutine counts and memory come from the default Go/process collectors.
+type SelfMetrics struct {
+	source prober.StatsProvider
+
+	schedulerLag        *prometheus.Desc
+	startOffset         *prometheus.Desc
+	probesInFlight      *prometheus.Desc
+	maxWorkers          *prometheus.Desc
+	probesQueued        *prometheus.Desc
+	workerWait          *prometheus.Desc
+	rateLimitWait       *prometheus.Desc
+	storeSize           *prometheus.Desc
+	subscriberPanics    *prometheus.Desc
+	queueDrops          *prometheus.Desc
+	configReloadTime    *prometheus.Desc
+	configReloadSuccess *prometheus.Desc
+	configReloadFails   *prometheus.Desc
+	configHash          *prometheus.Desc
+	bufferResults       *prometheus.Desc
+	bufferBytes         *prometheus.Desc
+	bufferEvicted       *prometheus.Desc
+
+	mu             sync.Mutex
+	buffers        map[string]BufferSource // output name -> buffer
+	queues         map[string]QueueSource  // subscriber name -> queue
+	lastReloadAt   time.Time
+	lastReloadGood bool
+	reloadFailures uint64
+	hash           string
+}
+
+func NewSelfMetrics(cfg *config.WatchDogConfig, source prober.StatsProvider) *SelfMetrics {
+	desc := func(name, help string, labels []string) *prometheus.Desc {
+		fqName := prometheus.BuildFQName(cfg.Metrics.Namespace, cfg.Metrics.Subsystem, cfg.Metrics.MetricName(name))
+		return prometheus.NewDesc(fqName, help, labels, prometheus.Labels{"environment": cfg.Metrics.Environment})
+	}
+	return &SelfMetrics{
+		source:              source,
+		schedulerLag:        desc("exporter_scheduler_lag_seconds", "Delay between the scheduled and actual start of the last probe cycle", []string{"endpoint"}),
+		startOffset:         desc("exporter_probe_start_offset_seconds", "Offset of the endpoint's probes within its interval, set by settings.probe-spread", []string{"endpoint"}),
+		probesInFlight:      desc("exporter_probes_in_flight", "Number of probes currently executing", nil),
+		maxWorkers:          desc("exporter_max_workers", "Configured max-workers-count", nil),
+		probesQueued:        desc("exporter_probes_queued", "Number of probes waiting for a free worker", nil),
+		workerWait:          desc("exporter_worker_wait_seconds", "Time probes waited for a free worker", nil),
+		rateLimitWait:       desc("exporter_rate_limit_wait_seconds", "Delay of the probes held back by rate-limit", nil),
+		storeSize:           desc("exporter_store_results", "Number of results held in the store", nil),
+		subscriberPanics:    desc("exporter_subscriber_panics_total", "Number of result subscribers that panicked (result dropped for that subscriber)", nil),
+		queueDrops:          desc("exporter_subscriber_queue_drops_total", "Results a subscriber dropped because its queue was full", []string{"subscriber"}),
+		configReloadTime:    desc("config_last_reload_success_timestamp_seconds", "Unix timestamp of the last successful config load", nil),
+		configReloadSuccess: desc("config_last_reload_successful", "Whether the last config load attempt succeeded (1/0)", nil),
+		configReloadFails:   desc("config_reload_failures_total", "Number of failed config load attempts", nil),
+		configHash:          desc("config_hash_info", "Hash (sha256) of the active config file (always 1)", []string{"hash"}),
+		bufferResults:       desc("exporter_buffer_results", "Results held in the output's in-memory buffer", []string{"output"}),
+		bufferBytes:         desc("exporter_buffer_bytes", "Approximate memory held by the output's in-memory buffer", []string{"output"}),
+		bufferEvicted:       desc("exporter_buffer_evicted_total", "Results evicted from the output's buffer to stay within its memory budget", []string{"output"}),
+		buffers:             make(map[string]BufferSource),
+		queues:              make(map[string]QueueSource),
+	}
+}
+
+// AddBuffer exposes the usage of an output's in-memory buffer, labelled output=name.
+func (s *SelfMetrics) AddBuffer(name string, source BufferSource) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.buffers[name] = source
+}
+
+// AddQueue exposes the drops of a subscriber's queue, labelled subscriber=name.
+func (s *SelfMetrics) AddQueue(name string, source QueueSource) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queues[name] = source
+}
+
+// SetConfigLoaded records the outcome of a config (re)load; hash is the active config hash on success.
+func (s *SelfMetrics) SetConfigLoaded(ok bool, hash string, at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastReloadGood = ok
+	if ok {
+		s.lastReloadAt = at
+		s.hash = hash
+	} else {
+		s.reloadFailures++
+	}
+}
+
+func (s *SelfMetrics) Describe(ch chan<- *prometheus.Desc) {
+	ch <- s.schedulerLag
+	ch <- s.startOffset
+	ch <- s.probesInFlight
+	ch <- s.maxWorkers
+	ch <- s.probesQueued
+	ch <- s.workerWait
+	ch <- s.rateLimitWait
+	ch <- s.storeSize
+	ch <- s.subscriberPanics
+	ch <- s.queueDrops
+	ch <- s.configReloadTime
+	ch <- s.configReloadSuccess
+	ch <- s.configReloadFails
+	ch <- s.configHash
+	ch <- s.bufferResults
+	ch <- s.bufferBytes
+	ch <- s.bufferEvicted
+}
+
+func (s *SelfMetrics) Collect(ch chan<- prometheus.Metric) {
+	st := s.source.Stats()
+	for endpoint, lag := range st.SchedulerLag {
+		ch <- prometheus.MustNewConstMetric(s.schedulerLag, prometheus.GaugeValue, lag.Seconds(), endpoint)
+	}
+	for endpoint, offset := range st.StartOffset {
+		ch <- prometheus.MustNewConstMetric(s.startOffset, prometheus.GaugeValue, offset.Seconds(), endpoint)
+	}
+	ch <- prometheus.MustNewConstMetric(s.probesInFlight, prometheus.GaugeValue, float64(st.ProbesInFlight))
+	ch <- prometheus.MustNewConstMetric(s.maxWorkers, prometheus.GaugeValue, float64(st.MaxWorkers))
+	ch <- prometheus.MustNewConstMetric(s.probesQueued, prometheus.GaugeValue, float64(st.ProbesQueued))
+	ch <- prometheus.MustNewConstSummary(s.workerWait, st.WorkerWaits, st.WorkerWaitTotal.Seconds(), nil)
+	ch <- prometheus.MustNewConstSummary(s.rateLimitWait, st.RateLimitWaits, st.RateLimitWait.Seconds(), nil)
+	ch <- prometheus.MustNewConstMetric(s.storeSize, prometheus.GaugeValue, float64(st.StoreSize))
+	ch <- prometheus.MustNewConstMetric(s.subscriberPanics, prometheus.CounterValue, float64(st.SubscriberPanics))
+
+	s.mu.Lock()
+	reloadAt, reloadGood, reloadFailures, hash := s.lastReloadAt, s.lastReloadGood, s.reloadFailures, s.hash
+	buffers := make(map[string]BufferSource, len(s.buffers))
+	for name, source := range s.buffers {
+		buffers[name] = source
+	}
+	queues := make(map[string]QueueSource, len(s.queues))
+	for name, source := range s.queues {
+		queues[name] = source
+	}
+	s.mu.Unlock()
+	if !reloadAt.IsZero() {
+		ch <- prometheus.MustNewConstMetric(s.configReloadTime, prometheus.GaugeValue, float64(reloadAt.Unix()))
+	}
+	ch <- prometheus.MustNewConstMetric(s.configReloadSuccess, prometheus.GaugeValue, boolToFloat(reloadGood))
+	ch <- prometheus.MustNewConstMetric(s.configReloadFails, prometheus.CounterValue, float64(reloadFailures))
+	if hash != "" {
+		ch <- prometheus.MustNewConstMetric(s.configHash, prometheus.GaugeValue, 1, hash)
+	}
+	for name, source := range buffers {
+		results, bytes, evicted := source.BufferUsage()
+		ch <- prometheus.MustNewConstMetric(s.bufferResults, prometheus.GaugeValue, float64(results), name)
+		ch <- prometheus.MustNewConstMetric(s.bufferBytes, prometheus.GaugeValue, float64(bytes), name)
+		ch <- prometheus.MustNewConstMetric(s.bufferEvicted, prometheus.CounterValue, float64(evicted), name)
+	}
+	for name, source := range queues {
+		ch <- prometheus.MustNewConstMetric(s.queueDrops, prometheus.CounterValue, float64(source.QueueDrops()), name)
+	}
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,105 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/kinjelom/watchdog_exporter/prober"
+)
+
+type fakeStats struct{ st prober.Stats }
+
+func (f fakeStats) Stats() prober.Stats { return f.st }
+
+type fakeBuffer struct{}
+
+func (fakeBuffer) BufferUsage() (int, int64, uint64) { return 3, 2048, 5 }
+
+type fakeQueue uint64
+
+func (q fakeQueue) QueueDrops() uint64 { return uint64(q) }
+
+func TestSelfMetrics_Collect(t *testing.T) {
+	cfg := makeBasicConfig()
+	s := NewSelfMetrics(cfg, fakeStats{st: prober.Stats{
+		ProbesInFlight:   2,
+		MaxWorkers:       4,
+		ProbesQueued:     3,
+		WorkerWaits:      10,
+		WorkerWaitTotal:  2500 * time.Millisecond,
+		RateLimitWaits:   4,
+		RateLimitWait:    time.Second,
+		StoreSize:        7,
+		SubscriberPanics: 1,
+		SchedulerLag:     map[string]time.Duration{"ep": 1500 * time.Millisecond},
+		StartOffset:      map[string]time.Duration{"ep": 42 * time.Second},
+	}})
+	s.SetConfigLoaded(true, "abc123", time.Unix(1700000000, 0))
+	s.SetConfigLoaded(false, "", time.Unix(1700000100, 0))
+	s.AddBuffer("history", fakeBuffer{})
+	s.AddQueue("mqtt", fakeQueue(4))
+	s.AddQueue("notifications", fakeQueue(0))
+
+	expected := `
+# HELP ns_exporter_probes_in_flight Number of probes currently executing
+# TYPE ns_exporter_probes_in_flight gauge
+ns_exporter_probes_in_flight{environment="env"} 2
+# HELP ns_exporter_probes_queued Number of probes waiting for a free worker
+# TYPE ns_exporter_probes_queued gauge
+ns_exporter_probes_queued{environment="env"} 3
+# HELP ns_exporter_worker_wait_seconds Time probes waited for a free worker
+# TYPE ns_exporter_worker_wait_seconds summary
+ns_exporter_worker_wait_seconds_sum{environment="env"} 2.5
+ns_exporter_worker_wait_seconds_count{environment="env"} 10
+# HELP ns_exporter_rate_limit_wait_seconds Delay of the probes held back by rate-limit
+# TYPE ns_exporter_rate_limit_wait_seconds summary
+ns_exporter_rate_limit_wait_seconds_sum{environment="env"} 1
+ns_exporter_rate_limit_wait_seconds_count{environment="env"} 4
+# HELP ns_exporter_scheduler_lag_seconds Delay between the scheduled and actual start of the last probe cycle
+# TYPE ns_exporter_scheduler_lag_seconds gauge
+ns_exporter_scheduler_lag_seconds{endpoint="ep",environment="env"} 1.5
+# HELP ns_exporter_probe_start_offset_seconds Offset of the endpoint's probes within its interval, set by settings.probe-spread
+# TYPE ns_exporter_probe_start_offset_seconds gauge
+ns_exporter_probe_start_offset_seconds{endpoint="ep",environment="env"} 42
+# HELP ns_exporter_store_results Number of results held in the store
+# TYPE ns_exporter_store_results gauge
+ns_exporter_store_results{environment="env"} 7
+# HELP ns_config_last_reload_success_timestamp_seconds Unix timestamp of the last successful config load
+# TYPE ns_config_last_reload_success_timestamp_seconds gauge
+ns_config_last_reload_success_timestamp_seconds{environment="env"} 1.7e+09
+# HELP ns_config_last_reload_successful Whether the last config load attempt succeeded (1/0)
+# TYPE ns_config_last_reload_successful gauge
+ns_config_last_reload_successful{environment="env"} 0
+# HELP ns_config_reload_failures_total Number of failed config load attempts
+# TYPE ns_config_reload_failures_total counter
+ns_config_reload_failures_total{environment="env"} 1
+# HELP ns_config_hash_info Hash (sha256) of the active config file (always 1)
+# TYPE ns_config_hash_info gauge
+ns_config_hash_info{environment="env",hash="abc123"} 1
+# HELP ns_exporter_buffer_results Results held in the output's in-memory buffer
+# TYPE ns_exporter_buffer_results gauge
+ns_exporter_buffer_results{environment="env",output="history"} 3
+# HELP ns_exporter_buffer_bytes Approximate memory held by the output's in-memory buffer
+# TYPE ns_exporter_buffer_bytes gauge
+ns_exporter_buffer_bytes{environment="env",output="history"} 2048
+# HELP ns_exporter_buffer_evicted_total Results evicted from the output's buffer to stay within its memory budget
+# TYPE ns_exporter_buffer_evicted_total counter
+ns_exporter_buffer_evicted_total{environment="env",output="history"} 5
+# HELP ns_exporter_subscriber_queue_drops_total Results a subscriber dropped because its queue was full
+# TYPE ns_exporter_subscriber_queue_drops_total counter
+ns_exporter_subscriber_queue_drops_total{environment="env",subscriber="mqtt"} 4
+ns_exporter_subscriber_queue_drops_total{environment="env",subscriber="notifications"} 0
+`
+	err := testutil.CollectAndCompare(s, strings.NewReader(expected),
+		"ns_exporter_probes_in_flight", "ns_exporter_probes_queued", "ns_exporter_worker_wait_seconds", "ns_exporter_rate_limit_wait_seconds", "ns_exporter_scheduler_lag_seconds", "ns_exporter_probe_start_offset_seconds", "ns_exporter_store_results",
+		"ns_config_last_reload_success_timestamp_seconds", "ns_config_last_reload_successful",
+		"ns_config_reload_failures_total", "ns_config_hash_info",
+		"ns_exporter_buffer_results", "ns_exporter_buffer_bytes", "ns_exporter_buffer_evicted_total",
+		"ns_exporter_subscriber_queue_drops_total")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,266 +1,1026 @@
+// Package metrics exposes probe results and the exporter's own state as Prometheus metrics.
 package metrics
 
 import (
 	"fmt"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
-	"watchdog_exporter/config"
-	"watchdog_exporter/prober"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+	"github.com/kinjelom/watchdog_exporter/prober"
+	"github.com/kinjelom/watchdog_exporter/validator"
 )
 
 // WDMetrics exposes endpoint validation and TLS certificate metrics.
 type WDMetrics struct {
-	cfg      *config.WatchDogConfig
-	provider prober.Provider
-
-	BuildInfo                  *prometheus.GaugeVec
-	EndpointLastProbeTimestamp *prometheus.GaugeVec
-	EndpointValidation         *prometheus.GaugeVec
-	EndpointDuration           *prometheus.GaugeVec
-	EndpointTLSCertDaysLeft    *prometheus.GaugeVec
-
-	lastMu        sync.Mutex
-	lastByKey     map[string]prometheus.Labels
-	lastCertMu    sync.Mutex
-	lastCertByKey map[string][]prometheus.Labels
+	cfg        *config.WatchDogConfig
+	provider   prober.Provider
+	registerer prometheus.Registerer
+
+	BuildInfo                   *prometheus.GaugeVec
+	EndpointLastProbeTimestamp  *prometheus.GaugeVec
+	EndpointLastStateChange     *prometheus.GaugeVec
+	EndpointConsecutiveFailures *prometheus.GaugeVec
+	EndpointAvailability        *prometheus.GaugeVec
+	EndpointValidation          *prometheus.GaugeVec
+	EndpointDuration            *prometheus.GaugeVec
+	EndpointDurationHistogram   *prometheus.HistogramVec
+	EndpointPhaseHistogram      *prometheus.HistogramVec
+	EndpointDurationSummary     *prometheus.SummaryVec
+	EndpointHeaderMatch         *prometheus.GaugeVec
+	EndpointBodyMatch           *prometheus.GaugeVec
+	EndpointTLSPinValid         *prometheus.GaugeVec
+	EndpointSLOMet              *prometheus.GaugeVec
+	EndpointMaintenance         *prometheus.GaugeVec
+	EndpointRawPassed           *prometheus.GaugeVec
+	EndpointProbeSuppressed     *prometheus.GaugeVec
+	EndpointRemoteIPInfo        *prometheus.GaugeVec
+	EndpointRedirects           *prometheus.GaugeVec
+	EndpointIPv6Fallback        *prometheus.GaugeVec
+	EndpointResponseHeaderInfo  *prometheus.GaugeVec
+	EndpointErrorClass          *prometheus.GaugeVec
+	EndpointTLSCertDaysLeft     *prometheus.GaugeVec
+	EndpointTLSCertNotAfter     *prometheus.GaugeVec
+	EndpointTLSInfo             *prometheus.GaugeVec
+	EndpointStateTransitions    *prometheus.CounterVec
+	EndpointProbeAttempts       *prometheus.CounterVec
+	RouteUp                     *prometheus.GaugeVec
+	RouteDuration               *prometheus.GaugeVec
+	GroupProbeDuration          *prometheus.SummaryVec
+	GroupProbeFailures          *prometheus.CounterVec
+
+	rebuildMu      sync.RWMutex // held for write by RebuildAll, for read by whatever sets or removes endpoint series
+	seriesMu       sync.Mutex
+	seriesByKey    map[string]*endpointSeries
+	lastSupprMu    sync.Mutex
+	lastSupprByKey map[string]prometheus.Labels
+
+	enabled map[string]bool // default metric name -> registered
+
+	ipLabel       bool     // series carry the probed address (probe-all-ips)
+	customLabels  []string // endpoint labels of the config, on every endpoint series
+	remoteIPLabel string   // label of endpoint_remote_ip_info: ip, or remote_ip when ipLabel is set
+}
+
+// BuildInfo describes the running binary, exported as build_info labels.
+type BuildInfo struct {
+	ProgramName string
+	Version     string
+	Commit      string
+	BuildDate   string
+	GoVersion   string
+}
+
+func NewWDMetrics(build BuildInfo, cfg *config.WatchDogConfig, provider prober.Provider) *WDMetrics {
+	return newWDMetrics(prometheus.DefaultRegisterer, build, cfg, provider)
 }
 
-func NewWDMetrics(programName, programVersion string, cfg *config.WatchDogConfig, provider prober.Provider) *WDMetrics {
+// newWDMetrics registers the enabled collectors with reg (a private registry for on-demand probes).
+func newWDMetrics(reg prometheus.Registerer, build BuildInfo, cfg *config.WatchDogConfig, provider prober.Provider) *WDMetrics {
 	opts := func(name, help string, constantLabels *prometheus.Labels) prometheus.GaugeOpts {
 		return prometheus.GaugeOpts{
 			Namespace:   cfg.Metrics.Namespace,
-			Name:        name,
+			Subsystem:   cfg.Metrics.Subsystem,
+			Name:        cfg.Metrics.MetricName(name),
 			Help:        help,
 			ConstLabels: *constantLabels,
 		}
 	}
 
+	// With probe-all-ips anywhere, every endpoint series also carries the probed address (empty for the others).
+	ipLabel := cfg.ProbesAllIPs()
 	baseEndpointLabels := []string{"group", "endpoint", "protocol", "url", "route"}
-	endpointResultLabels := []string{"group", "endpoint", "protocol", "url", "route", "status", "is_error"}
-	certLabels := []string{
-		"group", "endpoint", "protocol", "url", "route",
-		"cert_position", "cert_serial", "cert_cn", "cert_is_ca", "cert_issuer_cn",
+	if ipLabel {
+		baseEndpointLabels = append(baseEndpointLabels, "ip")
 	}
+	customLabels := cfg.EndpointLabelNames()
+	baseEndpointLabels = append(baseEndpointLabels, customLabels...)
+	withBase := func(extra ...string) []string {
+		return append(slices.Clone(baseEndpointLabels), extra...)
+	}
+	// The remote IP label must not clash with the base ip label.
+	remoteIPLabel := "ip"
+	if ipLabel {
+		remoteIPLabel = "remote_ip"
+	}
+	endpointResultLabels := withBase("status", "is_error")
+	certLabels := withBase("cert_position", "cert_serial", "cert_cn", "cert_is_ca", "cert_issuer_cn")
+	tlsInfoLabels := withBase("tls_version", "cipher", "alpn")
+	availabilityLabels := withBase("window")
+	remoteIPLabels := withBase(remoteIPLabel)
+	errorClassLabels := withBase("error_class")
+	headerInfoLabels := withBase("header", "value")
+	suppressedLabels := withBase("reason")
+	transitionLabels := withBase("from", "to")
 
 	m := &WDMetrics{
-		cfg:           cfg,
-		provider:      provider,
-		lastByKey:     make(map[string]prometheus.Labels),
-		lastCertByKey: make(map[string][]prometheus.Labels),
+		cfg:            cfg,
+		provider:       provider,
+		registerer:     reg,
+		seriesByKey:    make(map[string]*endpointSeries),
+		lastSupprByKey: make(map[string]prometheus.Labels),
+		ipLabel:        ipLabel,
+		customLabels:   customLabels,
+		remoteIPLabel:  remoteIPLabel,
 
-		BuildInfo: promauto.NewGaugeVec(
+		BuildInfo: prometheus.NewGaugeVec(
 			opts("build_info", "Program build information", &prometheus.Labels{
-				"program_name":    programName,
-				"program_version": programVersion,
+				"program_name":    build.ProgramName,
+				"program_version": build.Version,
+				"commit":          build.Commit,
+				"build_date":      build.BuildDate,
+				"go_version":      build.GoVersion,
 			}),
 			[]string{},
 		),
 
-		EndpointLastProbeTimestamp: promauto.NewGaugeVec(
+		EndpointLastProbeTimestamp: prometheus.NewGaugeVec(
 			opts("endpoint_last_probe_timestamp_seconds", "Unix timestamp of the last probe", &prometheus.Labels{
 				"environment": cfg.Metrics.Environment,
 			}),
 			baseEndpointLabels,
 		),
 
-		EndpointValidation: promauto.NewGaugeVec(
+		EndpointLastStateChange: prometheus.NewGaugeVec(
+			opts("endpoint_last_state_change_timestamp_seconds", "Unix timestamp of the last status change (or of the first probe after start)", &prometheus.Labels{
+				"environment": cfg.Metrics.Environment,
+			}),
+			baseEndpointLabels,
+		),
+
+		EndpointConsecutiveFailures: prometheus.NewGaugeVec(
+			opts("endpoint_consecutive_failures", "Number of failed probes in a row (0 after a successful probe)", &prometheus.Labels{
+				"environment": cfg.Metrics.Environment,
+			}),
+			baseEndpointLabels,
+		),
+
+		EndpointAvailability: prometheus.NewGaugeVec(
+			opts("endpoint_availability_ratio", "Ratio of successful probes over a sliding window (0..1)", &prometheus.Labels{
+				"environment": cfg.Metrics.Environment,
+			}),
+			availabilityLabels,
+		),
+
+		EndpointValidation: prometheus.NewGaugeVec(
 			opts("endpoint_validation", "Endpoint validation status (includes TLS error types)", &prometheus.Labels{
 				"environment": cfg.Metrics.Environment,
 			}),
 			endpointResultLabels,
 		),
 
-		EndpointDuration: promauto.NewGaugeVec(
+		EndpointDuration: prometheus.NewGaugeVec(
 			opts("endpoint_duration_seconds", "Duration of endpoint test in seconds", &prometheus.Labels{
 				"environment": cfg.Metrics.Environment,
 			}),
 			endpointResultLabels,
 		),
 
-		EndpointTLSCertDaysLeft: promauto.NewGaugeVec(
+		EndpointDurationHistogram: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: cfg.Metrics.Namespace,
+				Subsystem: cfg.Metrics.Subsystem,
+				Name:      cfg.Metrics.MetricName("endpoint_probe_duration_seconds"),
+				Help:      "Distribution of endpoint probe durations (native histogram)",
+				ConstLabels: prometheus.Labels{
+					"environment": cfg.Metrics.Environment,
+				},
+				NativeHistogramBucketFactor:     1.1,
+				NativeHistogramMaxBucketNumber:  160,
+				NativeHistogramMinResetDuration: time.Hour,
+			},
+			baseEndpointLabels,
+		),
+
+		EndpointPhaseHistogram: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: cfg.Metrics.Namespace,
+				Subsystem: cfg.Metrics.Subsystem,
+				Name:      cfg.Metrics.MetricName("endpoint_probe_phase_duration_seconds"),
+				Help:      "Distribution of HTTP probe phase durations: dns, connect, tls, ttfb (native histogram)",
+				ConstLabels: prometheus.Labels{
+					"environment": cfg.Metrics.Environment,
+				},
+				NativeHistogramBucketFactor:     1.1,
+				NativeHistogramMaxBucketNumber:  160,
+				NativeHistogramMinResetDuration: time.Hour,
+			},
+			withBase("phase"),
+		),
+
+		EndpointDurationSummary: prometheus.NewSummaryVec(
+			prometheus.SummaryOpts{
+				Namespace: cfg.Metrics.Namespace,
+				Subsystem: cfg.Metrics.Subsystem,
+				Name:      cfg.Metrics.MetricName("endpoint_duration_summary_seconds"),
+				Help:      "Quantiles of endpoint probe durations",
+				ConstLabels: prometheus.Labels{
+					"environment": cfg.Metrics.Environment,
+				},
+				Objectives: cfg.Metrics.DurationSummaryObjectives,
+			},
+			baseEndpointLabels,
+		),
+
+		EndpointHeaderMatch: prometheus.NewGaugeVec(
+			opts("endpoint_header_match", "Whether all expected response headers matched (1/0; absent if not configured)", &prometheus.Labels{
+				"environment": cfg.Metrics.Environment,
+			}),
+			baseEndpointLabels,
+		),
+
+		EndpointBodyMatch: prometheus.NewGaugeVec(
+			opts("endpoint_body_match", "Whether the response body matched body-regex (1/0; absent if not configured)", &prometheus.Labels{
+				"environment": cfg.Metrics.Environment,
+			}),
+			baseEndpointLabels,
+		),
+
+		EndpointTLSPinValid: prometheus.NewGaugeVec(
+			opts("endpoint_tls_pin_valid", "Whether the leaf key matched pin-sha256 (1/0; absent if not configured)", &prometheus.Labels{
+				"environment": cfg.Metrics.Environment,
+			}),
+			baseEndpointLabels,
+		),
+
+		EndpointSLOMet: prometheus.NewGaugeVec(
+			opts("endpoint_slo_met", "Whether the probe completed within max-duration (1/0; absent if not configured)", &prometheus.Labels{
+				"environment": cfg.Metrics.Environment,
+			}),
+			baseEndpointLabels,
+		),
+
+		EndpointMaintenance: prometheus.NewGaugeVec(
+			opts("endpoint_maintenance", "Whether the last probe ran inside a maintenance window with mode probe (1/0)", &prometheus.Labels{
+				"environment": cfg.Metrics.Environment,
+			}),
+			baseEndpointLabels,
+		),
+
+		EndpointRawPassed: prometheus.NewGaugeVec(
+			opts("endpoint_raw_passed", "Whether the last probe itself passed (1/0), before failure-threshold and success-threshold", &prometheus.Labels{
+				"environment": cfg.Metrics.Environment,
+			}),
+			baseEndpointLabels,
+		),
+
+		EndpointProbeSuppressed: prometheus.NewGaugeVec(
+			opts("endpoint_probe_suppressed", "Probing is currently skipped, by reason (maintenance, paused, dependency)", &prometheus.Labels{
+				"environment": cfg.Metrics.Environment,
+			}),
+			suppressedLabels,
+		),
+
+		EndpointRemoteIPInfo: prometheus.NewGaugeVec(
+			opts("endpoint_remote_ip_info", "Remote IP the last probe connected to (always 1)", &prometheus.Labels{
+				"environment": cfg.Metrics.Environment,
+			}),
+			remoteIPLabels,
+		),
+
+		EndpointRedirects: prometheus.NewGaugeVec(
+			opts("endpoint_redirects", "Number of redirects followed by the last probe", &prometheus.Labels{
+				"environment": cfg.Metrics.Environment,
+			}),
+			baseEndpointLabels,
+		),
+
+		EndpointIPv6Fallback: prometheus.NewGaugeVec(
+			opts("endpoint_ipv6_fallback", "Whether the last probe fell back from IPv6 to IPv4 (1/0; absent without a connection)", &prometheus.Labels{
+				"environment": cfg.Metrics.Environment,
+			}),
+			baseEndpointLabels,
+		),
+
+		EndpointResponseHeaderInfo: prometheus.NewGaugeVec(
+			opts("endpoint_response_header_info", "Value of a response header listed in validation.capture-headers (always 1)", &prometheus.Labels{
+				"environment": cfg.Metrics.Environment,
+			}),
+			headerInfoLabels,
+		),
+
+		EndpointErrorClass: prometheus.NewGaugeVec(
+			opts("endpoint_error_class", "Class of the last probe's failure: dns, connect, tls, timeout, http, config, other (always 1; absent while passing)", &prometheus.Labels{
+				"environment": cfg.Metrics.Environment,
+			}),
+			errorClassLabels,
+		),
+
+		EndpointTLSCertDaysLeft: prometheus.NewGaugeVec(
 			opts("endpoint_tls_cert_days_left", "Days until certificate expiration (by chain position)", &prometheus.Labels{
 				"environment": cfg.Metrics.Environment,
 			}),
 			certLabels,
 		),
+
+		EndpointTLSCertNotAfter: prometheus.NewGaugeVec(
+			opts("endpoint_tls_cert_not_after_timestamp_seconds", "Unix timestamp of certificate expiration (by chain position)", &prometheus.Labels{
+				"environment": cfg.Metrics.Environment,
+			}),
+			certLabels,
+		),
+
+		EndpointTLSInfo: prometheus.NewGaugeVec(
+			opts("endpoint_tls_info", "Negotiated TLS connection parameters (always 1)", &prometheus.Labels{
+				"environment": cfg.Metrics.Environment,
+			}),
+			tlsInfoLabels,
+		),
+
+		EndpointStateTransitions: prometheus.NewCounterVec(
+			prometheus.CounterOpts(opts("endpoint_state_transitions_total", "Number of probe status changes (from -> to)", &prometheus.Labels{
+				"environment": cfg.Metrics.Environment,
+			})),
+			transitionLabels,
+		),
+
+		EndpointProbeAttempts: prometheus.NewCounterVec(
+			prometheus.CounterOpts(opts("endpoint_probe_attempts_total", "Number of probe attempts, retries included", &prometheus.Labels{
+				"environment": cfg.Metrics.Environment,
+			})),
+			baseEndpointLabels,
+		),
+
+		RouteUp: prometheus.NewGaugeVec(
+			opts("route_up", "Whether the route canary passed (1/0), i.e. the route itself works", &prometheus.Labels{
+				"environment": cfg.Metrics.Environment,
+			}),
+			[]string{"route"},
+		),
+
+		RouteDuration: prometheus.NewGaugeVec(
+			opts("route_duration_seconds", "Duration of the last route canary probe in seconds", &prometheus.Labels{
+				"environment": cfg.Metrics.Environment,
+			}),
+			[]string{"route"},
+		),
+
+		GroupProbeDuration: prometheus.NewSummaryVec(
+			prometheus.SummaryOpts{
+				Namespace: cfg.Metrics.Namespace,
+				Subsystem: cfg.Metrics.Subsystem,
+				Name:      cfg.Metrics.MetricName("group_probe_duration_seconds"),
+				Help:      "Count and sum of probe durations per group",
+				ConstLabels: prometheus.Labels{
+					"environment": cfg.Metrics.Environment,
+				},
+			},
+			[]string{"group"},
+		),
+
+		GroupProbeFailures: prometheus.NewCounterVec(
+			prometheus.CounterOpts(opts("group_probe_failures_total", "Number of failed probes per group", &prometheus.Labels{
+				"environment": cfg.Metrics.Environment,
+			})),
+			[]string{"group"},
+		),
 	}
 
+	// Niche metrics that must be turned on explicitly (metrics.enabled or a dedicated flag).
+	offByDefault := map[string]bool{
+		"endpoint_probe_duration_seconds":       !cfg.Metrics.NativeHistograms,
+		"endpoint_probe_phase_duration_seconds": !cfg.Metrics.NativeHistograms,
+		"endpoint_duration_summary_seconds":     len(cfg.Metrics.DurationSummaryObjectives) == 0,
+		"group_probe_duration_seconds":          true,
+		"group_probe_failures_total":            true,
+		"endpoint_error_class":                  true,
+	}
+	m.register(offByDefault, map[string]prometheus.Collector{
+		"build_info":                                    m.BuildInfo,
+		"endpoint_last_probe_timestamp_seconds":         m.EndpointLastProbeTimestamp,
+		"endpoint_last_state_change_timestamp_seconds":  m.EndpointLastStateChange,
+		"endpoint_consecutive_failures":                 m.EndpointConsecutiveFailures,
+		"endpoint_availability_ratio":                   m.EndpointAvailability,
+		"endpoint_validation":                           m.EndpointValidation,
+		"endpoint_duration_seconds":                     m.EndpointDuration,
+		"endpoint_probe_duration_seconds":               m.EndpointDurationHistogram,
+		"endpoint_probe_phase_duration_seconds":         m.EndpointPhaseHistogram,
+		"endpoint_duration_summary_seconds":             m.EndpointDurationSummary,
+		"endpoint_header_match":                         m.EndpointHeaderMatch,
+		"endpoint_body_match":                           m.EndpointBodyMatch,
+		"endpoint_tls_pin_valid":                        m.EndpointTLSPinValid,
+		"endpoint_slo_met":                              m.EndpointSLOMet,
+		"endpoint_maintenance":                          m.EndpointMaintenance,
+		"endpoint_raw_passed":                           m.EndpointRawPassed,
+		"endpoint_probe_suppressed":                     m.EndpointProbeSuppressed,
+		"endpoint_remote_ip_info":                       m.EndpointRemoteIPInfo,
+		"endpoint_redirects":                            m.EndpointRedirects,
+		"endpoint_ipv6_fallback":                        m.EndpointIPv6Fallback,
+		"endpoint_response_header_info":                 m.EndpointResponseHeaderInfo,
+		"endpoint_error_class":                          m.EndpointErrorClass,
+		"endpoint_tls_cert_days_left":                   m.EndpointTLSCertDaysLeft,
+		"endpoint_tls_cert_not_after_timestamp_seconds": m.EndpointTLSCertNotAfter,
+		"endpoint_tls_info":                             m.EndpointTLSInfo,
+		"endpoint_state_transitions_total":              m.EndpointStateTransitions,
+		"endpoint_probe_attempts_total":                 m.EndpointProbeAttempts,
+		"route_up":                                      m.RouteUp,
+		"route_duration_seconds":                        m.RouteDuration,
+		"group_probe_duration_seconds":                  m.GroupProbeDuration,
+		"group_probe_failures_total":                    m.GroupProbeFailures,
+	})
+
 	m.BuildInfo.With(nil).Set(1)
 	return m
 }
 
+// register registers the enabled collectors (keyed by default metric name) with m.registerer.
+// Disabled collectors stay unregistered and their series are not updated.
+func (m *WDMetrics) register(offByDefault map[string]bool, collectors map[string]prometheus.Collector) {
+	m.enabled = make(map[string]bool, len(collectors))
+	for name, c := range collectors {
+		if name != "build_info" && !m.cfg.Metrics.MetricEnabled(name, !offByDefault[name]) {
+			continue
+		}
+		m.registerer.MustRegister(c)
+		m.enabled[name] = true
+	}
+}
+
 // OnResult updates all metrics for a single probe result.
 func (m *WDMetrics) OnResult(r prober.Result) {
-	isErr := "false"
-	if r.Err != nil {
-		isErr = "true"
+	m.countTransition(r)
+	m.countAttempts(r)
+	m.observeDuration(r)
+	m.aggregateGroup(r)
+	m.setSeries(r)
+}
+
+// OnRouteResult updates the route canary metrics.
+// Route results are not part of the provider snapshot, so RebuildAll keeps these series.
+func (m *WDMetrics) OnRouteResult(r prober.RouteResult) {
+	if m.enabled["route_up"] {
+		up := 0.0
+		if r.Up() {
+			up = 1
+		}
+		m.RouteUp.WithLabelValues(r.Route).Set(up)
 	}
+	if m.enabled["route_duration_seconds"] {
+		m.RouteDuration.WithLabelValues(r.Route).Set(r.Duration)
+	}
+}
 
-	lblBase := prometheus.Labels{
-		"group":    r.Group,
-		"endpoint": r.Endpoint,
-		"protocol": r.Protocol,
-		"url":      r.URL,
-		"route":    r.Route,
+// OnRouteRemoved drops the series of a route canary removed by a config reload.
+func (m *WDMetrics) OnRouteRemoved(routeName string) {
+	m.RouteUp.DeleteLabelValues(routeName)
+	m.RouteDuration.DeleteLabelValues(routeName)
+}
+
+// aggregateGroup updates the optional per-group aggregates (cumulative, not replayed by RebuildAll).
+func (m *WDMetrics) aggregateGroup(r prober.Result) {
+	if m.enabled["group_probe_duration_seconds"] {
+		m.GroupProbeDuration.WithLabelValues(r.Group).Observe(r.Duration)
+	}
+	if m.enabled["group_probe_failures_total"] {
+		c := m.GroupProbeFailures.WithLabelValues(r.Group)
+		if r.Failed() {
+			c.Inc()
+		}
+	}
+}
+
+// countAttempts adds the attempts of a result (1 + the retries it took) to the attempts counter.
+// Counters are cumulative, so this is not replayed by RebuildAll.
+func (m *WDMetrics) countAttempts(r prober.Result) {
+	if r.Attempts == 0 || !m.enabled["endpoint_probe_attempts_total"] {
+		return
+	}
+	es := m.seriesOf(r)
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	if es.attempts == nil {
+		es.attempts = m.EndpointProbeAttempts.With(es.base)
+	}
+	es.attempts.Add(float64(r.Attempts))
+}
+
+// observeDuration records the probe duration in the histogram and summary, and the phase timings in theirs.
+// Like counters, observations are cumulative and not replayed by RebuildAll.
+func (m *WDMetrics) observeDuration(r prober.Result) {
+	histogramOn := m.enabled["endpoint_probe_duration_seconds"]
+	summaryOn := m.enabled["endpoint_duration_summary_seconds"]
+	phasesOn := m.enabled["endpoint_probe_phase_duration_seconds"]
+	if !histogramOn && !summaryOn && !phasesOn {
+		return
+	}
+	es := m.seriesOf(r)
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	if histogramOn {
+		if es.histogram == nil {
+			es.histogram = m.EndpointDurationHistogram.With(es.base)
+		}
+		es.histogram.Observe(r.Duration)
+	}
+	if summaryOn {
+		if es.summary == nil {
+			es.summary = m.EndpointDurationSummary.With(es.base)
+		}
+		es.summary.Observe(r.Duration)
 	}
-	m.EndpointLastProbeTimestamp.With(lblBase).Set(float64(r.At.Unix()))
+	if phasesOn {
+		// a phase the probe did not go through is not observed
+		for phase, seconds := range map[string]float64{"dns": r.Phases.DNS, "connect": r.Phases.Connect, "tls": r.Phases.TLS, "ttfb": r.Phases.TTFB} {
+			if seconds <= 0 {
+				continue
+			}
+			obs, ok := es.phases[phase]
+			if !ok {
+				if es.phases == nil {
+					es.phases = make(map[string]prometheus.Observer)
+				}
+				obs = m.EndpointPhaseHistogram.With(es.withBase("phase", phase))
+				es.phases[phase] = obs
+			}
+			obs.Observe(seconds)
+		}
+	}
+}
 
-	status := deriveStatus(r)
-	lblAll := prometheus.Labels{
+// OnSuppressed tracks why an endpoint+route is not being probed (Reason "" clears it).
+// Suppression is not part of the provider snapshot, so RebuildAll keeps these series.
+func (m *WDMetrics) OnSuppressed(s prober.Suppression) {
+	if !m.enabled["endpoint_probe_suppressed"] {
+		return
+	}
+	key := s.Group + "\x00" + s.Endpoint + "\x00" + s.Protocol + "\x00" + s.URL + "\x00" + s.Route
+
+	m.lastSupprMu.Lock()
+	defer m.lastSupprMu.Unlock()
+	if prev, ok := m.lastSupprByKey[key]; ok {
+		m.EndpointProbeSuppressed.Delete(prev)
+		delete(m.lastSupprByKey, key)
+	}
+	if s.Reason == "" {
+		return
+	}
+	lbl := prometheus.Labels{
+		"group":    s.Group,
+		"endpoint": s.Endpoint,
+		"protocol": s.Protocol,
+		"url":      s.URL,
+		"route":    s.Route,
+		"reason":   s.Reason,
+	}
+	if m.ipLabel {
+		lbl["ip"] = ""
+	}
+	m.addCustomLabels(lbl, s.Labels)
+	m.EndpointProbeSuppressed.With(lbl).Set(1)
+	m.lastSupprByKey[key] = lbl
+}
+
+// countTransition increments the transition counter when the probe status changed.
+// Counters are cumulative, so this is not replayed by RebuildAll.
+func (m *WDMetrics) countTransition(r prober.Result) {
+	if r.PrevStatus == "" || r.PrevStatus == r.Status || !m.enabled["endpoint_state_transitions_total"] {
+		return
+	}
+	lbl := prometheus.Labels{
 		"group":    r.Group,
 		"endpoint": r.Endpoint,
 		"protocol": r.Protocol,
 		"url":      r.URL,
 		"route":    r.Route,
-		"status":   status,
-		"is_error": isErr,
+		"from":     string(r.PrevStatus),
+		"to":       string(r.Status),
+	}
+	if m.ipLabel {
+		lbl["ip"] = r.IP
 	}
+	m.addCustomLabels(lbl, r.Labels)
+	m.EndpointStateTransitions.With(lbl).Inc()
+}
+
+// endpointSeries caches the metric children of one endpoint+route. A probe whose labels are unchanged only sets
+// values; series are deleted and re-created only when a label value (status, IP, TLS, certificate) changes.
+type endpointSeries struct {
+	mu   sync.Mutex
+	base prometheus.Labels
+
+	lastProbe    prometheus.Gauge
+	lastChange   prometheus.Gauge
+	consecutive  prometheus.Gauge
+	redirects    prometheus.Gauge
+	ipv6Fallback prometheus.Gauge
+	histogram    prometheus.Observer
+	attempts     prometheus.Counter
+	summary      prometheus.Observer
+	phases       map[string]prometheus.Observer
+	availability map[string]prometheus.Gauge
+
+	status     string // status + is_error of the current validation/duration series
+	statusLbl  prometheus.Labels
+	validation prometheus.Gauge
+	duration   prometheus.Gauge
 
+	ipLbl      prometheus.Labels
+	headerLbls map[string]prometheus.Labels // captured header name -> labels of its info series
+	classLbl   prometheus.Labels
+	tlsInfoLbl prometheus.Labels
+	certSig    string
+	certLbls   []prometheus.Labels
+	certDays   []prometheus.Gauge
+	certAfter  []prometheus.Gauge
+}
+
+// seriesOf returns the cached series of r's endpoint+route, creating it on first use.
+func (m *WDMetrics) seriesOf(r prober.Result) *endpointSeries {
 	key := baseKeyOf(r)
+	m.seriesMu.Lock()
+	defer m.seriesMu.Unlock()
+	es, ok := m.seriesByKey[key]
+	if !ok {
+		es = &endpointSeries{base: prometheus.Labels{
+			"group":    r.Group,
+			"endpoint": r.Endpoint,
+			"protocol": r.Protocol,
+			"url":      r.URL,
+			"route":    r.Route,
+		}}
+		if m.ipLabel {
+			es.base["ip"] = r.IP
+		}
+		m.addCustomLabels(es.base, r.Labels)
+		m.seriesByKey[key] = es
+	}
+	return es
+}
 
-	// Remove old metric series for this endpoint key.
-	m.lastMu.Lock()
-	if prev, ok := m.lastByKey[key]; ok {
-		m.EndpointValidation.Delete(prev)
-		m.EndpointDuration.Delete(prev)
+// addCustomLabels sets the endpoint labels of the config on lbl, "" for the ones the endpoint does not define.
+func (m *WDMetrics) addCustomLabels(lbl prometheus.Labels, values map[string]string) {
+	for _, name := range m.customLabels {
+		lbl[name] = values[name]
 	}
-	m.lastByKey[key] = lblAll
-	m.lastMu.Unlock()
+}
 
-	// Set new metrics.
-	m.EndpointValidation.With(lblAll).Set(1)
-	m.EndpointDuration.With(lblAll).Set(r.Duration)
+// withBase copies the base labels plus extra name/value pairs.
+func (es *endpointSeries) withBase(kv ...string) prometheus.Labels {
+	lbl := make(prometheus.Labels, len(es.base)+len(kv)/2)
+	for k, v := range es.base {
+		lbl[k] = v
+	}
+	for i := 0; i+1 < len(kv); i += 2 {
+		lbl[kv[i]] = kv[i+1]
+	}
+	return lbl
+}
 
-	// Handle certificates (TLS chain details).
-	if r.TLS != nil && r.TLS.HadTLS {
-		m.lastCertMu.Lock()
-		if prevs, ok := m.lastCertByKey[key]; ok {
-			for _, pl := range prevs {
-				m.EndpointTLSCertDaysLeft.Delete(pl)
+// setSeries sets the last-value series for a single probe result.
+func (m *WDMetrics) setSeries(r prober.Result) {
+	m.rebuildMu.RLock()
+	defer m.rebuildMu.RUnlock()
+	m.updateSeries(r)
+}
+
+// updateSeries does setSeries; callers hold rebuildMu.
+func (m *WDMetrics) updateSeries(r prober.Result) {
+	es := m.seriesOf(r)
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	if m.enabled["endpoint_last_probe_timestamp_seconds"] {
+		if es.lastProbe == nil {
+			es.lastProbe = m.EndpointLastProbeTimestamp.With(es.base)
+		}
+		es.lastProbe.Set(float64(r.At.Unix()))
+	}
+	if m.enabled["endpoint_last_state_change_timestamp_seconds"] && !r.StatusSince.IsZero() {
+		if es.lastChange == nil {
+			es.lastChange = m.EndpointLastStateChange.With(es.base)
+		}
+		es.lastChange.Set(float64(r.StatusSince.Unix()))
+	}
+	if m.enabled["endpoint_consecutive_failures"] {
+		if es.consecutive == nil {
+			es.consecutive = m.EndpointConsecutiveFailures.With(es.base)
+		}
+		es.consecutive.Set(float64(r.ConsecutiveFailures))
+	}
+	if m.enabled["endpoint_redirects"] {
+		if es.redirects == nil {
+			es.redirects = m.EndpointRedirects.With(es.base)
+		}
+		es.redirects.Set(float64(r.Redirects))
+	}
+	if m.enabled["endpoint_ipv6_fallback"] && r.RemoteIP != "" {
+		if es.ipv6Fallback == nil {
+			es.ipv6Fallback = m.EndpointIPv6Fallback.With(es.base)
+		}
+		fallback := 0.0
+		if r.IPv6Fallback {
+			fallback = 1
+		}
+		es.ipv6Fallback.Set(fallback)
+	}
+	if m.enabled["endpoint_header_match"] {
+		setOptionalBool(m.EndpointHeaderMatch, es.base, r.HeaderMatch)
+	}
+	if m.enabled["endpoint_body_match"] {
+		setOptionalBool(m.EndpointBodyMatch, es.base, r.BodyMatch)
+	}
+	if m.enabled["endpoint_tls_pin_valid"] {
+		setOptionalBool(m.EndpointTLSPinValid, es.base, r.PinMatch)
+	}
+	if m.enabled["endpoint_slo_met"] {
+		setOptionalBool(m.EndpointSLOMet, es.base, r.SLOMet)
+	}
+	if m.enabled["endpoint_maintenance"] {
+		setOptionalBool(m.EndpointMaintenance, es.base, &r.Maintenance)
+	}
+	if m.enabled["endpoint_raw_passed"] {
+		var passed *bool
+		if r.RawStatus != "" {
+			p := !r.RawStatus.Failed()
+			passed = &p
+		}
+		setOptionalBool(m.EndpointRawPassed, es.base, passed)
+	}
+	if m.enabled["endpoint_availability_ratio"] {
+		for window, ratio := range r.Availability {
+			g, ok := es.availability[window]
+			if !ok {
+				if es.availability == nil {
+					es.availability = make(map[string]prometheus.Gauge)
+				}
+				g = m.EndpointAvailability.With(es.withBase("window", window))
+				es.availability[window] = g
 			}
+			g.Set(ratio)
 		}
-		m.lastCertByKey[key] = m.buildAndSetCertSeries(r)
-		m.lastCertMu.Unlock()
+	}
+
+	m.setStatusSeries(es, r)
+	if m.enabled["endpoint_error_class"] {
+		m.setErrorClassSeries(es, r)
+	}
+	if m.enabled["endpoint_response_header_info"] {
+		m.setHeaderSeries(es, r.Headers)
+	}
+	if m.enabled["endpoint_remote_ip_info"] && r.RemoteIP != "" {
+		m.setRemoteIPSeries(es, r.RemoteIP)
+	}
+	if r.TLS != nil && r.TLS.HadTLS {
+		m.setTLSInfoSeries(es, r)
+		m.setCertSeries(es, r)
 	} else {
-		// No TLS: remove any previous certificate series.
-		m.lastCertMu.Lock()
-		if prevs, ok := m.lastCertByKey[key]; ok {
-			for _, pl := range prevs {
-				m.EndpointTLSCertDaysLeft.Delete(pl)
-			}
-			delete(m.lastCertByKey, key)
+		// No TLS: remove any previous TLS series.
+		m.deleteTLSSeries(es)
+	}
+}
+
+// setStatusSeries sets validation and duration, replacing their series only when status or is_error changed.
+func (m *WDMetrics) setStatusSeries(es *endpointSeries, r prober.Result) {
+	isErr := "false"
+	if r.ErrorText() != "" {
+		isErr = "true"
+	}
+	status := string(r.Status)
+	if es.statusLbl == nil || es.status != status+"\x00"+isErr {
+		if es.statusLbl != nil {
+			m.EndpointValidation.Delete(es.statusLbl)
+			m.EndpointDuration.Delete(es.statusLbl)
 		}
-		m.lastCertMu.Unlock()
+		es.status = status + "\x00" + isErr
+		es.statusLbl = es.withBase("status", status, "is_error", isErr)
+		es.validation, es.duration = nil, nil
+		if m.enabled["endpoint_validation"] {
+			es.validation = m.EndpointValidation.With(es.statusLbl)
+		}
+		if m.enabled["endpoint_duration_seconds"] {
+			es.duration = m.EndpointDuration.With(es.statusLbl)
+		}
+	}
+	if es.validation != nil {
+		es.validation.Set(1)
+	}
+	if es.duration != nil {
+		es.duration.Set(r.Duration)
 	}
 }
 
-// buildAndSetCertSeries sets TLS certificate expiration metrics and returns the created label sets.
-func (m *WDMetrics) buildAndSetCertSeries(r prober.Result) []prometheus.Labels {
-	out := make([]prometheus.Labels, 0, len(r.TLS.Certificates))
+// setRemoteIPSeries keeps the last known address, replacing the series when it changes.
+func (m *WDMetrics) setRemoteIPSeries(es *endpointSeries, ip string) {
+	if es.ipLbl != nil && es.ipLbl[m.remoteIPLabel] == ip {
+		return
+	}
+	if es.ipLbl != nil {
+		m.EndpointRemoteIPInfo.Delete(es.ipLbl)
+	}
+	es.ipLbl = es.withBase(m.remoteIPLabel, ip)
+	m.EndpointRemoteIPInfo.With(es.ipLbl).Set(1)
+}
+
+// setHeaderSeries keeps one info series per captured header, replacing it when the value changes and deleting it
+// when the header is no longer in the response.
+func (m *WDMetrics) setHeaderSeries(es *endpointSeries, headers map[string]string) {
+	for name, lbl := range es.headerLbls {
+		if value, ok := headers[name]; !ok || lbl["value"] != value {
+			m.EndpointResponseHeaderInfo.Delete(lbl)
+			delete(es.headerLbls, name)
+		}
+	}
+	for name, value := range headers {
+		if _, ok := es.headerLbls[name]; ok {
+			continue
+		}
+		if es.headerLbls == nil {
+			es.headerLbls = make(map[string]prometheus.Labels, len(headers))
+		}
+		lbl := es.withBase("header", name, "value", value)
+		m.EndpointResponseHeaderInfo.With(lbl).Set(1)
+		es.headerLbls[name] = lbl
+	}
+}
+
+// setErrorClassSeries keeps one series with the class of the current failure and removes it on success.
+func (m *WDMetrics) setErrorClassSeries(es *endpointSeries, r prober.Result) {
+	class := string(r.ErrorClass)
+	if class == "" && r.Failed() {
+		// results built outside the engine carry no class
+		class = string(validator.ClassifyError(r.Status, r.Err))
+	}
+	if es.classLbl != nil && es.classLbl["error_class"] == class {
+		return
+	}
+	if es.classLbl != nil {
+		m.EndpointErrorClass.Delete(es.classLbl)
+		es.classLbl = nil
+	}
+	if class != "" {
+		es.classLbl = es.withBase("error_class", class)
+		m.EndpointErrorClass.With(es.classLbl).Set(1)
+	}
+}
+
+// setTLSInfoSeries sets the TLS connection info metric, replacing it when version, cipher or ALPN changed.
+func (m *WDMetrics) setTLSInfoSeries(es *endpointSeries, r prober.Result) {
+	if es.tlsInfoLbl != nil && es.tlsInfoLbl["tls_version"] == r.TLS.Version &&
+		es.tlsInfoLbl["cipher"] == r.TLS.CipherSuite && es.tlsInfoLbl["alpn"] == r.TLS.ALPN {
+		return
+	}
+	if es.tlsInfoLbl != nil {
+		m.EndpointTLSInfo.Delete(es.tlsInfoLbl)
+	}
+	es.tlsInfoLbl = es.withBase("tls_version", r.TLS.Version, "cipher", r.TLS.CipherSuite, "alpn", r.TLS.ALPN)
+	if m.enabled["endpoint_tls_info"] {
+		m.EndpointTLSInfo.With(es.tlsInfoLbl).Set(1)
+	}
+}
+
+// setCertSeries sets TLS certificate expiration metrics; the series are rebuilt only when the chain changed.
+func (m *WDMetrics) setCertSeries(es *endpointSeries, r prober.Result) {
+	daysLeftOn := m.enabled["endpoint_tls_cert_days_left"]
+	notAfterOn := m.enabled["endpoint_tls_cert_not_after_timestamp_seconds"]
+	if !daysLeftOn && !notAfterOn {
+		return
+	}
+	var sig strings.Builder
 	for _, c := range r.TLS.Certificates {
-		lblCert := prometheus.Labels{
-			"group":          r.Group,
-			"endpoint":       r.Endpoint,
-			"protocol":       r.Protocol,
-			"url":            r.URL,
-			"route":          r.Route,
-			"cert_position":  strconv.Itoa(c.Position),
-			"cert_serial":    c.SerialHex,
-			"cert_cn":        c.CommonName,
-			"cert_is_ca":     fmt.Sprintf("%v", c.IsCA),
-			"cert_issuer_cn": c.IssuerCN,
+		sig.WriteString(c.SerialHex)
+		sig.WriteByte(0)
+		sig.WriteString(c.CommonName)
+		sig.WriteByte(0)
+		sig.WriteString(c.IssuerCN)
+		sig.WriteByte(0)
+	}
+	if es.certLbls == nil || es.certSig != sig.String() {
+		m.deleteCertSeries(es)
+		es.certSig = sig.String()
+		es.certLbls = make([]prometheus.Labels, 0, len(r.TLS.Certificates))
+		es.certDays = make([]prometheus.Gauge, 0, len(r.TLS.Certificates))
+		es.certAfter = make([]prometheus.Gauge, 0, len(r.TLS.Certificates))
+		for _, c := range r.TLS.Certificates {
+			lblCert := es.withBase(
+				"cert_position", strconv.Itoa(c.Position),
+				"cert_serial", c.SerialHex,
+				"cert_cn", c.CommonName,
+				"cert_is_ca", fmt.Sprintf("%v", c.IsCA),
+				"cert_issuer_cn", c.IssuerCN,
+			)
+			var days, after prometheus.Gauge
+			if daysLeftOn {
+				days = m.EndpointTLSCertDaysLeft.With(lblCert)
+			}
+			if notAfterOn {
+				after = m.EndpointTLSCertNotAfter.With(lblCert)
+			}
+			es.certLbls = append(es.certLbls, lblCert)
+			es.certDays = append(es.certDays, days)
+			es.certAfter = append(es.certAfter, after)
 		}
-		m.EndpointTLSCertDaysLeft.With(lblCert).Set(c.DaysLeft)
-		out = append(out, lblCert)
 	}
-	return out
+	for i, c := range r.TLS.Certificates {
+		if es.certDays[i] != nil {
+			es.certDays[i].Set(c.DaysLeft)
+		}
+		if es.certAfter[i] != nil {
+			es.certAfter[i].Set(float64(c.NotAfter.Unix()))
+		}
+	}
+}
+
+func (m *WDMetrics) deleteCertSeries(es *endpointSeries) {
+	for _, pl := range es.certLbls {
+		m.EndpointTLSCertDaysLeft.Delete(pl)
+		m.EndpointTLSCertNotAfter.Delete(pl)
+	}
+	es.certSig, es.certLbls, es.certDays, es.certAfter = "", nil, nil, nil
+}
+
+func (m *WDMetrics) deleteTLSSeries(es *endpointSeries) {
+	m.deleteCertSeries(es)
+	if es.tlsInfoLbl != nil {
+		m.EndpointTLSInfo.Delete(es.tlsInfoLbl)
+		es.tlsInfoLbl = nil
+	}
 }
 
-// RebuildAll fully resets and rebuilds metrics from the provider snapshot.
+// OnRemoved deletes every series of a result key that no longer exists (an address gone from DNS).
+// Cumulative series (transitions, attempts, histogram, summary) are deleted too, as nothing will update them again.
+func (m *WDMetrics) OnRemoved(r prober.Result) {
+	m.rebuildMu.RLock()
+	defer m.rebuildMu.RUnlock()
+	key := baseKeyOf(r)
+	m.seriesMu.Lock()
+	es, ok := m.seriesByKey[key]
+	delete(m.seriesByKey, key)
+	m.seriesMu.Unlock()
+	if !ok {
+		return
+	}
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	for _, vec := range []interface{ DeletePartialMatch(prometheus.Labels) int }{
+		m.EndpointValidation, m.EndpointDuration, m.EndpointLastProbeTimestamp, m.EndpointLastStateChange,
+		m.EndpointConsecutiveFailures, m.EndpointAvailability, m.EndpointHeaderMatch, m.EndpointBodyMatch,
+		m.EndpointTLSPinValid, m.EndpointSLOMet, m.EndpointMaintenance, m.EndpointRawPassed, m.EndpointRemoteIPInfo, m.EndpointRedirects, m.EndpointIPv6Fallback, m.EndpointResponseHeaderInfo, m.EndpointErrorClass, m.EndpointTLSCertDaysLeft,
+		m.EndpointTLSCertNotAfter, m.EndpointTLSInfo, m.EndpointStateTransitions, m.EndpointProbeAttempts,
+		m.EndpointDurationHistogram, m.EndpointPhaseHistogram, m.EndpointDurationSummary,
+	} {
+		vec.DeletePartialMatch(es.base)
+	}
+}
+
+// RebuildAll fully resets and rebuilds metrics from the provider snapshot. Results arriving meanwhile wait for it, so
+// they are neither written to the children being reset nor overwritten by the older snapshot.
 func (m *WDMetrics) RebuildAll() {
+	m.rebuildMu.Lock()
+	defer m.rebuildMu.Unlock()
 	results := m.provider.Snapshot()
 
 	m.EndpointValidation.Reset()
 	m.EndpointDuration.Reset()
 	m.EndpointLastProbeTimestamp.Reset()
+	m.EndpointLastStateChange.Reset()
+	m.EndpointConsecutiveFailures.Reset()
+	m.EndpointAvailability.Reset()
+	m.EndpointHeaderMatch.Reset()
+	m.EndpointBodyMatch.Reset()
+	m.EndpointTLSPinValid.Reset()
+	m.EndpointSLOMet.Reset()
+	m.EndpointMaintenance.Reset()
+	m.EndpointRawPassed.Reset()
+	m.EndpointRemoteIPInfo.Reset()
+	m.EndpointRedirects.Reset()
+	m.EndpointIPv6Fallback.Reset()
+	m.EndpointResponseHeaderInfo.Reset()
+	m.EndpointErrorClass.Reset()
 	m.EndpointTLSCertDaysLeft.Reset()
+	m.EndpointTLSCertNotAfter.Reset()
+	m.EndpointTLSInfo.Reset()
 
-	m.lastMu.Lock()
-	m.lastByKey = make(map[string]prometheus.Labels)
-	m.lastMu.Unlock()
-
-	m.lastCertMu.Lock()
-	m.lastCertByKey = make(map[string][]prometheus.Labels)
-	m.lastCertMu.Unlock()
+	// The cached children belong to the vectors just reset.
+	m.seriesMu.Lock()
+	m.seriesByKey = make(map[string]*endpointSeries)
+	m.seriesMu.Unlock()
 
 	for _, r := range results {
-		m.OnResult(r)
+		m.updateSeries(r)
 	}
 }
 
 // Helpers
 
-// baseKeyOf builds a unique key for a given endpoint ignoring status/is_error.
-func baseKeyOf(r prober.Result) string {
-	return r.Group + "\x00" + r.Endpoint + "\x00" + r.Protocol + "\x00" + r.URL + "\x00" + r.Route
-}
-
-// mapTLSErrorToStatus maps known TLS handshake error strings to a stable status.
-func mapTLSErrorToStatus(errMsg string) string {
-	errMsg = strings.ToLower(errMsg)
-	switch {
-	case strings.Contains(errMsg, "expired-cert-leaf"):
-		return "expired-cert-leaf"
-	case strings.Contains(errMsg, "invalid-tls-chain"):
-		return "invalid-tls-chain"
-	case strings.Contains(errMsg, "invalid-tls-hostname"):
-		return "invalid-tls-hostname"
-	case strings.Contains(errMsg, "invalid-tls-certificate"):
-		return "invalid-tls-certificate"
-	case strings.Contains(errMsg, "unknownauthorityerror"):
-		return "invalid-tls-unknown-authority"
-	case strings.Contains(errMsg, "certificateinvaliderror"):
-		return "invalid-tls-certificate"
-	case strings.Contains(errMsg, "hostnameerror"):
-		return "invalid-tls-hostname"
-	case strings.Contains(errMsg, "handshake"):
-		return "invalid-tls-handshake"
-	case strings.Contains(errMsg, "tls"):
-		return "invalid-tls-other"
-	default:
-		return ""
+// setOptionalBool sets a 1/0 gauge, or removes the series when the value is not known.
+func setOptionalBool(g *prometheus.GaugeVec, lbl prometheus.Labels, v *bool) {
+	if v == nil {
+		g.Delete(lbl)
+		return
 	}
-}
-
-// deriveStatus determines the final validation status for a given probe result.
-func deriveStatus(r prober.Result) string {
-	// Prefer TLS-related classification.
-	if r.TLS != nil {
-		if !r.TLS.HadTLS {
-			return "invalid-tls-missing"
-		}
-		if !r.TLS.ChainValid {
-			return "invalid-tls-chain"
-		}
-	}
-
-	if r.Err != nil {
-		if tlsStatus := mapTLSErrorToStatus(r.Err.Error()); tlsStatus != "" {
-			return tlsStatus
-		}
-	}
-
-	if r.Status != "" {
-		return r.Status
-	}
-
-	if r.Err != nil {
-		return "unknown-error"
+	if *v {
+		g.With(lbl).Set(1)
+	} else {
+		g.With(lbl).Set(0)
 	}
+}
 
-	return "valid"
+// baseKeyOf builds a unique key for a given endpoint ignoring status/is_error.
+func baseKeyOf(r prober.Result) string {
+	return r.Group + "\x00" + r.Endpoint + "\x00" + r.Protocol + "\x00" + r.URL + "\x00" + r.Route + "\x00" + r.IP
 }
@@ -1,21 +1,28 @@
 package metrics
 
 import (
+	"context"
 	"errors"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/testutil"
 
-	"watchdog_exporter/config"
-	"watchdog_exporter/prober"
-	"watchdog_exporter/validator"
+	"github.com/kinjelom/watchdog_exporter/config"
+	"github.com/kinjelom/watchdog_exporter/prober"
+	"github.com/kinjelom/watchdog_exporter/status"
+	"github.com/kinjelom/watchdog_exporter/validator"
 )
 
 func TestBuildInfoMetric(t *testing.T) {
 	cfg := makeBasicConfig()
-	m := NewWDMetrics("myprog", "v1.2.3", cfg, newFakeProvider())
+	m := NewWDMetrics(BuildInfo{ProgramName: "myprog", Version: "v1.2.3", Commit: "abc123", BuildDate: "2025-01-01T00:00:00Z", GoVersion: "go1.24.1"}, cfg, newFakeProvider())
 	t.Cleanup(func() { unregisterMetrics(m) })
 
 	// set the gauge value (already set to 1 in constructor, ale ustawiamy jawnie)
@@ -24,11 +31,202 @@ func TestBuildInfoMetric(t *testing.T) {
 	if got := testutil.ToFloat64(m.BuildInfo.With(nil)); got != 1 {
 		t.Fatalf("expected BuildInfo 1, got %v", got)
 	}
+	expected := `
+# HELP ns_build_info Program build information
+# TYPE ns_build_info gauge
+ns_build_info{build_date="2025-01-01T00:00:00Z",commit="abc123",go_version="go1.24.1",program_name="myprog",program_version="v1.2.3"} 1
+`
+	if err := testutil.CollectAndCompare(m.BuildInfo, strings.NewReader(expected)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMetricNames_SubsystemAndOverrides(t *testing.T) {
+	cfg := makeBasicConfig()
+	cfg.Metrics.Namespace = "company"
+	cfg.Metrics.Subsystem = "synthetics"
+	cfg.Metrics.Names = map[string]string{"endpoint_validation": "probe_status"}
+	m := NewWDMetrics(BuildInfo{ProgramName: "prog", Version: "ver"}, cfg, newFakeProvider())
+	t.Cleanup(func() { unregisterMetrics(m) })
+
+	m.OnResult(prober.Result{Group: "g", Endpoint: "ep", Protocol: "http", URL: "http://a", Route: "r", Status: "valid"})
+
+	if got := testutil.CollectAndCount(m.EndpointValidation, "company_synthetics_probe_status"); got != 1 {
+		t.Fatalf("expected 1 series named company_synthetics_probe_status, got %d", got)
+	}
+	if got := testutil.CollectAndCount(m.EndpointDuration, "company_synthetics_endpoint_duration_seconds"); got != 1 {
+		t.Fatalf("expected 1 series named company_synthetics_endpoint_duration_seconds, got %d", got)
+	}
+}
+
+func TestMetricsEnabled_DisabledMetricIsNotExported(t *testing.T) {
+	cfg := makeBasicConfig()
+	cfg.Metrics.Enabled = map[string]bool{"endpoint_tls_cert_days_left": false}
+	m := NewWDMetrics(BuildInfo{ProgramName: "prog", Version: "ver"}, cfg, newFakeProvider())
+	t.Cleanup(func() { unregisterMetrics(m) })
+
+	m.OnResult(prober.Result{
+		Group: "g", Endpoint: "ep", Protocol: "https", URL: "https://a", Route: "r", Status: "valid",
+		TLS: &validator.CertsReport{
+			HadTLS:       true,
+			ChainValid:   true,
+			Certificates: []validator.CertInfo{{Position: 0, SerialHex: "01", DaysLeft: 10}},
+		},
+	})
+
+	if got := testutil.CollectAndCount(m.EndpointTLSCertDaysLeft); got != 0 {
+		t.Fatalf("expected no endpoint_tls_cert_days_left series, got %d", got)
+	}
+	if prometheus.Unregister(m.EndpointTLSCertDaysLeft) {
+		t.Fatal("disabled metric must not be registered")
+	}
+	if got := testutil.CollectAndCount(m.EndpointValidation); got != 1 {
+		t.Fatalf("expected enabled endpoint_validation to be set, got %d series", got)
+	}
+}
+
+func TestNativeHistogram_OffByDefault(t *testing.T) {
+	cfg := makeBasicConfig()
+	m := NewWDMetrics(BuildInfo{ProgramName: "prog", Version: "ver"}, cfg, newFakeProvider())
+	t.Cleanup(func() { unregisterMetrics(m) })
+
+	m.OnResult(prober.Result{Group: "g", Endpoint: "ep", Protocol: "http", URL: "http://a", Route: "r", Status: "valid", Duration: 0.2})
+	if got := testutil.CollectAndCount(m.EndpointDurationHistogram); got != 0 {
+		t.Fatalf("expected no histogram series without native-histograms, got %d", got)
+	}
+	if got := testutil.CollectAndCount(m.EndpointPhaseHistogram); got != 0 {
+		t.Fatalf("expected no phase histogram series without native-histograms, got %d", got)
+	}
+}
+
+func TestNativeHistogram_ObservesDuration(t *testing.T) {
+	cfg := makeBasicConfig()
+	cfg.Metrics.NativeHistograms = true
+	m := NewWDMetrics(BuildInfo{ProgramName: "prog", Version: "ver"}, cfg, newFakeProvider())
+	t.Cleanup(func() { unregisterMetrics(m) })
+
+	r := prober.Result{Group: "g", Endpoint: "ep", Protocol: "http", URL: "http://a", Route: "r", Status: "valid", Duration: 0.2}
+	m.OnResult(r)
+	r.Duration = 0.4
+	m.OnResult(r)
+
+	mf, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range mf {
+		if f.GetName() != "ns_endpoint_probe_duration_seconds" {
+			continue
+		}
+		h := f.GetMetric()[0].GetHistogram()
+		if h.GetSampleCount() != 2 {
+			t.Fatalf("expected 2 observations, got %d", h.GetSampleCount())
+		}
+		if len(h.GetPositiveSpan()) == 0 {
+			t.Fatal("expected native histogram buckets")
+		}
+		return
+	}
+	t.Fatal("ns_endpoint_probe_duration_seconds not registered")
+}
+
+func TestNativeHistogram_ObservesPhases(t *testing.T) {
+	cfg := makeBasicConfig()
+	cfg.Metrics.NativeHistograms = true
+	m := NewWDMetrics(BuildInfo{ProgramName: "prog", Version: "ver"}, cfg, newFakeProvider())
+	t.Cleanup(func() { unregisterMetrics(m) })
+
+	r := prober.Result{Group: "g", Endpoint: "ep", Protocol: "http", URL: "http://a", Route: "r", Status: "valid", Duration: 0.2,
+		Phases: validator.Phases{DNS: 0.01, Connect: 0.02, TTFB: 0.15}}
+	m.OnResult(r)
+	r.Phases = validator.Phases{Connect: 0.03, TTFB: 0.1} // cached address
+	m.OnResult(r)
+
+	mf, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := map[string]uint64{}
+	for _, f := range mf {
+		if f.GetName() != "ns_endpoint_probe_phase_duration_seconds" {
+			continue
+		}
+		for _, metric := range f.GetMetric() {
+			for _, lp := range metric.GetLabel() {
+				if lp.GetName() == "phase" {
+					counts[lp.GetValue()] = metric.GetHistogram().GetSampleCount()
+				}
+			}
+		}
+	}
+	want := map[string]uint64{"dns": 1, "connect": 2, "ttfb": 2}
+	if !maps.Equal(counts, want) {
+		t.Fatalf("phase observations = %v, want %v (tls not gone through)", counts, want)
+	}
+
+	m.OnRemoved(r)
+	if got := testutil.CollectAndCount(m.EndpointPhaseHistogram); got != 0 {
+		t.Fatalf("expected the phase series removed with the key, got %d", got)
+	}
+}
+
+func TestGroupAggregates_OptIn(t *testing.T) {
+	cfg := makeBasicConfig()
+	cfg.Metrics.Enabled = map[string]bool{
+		"group_probe_duration_seconds": true,
+		"group_probe_failures_total":   true,
+		"endpoint_validation":          false,
+		"endpoint_duration_seconds":    false,
+	}
+	m := NewWDMetrics(BuildInfo{ProgramName: "prog", Version: "ver"}, cfg, newFakeProvider())
+	t.Cleanup(func() { unregisterMetrics(m) })
+
+	m.OnResult(prober.Result{Group: "g", Endpoint: "ep1", Route: "r", Status: "valid", Duration: 0.5})
+	m.OnResult(prober.Result{Group: "g", Endpoint: "ep2", Route: "r", Status: "request-execution-timeout", Duration: 1.5, Err: errors.New("timeout")})
+
+	expected := `
+# HELP ns_group_probe_duration_seconds Count and sum of probe durations per group
+# TYPE ns_group_probe_duration_seconds summary
+ns_group_probe_duration_seconds_sum{environment="env",group="g"} 2
+ns_group_probe_duration_seconds_count{environment="env",group="g"} 2
+# HELP ns_group_probe_failures_total Number of failed probes per group
+# TYPE ns_group_probe_failures_total counter
+ns_group_probe_failures_total{environment="env",group="g"} 1
+`
+	if err := testutil.GatherAndCompare(prometheus.DefaultGatherer, strings.NewReader(expected),
+		"ns_group_probe_duration_seconds", "ns_group_probe_failures_total"); err != nil {
+		t.Fatal(err)
+	}
+	if got := testutil.CollectAndCount(m.EndpointValidation); got != 0 {
+		t.Fatalf("expected per-endpoint validation series to be disabled, got %d", got)
+	}
+}
+
+func TestDurationSummary_ConfiguredObjectives(t *testing.T) {
+	cfg := makeBasicConfig()
+	cfg.Metrics.DurationSummaryObjectives = map[float64]float64{0.5: 0.05}
+	m := NewWDMetrics(BuildInfo{ProgramName: "prog", Version: "ver"}, cfg, newFakeProvider())
+	t.Cleanup(func() { unregisterMetrics(m) })
+
+	for _, d := range []float64{0.1, 0.2, 0.3} {
+		m.OnResult(prober.Result{Group: "g", Endpoint: "ep", Protocol: "http", URL: "http://a", Route: "r", Status: "valid", Duration: d})
+	}
+
+	expected := `
+# HELP ns_endpoint_duration_summary_seconds Quantiles of endpoint probe durations
+# TYPE ns_endpoint_duration_summary_seconds summary
+ns_endpoint_duration_summary_seconds{endpoint="ep",environment="env",group="g",protocol="http",route="r",url="http://a",quantile="0.5"} 0.2
+ns_endpoint_duration_summary_seconds_sum{endpoint="ep",environment="env",group="g",protocol="http",route="r",url="http://a"} 0.6000000000000001
+ns_endpoint_duration_summary_seconds_count{endpoint="ep",environment="env",group="g",protocol="http",route="r",url="http://a"} 3
+`
+	if err := testutil.CollectAndCompare(m.EndpointDurationSummary, strings.NewReader(expected)); err != nil {
+		t.Fatal(err)
+	}
 }
 
 func TestEndpointValidationMetric(t *testing.T) {
 	cfg := makeBasicConfig()
-	m := NewWDMetrics("prog", "ver", cfg, newFakeProvider())
+	m := NewWDMetrics(BuildInfo{ProgramName: "prog", Version: "ver"}, cfg, newFakeProvider())
 	t.Cleanup(func() { unregisterMetrics(m) })
 
 	labels := prometheus.Labels{
@@ -49,7 +247,7 @@ func TestEndpointValidationMetric(t *testing.T) {
 
 func TestEndpointDurationMetric(t *testing.T) {
 	cfg := makeBasicConfig()
-	m := NewWDMetrics("prog", "ver", cfg, newFakeProvider())
+	m := NewWDMetrics(BuildInfo{ProgramName: "prog", Version: "ver"}, cfg, newFakeProvider())
 	t.Cleanup(func() { unregisterMetrics(m) })
 
 	labels := prometheus.Labels{
@@ -70,7 +268,7 @@ func TestEndpointDurationMetric(t *testing.T) {
 
 func TestOnResult_SetsLastProbeTimestamp(t *testing.T) {
 	cfg := makeBasicConfig()
-	m := NewWDMetrics("prog", "ver", cfg, newFakeProvider())
+	m := NewWDMetrics(BuildInfo{ProgramName: "prog", Version: "ver"}, cfg, newFakeProvider())
 	t.Cleanup(func() { unregisterMetrics(m) })
 
 	// Fixed timestamp to avoid flakiness
@@ -106,9 +304,300 @@ func TestOnResult_SetsLastProbeTimestamp(t *testing.T) {
 	}
 }
 
+func TestOnResult_ProbeAllIPsAddsIPLabel(t *testing.T) {
+	cfg := makeBasicConfig()
+	cfg.Endpoints["ep"] = config.Endpoint{ProbeAllIPs: true}
+	cfg.Metrics.Namespace = "perip" // the default registry keeps the label names of a metric name after unregistering
+	m := NewWDMetrics(BuildInfo{ProgramName: "prog", Version: "ver"}, cfg, newFakeProvider())
+	t.Cleanup(func() { unregisterMetrics(m) })
+
+	at := time.Unix(1700000000, 0)
+	for _, ip := range []string{"10.0.0.1", "10.0.0.2"} {
+		m.OnResult(prober.Result{Group: "g", Endpoint: "ep", Protocol: "http", URL: "http://a", Route: "r", IP: ip,
+			Status: status.Valid, At: at, RemoteIP: ip})
+	}
+	m.OnRemoved(prober.Result{Group: "g", Endpoint: "ep", Protocol: "http", URL: "http://a", Route: "r", IP: "10.0.0.2"})
+
+	expected := `
+# HELP perip_endpoint_last_probe_timestamp_seconds Unix timestamp of the last probe
+# TYPE perip_endpoint_last_probe_timestamp_seconds gauge
+perip_endpoint_last_probe_timestamp_seconds{endpoint="ep",environment="env",group="g",ip="10.0.0.1",protocol="http",route="r",url="http://a"} 1.7e+09
+`
+	if err := testutil.CollectAndCompare(m.EndpointLastProbeTimestamp, strings.NewReader(expected)); err != nil {
+		t.Fatal(err)
+	}
+	expected = `
+# HELP perip_endpoint_remote_ip_info Remote IP the last probe connected to (always 1)
+# TYPE perip_endpoint_remote_ip_info gauge
+perip_endpoint_remote_ip_info{endpoint="ep",environment="env",group="g",ip="10.0.0.1",protocol="http",remote_ip="10.0.0.1",route="r",url="http://a"} 1
+`
+	if err := testutil.CollectAndCompare(m.EndpointRemoteIPInfo, strings.NewReader(expected)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOnResult_AddsEndpointLabels(t *testing.T) {
+	cfg := makeBasicConfig()
+	cfg.Endpoints["pay"] = config.Endpoint{Labels: map[string]string{"team": "payments", "tier": "critical"}}
+	cfg.Endpoints["web"] = config.Endpoint{Labels: map[string]string{"team": "web"}}
+	cfg.Metrics.Namespace = "custom" // the default registry keeps the label names of a metric name after unregistering
+	m := NewWDMetrics(BuildInfo{ProgramName: "prog", Version: "ver"}, cfg, newFakeProvider())
+	t.Cleanup(func() { unregisterMetrics(m) })
+
+	at := time.Unix(1700000000, 0)
+	m.OnResult(prober.Result{Group: "g", Endpoint: "pay", Protocol: "http", URL: "http://p", Route: "r",
+		Labels: cfg.Endpoints["pay"].Labels, Status: status.Valid, At: at})
+	m.OnResult(prober.Result{Group: "g", Endpoint: "web", Protocol: "http", URL: "http://w", Route: "r",
+		Labels: cfg.Endpoints["web"].Labels, Status: status.Valid, At: at})
+
+	expected := `
+# HELP custom_endpoint_last_probe_timestamp_seconds Unix timestamp of the last probe
+# TYPE custom_endpoint_last_probe_timestamp_seconds gauge
+custom_endpoint_last_probe_timestamp_seconds{endpoint="pay",environment="env",group="g",protocol="http",route="r",team="payments",tier="critical",url="http://p"} 1.7e+09
+custom_endpoint_last_probe_timestamp_seconds{endpoint="web",environment="env",group="g",protocol="http",route="r",team="web",tier="",url="http://w"} 1.7e+09
+`
+	if err := testutil.CollectAndCompare(m.EndpointLastProbeTimestamp, strings.NewReader(expected)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOnResult_CountsAttempts(t *testing.T) {
+	cfg := makeBasicConfig()
+	m := NewWDMetrics(BuildInfo{ProgramName: "prog", Version: "ver"}, cfg, newFakeProvider())
+	t.Cleanup(func() { unregisterMetrics(m) })
+
+	r := prober.Result{Group: "g", Endpoint: "e", Protocol: "http", URL: "http://e", Route: "r", Status: status.Valid, Attempts: 3}
+	m.OnResult(r)
+	r.Attempts = 1
+	m.OnResult(r)
+
+	expected := `
+# HELP ns_endpoint_probe_attempts_total Number of probe attempts, retries included
+# TYPE ns_endpoint_probe_attempts_total counter
+ns_endpoint_probe_attempts_total{endpoint="e",environment="env",group="g",protocol="http",route="r",url="http://e"} 4
+`
+	if err := testutil.CollectAndCompare(m.EndpointProbeAttempts, strings.NewReader(expected)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOnResult_SetsRawPassed(t *testing.T) {
+	cfg := makeBasicConfig()
+	m := NewWDMetrics(BuildInfo{ProgramName: "prog", Version: "ver"}, cfg, newFakeProvider())
+	t.Cleanup(func() { unregisterMetrics(m) })
+
+	// failure-threshold still holds the valid status
+	m.OnResult(prober.Result{Group: "g", Endpoint: "e", Protocol: "http", URL: "http://e", Route: "r",
+		Status: status.Valid, RawStatus: status.RequestExecutionTimeout})
+
+	expected := `
+# HELP ns_endpoint_raw_passed Whether the last probe itself passed (1/0), before failure-threshold and success-threshold
+# TYPE ns_endpoint_raw_passed gauge
+ns_endpoint_raw_passed{endpoint="e",environment="env",group="g",protocol="http",route="r",url="http://e"} 0
+`
+	if err := testutil.CollectAndCompare(m.EndpointRawPassed, strings.NewReader(expected)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOnRouteResult_SetsRouteUp(t *testing.T) {
+	cfg := makeBasicConfig()
+	m := NewWDMetrics(BuildInfo{ProgramName: "prog", Version: "ver"}, cfg, newFakeProvider())
+	t.Cleanup(func() { unregisterMetrics(m) })
+
+	m.OnRouteResult(prober.RouteResult{Route: "direct", Status: status.Valid, Duration: 0.25})
+	m.OnRouteResult(prober.RouteResult{Route: "via-proxy-eu", Status: status.InvalidRequestExecution, Err: errors.New("proxyconnect tcp: connection refused"), Duration: 0.5})
+
+	expected := `
+# HELP ns_route_up Whether the route canary passed (1/0), i.e. the route itself works
+# TYPE ns_route_up gauge
+ns_route_up{environment="env",route="direct"} 1
+ns_route_up{environment="env",route="via-proxy-eu"} 0
+`
+	if err := testutil.CollectAndCompare(m.RouteUp, strings.NewReader(expected)); err != nil {
+		t.Fatal(err)
+	}
+	if got := testutil.ToFloat64(m.RouteDuration.WithLabelValues("via-proxy-eu")); got != 0.5 {
+		t.Fatalf("route duration = %v, want 0.5", got)
+	}
+
+	m.OnRouteRemoved("via-proxy-eu")
+	if n := testutil.CollectAndCount(m.RouteUp); n != 1 {
+		t.Fatalf("route_up series after removal = %d, want 1", n)
+	}
+}
+
+func TestOnResult_SetsLastStateChange(t *testing.T) {
+	cfg := makeBasicConfig()
+	m := NewWDMetrics(BuildInfo{ProgramName: "prog", Version: "ver"}, cfg, newFakeProvider())
+	t.Cleanup(func() { unregisterMetrics(m) })
+
+	since := time.Unix(1700000000, 0)
+	m.OnResult(prober.Result{Group: "g", Endpoint: "ep", Protocol: "http", URL: "http://a", Route: "r",
+		Status: status.Valid, At: since.Add(time.Hour), StatusSince: since})
+
+	expected := `
+# HELP ns_endpoint_last_state_change_timestamp_seconds Unix timestamp of the last status change (or of the first probe after start)
+# TYPE ns_endpoint_last_state_change_timestamp_seconds gauge
+ns_endpoint_last_state_change_timestamp_seconds{endpoint="ep",environment="env",group="g",protocol="http",route="r",url="http://a"} 1.7e+09
+`
+	if err := testutil.CollectAndCompare(m.EndpointLastStateChange, strings.NewReader(expected)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOnResult_SetsConsecutiveFailures(t *testing.T) {
+	cfg := makeBasicConfig()
+	m := NewWDMetrics(BuildInfo{ProgramName: "prog", Version: "ver"}, cfg, newFakeProvider())
+	t.Cleanup(func() { unregisterMetrics(m) })
+
+	r := prober.Result{
+		Group:               "g",
+		Endpoint:            "ep",
+		Protocol:            "http",
+		URL:                 "http://example.io",
+		Route:               "r",
+		Status:              "request-execution-timeout",
+		ConsecutiveFailures: 3,
+		At:                  time.Unix(1700000000, 0),
+	}
+	m.OnResult(r)
+
+	lblBase := prometheus.Labels{
+		"group":    "g",
+		"endpoint": "ep",
+		"protocol": "http",
+		"url":      "http://example.io",
+		"route":    "r",
+	}
+	if got := testutil.ToFloat64(m.EndpointConsecutiveFailures.With(lblBase)); got != 3 {
+		t.Fatalf("endpoint_consecutive_failures got %v, want 3", got)
+	}
+}
+
+func TestOnResult_SetsContentMatches(t *testing.T) {
+	cfg := makeBasicConfig()
+	m := NewWDMetrics(BuildInfo{ProgramName: "prog", Version: "ver"}, cfg, newFakeProvider())
+	t.Cleanup(func() { unregisterMetrics(m) })
+
+	headerOK, bodyOK := true, false
+	r := prober.Result{
+		Group:       "g",
+		Endpoint:    "ep",
+		Protocol:    "http",
+		URL:         "http://example.io",
+		Route:       "r",
+		Status:      "unexpected-body-regex",
+		HeaderMatch: &headerOK,
+		BodyMatch:   &bodyOK,
+		PinMatch:    &headerOK,
+		SLOMet:      &bodyOK,
+	}
+	m.OnResult(r)
+
+	lblBase := prometheus.Labels{
+		"group":    "g",
+		"endpoint": "ep",
+		"protocol": "http",
+		"url":      "http://example.io",
+		"route":    "r",
+	}
+	if got := testutil.ToFloat64(m.EndpointHeaderMatch.With(lblBase)); got != 1 {
+		t.Fatalf("endpoint_header_match got %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(m.EndpointBodyMatch.With(lblBase)); got != 0 {
+		t.Fatalf("endpoint_body_match got %v, want 0", got)
+	}
+	if got := testutil.ToFloat64(m.EndpointTLSPinValid.With(lblBase)); got != 1 {
+		t.Fatalf("endpoint_tls_pin_valid got %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(m.EndpointSLOMet.With(lblBase)); got != 0 {
+		t.Fatalf("endpoint_slo_met got %v, want 0", got)
+	}
+
+	// body check no longer configured -> series removed
+	r.BodyMatch = nil
+	m.OnResult(r)
+	if got := testutil.CollectAndCount(m.EndpointBodyMatch); got != 0 {
+		t.Fatalf("expected endpoint_body_match to be removed, got %d series", got)
+	}
+}
+
+func TestOnSuppressed_SetsAndClearsReason(t *testing.T) {
+	cfg := makeBasicConfig()
+	m := NewWDMetrics(BuildInfo{ProgramName: "prog", Version: "ver"}, cfg, newFakeProvider())
+	t.Cleanup(func() { unregisterMetrics(m) })
+
+	s := prober.Suppression{Group: "g", Endpoint: "ep", Protocol: "http", URL: "http://a", Route: "r", Reason: prober.SuppressedPaused}
+	m.OnSuppressed(s)
+
+	lbl := prometheus.Labels{"group": "g", "endpoint": "ep", "protocol": "http", "url": "http://a", "route": "r", "reason": "paused"}
+	if got := testutil.ToFloat64(m.EndpointProbeSuppressed.With(lbl)); got != 1 {
+		t.Fatalf("endpoint_probe_suppressed{reason=paused} got %v, want 1", got)
+	}
+
+	s.Reason = ""
+	m.OnSuppressed(s)
+	if got := testutil.CollectAndCount(m.EndpointProbeSuppressed); got != 0 {
+		t.Fatalf("expected suppression series to be removed, got %d", got)
+	}
+}
+
+func TestOnResult_RemoteIPInfoFollowsBackend(t *testing.T) {
+	cfg := makeBasicConfig()
+	m := NewWDMetrics(BuildInfo{ProgramName: "prog", Version: "ver"}, cfg, newFakeProvider())
+	t.Cleanup(func() { unregisterMetrics(m) })
+
+	r := prober.Result{Group: "g", Endpoint: "ep", Protocol: "http", URL: "http://a", Route: "r", Status: "valid", RemoteIP: "10.0.0.1"}
+	m.OnResult(r)
+	r.RemoteIP = "10.0.0.2"
+	m.OnResult(r)
+
+	if got := testutil.CollectAndCount(m.EndpointRemoteIPInfo); got != 1 {
+		t.Fatalf("expected 1 endpoint_remote_ip_info series, got %d", got)
+	}
+	lbl := prometheus.Labels{"group": "g", "endpoint": "ep", "protocol": "http", "url": "http://a", "route": "r", "ip": "10.0.0.2"}
+	if got := testutil.ToFloat64(m.EndpointRemoteIPInfo.With(lbl)); got != 1 {
+		t.Fatalf("endpoint_remote_ip_info{ip=10.0.0.2} got %v, want 1", got)
+	}
+}
+
+func TestOnResult_SetsAvailabilityPerWindow(t *testing.T) {
+	cfg := makeBasicConfig()
+	m := NewWDMetrics(BuildInfo{ProgramName: "prog", Version: "ver"}, cfg, newFakeProvider())
+	t.Cleanup(func() { unregisterMetrics(m) })
+
+	m.OnResult(prober.Result{
+		Group:        "g",
+		Endpoint:     "ep",
+		Protocol:     "http",
+		URL:          "http://example.io",
+		Route:        "r",
+		Status:       "valid",
+		At:           time.Unix(1700000000, 0),
+		Availability: map[string]float64{"1h": 0.5, "30d": 0.99},
+	})
+
+	lbl := prometheus.Labels{
+		"group":    "g",
+		"endpoint": "ep",
+		"protocol": "http",
+		"url":      "http://example.io",
+		"route":    "r",
+		"window":   "1h",
+	}
+	if got := testutil.ToFloat64(m.EndpointAvailability.With(lbl)); got != 0.5 {
+		t.Fatalf("endpoint_availability_ratio{window=1h} got %v, want 0.5", got)
+	}
+	lbl["window"] = "30d"
+	if got := testutil.ToFloat64(m.EndpointAvailability.With(lbl)); got != 0.99 {
+		t.Fatalf("endpoint_availability_ratio{window=30d} got %v, want 0.99", got)
+	}
+}
+
 func TestOnResult_TLS_OK_UsesEndpointValidation(t *testing.T) {
 	cfg := makeBasicConfig()
-	m := NewWDMetrics("prog", "ver", cfg, newFakeProvider())
+	m := NewWDMetrics(BuildInfo{ProgramName: "prog", Version: "ver"}, cfg, newFakeProvider())
 	t.Cleanup(func() { unregisterMetrics(m) })
 
 	r := prober.Result{
@@ -146,7 +635,7 @@ func TestOnResult_TLS_OK_UsesEndpointValidation(t *testing.T) {
 
 func TestOnResult_TLSCertDaysLeft_ForLeaf(t *testing.T) {
 	cfg := makeBasicConfig()
-	m := NewWDMetrics("prog", "ver", cfg, newFakeProvider())
+	m := NewWDMetrics(BuildInfo{ProgramName: "prog", Version: "ver"}, cfg, newFakeProvider())
 	t.Cleanup(func() { unregisterMetrics(m) })
 
 	r := prober.Result{
@@ -169,8 +658,8 @@ func TestOnResult_TLSCertDaysLeft_ForLeaf(t *testing.T) {
 					CommonName: "example.io",
 					IsCA:       false,
 					IssuerCN:   "R12",
-					// NotAfter not needed in this metric
-					DaysLeft: 10.0,
+					NotAfter:   time.Unix(1700864000, 0),
+					DaysLeft:   10.0,
 				},
 			},
 		},
@@ -194,6 +683,119 @@ func TestOnResult_TLSCertDaysLeft_ForLeaf(t *testing.T) {
 	if got := testutil.ToFloat64(m.EndpointTLSCertDaysLeft.With(lblCert)); got != 10.0 {
 		t.Fatalf("endpoint_tls_cert_days_left got %v, want 10.0", got)
 	}
+	if got := testutil.ToFloat64(m.EndpointTLSCertNotAfter.With(lblCert)); got != 1700864000 {
+		t.Fatalf("endpoint_tls_cert_not_after_timestamp_seconds got %v, want 1700864000", got)
+	}
+}
+
+func TestOnResult_ResponseHeaderInfoFollowsValues(t *testing.T) {
+	cfg := makeBasicConfig()
+	m := NewWDMetrics(BuildInfo{ProgramName: "prog", Version: "ver"}, cfg, newFakeProvider())
+	t.Cleanup(func() { unregisterMetrics(m) })
+
+	r := prober.Result{Group: "g", Endpoint: "ep", Protocol: "http", URL: "http://a", Route: "r", Status: status.Valid,
+		Headers: map[string]string{"X-Build-Version": "1.4.2", "Via": "1.1 pop-waw"}}
+	m.OnResult(r)
+	r.Headers = map[string]string{"X-Build-Version": "1.4.3"} // new build, Via gone
+	m.OnResult(r)
+
+	expected := `
+# HELP ns_endpoint_response_header_info Value of a response header listed in validation.capture-headers (always 1)
+# TYPE ns_endpoint_response_header_info gauge
+ns_endpoint_response_header_info{endpoint="ep",environment="env",group="g",header="X-Build-Version",protocol="http",route="r",url="http://a",value="1.4.3"} 1
+`
+	if err := testutil.CollectAndCompare(m.EndpointResponseHeaderInfo, strings.NewReader(expected)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOnResult_TLSInfo_ReplacedOnChange(t *testing.T) {
+	cfg := makeBasicConfig()
+	m := NewWDMetrics(BuildInfo{ProgramName: "prog", Version: "ver"}, cfg, newFakeProvider())
+	t.Cleanup(func() { unregisterMetrics(m) })
+
+	r := prober.Result{
+		Group:    "g",
+		Endpoint: "ep",
+		Protocol: "https",
+		URL:      "https://example.io",
+		Route:    "r",
+		Status:   "valid",
+		At:       time.Unix(1700000000, 0),
+		TLS: &validator.CertsReport{
+			HadTLS:      true,
+			ChainValid:  true,
+			Version:     "TLS 1.2",
+			CipherSuite: "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256",
+			ALPN:        "h2",
+		},
+	}
+	m.OnResult(r)
+
+	lblInfo := prometheus.Labels{
+		"group":       "g",
+		"endpoint":    "ep",
+		"protocol":    "https",
+		"url":         "https://example.io",
+		"route":       "r",
+		"tls_version": "TLS 1.2",
+		"cipher":      "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256",
+		"alpn":        "h2",
+	}
+	if got := testutil.ToFloat64(m.EndpointTLSInfo.With(lblInfo)); got != 1 {
+		t.Fatalf("endpoint_tls_info got %v, want 1", got)
+	}
+
+	// A renegotiated version must replace the previous series, not add a second one.
+	r.TLS.Version = "TLS 1.3"
+	r.TLS.CipherSuite = "TLS_AES_128_GCM_SHA256"
+	m.OnResult(r)
+
+	if got := testutil.CollectAndCount(m.EndpointTLSInfo); got != 1 {
+		t.Fatalf("expected 1 endpoint_tls_info series, got %d", got)
+	}
+}
+
+func TestOnResult_CountsStateTransitions(t *testing.T) {
+	cfg := makeBasicConfig()
+	m := NewWDMetrics(BuildInfo{ProgramName: "prog", Version: "ver"}, cfg, newFakeProvider())
+	t.Cleanup(func() { unregisterMetrics(m) })
+
+	base := prober.Result{
+		Group:    "g",
+		Endpoint: "ep",
+		Protocol: "http",
+		URL:      "http://example.io",
+		Route:    "r",
+		At:       time.Unix(1700000000, 0),
+	}
+	// first probe, steady state, failure, steady failure, recovery
+	for _, st := range [][2]status.Status{{"", "valid"}, {"valid", "valid"}, {"valid", "unexpected-status-code"}, {"unexpected-status-code", "unexpected-status-code"}, {"unexpected-status-code", "valid"}} {
+		r := base
+		r.PrevStatus, r.Status = st[0], st[1]
+		m.OnResult(r)
+	}
+
+	lbl := func(from, to string) prometheus.Labels {
+		return prometheus.Labels{
+			"group":    "g",
+			"endpoint": "ep",
+			"protocol": "http",
+			"url":      "http://example.io",
+			"route":    "r",
+			"from":     from,
+			"to":       to,
+		}
+	}
+	if got := testutil.ToFloat64(m.EndpointStateTransitions.With(lbl("valid", "unexpected-status-code"))); got != 1 {
+		t.Fatalf("valid -> unexpected-status-code got %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(m.EndpointStateTransitions.With(lbl("unexpected-status-code", "valid"))); got != 1 {
+		t.Fatalf("unexpected-status-code -> valid got %v, want 1", got)
+	}
+	if got := testutil.CollectAndCount(m.EndpointStateTransitions); got != 2 {
+		t.Fatalf("expected 2 transition series, got %d", got)
+	}
 }
 
 func TestRebuildAll_FromProviderSnapshot(t *testing.T) {
@@ -206,7 +808,7 @@ func TestRebuildAll_FromProviderSnapshot(t *testing.T) {
 			Protocol: "https",
 			URL:      "https://svc.local",
 			Route:    "routeB",
-			Status:   "ok",
+			Status:   status.InvalidTLSChain, // as the engine resolved it
 			Duration: 0.99,
 			Err:      errors.New("ignored in labels but is_error=true"),
 			At:       time.Unix(1700001000, 0),
@@ -227,7 +829,7 @@ func TestRebuildAll_FromProviderSnapshot(t *testing.T) {
 		},
 	})
 
-	m := NewWDMetrics("prog", "ver", cfg, prov)
+	m := NewWDMetrics(BuildInfo{ProgramName: "prog", Version: "ver"}, cfg, prov)
 	t.Cleanup(func() { unregisterMetrics(m) })
 
 	// When
@@ -245,7 +847,7 @@ func TestRebuildAll_FromProviderSnapshot(t *testing.T) {
 		t.Fatalf("timestamp mismatch, got %v", got)
 	}
 
-	// endpointResultLabels: TLS chain invalid → status must be "invalid-tls-chain"
+	// endpointResultLabels: the status of the result is kept
 	lblAll := prometheus.Labels{
 		"group":    "g2",
 		"endpoint": "ep2",
@@ -326,6 +928,240 @@ func unregisterMetrics(m *WDMetrics) {
 	prometheus.Unregister(m.BuildInfo)
 	prometheus.Unregister(m.EndpointValidation)
 	prometheus.Unregister(m.EndpointDuration)
+	prometheus.Unregister(m.EndpointDurationHistogram)
+	prometheus.Unregister(m.EndpointPhaseHistogram)
+	prometheus.Unregister(m.EndpointDurationSummary)
+	prometheus.Unregister(m.EndpointHeaderMatch)
+	prometheus.Unregister(m.EndpointBodyMatch)
+	prometheus.Unregister(m.EndpointTLSPinValid)
+	prometheus.Unregister(m.EndpointSLOMet)
+	prometheus.Unregister(m.EndpointProbeSuppressed)
+	prometheus.Unregister(m.EndpointRemoteIPInfo)
+	prometheus.Unregister(m.EndpointRedirects)
+	prometheus.Unregister(m.EndpointIPv6Fallback)
+	prometheus.Unregister(m.EndpointMaintenance)
+	prometheus.Unregister(m.EndpointRawPassed)
+	prometheus.Unregister(m.EndpointProbeAttempts)
+	prometheus.Unregister(m.EndpointResponseHeaderInfo)
+	prometheus.Unregister(m.EndpointErrorClass)
 	prometheus.Unregister(m.EndpointLastProbeTimestamp)
+	prometheus.Unregister(m.EndpointLastStateChange)
+	prometheus.Unregister(m.EndpointConsecutiveFailures)
+	prometheus.Unregister(m.EndpointAvailability)
 	prometheus.Unregister(m.EndpointTLSCertDaysLeft)
+	prometheus.Unregister(m.EndpointTLSCertNotAfter)
+	prometheus.Unregister(m.EndpointTLSInfo)
+	prometheus.Unregister(m.EndpointStateTransitions)
+	prometheus.Unregister(m.RouteUp)
+	prometheus.Unregister(m.RouteDuration)
+	prometheus.Unregister(m.GroupProbeDuration)
+	prometheus.Unregister(m.GroupProbeFailures)
+}
+
+// blockingProvider holds Snapshot until release is closed, reporting on entered that it was called.
+type blockingProvider struct {
+	results []prober.Result
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (p *blockingProvider) Snapshot() []prober.Result {
+	close(p.entered)
+	<-p.release
+	return p.results
+}
+
+func TestRebuildAll_ResultsWaitForTheRebuild(t *testing.T) {
+	cfg := makeBasicConfig()
+	old := prober.Result{Group: "g", Endpoint: "ep", Protocol: "http", URL: "http://a", Route: "r", Status: "valid", At: time.Unix(100, 0)}
+	prov := &blockingProvider{results: []prober.Result{old}, entered: make(chan struct{}), release: make(chan struct{})}
+	m := NewWDMetrics(BuildInfo{ProgramName: "prog", Version: "ver"}, cfg, prov)
+	t.Cleanup(func() { unregisterMetrics(m) })
+	m.OnResult(old)
+
+	rebuilt := make(chan struct{})
+	go func() {
+		m.RebuildAll()
+		close(rebuilt)
+	}()
+	<-prov.entered
+	written := make(chan struct{})
+	newer := old
+	newer.At = time.Unix(200, 0)
+	go func() {
+		m.OnResult(newer)
+		close(written)
+	}()
+	select {
+	case <-written:
+		t.Fatal("a result was written while the series were being rebuilt")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(prov.release)
+	<-rebuilt
+	<-written
+
+	lbl := prometheus.Labels{"group": "g", "endpoint": "ep", "protocol": "http", "url": "http://a", "route": "r"}
+	if got := testutil.ToFloat64(m.EndpointLastProbeTimestamp.With(lbl)); got != 200 {
+		t.Fatalf("expected the newer result to win over the snapshot, got %v", got)
+	}
+}
+
+func TestOnResult_StatusChangeReplacesSeries(t *testing.T) {
+	cfg := makeBasicConfig()
+	m := NewWDMetrics(BuildInfo{ProgramName: "prog", Version: "ver"}, cfg, newFakeProvider())
+	t.Cleanup(func() { unregisterMetrics(m) })
+
+	r := prober.Result{Group: "g", Endpoint: "ep", Protocol: "http", URL: "http://a", Route: "r", Status: "valid", Duration: 0.1}
+	m.OnResult(r)
+	r.Duration = 0.2
+	m.OnResult(r)
+	if got := testutil.CollectAndCount(m.EndpointDuration); got != 1 {
+		t.Fatalf("expected 1 duration series for unchanged status, got %d", got)
+	}
+
+	r.Status, r.Err = "invalid", errors.New("boom")
+	m.OnResult(r)
+	expected := `
+# HELP ns_endpoint_validation Endpoint validation status (includes TLS error types)
+# TYPE ns_endpoint_validation gauge
+ns_endpoint_validation{endpoint="ep",environment="env",group="g",is_error="true",protocol="http",route="r",status="invalid",url="http://a"} 1
+`
+	if err := testutil.CollectAndCompare(m.EndpointValidation, strings.NewReader(expected)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOnResult_KeepsResolvedStatus(t *testing.T) {
+	cfg := makeBasicConfig()
+	cfg.Metrics.Enabled = map[string]bool{"endpoint_error_class": true}
+	m := NewWDMetrics(BuildInfo{ProgramName: "prog", Version: "ver"}, cfg, newFakeProvider())
+	t.Cleanup(func() { unregisterMetrics(m) })
+
+	// a failure inside a maintenance window, with a broken chain: TLS facts must not override the engine's status
+	r := prober.Result{Group: "g", Endpoint: "ep", Protocol: "https", URL: "https://a", Route: "r",
+		Status: status.Maintenance, RawStatus: status.InvalidTLSChain, TLS: &validator.CertsReport{HadTLS: true}}
+	m.OnResult(r)
+	expected := `
+# HELP ns_endpoint_validation Endpoint validation status (includes TLS error types)
+# TYPE ns_endpoint_validation gauge
+ns_endpoint_validation{endpoint="ep",environment="env",group="g",is_error="false",protocol="https",route="r",status="maintenance",url="https://a"} 1
+`
+	if err := testutil.CollectAndCompare(m.EndpointValidation, strings.NewReader(expected)); err != nil {
+		t.Fatal(err)
+	}
+	if got := testutil.CollectAndCount(m.EndpointErrorClass); got != 0 {
+		t.Fatalf("expected no error class series in maintenance, got %d", got)
+	}
+}
+
+func BenchmarkOnResult_Unchanged(b *testing.B) {
+	cfg := makeBasicConfig()
+	m := NewWDMetrics(BuildInfo{ProgramName: "prog", Version: "ver"}, cfg, newFakeProvider())
+	b.Cleanup(func() { unregisterMetrics(m) })
+	r := prober.Result{
+		Group: "g", Endpoint: "ep", Protocol: "https", URL: "https://a", Route: "r", Status: "valid",
+		Duration: 0.1, RemoteIP: "10.0.0.1", Availability: map[string]float64{"1h": 1},
+		TLS: &validator.CertsReport{
+			HadTLS: true, ChainValid: true, Version: "TLS 1.3",
+			Certificates: []validator.CertInfo{{Position: 0, SerialHex: "01", CommonName: "a", DaysLeft: 10}},
+		},
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		m.OnResult(r)
+	}
+}
+
+func TestOnResult_ErrorClass_OptInAndClearedOnSuccess(t *testing.T) {
+	cfg := makeBasicConfig()
+	cfg.Metrics.Enabled = map[string]bool{"endpoint_error_class": true}
+	m := NewWDMetrics(BuildInfo{ProgramName: "prog", Version: "ver"}, cfg, newFakeProvider())
+	t.Cleanup(func() { unregisterMetrics(m) })
+
+	r := prober.Result{Group: "g", Endpoint: "ep", Protocol: "http", URL: "http://a", Route: "r",
+		Status: status.InvalidRequestExecution, Err: errors.New("dial tcp: lookup a: no such host"), ErrorClass: status.ClassDNS}
+	m.OnResult(r)
+	expected := `
+# HELP ns_endpoint_error_class Class of the last probe's failure: dns, connect, tls, timeout, http, config, other (always 1; absent while passing)
+# TYPE ns_endpoint_error_class gauge
+ns_endpoint_error_class{endpoint="ep",environment="env",error_class="dns",group="g",protocol="http",route="r",url="http://a"} 1
+`
+	if err := testutil.CollectAndCompare(m.EndpointErrorClass, strings.NewReader(expected)); err != nil {
+		t.Fatal(err)
+	}
+
+	r.Status, r.Err, r.ErrorClass = status.Valid, nil, status.ClassNone
+	m.OnResult(r)
+	if got := testutil.CollectAndCount(m.EndpointErrorClass); got != 0 {
+		t.Fatalf("expected no error class series after success, got %d", got)
+	}
+}
+
+type resultsSub struct {
+	ch chan prober.Result
+}
+
+func (s *resultsSub) OnResult(r prober.Result) {
+	select {
+	case s.ch <- r:
+	default: // drop results the test no longer reads
+	}
+}
+
+func TestOnResult_HeldFailureKeepsErrorSeries(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			// the first probe fails with an error: the connection is dropped without a response
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			_ = conn.Close()
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	cfg := makeBasicConfig()
+	cfg.Settings.ProbeInterval = 20 * time.Millisecond
+	cfg.Endpoints["ep"] = config.Endpoint{
+		Group: "g", Protocol: "http", Routes: []string{"r"},
+		Request:          config.EndpointRequest{URL: srv.URL, Timeout: 250 * time.Millisecond, Method: http.MethodGet},
+		Validation:       &config.EndpointValidation{StatusCode: http.StatusOK},
+		FailureThreshold: 1, SuccessThreshold: 5,
+	}
+	cfg.Routes["r"] = config.Route{}
+	e := prober.NewEngine(cfg, validator.NewWatchDogValidator(validator.NewDefaultTLSChecker(false), validator.NewDefaultHTTPResponseChecker(false), false))
+	sub := &resultsSub{ch: make(chan prober.Result, 3)}
+	e.Subscribe(sub)
+	m := NewWDMetrics(BuildInfo{ProgramName: "prog", Version: "ver"}, cfg, newFakeProvider())
+	t.Cleanup(func() { unregisterMetrics(m) })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go e.Start(ctx)
+
+	var failed string
+	for i := 0; i < 3; i++ {
+		select {
+		case r := <-sub.ch:
+			if i == 0 {
+				failed = string(r.Status)
+			} else if r.RawStatus != status.Valid {
+				t.Fatalf("probe #%d: expected a passing probe, got %q", i, r.RawStatus)
+			}
+			m.OnResult(r)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timed out waiting for probe #%d", i)
+		}
+		// passing probes are held as the failure, which keeps its error and its series
+		lbl := prometheus.Labels{"group": "g", "endpoint": "ep", "protocol": "http", "url": srv.URL, "route": "r",
+			"status": failed, "is_error": "true"}
+		if got := testutil.CollectAndCount(m.EndpointValidation); got != 1 {
+			t.Fatalf("probe #%d: expected 1 validation series, got %d", i, got)
+		}
+		if got := testutil.ToFloat64(m.EndpointValidation.With(lbl)); got != 1 {
+			t.Fatalf("probe #%d: expected the held failure series, got %v", i, got)
+		}
+	}
 }
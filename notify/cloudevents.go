@@ -0,0 +1,111 @@
+package notify
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+	"github.com/kinjelom/watchdog_exporter/status"
+)
+
+// CloudEvents content modes of the HTTP binding.
+const (
+	CloudEventsStructured = "structured"
+	CloudEventsBinary     = "binary"
+)
+
+// CloudEventsSender posts events as CloudEvents 1.0 over HTTP.
+// The type is "<type-prefix>.<kind>" (e.g. io.watchdog.endpoint.down), the subject is group/endpoint/route.
+type CloudEventsSender struct {
+	cfg    config.CloudEventsConfig
+	client *http.Client
+}
+
+func NewCloudEventsSender(cfg config.CloudEventsConfig) (*CloudEventsSender, error) {
+	if cfg.Mode != CloudEventsStructured && cfg.Mode != CloudEventsBinary {
+		return nil, fmt.Errorf("unsupported mode %q (structured or binary)", cfg.Mode)
+	}
+	return &CloudEventsSender{cfg: cfg, client: httpClient}, nil
+}
+
+// cloudEventData is the "data" of the emitted events.
+type cloudEventData struct {
+	Kind                string        `json:"kind"`
+	Group               string        `json:"group"`
+	Endpoint            string        `json:"endpoint"`
+	Route               string        `json:"route"`
+	Protocol            string        `json:"protocol"`
+	URL                 string        `json:"url"`
+	Status              status.Status `json:"status"`
+	PrevStatus          status.Status `json:"prev_status,omitempty"`
+	Error               string        `json:"error,omitempty"`
+	DurationSeconds     float64       `json:"duration_seconds"`
+	ConsecutiveFailures int           `json:"consecutive_failures"`
+	At                  time.Time     `json:"at"`
+}
+
+type cloudEvent struct {
+	SpecVersion     string         `json:"specversion"`
+	ID              string         `json:"id"`
+	Source          string         `json:"source"`
+	Type            string         `json:"type"`
+	Subject         string         `json:"subject"`
+	Time            string         `json:"time"`
+	DataContentType string         `json:"datacontenttype"`
+	Data            cloudEventData `json:"data"`
+}
+
+func (s *CloudEventsSender) Send(ctx context.Context, ev Event) error {
+	at := ev.At
+	if at.IsZero() {
+		at = time.Now()
+	}
+	ce := cloudEvent{
+		SpecVersion:     "1.0",
+		ID:              newEventID(),
+		Source:          s.cfg.Source,
+		Type:            s.cfg.TypePrefix + "." + ev.Kind,
+		Subject:         ev.Key(),
+		Time:            at.UTC().Format(time.RFC3339Nano),
+		DataContentType: "application/json",
+		Data: cloudEventData{
+			Kind: ev.Kind, Group: ev.Group, Endpoint: ev.Endpoint, Route: ev.Route, Protocol: ev.Protocol, URL: ev.URL,
+			Status: ev.Status, PrevStatus: ev.PrevStatus, Error: ev.Error, DurationSeconds: ev.Duration,
+			ConsecutiveFailures: ev.ConsecutiveFailures, At: at,
+		},
+	}
+
+	headers := map[string]string{}
+	for k, v := range s.cfg.Headers {
+		headers[k] = v
+	}
+	var payload any = ce
+	if s.cfg.Mode == CloudEventsStructured {
+		headers["Content-Type"] = "application/cloudevents+json"
+	} else {
+		// Binary mode: attributes as ce-* headers, data as the body.
+		headers["Content-Type"] = ce.DataContentType
+		headers["ce-specversion"] = ce.SpecVersion
+		headers["ce-id"] = ce.ID
+		headers["ce-source"] = ce.Source
+		headers["ce-type"] = ce.Type
+		headers["ce-subject"] = ce.Subject
+		headers["ce-time"] = ce.Time
+		payload = ce.Data
+	}
+	return postJSON(ctx, s.client, s.cfg.URL, payload, headers)
+}
+
+// newEventID returns a random UUIDv4 string.
+func newEventID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	h := hex.EncodeToString(b)
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
@@ -0,0 +1,69 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+)
+
+func TestCloudEventsSender_Structured(t *testing.T) {
+	var contentType, auth string
+	var got cloudEvent
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType, auth = r.Header.Get("Content-Type"), r.Header.Get("Authorization")
+		_ = json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+
+	s, err := NewCloudEventsSender(config.CloudEventsConfig{URL: srv.URL, Source: "/wd", TypePrefix: "io.watchdog.endpoint",
+		Mode: CloudEventsStructured, Headers: map[string]string{"Authorization": "Bearer x"}})
+	assert.NoError(t, err)
+	at := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	assert.NoError(t, s.Send(context.Background(), Event{Kind: EventDown, Group: "g", Endpoint: "ep", Route: "r",
+		Status: "status-code-mismatch", Error: "got 503", At: at}))
+
+	assert.Equal(t, "application/cloudevents+json", contentType)
+	assert.Equal(t, "Bearer x", auth)
+	assert.Equal(t, "1.0", got.SpecVersion)
+	assert.Equal(t, "io.watchdog.endpoint.down", got.Type)
+	assert.Equal(t, "/wd", got.Source)
+	assert.Equal(t, "g/ep/r", got.Subject)
+	assert.Equal(t, "2025-01-01T12:00:00Z", got.Time)
+	assert.Regexp(t, regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`), got.ID)
+	assert.Equal(t, "got 503", got.Data.Error)
+}
+
+func TestCloudEventsSender_Binary(t *testing.T) {
+	var headers http.Header
+	var data cloudEventData
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header.Clone()
+		_ = json.NewDecoder(r.Body).Decode(&data)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	s, err := NewCloudEventsSender(config.CloudEventsConfig{URL: srv.URL, Source: "/wd", TypePrefix: "t", Mode: CloudEventsBinary})
+	assert.NoError(t, err)
+	assert.NoError(t, s.Send(context.Background(), Event{Kind: EventRecovered, Endpoint: "ep", Status: "valid"}))
+
+	assert.Equal(t, "application/json", headers.Get("Content-Type"))
+	assert.Equal(t, "1.0", headers.Get("ce-specversion"))
+	assert.Equal(t, "t.recovered", headers.Get("ce-type"))
+	assert.NotEmpty(t, headers.Get("ce-id"))
+	assert.Equal(t, "recovered", data.Kind)
+	assert.Equal(t, "ep", data.Endpoint)
+}
+
+func TestCloudEventsSender_UnknownMode(t *testing.T) {
+	_, err := NewCloudEventsSender(config.CloudEventsConfig{Mode: "batched"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,65 @@
+package notify
+
+import (
+	"fmt"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+)
+
+// FromConfig builds a Notifier with every configured channel, or returns nil when there is none.
+func FromConfig(cfg config.NotificationSettings) (*Notifier, error) {
+	n := NewNotifier(cfg.QueueSize)
+	for i, c := range cfg.Slack {
+		s, err := NewSlackSender(c)
+		if err != nil {
+			return nil, fmt.Errorf("slack[%d]: %w", i, err)
+		}
+		n.AddChannel(fmt.Sprintf("slack[%d]", i), s, c.ChannelOptions)
+	}
+	for i, c := range cfg.Teams {
+		s, err := NewTeamsSender(c)
+		if err != nil {
+			return nil, fmt.Errorf("teams[%d]: %w", i, err)
+		}
+		n.AddChannel(fmt.Sprintf("teams[%d]", i), s, c.ChannelOptions)
+	}
+	for i, c := range cfg.PagerDuty {
+		s, err := NewPagerDutySender(c)
+		if err != nil {
+			return nil, fmt.Errorf("pagerduty[%d]: %w", i, err)
+		}
+		n.AddChannel(fmt.Sprintf("pagerduty[%d]", i), s, c.ChannelOptions)
+	}
+	for i, c := range cfg.Opsgenie {
+		s, err := NewOpsgenieSender(c)
+		if err != nil {
+			return nil, fmt.Errorf("opsgenie[%d]: %w", i, err)
+		}
+		n.AddChannel(fmt.Sprintf("opsgenie[%d]", i), s, c.ChannelOptions)
+	}
+	for i, c := range cfg.Email {
+		s, err := NewEmailSender(c)
+		if err != nil {
+			return nil, fmt.Errorf("email[%d]: %w", i, err)
+		}
+		n.AddChannel(fmt.Sprintf("email[%d]", i), s, c.ChannelOptions)
+	}
+	for i, c := range cfg.CloudEvents {
+		s, err := NewCloudEventsSender(c)
+		if err != nil {
+			return nil, fmt.Errorf("cloudevents[%d]: %w", i, err)
+		}
+		n.AddChannel(fmt.Sprintf("cloudevents[%d]", i), s, c.ChannelOptions)
+	}
+	for i, c := range cfg.Syslog {
+		s, err := NewSyslogSender(c)
+		if err != nil {
+			return nil, fmt.Errorf("syslog[%d]: %w", i, err)
+		}
+		n.AddChannel(fmt.Sprintf("syslog[%d]", i), s, c.ChannelOptions)
+	}
+	if n.Len() == 0 {
+		return nil, nil
+	}
+	return n, nil
+}
@@ -0,0 +1,140 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+)
+
+// Email TLS modes.
+const (
+	EmailTLSStartTLS = "starttls"
+	EmailTLSImplicit = "tls"
+	EmailTLSNone     = "none"
+)
+
+// EmailSender mails events over SMTP, to per-group recipients when configured.
+type EmailSender struct {
+	cfg     config.EmailConfig
+	subject *template.Template
+	body    *template.Template
+}
+
+func NewEmailSender(cfg config.EmailConfig) (*EmailSender, error) {
+	switch cfg.TLS {
+	case EmailTLSStartTLS, EmailTLSImplicit, EmailTLSNone:
+	default:
+		return nil, fmt.Errorf("unknown tls mode %q", cfg.TLS)
+	}
+	subject, err := parseTemplate("email-subject", cfg.Subject, DefaultSubjectTemplate)
+	if err != nil {
+		return nil, err
+	}
+	body, err := parseTemplate("email-body", cfg.Template, DefaultTemplate)
+	if err != nil {
+		return nil, err
+	}
+	return &EmailSender{cfg: cfg, subject: subject, body: body}, nil
+}
+
+// recipients returns the group's recipients, or the default ones.
+func (s *EmailSender) recipients(group string) []string {
+	if to, ok := s.cfg.GroupRecipients[group]; ok {
+		return to
+	}
+	return s.cfg.To
+}
+
+func (s *EmailSender) Send(ctx context.Context, ev Event) error {
+	to := s.recipients(ev.Group)
+	if len(to) == 0 {
+		return nil
+	}
+	subject, err := render(s.subject, ev)
+	if err != nil {
+		return err
+	}
+	body, err := render(s.body, ev)
+	if err != nil {
+		return err
+	}
+	return s.deliver(ctx, to, s.message(to, subject, body, time.Now()))
+}
+
+func (s *EmailSender) message(to []string, subject, body string, now time.Time) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", s.cfg.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", strings.ReplaceAll(subject, "\n", " ")))
+	fmt.Fprintf(&b, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(body, "\r\n", "\n"), "\n", "\r\n"))
+	b.WriteString("\r\n")
+	return b.Bytes()
+}
+
+func (s *EmailSender) deliver(ctx context.Context, to []string, msg []byte) error {
+	addr := net.JoinHostPort(s.cfg.Host, strconv.Itoa(s.cfg.Port))
+	tlsCfg := &tls.Config{ServerName: s.cfg.Host}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	if s.cfg.TLS == EmailTLSImplicit {
+		conn = tls.Client(conn, tlsCfg)
+	}
+
+	c, err := smtp.NewClient(conn, s.cfg.Host)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	defer func() { _ = c.Close() }()
+
+	if s.cfg.TLS == EmailTLSStartTLS {
+		if err = c.StartTLS(tlsCfg); err != nil {
+			return fmt.Errorf("starttls: %w", err)
+		}
+	}
+	if s.cfg.Username != "" {
+		if err = c.Auth(smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, s.cfg.Host)); err != nil {
+			return fmt.Errorf("auth: %w", err)
+		}
+	}
+	if err = c.Mail(s.cfg.From); err != nil {
+		return err
+	}
+	for _, rcpt := range to {
+		if err = c.Rcpt(rcpt); err != nil {
+			return fmt.Errorf("rcpt %s: %w", rcpt, err)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(msg); err != nil {
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
@@ -0,0 +1,126 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+)
+
+type mail struct {
+	from string
+	to   []string
+	data string
+}
+
+// fakeSMTP accepts one plain-text SMTP session and reports the received mail.
+func fakeSMTP(t *testing.T) (host string, port int, received <-chan mail) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+
+	out := make(chan mail, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		tp := textproto.NewConn(conn)
+		var m mail
+		_ = tp.PrintfLine("220 fake ESMTP")
+		for {
+			line, err := tp.ReadLine()
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+			switch {
+			case cmd == "EHLO" || cmd == "HELO":
+				_ = tp.PrintfLine("250 fake")
+			case strings.HasPrefix(strings.ToUpper(line), "MAIL FROM:"):
+				m.from = strings.Trim(line[len("MAIL FROM:"):], "<>")
+				_ = tp.PrintfLine("250 OK")
+			case strings.HasPrefix(strings.ToUpper(line), "RCPT TO:"):
+				m.to = append(m.to, strings.Trim(line[len("RCPT TO:"):], "<>"))
+				_ = tp.PrintfLine("250 OK")
+			case cmd == "DATA":
+				_ = tp.PrintfLine("354 go ahead")
+				data, _ := tp.ReadDotBytes()
+				m.data = string(data)
+				_ = tp.PrintfLine("250 queued")
+			case cmd == "QUIT":
+				_ = tp.PrintfLine("221 bye")
+				out <- m
+				return
+			default:
+				_ = tp.PrintfLine("502 not implemented")
+			}
+		}
+	}()
+
+	h, p, _ := net.SplitHostPort(ln.Addr().String())
+	port, _ = strconv.Atoi(p)
+	return h, port, out
+}
+
+func TestEmailSender_Send(t *testing.T) {
+	host, port, received := fakeSMTP(t)
+	s, err := NewEmailSender(config.EmailConfig{
+		Host: host, Port: port, TLS: EmailTLSNone,
+		From:            "watchdog@example.com",
+		To:              []string{"ops@example.com"},
+		GroupRecipients: map[string][]string{"payments": {"pay@example.com", "lead@example.com"}},
+	})
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	assert.NoError(t, s.Send(ctx, Event{Kind: EventDown, Group: "payments", Endpoint: "ep", Route: "r", Status: "status-code-mismatch", Error: "got 503"}))
+
+	select {
+	case m := <-received:
+		assert.Equal(t, "watchdog@example.com", m.from)
+		assert.Equal(t, []string{"pay@example.com", "lead@example.com"}, m.to)
+		assert.Contains(t, m.data, "Subject: [DOWN] payments/ep via r: status-code-mismatch\n")
+		assert.Contains(t, m.data, "To: pay@example.com, lead@example.com\n")
+		assert.Contains(t, m.data, "Error: got 503")
+	case <-time.After(2 * time.Second):
+		t.Fatal("no mail received")
+	}
+}
+
+func TestEmailSender_Recipients(t *testing.T) {
+	s := &EmailSender{cfg: config.EmailConfig{To: []string{"a"}, GroupRecipients: map[string][]string{"g": {"b"}, "quiet": {}}}}
+	assert.Equal(t, []string{"a"}, s.recipients("other"))
+	assert.Equal(t, []string{"b"}, s.recipients("g"))
+	assert.Empty(t, s.recipients("quiet"))
+}
+
+func TestEmailSender_UnknownTLSMode(t *testing.T) {
+	_, err := NewEmailSender(config.EmailConfig{TLS: "ssl3"})
+	assert.Error(t, err)
+}
+
+func TestEmailSender_MessageHeaders(t *testing.T) {
+	s := &EmailSender{cfg: config.EmailConfig{From: "f@x"}}
+	msg := string(s.message([]string{"t@x"}, "Zażółć", "line1\nline2", time.Unix(0, 0).UTC()))
+	sc := bufio.NewScanner(strings.NewReader(msg))
+	var headers []string
+	for sc.Scan() && sc.Text() != "" {
+		headers = append(headers, sc.Text())
+	}
+	assert.Contains(t, headers, "Subject: =?utf-8?q?Za=C5=BC=C3=B3=C5=82=C4=87?=")
+	assert.True(t, strings.HasSuffix(msg, "line1\r\nline2\r\n"))
+}
@@ -0,0 +1,243 @@
+// Package notify turns status transitions into notifications (chat, paging, mail, ...).
+//
+// Notifier subscribes to the prober engine, derives an Event when a result changes state
+// and hands it to every matching channel. Each channel has its own queue and worker, so a slow
+// or unreachable service never blocks the probing loops or the other channels.
+package notify
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+	"github.com/kinjelom/watchdog_exporter/prober"
+	"github.com/kinjelom/watchdog_exporter/status"
+)
+
+// Event kinds.
+const (
+	EventDown      = "down"      // healthy (or first probe) -> failed
+	EventRecovered = "recovered" // failed -> healthy
+	EventChanged   = "changed"   // failed -> failed with another status
+)
+
+// Event is a status transition of one endpoint+route. It is also the data of message templates.
+type Event struct {
+	Kind string
+
+	Group    string
+	Endpoint string
+	Route    string
+	Protocol string
+	URL      string
+	IP       string // probed address, only with probe-all-ips
+
+	Status              status.Status
+	PrevStatus          status.Status
+	Error               string
+	Duration            float64
+	ConsecutiveFailures int
+	At                  time.Time
+}
+
+// Title is a short upper-case label of the kind, e.g. "DOWN".
+func (e Event) Title() string {
+	switch e.Kind {
+	case EventDown:
+		return "DOWN"
+	case EventRecovered:
+		return "RECOVERED"
+	default:
+		return "CHANGED"
+	}
+}
+
+// Key identifies the endpoint+route the event is about (stable across events, used for deduplication).
+func (e Event) Key() string {
+	if e.IP != "" {
+		return e.Group + "/" + e.Endpoint + "/" + e.Route + "/" + e.IP
+	}
+	return e.Group + "/" + e.Endpoint + "/" + e.Route
+}
+
+// EventOf returns the transition described by r, if any.
+func EventOf(r prober.Result) (Event, bool) {
+	failed := r.Failed()
+	prevFailed := r.PrevStatus.Failed()
+
+	var kind string
+	switch {
+	case failed && !prevFailed:
+		kind = EventDown
+	case !failed && prevFailed:
+		kind = EventRecovered
+	case failed && r.PrevStatus != r.Status:
+		kind = EventChanged
+	default:
+		return Event{}, false
+	}
+
+	ev := Event{
+		Kind:                kind,
+		Group:               r.Group,
+		Endpoint:            r.Endpoint,
+		Route:               r.Route,
+		Protocol:            r.Protocol,
+		URL:                 r.URL,
+		IP:                  r.IP,
+		Status:              r.Status,
+		PrevStatus:          r.PrevStatus,
+		Duration:            r.Duration,
+		ConsecutiveFailures: r.ConsecutiveFailures,
+		At:                  r.At,
+	}
+	if r.Err != nil {
+		ev.Error = r.ErrorText()
+	}
+	return ev, true
+}
+
+// Sender delivers one event to an external service.
+type Sender interface {
+	Send(ctx context.Context, ev Event) error
+}
+
+// Notifier is a prober.Subscriber fanning transition events out to channels.
+type Notifier struct {
+	queueSize int
+	channels  []*channel
+	drops     atomic.Uint64 // events dropped because a channel queue was full
+}
+
+type channel struct {
+	name     string
+	sender   Sender
+	groups   map[string]bool
+	timeout  time.Duration
+	throttle *throttle
+	queue    chan Event
+}
+
+func NewNotifier(queueSize int) *Notifier {
+	return &Notifier{queueSize: queueSize}
+}
+
+// AddChannel registers a sender; call it before Run.
+func (n *Notifier) AddChannel(name string, s Sender, opts config.ChannelOptions) {
+	ch := &channel{
+		name:     name,
+		sender:   s,
+		timeout:  opts.Timeout,
+		throttle: newThrottle(opts.Throttle.Max, opts.Throttle.Interval),
+		queue:    make(chan Event, n.queueSize),
+	}
+	if len(opts.Groups) > 0 {
+		ch.groups = make(map[string]bool, len(opts.Groups))
+		for _, g := range opts.Groups {
+			ch.groups[g] = true
+		}
+	}
+	n.channels = append(n.channels, ch)
+}
+
+// Len returns the number of channels.
+func (n *Notifier) Len() int {
+	return len(n.channels)
+}
+
+func (n *Notifier) OnResult(r prober.Result) {
+	ev, ok := EventOf(r)
+	if !ok {
+		return
+	}
+	for _, ch := range n.channels {
+		if ch.groups != nil && !ch.groups[ev.Group] {
+			continue
+		}
+		select {
+		case ch.queue <- ev:
+		default:
+			n.drops.Add(1)
+			slog.Warn("notification queue full, event dropped", "channel", ch.name, "kind", ev.Kind, "group", ev.Group, "endpoint", ev.Endpoint, "route", ev.Route)
+		}
+	}
+}
+
+// QueueDrops returns the number of events dropped because a channel queue was full.
+func (n *Notifier) QueueDrops() uint64 {
+	return n.drops.Load()
+}
+
+// Run delivers queued events until ctx is done.
+func (n *Notifier) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, ch := range n.channels {
+		wg.Add(1)
+		go func(ch *channel) {
+			defer wg.Done()
+			ch.run(ctx)
+		}(ch)
+	}
+	wg.Wait()
+}
+
+func (ch *channel) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev := <-ch.queue:
+			ch.deliver(ctx, ev)
+		}
+	}
+}
+
+func (ch *channel) deliver(ctx context.Context, ev Event) {
+	if !ch.throttle.allow(time.Now()) {
+		slog.Debug("notification throttled, event dropped", "channel", ch.name, "kind", ev.Kind, "group", ev.Group, "endpoint", ev.Endpoint, "route", ev.Route)
+		return
+	}
+	sendCtx, cancel := context.WithTimeout(ctx, ch.timeout)
+	defer cancel()
+	if err := ch.sender.Send(sendCtx, ev); err != nil {
+		slog.Warn("notification failed", "channel", ch.name, "kind", ev.Kind, "group", ev.Group, "endpoint", ev.Endpoint, "route", ev.Route, "err", err)
+	}
+}
+
+// throttle allows at most max events per fixed interval window (max <= 0 = unlimited).
+type throttle struct {
+	max      int
+	interval time.Duration
+
+	mu      sync.Mutex
+	start   time.Time
+	count   int
+	dropped int
+}
+
+func newThrottle(max int, interval time.Duration) *throttle {
+	return &throttle{max: max, interval: interval}
+}
+
+func (t *throttle) allow(now time.Time) bool {
+	if t.max <= 0 {
+		return true
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if now.Sub(t.start) >= t.interval {
+		if t.dropped > 0 {
+			slog.Warn("notifications throttled in the last window", "dropped", t.dropped)
+		}
+		t.start, t.count, t.dropped = now, 0, 0
+	}
+	if t.count >= t.max {
+		t.dropped++
+		return false
+	}
+	t.count++
+	return true
+}
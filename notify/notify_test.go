@@ -0,0 +1,146 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+	"github.com/kinjelom/watchdog_exporter/prober"
+	"github.com/kinjelom/watchdog_exporter/status"
+)
+
+func TestEventOf(t *testing.T) {
+	tests := []struct {
+		name     string
+		prev     status.Status
+		status   status.Status
+		wantKind string
+	}{
+		{"first probe ok", "", "valid", ""},
+		{"first probe failed", "", "status-code-mismatch", EventDown},
+		{"still ok", "valid", "valid", ""},
+		{"goes down", "valid", "request-execution-timeout", EventDown},
+		{"recovers", "request-execution-timeout", "valid", EventRecovered},
+		{"still failing", "request-execution-timeout", "request-execution-timeout", ""},
+		{"failure changes", "request-execution-timeout", "status-code-mismatch", EventChanged},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ev, ok := EventOf(prober.Result{PrevStatus: tt.prev, Status: tt.status})
+			assert.Equal(t, tt.wantKind != "", ok)
+			assert.Equal(t, tt.wantKind, ev.Kind)
+		})
+	}
+}
+
+func TestEventOf_CopiesError(t *testing.T) {
+	ev, ok := EventOf(prober.Result{Group: "g", Endpoint: "ep", Route: "r", Status: "request-execution-error", Err: errors.New("boom")})
+	assert.True(t, ok)
+	assert.Equal(t, "boom", ev.Error)
+	assert.Equal(t, "g/ep/r", ev.Key())
+}
+
+type recordingSender struct {
+	mu     sync.Mutex
+	events []Event
+	got    chan struct{}
+}
+
+func newRecordingSender() *recordingSender {
+	return &recordingSender{got: make(chan struct{}, 10)}
+}
+
+func (s *recordingSender) Send(_ context.Context, ev Event) error {
+	s.mu.Lock()
+	s.events = append(s.events, ev)
+	s.mu.Unlock()
+	s.got <- struct{}{}
+	return nil
+}
+
+func TestNotifier_RoutesByGroup(t *testing.T) {
+	all, ops := newRecordingSender(), newRecordingSender()
+	n := NewNotifier(10)
+	n.AddChannel("all", all, config.ChannelOptions{Timeout: time.Second})
+	n.AddChannel("ops", ops, config.ChannelOptions{Timeout: time.Second, Groups: []string{"ops"}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go n.Run(ctx)
+
+	n.OnResult(prober.Result{Group: "dev", Endpoint: "a", PrevStatus: "valid", Status: "status-code-mismatch"})
+	n.OnResult(prober.Result{Group: "ops", Endpoint: "b", PrevStatus: "valid", Status: "status-code-mismatch"})
+	n.OnResult(prober.Result{Group: "ops", Endpoint: "c", PrevStatus: "valid", Status: "valid"}) // no transition
+
+	for i := 0; i < 2; i++ {
+		waitFor(t, all.got)
+	}
+	waitFor(t, ops.got)
+
+	assert.Len(t, all.events, 2)
+	if assert.Len(t, ops.events, 1) {
+		assert.Equal(t, "b", ops.events[0].Endpoint)
+	}
+}
+
+func TestNotifier_DropsWhenQueueFull(t *testing.T) {
+	s := newRecordingSender()
+	n := NewNotifier(1)
+	n.AddChannel("c", s, config.ChannelOptions{Timeout: time.Second})
+
+	// Not running: the second event does not fit into the queue.
+	n.OnResult(prober.Result{Endpoint: "a", PrevStatus: "valid", Status: "status-code-mismatch"})
+	n.OnResult(prober.Result{Endpoint: "b", PrevStatus: "valid", Status: "status-code-mismatch"})
+	assert.Len(t, n.channels[0].queue, 1)
+	assert.Equal(t, uint64(1), n.QueueDrops())
+}
+
+func TestThrottle(t *testing.T) {
+	now := time.Unix(1000, 0)
+	th := newThrottle(2, time.Minute)
+	assert.True(t, th.allow(now))
+	assert.True(t, th.allow(now.Add(time.Second)))
+	assert.False(t, th.allow(now.Add(2*time.Second)))
+	assert.True(t, th.allow(now.Add(time.Minute)))
+
+	unlimited := newThrottle(0, time.Minute)
+	for i := 0; i < 100; i++ {
+		assert.True(t, unlimited.allow(now))
+	}
+}
+
+func TestRender_DefaultTemplate(t *testing.T) {
+	tmpl, err := parseTemplate("t", "", DefaultTemplate)
+	assert.NoError(t, err)
+	got, err := render(tmpl, Event{Kind: EventDown, Group: "g", Endpoint: "ep", Route: "direct", URL: "https://x",
+		Status: "status-code-mismatch", PrevStatus: "valid", Error: "got 503", Duration: 0.1234})
+	assert.NoError(t, err)
+	assert.Equal(t, "[DOWN] g/ep via direct: status-code-mismatch (was valid)\nError: got 503\nURL: https://x, duration: 0.123s", got)
+}
+
+func TestFromConfig(t *testing.T) {
+	n, err := FromConfig(config.NotificationSettings{QueueSize: 10})
+	assert.NoError(t, err)
+	assert.Nil(t, n)
+
+	_, err = FromConfig(config.NotificationSettings{Slack: []config.SlackConfig{{ChannelOptions: config.ChannelOptions{Template: "{{.Nope"}}}})
+	assert.Error(t, err)
+
+	n, err = FromConfig(config.NotificationSettings{QueueSize: 10, Slack: []config.SlackConfig{{}}, Teams: []config.TeamsConfig{{}}})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n.Len())
+}
+
+func waitFor(t *testing.T, ch <-chan struct{}) {
+	t.Helper()
+	select {
+	case <-ch:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for delivery")
+	}
+}
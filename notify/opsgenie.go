@@ -0,0 +1,67 @@
+package notify
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+)
+
+// OpsgenieSender creates an alert on down/changed and closes it on recovery.
+// The alert alias is the endpoint+route, so Opsgenie deduplicates repeated events.
+type OpsgenieSender struct {
+	cfg    config.OpsgenieConfig
+	tmpl   *template.Template
+	client *http.Client
+}
+
+func NewOpsgenieSender(cfg config.OpsgenieConfig) (*OpsgenieSender, error) {
+	tmpl, err := parseTemplate("opsgenie", cfg.Template, DefaultSummaryTemplate)
+	if err != nil {
+		return nil, err
+	}
+	return &OpsgenieSender{cfg: cfg, tmpl: tmpl, client: httpClient}, nil
+}
+
+type opsgenieAlert struct {
+	Message     string            `json:"message"`
+	Alias       string            `json:"alias"`
+	Description string            `json:"description,omitempty"`
+	Priority    string            `json:"priority,omitempty"`
+	Tags        []string          `json:"tags,omitempty"`
+	Details     map[string]string `json:"details,omitempty"`
+	Source      string            `json:"source"`
+}
+
+type opsgenieClose struct {
+	Source string `json:"source"`
+	Note   string `json:"note,omitempty"`
+}
+
+func (s *OpsgenieSender) Send(ctx context.Context, ev Event) error {
+	base := strings.TrimSuffix(s.cfg.URL, "/") + "/v2/alerts"
+	headers := map[string]string{"Authorization": "GenieKey " + s.cfg.APIKey}
+	alias := dedupKey(ev)
+
+	if ev.Kind == EventRecovered {
+		target := base + "/" + url.PathEscape(alias) + "/close?identifierType=alias"
+		return postJSON(ctx, s.client, target, opsgenieClose{Source: "watchdog_exporter", Note: "recovered: " + string(ev.Status)}, headers)
+	}
+
+	message, err := render(s.tmpl, ev)
+	if err != nil {
+		return err
+	}
+	return postJSON(ctx, s.client, base, opsgenieAlert{
+		Message:     truncate(message, 130),
+		Alias:       alias,
+		Description: ev.Error,
+		Priority:    s.cfg.Priority,
+		Tags:        s.cfg.Tags,
+		Details:     detailsOf(ev),
+		Source:      "watchdog_exporter",
+	}, headers)
+}
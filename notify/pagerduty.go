@@ -0,0 +1,98 @@
+package notify
+
+import (
+	"context"
+	"net/http"
+	"text/template"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+)
+
+// PagerDutySender triggers an incident on down/changed and resolves it on recovery (Events API v2).
+// The dedup key is the endpoint+route, so repeated events update the same incident.
+type PagerDutySender struct {
+	cfg    config.PagerDutyConfig
+	tmpl   *template.Template
+	client *http.Client
+}
+
+func NewPagerDutySender(cfg config.PagerDutyConfig) (*PagerDutySender, error) {
+	tmpl, err := parseTemplate("pagerduty", cfg.Template, DefaultSummaryTemplate)
+	if err != nil {
+		return nil, err
+	}
+	return &PagerDutySender{cfg: cfg, tmpl: tmpl, client: httpClient}, nil
+}
+
+type pagerDutyEvent struct {
+	RoutingKey  string            `json:"routing_key"`
+	EventAction string            `json:"event_action"`
+	DedupKey    string            `json:"dedup_key"`
+	Payload     *pagerDutyPayload `json:"payload,omitempty"`
+}
+
+type pagerDutyPayload struct {
+	Summary       string            `json:"summary"`
+	Source        string            `json:"source"`
+	Severity      string            `json:"severity"`
+	Component     string            `json:"component,omitempty"`
+	Group         string            `json:"group,omitempty"`
+	Class         string            `json:"class,omitempty"`
+	CustomDetails map[string]string `json:"custom_details,omitempty"`
+}
+
+func (s *PagerDutySender) Send(ctx context.Context, ev Event) error {
+	msg := pagerDutyEvent{RoutingKey: s.cfg.RoutingKey, DedupKey: dedupKey(ev)}
+	if ev.Kind == EventRecovered {
+		msg.EventAction = "resolve"
+		return postJSON(ctx, s.client, s.cfg.URL, msg, nil)
+	}
+
+	summary, err := render(s.tmpl, ev)
+	if err != nil {
+		return err
+	}
+	source := ev.URL
+	if source == "" {
+		source = ev.Endpoint
+	}
+	msg.EventAction = "trigger"
+	msg.Payload = &pagerDutyPayload{
+		Summary:       truncate(summary, 1024),
+		Source:        source,
+		Severity:      s.cfg.Severity,
+		Component:     ev.Endpoint,
+		Group:         ev.Group,
+		Class:         string(ev.Status),
+		CustomDetails: detailsOf(ev),
+	}
+	return postJSON(ctx, s.client, s.cfg.URL, msg, nil)
+}
+
+// dedupKey identifies the incident/alert of an endpoint+route in paging systems.
+func dedupKey(ev Event) string {
+	return "watchdog/" + ev.Key()
+}
+
+// detailsOf returns the event fields as flat key/value pairs for incident details.
+func detailsOf(ev Event) map[string]string {
+	d := map[string]string{
+		"group":       ev.Group,
+		"endpoint":    ev.Endpoint,
+		"route":       ev.Route,
+		"url":         ev.URL,
+		"status":      string(ev.Status),
+		"prev_status": string(ev.PrevStatus),
+	}
+	if ev.Error != "" {
+		d["error"] = ev.Error
+	}
+	return d
+}
+
+func truncate(s string, max int) string {
+	if r := []rune(s); len(r) > max {
+		return string(r[:max])
+	}
+	return s
+}
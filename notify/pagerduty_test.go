@@ -0,0 +1,86 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+)
+
+func TestPagerDutySender_TriggerAndResolve(t *testing.T) {
+	var got []pagerDutyEvent
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ev pagerDutyEvent
+		_ = json.NewDecoder(r.Body).Decode(&ev)
+		got = append(got, ev)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	s, err := NewPagerDutySender(config.PagerDutyConfig{RoutingKey: "rk", Severity: "error", URL: srv.URL})
+	assert.NoError(t, err)
+
+	down := Event{Kind: EventDown, Group: "g", Endpoint: "ep", Route: "r", URL: "https://ep", Status: "status-code-mismatch", Error: "got 503"}
+	assert.NoError(t, s.Send(context.Background(), down))
+	up := down
+	up.Kind, up.Status, up.Error = EventRecovered, "valid", ""
+	assert.NoError(t, s.Send(context.Background(), up))
+
+	if assert.Len(t, got, 2) {
+		assert.Equal(t, "trigger", got[0].EventAction)
+		assert.Equal(t, "rk", got[0].RoutingKey)
+		assert.Equal(t, "watchdog/g/ep/r", got[0].DedupKey)
+		assert.Equal(t, "g/ep via r: status-code-mismatch", got[0].Payload.Summary)
+		assert.Equal(t, "https://ep", got[0].Payload.Source)
+		assert.Equal(t, "error", got[0].Payload.Severity)
+		assert.Equal(t, "got 503", got[0].Payload.CustomDetails["error"])
+
+		assert.Equal(t, "resolve", got[1].EventAction)
+		assert.Equal(t, got[0].DedupKey, got[1].DedupKey)
+		assert.Nil(t, got[1].Payload)
+	}
+}
+
+func TestOpsgenieSender_CreateAndClose(t *testing.T) {
+	type request struct {
+		path, query, auth string
+		body              map[string]any
+	}
+	var got []request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := request{path: r.URL.EscapedPath(), query: r.URL.RawQuery, auth: r.Header.Get("Authorization")}
+		_ = json.NewDecoder(r.Body).Decode(&req.body)
+		got = append(got, req)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	s, err := NewOpsgenieSender(config.OpsgenieConfig{APIKey: "key", URL: srv.URL + "/", Priority: "P2", Tags: []string{"synthetic"}})
+	assert.NoError(t, err)
+
+	down := Event{Kind: EventDown, Group: "g", Endpoint: "ep", Route: "r", Status: "request-execution-timeout"}
+	assert.NoError(t, s.Send(context.Background(), down))
+	down.Kind, down.Status = EventRecovered, "valid"
+	assert.NoError(t, s.Send(context.Background(), down))
+
+	if assert.Len(t, got, 2) {
+		assert.Equal(t, "/v2/alerts", got[0].path)
+		assert.Equal(t, "GenieKey key", got[0].auth)
+		assert.Equal(t, "watchdog/g/ep/r", got[0].body["alias"])
+		assert.Equal(t, "P2", got[0].body["priority"])
+		assert.Equal(t, "g/ep via r: request-execution-timeout", got[0].body["message"])
+
+		assert.Equal(t, "/v2/alerts/watchdog%2Fg%2Fep%2Fr/close", got[1].path)
+		assert.Equal(t, "identifierType=alias", got[1].query)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	assert.Equal(t, "abc", truncate("abc", 5))
+	assert.Equal(t, "ab", truncate("abcdef", 2))
+}
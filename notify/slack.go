@@ -0,0 +1,38 @@
+package notify
+
+import (
+	"context"
+	"net/http"
+	"text/template"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+)
+
+// SlackSender posts events to a Slack incoming webhook.
+type SlackSender struct {
+	cfg    config.SlackConfig
+	tmpl   *template.Template
+	client *http.Client
+}
+
+func NewSlackSender(cfg config.SlackConfig) (*SlackSender, error) {
+	tmpl, err := parseTemplate("slack", cfg.Template, DefaultTemplate)
+	if err != nil {
+		return nil, err
+	}
+	return &SlackSender{cfg: cfg, tmpl: tmpl, client: httpClient}, nil
+}
+
+type slackMessage struct {
+	Text     string `json:"text"`
+	Channel  string `json:"channel,omitempty"`
+	Username string `json:"username,omitempty"`
+}
+
+func (s *SlackSender) Send(ctx context.Context, ev Event) error {
+	text, err := render(s.tmpl, ev)
+	if err != nil {
+		return err
+	}
+	return postJSON(ctx, s.client, s.cfg.WebhookURL, slackMessage{Text: text, Channel: s.cfg.Channel, Username: s.cfg.Username}, nil)
+}
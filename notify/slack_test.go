@@ -0,0 +1,46 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+)
+
+func capture(t *testing.T, status int) (*httptest.Server, *map[string]any) {
+	t.Helper()
+	got := map[string]any{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &got
+}
+
+func TestSlackSender_Send(t *testing.T) {
+	srv, got := capture(t, http.StatusOK)
+	s, err := NewSlackSender(config.SlackConfig{
+		ChannelOptions: config.ChannelOptions{Template: "{{.Title}} {{.Endpoint}} {{.Status}}"},
+		WebhookURL:     srv.URL,
+		Channel:        "#ops",
+	})
+	assert.NoError(t, err)
+
+	assert.NoError(t, s.Send(context.Background(), Event{Kind: EventDown, Endpoint: "ep", Status: "status-code-mismatch"}))
+	assert.Equal(t, "DOWN ep status-code-mismatch", (*got)["text"])
+	assert.Equal(t, "#ops", (*got)["channel"])
+	assert.NotContains(t, *got, "username")
+}
+
+func TestSlackSender_ErrorStatus(t *testing.T) {
+	srv, _ := capture(t, http.StatusForbidden)
+	s, _ := NewSlackSender(config.SlackConfig{WebhookURL: srv.URL})
+	assert.ErrorContains(t, s.Send(context.Background(), Event{Kind: EventDown}), "unexpected status 403")
+}
@@ -0,0 +1,189 @@
+package notify
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+)
+
+// Syslog networks.
+const (
+	SyslogUDP = "udp"
+	SyslogTCP = "tcp"
+	SyslogTLS = "tls"
+)
+
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5, "lpr": 6, "news": 7,
+	"uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19, "local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// Syslog severities per event kind.
+const (
+	syslogSeverityError   = 3
+	syslogSeverityWarning = 4
+	syslogSeverityNotice  = 5
+)
+
+// SyslogSender writes events as RFC 5424 messages with a structured data element.
+// TCP and TLS use octet-counting framing (RFC 6587); the connection is kept open and redialed after errors.
+type SyslogSender struct {
+	cfg      config.SyslogConfig
+	tmpl     *template.Template
+	facility int
+	hostname string
+	tlsCfg   *tls.Config
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+func NewSyslogSender(cfg config.SyslogConfig) (*SyslogSender, error) {
+	facility, ok := syslogFacilities[cfg.Facility]
+	if !ok {
+		return nil, fmt.Errorf("unknown facility %q", cfg.Facility)
+	}
+	s := &SyslogSender{cfg: cfg, facility: facility, hostname: cfg.Hostname}
+	switch cfg.Network {
+	case SyslogUDP, SyslogTCP:
+	case SyslogTLS:
+		s.tlsCfg = &tls.Config{}
+		if cfg.CAFile != "" {
+			pem, err := os.ReadFile(cfg.CAFile)
+			if err != nil {
+				return nil, err
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no certificates in %s", cfg.CAFile)
+			}
+			s.tlsCfg.RootCAs = pool
+		}
+	default:
+		return nil, fmt.Errorf("unsupported network %q (udp, tcp or tls)", cfg.Network)
+	}
+	if s.hostname == "" {
+		s.hostname, _ = os.Hostname()
+	}
+	tmpl, err := parseTemplate("syslog", cfg.Template, DefaultSummaryTemplate)
+	if err != nil {
+		return nil, err
+	}
+	s.tmpl = tmpl
+	return s, nil
+}
+
+func (s *SyslogSender) Send(ctx context.Context, ev Event) error {
+	msg, err := s.format(ev, time.Now())
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		if s.conn, err = s.dial(ctx); err != nil {
+			return err
+		}
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = s.conn.SetWriteDeadline(deadline)
+	}
+	frame := msg
+	if s.cfg.Network != SyslogUDP {
+		frame = strconv.Itoa(len(msg)) + " " + msg
+	}
+	if _, err = s.conn.Write([]byte(frame)); err != nil {
+		_ = s.conn.Close()
+		s.conn = nil
+		return err
+	}
+	return nil
+}
+
+func (s *SyslogSender) dial(ctx context.Context) (net.Conn, error) {
+	var d net.Dialer
+	if s.cfg.Network == SyslogTLS {
+		host, _, _ := net.SplitHostPort(s.cfg.Address)
+		cfg := s.tlsCfg.Clone()
+		cfg.ServerName = host
+		td := tls.Dialer{NetDialer: &d, Config: cfg}
+		return td.DialContext(ctx, "tcp", s.cfg.Address)
+	}
+	return d.DialContext(ctx, s.cfg.Network, s.cfg.Address)
+}
+
+// format renders: <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID [SD-ID k="v" ...] MSG
+func (s *SyslogSender) format(ev Event, now time.Time) (string, error) {
+	text, err := render(s.tmpl, ev)
+	if err != nil {
+		return "", err
+	}
+	severity := syslogSeverityWarning
+	switch ev.Kind {
+	case EventDown:
+		severity = syslogSeverityError
+	case EventRecovered:
+		severity = syslogSeverityNotice
+	}
+
+	params := [][2]string{
+		{"group", ev.Group}, {"endpoint", ev.Endpoint}, {"route", ev.Route}, {"protocol", ev.Protocol},
+		{"url", ev.URL}, {"status", string(ev.Status)}, {"prev_status", string(ev.PrevStatus)}, {"error", ev.Error},
+		{"duration_seconds", strconv.FormatFloat(ev.Duration, 'f', -1, 64)},
+		{"consecutive_failures", strconv.Itoa(ev.ConsecutiveFailures)},
+	}
+	var sd strings.Builder
+	sd.WriteString("[" + s.cfg.SDID)
+	for _, p := range params {
+		if p[1] == "" {
+			continue
+		}
+		sd.WriteString(" " + p[0] + `="` + escapeSDParam(p[1]) + `"`)
+	}
+	sd.WriteString("]")
+
+	return fmt.Sprintf("<%d>1 %s %s %s %d %s %s %s",
+		s.facility*8+severity,
+		now.UTC().Format("2006-01-02T15:04:05.000000Z"),
+		syslogHeaderField(s.hostname, 255),
+		syslogHeaderField(s.cfg.AppName, 48),
+		os.Getpid(),
+		ev.Kind,
+		sd.String(),
+		strings.ReplaceAll(text, "\n", " "),
+	), nil
+}
+
+// escapeSDParam escapes '"', '\' and ']' in structured data values (RFC 5424, 6.3.3).
+func escapeSDParam(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(v)
+}
+
+// syslogHeaderField returns v as printable ASCII without spaces, "-" when empty.
+func syslogHeaderField(v string, max int) string {
+	v = strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' {
+			return '_'
+		}
+		return r
+	}, v)
+	if v == "" {
+		return "-"
+	}
+	if len(v) > max {
+		v = v[:max]
+	}
+	return v
+}
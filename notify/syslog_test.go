@@ -0,0 +1,105 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+)
+
+func syslogConfig(network, address string) config.SyslogConfig {
+	return config.SyslogConfig{Network: network, Address: address, Facility: "local0", AppName: "watchdog_exporter",
+		Hostname: "host1", SDID: "watchdog@32473"}
+}
+
+func TestSyslogSender_Format(t *testing.T) {
+	s, err := NewSyslogSender(syslogConfig(SyslogUDP, "127.0.0.1:514"))
+	assert.NoError(t, err)
+	got, err := s.format(Event{Kind: EventDown, Group: "g", Endpoint: "ep", Route: "r", Status: "status-code-mismatch",
+		Error: `got "503" [x]`, Duration: 0.5, ConsecutiveFailures: 1}, time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC))
+	assert.NoError(t, err)
+
+	want := fmt.Sprintf(`<131>1 2025-01-02T03:04:05.000000Z host1 watchdog_exporter %d down `+
+		`[watchdog@32473 group="g" endpoint="ep" route="r" status="status-code-mismatch" error="got \"503\" [x\]" duration_seconds="0.5" consecutive_failures="1"] `+
+		`g/ep via r: status-code-mismatch`, os.Getpid())
+	assert.Equal(t, want, got)
+}
+
+func TestSyslogSender_SeverityByKind(t *testing.T) {
+	s, _ := NewSyslogSender(syslogConfig(SyslogUDP, "127.0.0.1:514"))
+	for kind, pri := range map[string]string{EventDown: "<131>", EventChanged: "<132>", EventRecovered: "<133>"} {
+		got, _ := s.format(Event{Kind: kind}, time.Now())
+		assert.True(t, strings.HasPrefix(got, pri), kind)
+	}
+}
+
+func TestSyslogSender_TCPOctetCounting(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer func() { _ = ln.Close() }()
+
+	received := make(chan string, 2)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		r := bufio.NewReader(conn)
+		for {
+			lenStr, err := r.ReadString(' ')
+			if err != nil {
+				return
+			}
+			n, _ := strconv.Atoi(strings.TrimSpace(lenStr))
+			msg := make([]byte, n)
+			if _, err = io.ReadFull(r, msg); err != nil {
+				return
+			}
+			received <- string(msg)
+		}
+	}()
+
+	s, err := NewSyslogSender(syslogConfig(SyslogTCP, ln.Addr().String()))
+	assert.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	assert.NoError(t, s.Send(ctx, Event{Kind: EventDown, Endpoint: "a"}))
+	assert.NoError(t, s.Send(ctx, Event{Kind: EventRecovered, Endpoint: "a"}))
+
+	for _, kind := range []string{"down", "recovered"} {
+		select {
+		case msg := <-received:
+			assert.Contains(t, msg, " "+kind+" [watchdog@32473 ")
+		case <-time.After(2 * time.Second):
+			t.Fatal("no message")
+		}
+	}
+}
+
+func TestSyslogSender_InvalidConfig(t *testing.T) {
+	cfg := syslogConfig("quic", "x:1")
+	_, err := NewSyslogSender(cfg)
+	assert.Error(t, err)
+
+	cfg = syslogConfig(SyslogUDP, "x:1")
+	cfg.Facility = "nope"
+	_, err = NewSyslogSender(cfg)
+	assert.Error(t, err)
+}
+
+func TestSyslogHeaderField(t *testing.T) {
+	assert.Equal(t, "-", syslogHeaderField("", 10))
+	assert.Equal(t, "my_host", syslogHeaderField("my host", 10))
+	assert.Equal(t, "abc", syslogHeaderField("abcdef", 3))
+}
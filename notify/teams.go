@@ -0,0 +1,77 @@
+package notify
+
+import (
+	"context"
+	"net/http"
+	"text/template"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+)
+
+// TeamsSender posts events to a Microsoft Teams webhook as an Adaptive Card
+// (accepted by incoming webhooks and by Workflows "post to a channel when a webhook request is received").
+type TeamsSender struct {
+	cfg    config.TeamsConfig
+	tmpl   *template.Template
+	client *http.Client
+}
+
+func NewTeamsSender(cfg config.TeamsConfig) (*TeamsSender, error) {
+	tmpl, err := parseTemplate("teams", cfg.Template, DefaultTemplate)
+	if err != nil {
+		return nil, err
+	}
+	return &TeamsSender{cfg: cfg, tmpl: tmpl, client: httpClient}, nil
+}
+
+type teamsMessage struct {
+	Type        string            `json:"type"`
+	Attachments []teamsAttachment `json:"attachments"`
+}
+
+type teamsAttachment struct {
+	ContentType string    `json:"contentType"`
+	Content     teamsCard `json:"content"`
+}
+
+type teamsCard struct {
+	Schema  string           `json:"$schema"`
+	Type    string           `json:"type"`
+	Version string           `json:"version"`
+	Body    []teamsTextBlock `json:"body"`
+}
+
+type teamsTextBlock struct {
+	Type   string `json:"type"`
+	Text   string `json:"text"`
+	Wrap   bool   `json:"wrap"`
+	Weight string `json:"weight,omitempty"`
+	Color  string `json:"color,omitempty"`
+}
+
+func (s *TeamsSender) Send(ctx context.Context, ev Event) error {
+	text, err := render(s.tmpl, ev)
+	if err != nil {
+		return err
+	}
+	color := "Attention"
+	if ev.Kind == EventRecovered {
+		color = "Good"
+	}
+	msg := teamsMessage{
+		Type: "message",
+		Attachments: []teamsAttachment{{
+			ContentType: "application/vnd.microsoft.card.adaptive",
+			Content: teamsCard{
+				Schema:  "http://adaptivecards.io/schemas/adaptive-card.json",
+				Type:    "AdaptiveCard",
+				Version: "1.4",
+				Body: []teamsTextBlock{
+					{Type: "TextBlock", Text: ev.Title() + ": " + ev.Key(), Wrap: true, Weight: "Bolder", Color: color},
+					{Type: "TextBlock", Text: text, Wrap: true},
+				},
+			},
+		}},
+	}
+	return postJSON(ctx, s.client, s.cfg.WebhookURL, msg, nil)
+}
@@ -0,0 +1,47 @@
+package notify
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+)
+
+func TestTeamsSender_Send(t *testing.T) {
+	srv, got := capture(t, http.StatusAccepted)
+	s, err := NewTeamsSender(config.TeamsConfig{
+		ChannelOptions: config.ChannelOptions{Template: "{{.Endpoint}} is {{.Status}}"},
+		WebhookURL:     srv.URL,
+	})
+	assert.NoError(t, err)
+	assert.Same(t, httpClient, s.client, "notifiers share the client with a timeout")
+
+	assert.NoError(t, s.Send(context.Background(), Event{Kind: EventRecovered, Group: "g", Endpoint: "ep", Route: "r", Status: "valid"}))
+	assert.Equal(t, "message", (*got)["type"])
+	attachments := (*got)["attachments"].([]any)
+	assert.Len(t, attachments, 1)
+	att := attachments[0].(map[string]any)
+	assert.Equal(t, "application/vnd.microsoft.card.adaptive", att["contentType"])
+	card := att["content"].(map[string]any)
+	assert.Equal(t, "AdaptiveCard", card["type"])
+	assert.Equal(t, "1.4", card["version"])
+	assert.Equal(t, "http://adaptivecards.io/schemas/adaptive-card.json", card["$schema"])
+	assert.Equal(t, []any{
+		map[string]any{"type": "TextBlock", "text": "RECOVERED: g/ep/r", "wrap": true, "weight": "Bolder", "color": "Good"},
+		map[string]any{"type": "TextBlock", "text": "ep is valid", "wrap": true},
+	}, card["body"])
+
+	assert.NoError(t, s.Send(context.Background(), Event{Kind: EventDown, Group: "g", Endpoint: "ep", Route: "r", Status: "timeout"}))
+	title := (*got)["attachments"].([]any)[0].(map[string]any)["content"].(map[string]any)["body"].([]any)[0].(map[string]any)
+	assert.Equal(t, "DOWN: g/ep/r", title["text"])
+	assert.Equal(t, "Attention", title["color"])
+}
+
+func TestTeamsSender_ErrorStatus(t *testing.T) {
+	srv, _ := capture(t, http.StatusBadRequest)
+	s, _ := NewTeamsSender(config.TeamsConfig{WebhookURL: srv.URL})
+	assert.ErrorContains(t, s.Send(context.Background(), Event{Kind: EventDown}), "unexpected status 400")
+}
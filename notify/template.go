@@ -0,0 +1,34 @@
+package notify
+
+import (
+	"bytes"
+	"text/template"
+)
+
+// DefaultTemplate is used when a channel has no template configured.
+const DefaultTemplate = `[{{.Title}}] {{.Group}}/{{.Endpoint}} via {{.Route}}: {{.Status}}` +
+	`{{if .PrevStatus}} (was {{.PrevStatus}}){{end}}{{if .Error}}
+Error: {{.Error}}{{end}}
+URL: {{.URL}}, duration: {{printf "%.3f" .Duration}}s`
+
+// DefaultSummaryTemplate is the one-line default for incident titles (paging systems).
+const DefaultSummaryTemplate = `{{.Group}}/{{.Endpoint}} via {{.Route}}: {{.Status}}`
+
+// DefaultSubjectTemplate is the default e-mail subject.
+const DefaultSubjectTemplate = `[{{.Title}}] {{.Group}}/{{.Endpoint}} via {{.Route}}: {{.Status}}`
+
+// parseTemplate parses text, falling back to def when it is empty.
+func parseTemplate(name, text, def string) (*template.Template, error) {
+	if text == "" {
+		text = def
+	}
+	return template.New(name).Option("missingkey=zero").Parse(text)
+}
+
+func render(t *template.Template, ev Event) (string, error) {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, ev); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
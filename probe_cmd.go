@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/kinjelom/watchdog_exporter/api"
+	"github.com/kinjelom/watchdog_exporter/config"
+	"github.com/kinjelom/watchdog_exporter/prober"
+	"github.com/kinjelom/watchdog_exporter/status"
+)
+
+// Exit codes of the probe command, as Nagios plugins use them.
+const (
+	exitOK       = 0
+	exitWarning  = 1 // passed with a caveat (unverified certificate, too slow, certificate expiring soon) or maintenance
+	exitCritical = 2
+	exitUnknown  = 3 // usage or config error, the endpoint was not probed
+)
+
+var exitStates = map[int]string{exitOK: "OK", exitWarning: "WARNING", exitCritical: "CRITICAL", exitUnknown: "UNKNOWN"}
+
+// probeOutput is the JSON output of the probe command.
+type probeOutput struct {
+	State    string           `json:"state"`
+	ExitCode int              `json:"exit_code"`
+	Results  []api.ResultView `json:"results"`
+}
+
+// runProbeCommand implements `watchdog_exporter probe`: one probe of an endpoint (over all its routes, or one), printed
+// as text or JSON; the exit code is the worst of the results.
+func runProbeCommand(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("probe", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	configFile := fs.String("config", "config.yml", "Path to configuration YAML file, or a directory of them")
+	endpointName := fs.String("endpoint", "", "Endpoint to probe (required)")
+	routeName := fs.String("route", "", "Probe over this route only (default: all routes of the endpoint)")
+	output := fs.String("output", "text", "Output format: text | json")
+	logLevel := fs.String("log.level", "warn", "Minimum log level (logs go to stderr): debug | info | warn | error")
+	if err := fs.Parse(args); err != nil {
+		return exitUnknown
+	}
+	fail := func(format string, a ...any) int {
+		fmt.Fprintf(stderr, "UNKNOWN - "+format+"\n", a...)
+		return exitUnknown
+	}
+	if *endpointName == "" {
+		return fail("--endpoint is required")
+	}
+	if *output != "text" && *output != "json" {
+		return fail("invalid --output=%s: expected text or json", *output)
+	}
+	if err := setupLogging(*logLevel, "text"); err != nil {
+		return fail("%v", err)
+	}
+
+	cfg, err := config.LoadConfig(*configFile)
+	if err == nil {
+		err = cfg.Validate()
+	}
+	if err != nil {
+		return fail("cannot load --config=%s: %v", *configFile, err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wdv, _, err := newValidator(ctx, cfg)
+	if err != nil {
+		return fail("%v", err)
+	}
+	results, err := prober.NewEngine(cfg, wdv).ProbeEndpoint(ctx, *endpointName, *routeName)
+	if err != nil {
+		return fail("%v", err)
+	}
+
+	code := exitOK
+	for _, r := range results {
+		code = max(code, exitCodeOf(r.Status))
+	}
+	views := make([]api.ResultView, 0, len(results))
+	for _, r := range results {
+		views = append(views, api.NewResultView(r))
+	}
+	if *output == "json" {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(probeOutput{State: exitStates[code], ExitCode: code, Results: views})
+		return code
+	}
+	writeProbeText(stdout, *endpointName, code, views)
+	return code
+}
+
+// exitCodeOf maps a probe status to the exit code of the probe command.
+func exitCodeOf(s status.Status) int {
+	switch s {
+	case status.Valid:
+		return exitOK
+	case status.ValidInsecureTLS, status.TooSlow, status.CertExpiringSoon, status.Maintenance:
+		return exitWarning
+	}
+	return exitCritical
+}
+
+// writeProbeText prints a Nagios plugin answer: a summary with the durations as performance data, then a line per result.
+func writeProbeText(w io.Writer, endpointName string, code int, views []api.ResultView) {
+	failed := 0
+	perf := make([]string, 0, len(views))
+	for _, v := range views {
+		if exitCodeOf(v.Status) != exitOK {
+			failed++
+		}
+		perf = append(perf, fmt.Sprintf("'%s'=%.3fs", probeLabel(v), v.DurationSeconds))
+	}
+	fmt.Fprintf(w, "WATCHDOG %s - %s: %d of %d probe(s) not ok | %s\n", exitStates[code], endpointName, failed, len(views), strings.Join(perf, " "))
+	for _, v := range views {
+		line := fmt.Sprintf("%s: %s in %.3fs", probeLabel(v), v.Status, v.DurationSeconds)
+		if v.Error != "" {
+			line += ": " + v.Error
+		}
+		if t := v.TLS; t != nil {
+			line += fmt.Sprintf(", %s %s", t.Version, t.CipherSuite)
+			if len(t.Certificates) > 0 {
+				leaf := t.Certificates[0]
+				line += fmt.Sprintf(", certificate %s expires in %.1f days", leaf.CommonName, leaf.DaysLeft)
+			}
+		}
+		fmt.Fprintln(w, line)
+	}
+}
+
+// probeLabel names a result by its route, and address with probe-all-ips.
+func probeLabel(v api.ResultView) string {
+	if v.IP != "" {
+		return v.Route + "@" + v.IP
+	}
+	return v.Route
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/kinjelom/watchdog_exporter/status"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExitCodeOf(t *testing.T) {
+	tests := []struct {
+		status status.Status
+		want   int
+	}{
+		{status.Valid, exitOK},
+		{status.ValidInsecureTLS, exitWarning},
+		{status.TooSlow, exitWarning},
+		{status.CertExpiringSoon, exitWarning},
+		{status.Maintenance, exitWarning},
+		{status.UnexpectedStatusCode, exitCritical},
+		{status.InvalidRequestExecution, exitCritical},
+		{status.ExpiredCertLeaf, exitCritical},
+		{status.UnknownError, exitCritical},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.status), func(t *testing.T) {
+			assert.Equal(t, tt.want, exitCodeOf(tt.status))
+		})
+	}
+}
+
+func TestRunProbeCommandUnknown(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"missing endpoint", []string{"--config=missing.yml"}, "--endpoint is required"},
+		{"invalid output", []string{"--endpoint=a", "--output=xml"}, "invalid --output=xml"},
+		{"missing config", []string{"--endpoint=a", "--config=testdata/missing.yml"}, "cannot load --config=testdata/missing.yml"},
+		{"bad flag", []string{"--no-such-flag"}, "flag provided but not defined"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			assert.Equal(t, exitUnknown, runProbeCommand(tt.args, &stdout, &stderr))
+			assert.Contains(t, stderr.String(), tt.want)
+			assert.Empty(t, stdout.String())
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package prober
+
+import (
+	"context"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+	"github.com/kinjelom/watchdog_exporter/status"
+	"github.com/kinjelom/watchdog_exporter/validator"
+)
+
+// RemovalSubscriber is an optional Subscriber extension notified when a result key disappears,
+// e.g. an address that is no longer in DNS for a probe-all-ips endpoint.
+type RemovalSubscriber interface {
+	OnRemoved(Result)
+}
+
+// probeAllIPs resolves the URL host and probes every address on its own, so a broken backend behind round-robin
+// DNS is not masked by the healthy ones. A resolution failure is reported as a single result without an IP.
+func (e *Engine) probeAllIPs(ctx context.Context, endpointName string, endpoint config.Endpoint, routeKey string, route config.Route) {
+	ips, err := e.validator.ResolveHost(ctx, endpoint.Request)
+	if err != nil {
+		e.forgetIPs(endpointName, endpoint, routeKey, []string{""})
+		e.record(e.resultOf(endpointName, endpoint, routeKey, "", validator.Report{Status: status.InvalidRequestExecution}, err))
+		return
+	}
+	e.forgetIPs(endpointName, endpoint, routeKey, ips)
+	for _, ip := range ips {
+		res, ok := e.withRetries(ctx, endpoint, func() Result {
+			return e.probeIP(endpointName, endpoint, routeKey, route, ip)
+		})
+		if !ok {
+			return // stopping
+		}
+		e.record(res)
+	}
+}
+
+// probeIP runs one probe of the endpoint over the route, connecting to ip.
+func (e *Engine) probeIP(endpointName string, endpoint config.Endpoint, routeKey string, route config.Route, ip string) Result {
+	rep, err := e.validator.ProbeIP(endpointName, endpoint.Request, routeKey, route, ip, endpoint.Validation, endpoint.InspectTLSCerts)
+	return e.resultOf(endpointName, endpoint, routeKey, ip, rep, err)
+}
+
+// forgetIPs records the addresses probed this cycle and drops the state of those probed before but gone now.
+func (e *Engine) forgetIPs(endpointName string, endpoint config.Endpoint, routeKey string, ips []string) {
+	id := endpointName + "\x00" + routeKey
+	e.muIPs.Lock()
+	prev := e.probedIPs[id]
+	e.probedIPs[id] = ips
+	e.muIPs.Unlock()
+
+	current := make(map[string]bool, len(ips))
+	for _, ip := range ips {
+		current[ip] = true
+	}
+	for _, ip := range prev {
+		if current[ip] {
+			continue
+		}
+		e.forgetResult(Result{Group: endpoint.Group, Endpoint: endpointName, Protocol: endpoint.Protocol,
+			URL: endpoint.Request.URL, Route: routeKey, IP: ip})
+	}
+}
+
+func (e *Engine) notifyRemoved(r Result) {
+	e.muSubs.RLock()
+	defer e.muSubs.RUnlock()
+	for _, sub := range e.subs {
+		rs, ok := sub.(RemovalSubscriber)
+		if !ok {
+			continue
+		}
+		func() {
+			defer func() {
+				if rec := recover(); rec != nil {
+					e.stats.subscriberPanics.Add(1)
+				}
+			}()
+			rs.OnRemoved(r)
+		}()
+	}
+}
@@ -1,13 +1,17 @@
+// Package prober schedules the endpoint probes and fans their results out to subscribers.
 package prober
 
 import (
 	"context"
-	"log"
-	"math/rand"
+	"hash/fnv"
+	"log/slog"
+	"slices"
 	"sync"
 	"time"
-	"watchdog_exporter/config"
-	"watchdog_exporter/validator"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+	"github.com/kinjelom/watchdog_exporter/status"
+	"github.com/kinjelom/watchdog_exporter/validator"
 )
 
 // Result represents one probe outcome for an endpoint+route.
@@ -17,17 +21,75 @@ type Result struct {
 	Protocol string
 	URL      string
 	Route    string
+	// IP is the address probed, set only for endpoints with probe-all-ips (one result per resolved address).
+	IP string
+	// Labels are the endpoint's custom labels (config labels).
+	Labels map[string]string
 
-	Status   string
+	Status   status.Status // resolved by validator.ResolveStatus
 	Duration float64
 	Err      error
+	// Error is Err sanitized for display (validator.SanitizeError); ErrorClass groups the failure (dns, connect, ...).
+	Error      string
+	ErrorClass status.ErrorClass
+
+	// PrevStatus is the status of the previous probe for the same key ("" on the first probe).
+	PrevStatus status.Status
+	// StatusSince is when Status was first seen in a row: the probe that changed it, or the first probe after start.
+	StatusSince time.Time
+	// ConsecutiveFailures counts failed probes in a row, including this one (0 if it succeeded).
+	ConsecutiveFailures int
+	// Availability is the success ratio (0..1) per sliding window label, e.g. "24h".
+	Availability map[string]float64
 
 	TLS *validator.CertsReport
 
+	// HeaderMatch and BodyMatch report the content checks on their own (nil = not configured).
+	HeaderMatch *bool
+	BodyMatch   *bool
+	// PinMatch reports the validation.pin-sha256 check on its own (nil = not configured).
+	PinMatch *bool
+	// SLOMet reports whether the probe completed within validation.max-duration (nil = not configured or no answer).
+	SLOMet *bool
+	// RawStatus is the outcome of the probe itself; Status differs from it while failure-threshold or
+	// success-threshold hold the previous state.
+	RawStatus status.Status
+	// Attempts is the number of tries the result took (1 + the retries used).
+	Attempts int
+	// Maintenance reports that the probe ran inside a maintenance window (mode probe); a failure then has the
+	// maintenance status and keeps only its Error text (the failed status when there was no error).
+	Maintenance bool
+
+	// RemoteIP is the address the probe connected to (after target-ip override and DNS; the proxy if proxied).
+	RemoteIP string
+	// Redirects is the number of redirects followed (only with request.follow-redirects).
+	Redirects int
+	// IPv6Fallback reports that IPv6 was attempted but the probe connected over IPv4 (happy eyeballs hid a failure).
+	IPv6Fallback bool
+	// Headers are the response headers listed in validation.capture-headers (canonical name -> value).
+	Headers map[string]string
+	// Answers are the DNS records received (protocol dns).
+	Answers []string
+	// Phases are the phase timings of an HTTP probe (zero for a phase it did not go through).
+	Phases validator.Phases
+
 	// When the probe finished.
 	At time.Time
 }
 
+// Failed reports whether the probe did not pass validation.
+func (r Result) Failed() bool {
+	return r.Err != nil || r.Status == "" || r.Status.Failed()
+}
+
+// ErrorText returns the sanitized error, also for results that were built without going through the engine.
+func (r Result) ErrorText() string {
+	if r.Error == "" && r.Err != nil {
+		return validator.SanitizeError(r.Err)
+	}
+	return r.Error
+}
+
 // Provider exposes snapshots for passive readers (e.g., Prometheus exporter).
 type Provider interface {
 	Snapshot() []Result
@@ -43,64 +105,97 @@ type IntervalProvider func(endpointName string, ep config.Endpoint) time.Duratio
 
 // Engine runs probing loops and fans out results.
 type Engine struct {
+	muCfg     sync.RWMutex
 	cfg       *config.WatchDogConfig
 	validator *validator.WatchDogValidator
 
 	intervalFor IntervalProvider
 	store       *Store
+	sla         *SLATracker
 
 	muSubs sync.RWMutex
 	subs   []Subscriber
 
-	// edge-triggered logging state: last error per key ("" means healthy)
-	muErr       sync.Mutex
-	lastResults map[string]string
-}
+	stats engineStats
 
-// Store keeps the latest result per (group, endpoint, route, url, protocol).
-type Store struct {
-	mu    sync.RWMutex
-	items map[string]Result // key -> last result
-}
+	// worker pool: a slot per running probe (settings.max-workers-count)
+	muWorkers sync.Mutex
+	workers   chan struct{}
 
-func NewStore() *Store {
-	return &Store{items: make(map[string]Result)}
-}
+	// outbound token buckets (settings.rate-limit, nil = unlimited)
+	muLimit sync.Mutex
+	limiter *rateLimiter
 
-func (s *Store) keyOf(r Result) string {
-	// Stable small cardinality key
-	return r.Group + "\x00" + r.Endpoint + "\x00" + r.Route + "\x00" + r.Protocol + "\x00" + r.URL
-}
+	// suppression state: runtime pauses, rules and last reported reason per endpoint
+	muSuppr     sync.Mutex
+	paused      map[string]bool
+	suppressors []Suppressor
+	suppressed  map[string]string
 
-func (s *Store) Put(r Result) {
-	s.mu.Lock()
-	s.items[s.keyOf(r)] = r
-	s.mu.Unlock()
+	// edge-triggered state: last status and error per key
+	muErr       sync.Mutex
+	lastResults map[string]probeState
+
+	// addresses probed in the last cycle per endpoint+route (probe-all-ips)
+	muIPs     sync.Mutex
+	probedIPs map[string][]string
+
+	// route canaries: whether the last probe of each route passed
+	muRoutes sync.Mutex
+	routeUp  map[string]bool
+
+	// running loops, so a config reload can stop and start them (nil runCtx = not started)
+	muLoops       sync.Mutex
+	runCtx        context.Context
+	loops         sync.WaitGroup
+	endpointLoops map[string]loopHandle
+	routeLoops    map[string]loopHandle
 }
 
-func (s *Store) Snapshot() []Result {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	out := make([]Result, 0, len(s.items))
-	for _, v := range s.items {
-		out = append(out, v)
-	}
-	return out
+// probeState is the part of a Result used for edge detection.
+type probeState struct {
+	status   status.Status
+	err      string // "" means healthy
+	cause    error  // error behind err, kept for probes held by failure-threshold/success-threshold
+	class    status.ErrorClass
+	failures int // consecutive failures
+	since    time.Time
+	streak   int // consecutive probes disagreeing with status on pass/fail (failure-threshold, success-threshold)
 }
 
 func NewEngine(cfg *config.WatchDogConfig, v *validator.WatchDogValidator) *Engine {
-	interval := func(_ string, _ config.Endpoint) time.Duration {
-		return cfg.Settings.ProbeInterval
+	e := &Engine{
+		cfg:           cfg,
+		validator:     v,
+		store:         NewStore(),
+		sla:           NewSLATracker(cfg.Settings.AvailabilityWindows),
+		lastResults:   make(map[string]probeState),
+		paused:        make(map[string]bool),
+		suppressed:    make(map[string]string),
+		probedIPs:     make(map[string][]string),
+		routeUp:       make(map[string]bool),
+		endpointLoops: make(map[string]loopHandle),
+		routeLoops:    make(map[string]loopHandle),
+		workers:       workerSlots(cfg.Settings.MaxWorkersCount),
+		limiter:       newRateLimiter(cfg.Settings.RateLimit),
 	}
-	return &Engine{
-		cfg:         cfg,
-		validator:   v,
-		intervalFor: interval,
-		store:       NewStore(),
-		lastResults: make(map[string]string),
+	e.intervalFor = func(_ string, endpoint config.Endpoint) time.Duration {
+		return e.config().IntervalOf(endpoint)
 	}
+	e.AddSuppressor(e.suppressDependents)
+	return e
+}
+
+// config returns the current configuration (replaced by Reload).
+func (e *Engine) config() *config.WatchDogConfig {
+	e.muCfg.RLock()
+	defer e.muCfg.RUnlock()
+	return e.cfg
 }
 
+// Config returns the configuration the engine runs on; it follows every Reload.
+func (e *Engine) Config() *config.WatchDogConfig { return e.config() }
+
 func (e *Engine) Provider() Provider { return e.store }
 
 func (e *Engine) Subscribe(s Subscriber) {
@@ -115,118 +210,312 @@ func (e *Engine) notify(r Result) {
 	for _, s := range e.subs {
 		// Subscribers must be fast or internally buffered.
 		func(sub Subscriber, res Result) {
-			defer func() { _ = recover() }()
+			defer func() {
+				if rec := recover(); rec != nil {
+					e.stats.subscriberPanics.Add(1)
+				}
+			}()
 			sub.OnResult(res)
 		}(s, r)
 	}
 }
 
+// Start runs the probe loops until ctx is done; Reload may replace them meanwhile.
 func (e *Engine) Start(ctx context.Context) {
-	var wg sync.WaitGroup
-	for epName, ep := range e.cfg.Endpoints {
-		epName, ep := epName, ep
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			e.runEndpointLoop(ctx, epName, ep)
-		}()
+	cfg := e.config()
+	e.muLoops.Lock()
+	e.runCtx = ctx
+	for epName, ep := range cfg.ActiveEndpoints() {
+		e.startEndpointLoop(epName, ep)
 	}
+	for routeName, route := range cfg.Routes {
+		if hasCanary(route) {
+			e.startRouteLoop(routeName, route)
+		}
+	}
+	e.muLoops.Unlock()
+
 	<-ctx.Done()
-	wg.Wait()
+	e.muLoops.Lock()
+	e.runCtx = nil
+	e.muLoops.Unlock()
+	e.loops.Wait()
 }
 
 func (e *Engine) runEndpointLoop(ctx context.Context, endpointName string, endpoint config.Endpoint) {
+	if endpoint.Schedule != "" {
+		e.runScheduledLoop(ctx, endpointName, endpoint)
+		return
+	}
 	interval := e.intervalFor(endpointName, endpoint)
 	if interval <= 0 {
 		interval = 30 * time.Second
 	}
 
-	// Small jitter to avoid herd.
-	jit := time.Duration(rand.Int63n(int64(interval / 10)))
-	timer := time.NewTimer(jit)
+	// Spread first probes over the whole interval, at a stable offset per endpoint, to avoid a herd on start.
+	offset := e.offsetOf(endpointName, interval)
+	e.stats.setOffset(endpointName, offset)
+	slog.Debug("probe loop scheduled", "endpoint", endpointName, "interval", interval, "start_offset", offset)
+	timer := time.NewTimer(offset)
 	defer timer.Stop()
+	due := time.Now().Add(offset)
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		case <-timer.C:
-			e.probeOnce(ctx, endpointName, endpoint)
+			e.stats.setLag(endpointName, time.Since(due))
+			e.probeUnlessSuppressed(ctx, endpointName, endpoint)
 			timer.Reset(interval)
+			due = time.Now().Add(interval)
+		}
+	}
+}
+
+// runScheduledLoop probes an endpoint at every match of its cron schedule (local time) instead of every interval.
+func (e *Engine) runScheduledLoop(ctx context.Context, endpointName string, endpoint config.Endpoint) {
+	schedule, err := config.ParseCron(endpoint.Schedule)
+	if err != nil {
+		slog.Error("probe loop not started", "endpoint", endpointName, "err", err)
+		return
+	}
+	for {
+		due := schedule.Next(time.Now())
+		if due.IsZero() {
+			slog.Warn("probe schedule never matches", "endpoint", endpointName, "schedule", endpoint.Schedule)
+			return
+		}
+		slog.Debug("probe scheduled", "endpoint", endpointName, "at", due)
+		timer := time.NewTimer(time.Until(due))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+			e.stats.setLag(endpointName, time.Since(due))
+			e.probeUnlessSuppressed(ctx, endpointName, endpoint)
+		}
+	}
+}
+
+// probeUnlessSuppressed probes an endpoint now, or reports why it is skipped.
+func (e *Engine) probeUnlessSuppressed(ctx context.Context, endpointName string, endpoint config.Endpoint) {
+	reason := e.suppressionReason(endpointName, endpoint, time.Now())
+	e.updateSuppression(endpointName, endpoint, reason)
+	if reason == "" {
+		e.probeOnce(ctx, endpointName, endpoint)
+	}
+}
+
+// offsetOf is the delay of the first probe of an endpoint within its interval, per settings.probe-spread.
+func (e *Engine) offsetOf(endpointName string, interval time.Duration) time.Duration {
+	cfg := e.config()
+	switch cfg.Settings.ProbeSpread {
+	case config.SpreadNone:
+		return 0
+	case config.SpreadEven:
+		// the endpoints sharing the interval, in name order, take equal slices of it
+		var peers []string
+		for name, ep := range cfg.ActiveEndpoints() {
+			if ep.Schedule == "" && e.intervalFor(name, ep) == interval {
+				peers = append(peers, name)
+			}
+		}
+		slices.Sort(peers)
+		if i := slices.Index(peers, endpointName); i >= 0 {
+			return time.Duration(int64(interval) * int64(i) / int64(len(peers)))
 		}
 	}
+	return startOffset(endpointName, interval)
+}
+
+// startOffset maps the endpoint name to a deterministic delay in [0, interval) (FNV-1a hash),
+// so restarts and replicas schedule each endpoint at the same point of its cycle.
+func startOffset(endpointName string, interval time.Duration) time.Duration {
+	if interval <= 0 {
+		return 0
+	}
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(endpointName))
+	return time.Duration(h.Sum64() % uint64(interval))
 }
 
 // keyOf mirrors Store.keyOf without taking the Store lock.
 // It must generate the same key as Store.keyOf.
 func (e *Engine) keyOf(r Result) string {
-	return r.Group + "\x00" + r.Endpoint + "\x00" + r.Route + "\x00" + r.Protocol + "\x00" + r.URL
+	return r.Group + "\x00" + r.Endpoint + "\x00" + r.Route + "\x00" + r.Protocol + "\x00" + r.URL + "\x00" + r.IP
 }
 
-// edge-triggered logging:
+// trackTransition records r as the latest state for its key, sets r.PrevStatus
+// and does edge-triggered logging:
 // - log when transitioning from healthy -> error, or when error message changes
 // - log a single "recovered" when transitioning from error -> healthy
-func (e *Engine) logOnTransition(r Result) {
-	key := e.keyOf(r)
+func (e *Engine) trackTransition(r *Result) {
+	key := e.keyOf(*r)
 
 	e.muErr.Lock()
 	defer e.muErr.Unlock()
 
-	_, resExists := e.lastResults[key]
-	prev := e.lastResults[key]
-	cur := ""
-	if r.Err != nil {
-		cur = r.Err.Error()
+	last, resExists := e.lastResults[key]
+	if r.Failed() {
+		r.ConsecutiveFailures = last.failures + 1
+	}
+	streak := e.dampFlap(r, last, resExists)
+	prev := last.err
+	cur := r.ErrorText()
+	r.PrevStatus = last.status
+	r.StatusSince = last.since
+	if !resExists || last.status != r.Status {
+		r.StatusSince = r.At
 	}
 
+	attrs := []any{"group", r.Group, "endpoint", r.Endpoint, "route", r.Route, "url", r.URL, "protocol", r.Protocol, "status", r.Status}
 	switch {
 	case !resExists:
 		// first probe
-		log.Printf("probe STARTED: group=%q endpoint=%q route=%q url=%q protocol=%q status=%s err=%q",
-			r.Group, r.Endpoint, r.Route, r.URL, r.Protocol, r.Status, cur)
+		slog.Info("probe started", append(attrs, "err", cur)...)
 	case prev == "" && cur != "":
 		// first error
-		log.Printf("probe ERROR: group=%q endpoint=%q route=%q url=%q protocol=%q status=%s err=%q",
-			r.Group, r.Endpoint, r.Route, r.URL, r.Protocol, r.Status, cur)
+		slog.Warn("probe failed", append(attrs, "err", cur, "error_class", r.ErrorClass)...)
 	case prev != "" && cur == "":
 		// recovered
-		log.Printf("probe RECOVERED: group=%q endpoint=%q route=%q url=%q protocol=%q status=%s",
-			r.Group, r.Endpoint, r.Route, r.URL, r.Protocol, r.Status)
+		slog.Info("probe recovered", append(attrs, "prev_status", last.status)...)
 	case prev != "" && prev != cur:
 		// error changed
-		log.Printf("probe ERROR UPDATED: group=%q endpoint=%q route=%q url=%q protocol=%q status=%s err=%q (was %q)",
-			r.Group, r.Endpoint, r.Route, r.URL, r.Protocol, r.Status, cur, prev)
+		slog.Warn("probe error changed", append(attrs, "err", cur, "prev_err", prev, "error_class", r.ErrorClass)...)
+	}
+	e.lastResults[key] = probeState{status: r.Status, err: cur, cause: r.Err, class: r.ErrorClass, failures: r.ConsecutiveFailures,
+		since: r.StatusSince, streak: streak}
+}
+
+// dampFlap keeps the reported state of r's key when r passes (or fails) while the last reported state failed (or
+// passed), until failure-threshold (or success-threshold) such probes came in a row; the outcome of the probe itself
+// stays in RawStatus. Changes between failure statuses are not held. It returns the new streak.
+func (e *Engine) dampFlap(r *Result, last probeState, known bool) int {
+	if !known || r.Failed() == last.status.Failed() {
+		return 0
+	}
+	endpoint := e.config().Endpoints[r.Endpoint]
+	threshold := endpoint.SuccessThreshold
+	if r.Failed() {
+		threshold = endpoint.FailureThreshold
+	}
+	streak := last.streak + 1
+	if streak >= threshold {
+		return 0
 	}
-	e.lastResults[key] = cur
+	r.Status, r.Err, r.Error, r.ErrorClass = last.status, last.cause, last.err, last.class
+	return streak
 }
 
-func (e *Engine) probeOnce(_ context.Context, endpointName string, endpoint config.Endpoint) {
+func (e *Engine) probeOnce(ctx context.Context, endpointName string, endpoint config.Endpoint) {
+	cfg := e.config()
 	for _, routeKey := range endpoint.Routes {
-		route := e.cfg.Routes[routeKey]
-
-		status, duration, tlsRep, err := e.validator.Validate(
-			endpointName, endpoint.Request, routeKey, route, endpoint.Validation, endpoint.InspectTLSCerts)
-
-		res := Result{
-			Group:    endpoint.Group,
-			Endpoint: endpointName,
-			Protocol: endpoint.Protocol,
-			URL:      endpoint.Request.URL,
-			Route:    routeKey,
-
-			Status:   status,
-			Duration: duration,
-			Err:      err,
-			TLS:      tlsRep,
-			At:       time.Now(),
+		route := cfg.Routes[routeKey]
+		if probesAllIPs(endpoint, route) {
+			e.probeAllIPs(ctx, endpointName, endpoint, routeKey, route)
+			continue
 		}
+		res, ok := e.withRetries(ctx, endpoint, func() Result {
+			rep, err := e.probe(endpointName, endpoint, routeKey, route)
+			return e.resultOf(endpointName, endpoint, routeKey, "", rep, err)
+		})
+		if !ok {
+			return // stopping
+		}
+		e.record(res)
+	}
+}
 
-		// Edge-triggered logging
-		e.logOnTransition(res)
+// probe runs one probe of the endpoint over the route with the validator of its protocol.
+func (e *Engine) probe(endpointName string, endpoint config.Endpoint, routeKey string, route config.Route) (validator.Report, error) {
+	switch endpoint.Protocol {
+	case config.ProtocolDNS:
+		var q config.DNSQuery
+		if endpoint.DNS != nil {
+			q = *endpoint.DNS
+		}
+		return e.validator.ProbeDNS(endpointName, endpoint.Request, routeKey, route, q)
+	case config.ProtocolSMTP:
+		sp := config.SMTPProbe{EHLO: "localhost"}
+		if endpoint.SMTP != nil {
+			sp = *endpoint.SMTP
+		}
+		return e.validator.ProbeSMTP(endpointName, endpoint.Request, routeKey, route, sp, endpoint.InspectTLSCerts)
+	case config.ProtocolPostgres, config.ProtocolMySQL:
+		q := config.SQLProbe{Query: "SELECT 1"}
+		if endpoint.SQL != nil {
+			q = *endpoint.SQL
+		}
+		return e.validator.ProbeSQL(endpointName, endpoint.Protocol, endpoint.Request, routeKey, route, q)
+	}
+	return e.validator.Probe(endpointName, endpoint.Request, routeKey, route, endpoint.Validation, endpoint.InspectTLSCerts)
+}
 
-		// Save last state
-		e.store.Put(res)
-		// Fan out to subscribers (push exporters, logs, etc.)
-		e.notify(res)
+// probesAllIPs reports whether the route is probed once per resolved address (probe-all-ips, HTTP only).
+func probesAllIPs(endpoint config.Endpoint, route config.Route) bool {
+	return endpoint.ProbeAllIPs && route.TargetIP == "" && route.TargetSocket == "" && endpoint.HTTPBased()
+}
+
+// resultOf builds the result of one probe.
+func (e *Engine) resultOf(endpointName string, endpoint config.Endpoint, routeKey, ip string, rep validator.Report, err error) Result {
+	res := Result{
+		Group:    endpoint.Group,
+		Endpoint: endpointName,
+		Protocol: endpoint.Protocol,
+		URL:      endpoint.Request.URL,
+		Route:    routeKey,
+		IP:       ip,
+		Labels:   endpoint.Labels,
+
+		Status:      validator.ResolveStatus(rep.Status, err, rep.TLS),
+		Duration:    rep.Duration,
+		Err:         err,
+		Error:       validator.SanitizeError(err),
+		TLS:         rep.TLS,
+		HeaderMatch: rep.Matches.Header,
+		BodyMatch:   rep.Matches.Body,
+		PinMatch:    rep.PinMatch,
+		RemoteIP:    rep.RemoteIP,
+		Redirects:   rep.Redirects,
+		At:          time.Now(),
+
+		IPv6Fallback: rep.IPv6Fallback,
+		Headers:      rep.Headers,
+		Answers:      rep.Answers,
+		Phases:       rep.Phases,
+	}
+	if v := endpoint.Validation; v != nil && v.MaxDuration > 0 && err == nil {
+		met := rep.Duration <= v.MaxDuration.Seconds()
+		res.SLOMet = &met
+		// only a passing probe becomes too-slow: any other failure says more
+		if !met && !res.Status.Failed() {
+			res.Status = status.TooSlow
+		}
+	}
+	if w := endpoint.MaintenanceAt(res.At); w != nil && w.Mode == config.MaintenanceProbe {
+		res.Maintenance = true
+		if res.Failed() {
+			if res.Error == "" {
+				res.Error = string(res.Status) // keep what failed
+			}
+			res.Status, res.Err = status.Maintenance, nil
+		}
 	}
+	res.ErrorClass = validator.ClassifyError(res.Status, res.Err)
+	res.RawStatus = res.Status
+	return res
+}
+
+// record tracks, stores and fans out a result.
+func (e *Engine) record(res Result) {
+	// Edge detection and logging
+	e.trackTransition(&res)
+	res.Availability = e.sla.Record(e.keyOf(res), res)
+
+	// Save last state
+	e.store.Put(res)
+	// Fan out to subscribers (push exporters, logs, etc.)
+	e.notify(res)
 }
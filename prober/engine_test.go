@@ -2,17 +2,23 @@ package prober
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"io"
+	"maps"
 	"net/http"
 	"net/http/httptest"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
-	"watchdog_exporter/config"
-	"watchdog_exporter/validator"
-
 	"github.com/stretchr/testify/assert"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+	"github.com/kinjelom/watchdog_exporter/status"
+	"github.com/kinjelom/watchdog_exporter/validator"
 )
 
 func TestStore_PutAndSnapshotKeying(t *testing.T) {
@@ -42,6 +48,91 @@ func TestStore_PutAndSnapshotKeying(t *testing.T) {
 
 // --- Engine tests ---
 
+func TestEngine_TrackTransitionSetsPrevStatus(t *testing.T) {
+	e := NewEngine(makeCfg(time.Second), newValidator(false))
+
+	r := Result{Group: "g", Endpoint: "ep", Route: "r", Protocol: "http", URL: "http://a", Status: "valid"}
+	e.trackTransition(&r)
+	assert.Empty(t, r.PrevStatus, "first probe has no previous status")
+
+	r2 := r
+	r2.Status = "unexpected-status-code"
+	e.trackTransition(&r2)
+	assert.Equal(t, status.Valid, r2.PrevStatus)
+
+	// a different route is tracked independently
+	r3 := r
+	r3.Route = "other"
+	e.trackTransition(&r3)
+	assert.Empty(t, r3.PrevStatus)
+}
+
+func TestEngine_TrackTransitionSetsStatusSince(t *testing.T) {
+	e := NewEngine(makeCfg(time.Second), newValidator(false))
+	start := time.Unix(1700000000, 0)
+
+	steps := []struct {
+		status status.Status
+		want   time.Time
+	}{
+		{status.Valid, start}, // first probe
+		{status.Valid, start}, // unchanged
+		{status.RequestExecutionTimeout, start.Add(2 * time.Minute)}, // changed
+		{status.RequestExecutionTimeout, start.Add(2 * time.Minute)}, // unchanged
+		{status.UnexpectedStatusCode, start.Add(4 * time.Minute)},    // another failure counts as a change
+	}
+	for i, step := range steps {
+		r := Result{Group: "g", Endpoint: "ep", Route: "r", Protocol: "http", URL: "http://a", Status: step.status,
+			At: start.Add(time.Duration(i) * time.Minute)}
+		e.trackTransition(&r)
+		assert.Equal(t, step.want, r.StatusSince, "probe #%d", i)
+	}
+}
+
+func TestEngine_TrackTransitionCountsConsecutiveFailures(t *testing.T) {
+	e := NewEngine(makeCfg(time.Second), newValidator(false))
+
+	statuses := []status.Status{status.Valid, status.RequestExecutionTimeout, status.UnexpectedStatusCode, status.RequestExecutionTimeout, status.Valid}
+	want := []int{0, 1, 2, 3, 0}
+	for i, st := range statuses {
+		r := Result{Group: "g", Endpoint: "ep", Route: "r", Protocol: "http", URL: "http://a", Status: st}
+		e.trackTransition(&r)
+		assert.Equal(t, want[i], r.ConsecutiveFailures, "probe #%d (%s)", i, st)
+	}
+}
+
+func TestEngine_TrackTransitionDampsFlaps(t *testing.T) {
+	cfg := makeCfg(time.Second)
+	cfg.Endpoints["ep"] = config.Endpoint{FailureThreshold: 3, SuccessThreshold: 2}
+	e := NewEngine(cfg, newValidator(false))
+
+	steps := []struct {
+		raw, want status.Status
+	}{
+		{status.Valid, status.Valid}, // first probe is reported as is
+		{status.RequestExecutionTimeout, status.Valid},
+		{status.Valid, status.Valid}, // the failure streak is broken
+		{status.RequestExecutionTimeout, status.Valid},
+		{status.UnexpectedStatusCode, status.Valid},
+		{status.RequestExecutionTimeout, status.RequestExecutionTimeout}, // third failure in a row
+		{status.UnexpectedStatusCode, status.UnexpectedStatusCode},       // failure statuses are not held
+		{status.Valid, status.UnexpectedStatusCode},
+		{status.Valid, status.Valid}, // second pass in a row
+	}
+	for i, step := range steps {
+		r := Result{Group: "g", Endpoint: "ep", Route: "r", Protocol: "http", URL: "http://a", Status: step.raw, RawStatus: step.raw}
+		if step.raw.Failed() {
+			r.Err = errors.New(string(step.raw))
+			r.Error = string(step.raw)
+		}
+		e.trackTransition(&r)
+		assert.Equal(t, step.want, r.Status, "probe #%d", i)
+		assert.Equal(t, step.raw, r.RawStatus, "probe #%d", i)
+		assert.Equal(t, step.want.Failed(), r.ErrorText() != "", "probe #%d: the error follows the reported status", i)
+		assert.Equal(t, step.want.Failed(), r.Err != nil, "probe #%d: a held failure keeps its error", i)
+	}
+}
+
 func TestEngine_StoresLatestResult(t *testing.T) {
 	interval := 10 * time.Millisecond
 	cfg := makeCfg(interval)
@@ -91,7 +182,7 @@ func TestEngine_StoresLatestResult(t *testing.T) {
 	assert.Equal(t, "ep1", got.Endpoint)
 	assert.Equal(t, "r1", got.Route)
 	assert.Equal(t, srv.URL, got.URL)
-	assert.Equal(t, "valid", got.Status) // DefaultHTTPResponseChecker returns "valid" for 200 and no header/body checks
+	assert.Equal(t, status.Valid, got.Status) // DefaultHTTPResponseChecker returns "valid" for 200 and no header/body checks
 	assert.GreaterOrEqual(t, got.Duration, 0.0)
 }
 
@@ -132,7 +223,7 @@ func TestEngine_NotifiesSubscribers(t *testing.T) {
 		assert.Equal(t, "g", r.Group)
 		assert.Equal(t, "ep", r.Endpoint)
 		assert.Equal(t, "r", r.Route)
-		assert.Equal(t, "valid", r.Status)
+		assert.Equal(t, status.Valid, r.Status)
 	case <-time.After(500 * time.Millisecond):
 		t.Fatal("did not receive any result from subscriber")
 	}
@@ -249,6 +340,569 @@ DONE:
 	assert.True(t, seen["r2"], "expected result for route r2")
 }
 
+func TestEngine_DependencySuppression(t *testing.T) {
+	cfg := makeCfg(time.Second)
+	cfg.Endpoints["db"] = config.Endpoint{Group: "g", Routes: []string{"r1", "r2"}}
+	app := config.Endpoint{Group: "g", Routes: []string{"r1"}, DependsOn: []string{"db"}}
+	cfg.Endpoints["app"] = app
+	e := NewEngine(cfg, newValidator(false))
+	now := time.Now()
+
+	assert.Equal(t, "", e.suppressionReason("app", app, now), "no results of the dependency yet")
+
+	e.store.Put(Result{Group: "g", Endpoint: "db", Route: "r1", Status: status.UnexpectedStatusCode})
+	e.store.Put(Result{Group: "g", Endpoint: "db", Route: "r2", Status: status.Valid})
+	assert.Equal(t, "", e.suppressionReason("app", app, now), "one route of the dependency passes")
+
+	e.store.Put(Result{Group: "g", Endpoint: "db", Route: "r2", Status: status.InvalidRequestExecution})
+	assert.Equal(t, SuppressedDependency, e.suppressionReason("app", app, now))
+	assert.Equal(t, "", e.suppressionReason("db", cfg.Endpoints["db"], now), "the dependency itself is probed")
+
+	e.store.Put(Result{Group: "g", Endpoint: "db", Route: "r1", Status: status.Valid})
+	assert.Equal(t, "", e.suppressionReason("app", app, now))
+
+	e.store.Put(Result{Group: "g", Endpoint: "db", Route: "r1", Status: status.InvalidRequestExecution})
+	assert.NoError(t, e.Pause("db"))
+	assert.Equal(t, "", e.suppressionReason("app", app, now), "a paused dependency does not suppress")
+	assert.NoError(t, e.Resume("db"))
+	assert.Equal(t, SuppressedDependency, e.suppressionReason("app", app, now))
+}
+
+func TestEngine_PausedEndpointIsSuppressed(t *testing.T) {
+	interval := 10 * time.Millisecond
+	cfg := makeCfg(interval)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	cfg.Endpoints["ep"] = config.Endpoint{
+		Group:      "g",
+		Protocol:   "http",
+		Request:    config.EndpointRequest{URL: srv.URL, Timeout: 200 * time.Millisecond, Method: http.MethodGet},
+		Routes:     []string{"r"},
+		Validation: &config.EndpointValidation{StatusCode: http.StatusOK},
+	}
+	cfg.Routes["r"] = config.Route{}
+
+	e := NewEngine(cfg, newValidator(false))
+	assert.NoError(t, e.Pause("ep"))
+	assert.ErrorIs(t, e.Pause("missing"), ErrUnknownEndpoint)
+
+	sub := &suppressionSub{chanSub: chanSub{ch: make(chan Result, 10)}, suppr: make(chan Suppression, 10)}
+	e.Subscribe(sub)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go e.Start(ctx)
+
+	select {
+	case s := <-sub.suppr:
+		assert.Equal(t, "ep", s.Endpoint)
+		assert.Equal(t, "r", s.Route)
+		assert.Equal(t, SuppressedPaused, s.Reason)
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("did not receive suppression")
+	}
+	time.Sleep(3 * interval)
+	assert.Empty(t, sub.ch, "paused endpoint must not be probed")
+
+	assert.NoError(t, e.Resume("ep"))
+	select {
+	case s := <-sub.suppr:
+		assert.Equal(t, "", s.Reason)
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("did not receive resume")
+	}
+	select {
+	case r := <-sub.ch:
+		assert.Equal(t, status.Valid, r.Status)
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("did not receive result after resume")
+	}
+}
+
+func TestEngine_ProbeAllIPsSetsIP(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	cfg := makeCfg(time.Second)
+	cfg.Routes["r"] = config.Route{}
+	ep := config.Endpoint{
+		Group:       "g",
+		Protocol:    "http",
+		ProbeAllIPs: true,
+		Request:     config.EndpointRequest{URL: srv.URL, Timeout: 200 * time.Millisecond, Method: http.MethodGet},
+		Routes:      []string{"r"},
+		Validation:  &config.EndpointValidation{StatusCode: http.StatusOK},
+	}
+	cfg.Endpoints["ep"] = ep
+	e := NewEngine(cfg, newValidator(false))
+	sub := &chanSub{ch: make(chan Result, 10)}
+	e.Subscribe(sub)
+
+	e.probeOnce(context.Background(), "ep", ep)
+	r := <-sub.ch
+	assert.Equal(t, "127.0.0.1", r.IP)
+	assert.Equal(t, status.Valid, r.Status)
+}
+
+func TestEngine_MaxDurationMarksTooSlow(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(60 * time.Millisecond)
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name        string
+		path        string
+		maxDuration time.Duration
+		wantStatus  status.Status
+		wantMet     bool
+	}{
+		{"within SLO", "/", time.Second, status.Valid, true},
+		{"too slow", "/", 10 * time.Millisecond, status.TooSlow, false},
+		{"other failure wins", "/missing", 10 * time.Millisecond, status.UnexpectedStatusCode, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := makeCfg(time.Second)
+			cfg.Routes["r"] = config.Route{}
+			ep := config.Endpoint{
+				Group:      "g",
+				Protocol:   "http",
+				Request:    config.EndpointRequest{URL: srv.URL + tt.path, Timeout: 2 * time.Second, Method: http.MethodGet},
+				Routes:     []string{"r"},
+				Validation: &config.EndpointValidation{StatusCode: http.StatusOK, MaxDuration: tt.maxDuration},
+			}
+			cfg.Endpoints["ep"] = ep
+			e := NewEngine(cfg, newValidator(false))
+			sub := &chanSub{ch: make(chan Result, 10)}
+			e.Subscribe(sub)
+
+			e.probeOnce(context.Background(), "ep", ep)
+			r := <-sub.ch
+			assert.Equal(t, tt.wantStatus, r.Status)
+			if assert.NotNil(t, r.SLOMet) {
+				assert.Equal(t, tt.wantMet, *r.SLOMet)
+			}
+		})
+	}
+}
+
+func TestEngine_MaintenanceWindows(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	cfg := makeCfg(time.Second)
+	cfg.Routes["r"] = config.Route{}
+	ep := config.Endpoint{
+		Group:       "g",
+		Protocol:    "http",
+		Request:     config.EndpointRequest{URL: srv.URL, Timeout: 200 * time.Millisecond, Method: http.MethodGet},
+		Routes:      []string{"r"},
+		Validation:  &config.EndpointValidation{StatusCode: http.StatusOK},
+		Maintenance: []config.MaintenanceWindow{{Schedule: "* * * * *", Duration: time.Hour, Mode: config.MaintenanceProbe}},
+	}
+	cfg.Endpoints["ep"] = ep
+	e := NewEngine(cfg, newValidator(false))
+	sub := &chanSub{ch: make(chan Result, 10)}
+	e.Subscribe(sub)
+
+	e.probeOnce(context.Background(), "ep", ep)
+	r := <-sub.ch
+	assert.Equal(t, status.Maintenance, r.Status)
+	assert.True(t, r.Maintenance)
+	assert.NoError(t, r.Err)
+	assert.Equal(t, string(status.UnexpectedStatusCode), r.Error, "the failure is kept as text")
+	assert.False(t, r.Failed())
+
+	ep.Maintenance = append(ep.Maintenance, config.MaintenanceWindow{Schedule: "* * * * *", Duration: time.Hour, Mode: config.MaintenanceSuppress})
+	assert.Equal(t, SuppressedMaintenance, e.suppressionReason("ep", ep, time.Now()))
+}
+
+func TestEngine_RetriesBeforeReporting(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= 2 {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name         string
+		retries      int
+		wantStatus   status.Status
+		wantAttempts int
+	}{
+		{"passes on the last retry", 2, status.Valid, 3},
+		{"retries used up", 1, status.UnexpectedStatusCode, 2},
+		{"no retries", 0, status.UnexpectedStatusCode, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls.Store(0)
+			cfg := makeCfg(time.Second)
+			cfg.Routes["r"] = config.Route{}
+			ep := config.Endpoint{
+				Group:        "g",
+				Protocol:     "http",
+				Request:      config.EndpointRequest{URL: srv.URL, Timeout: 200 * time.Millisecond, Method: http.MethodGet},
+				Routes:       []string{"r"},
+				Validation:   &config.EndpointValidation{StatusCode: http.StatusOK},
+				Retries:      tt.retries,
+				RetryBackoff: time.Millisecond,
+			}
+			cfg.Endpoints["ep"] = ep
+			e := NewEngine(cfg, newValidator(false))
+			sub := &chanSub{ch: make(chan Result, 10)}
+			e.Subscribe(sub)
+
+			e.probeOnce(context.Background(), "ep", ep)
+			r := <-sub.ch
+			assert.Equal(t, tt.wantStatus, r.Status)
+			assert.Equal(t, tt.wantAttempts, r.Attempts)
+			assert.Empty(t, sub.ch, "only the final outcome is reported")
+		})
+	}
+}
+
+func TestEngine_MaxWorkersBoundsConcurrency(t *testing.T) {
+	var running, peak atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := running.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		running.Add(-1)
+	}))
+	defer srv.Close()
+
+	cfg := makeCfg(time.Second)
+	cfg.Settings.MaxWorkersCount = 2
+	cfg.Routes["r"] = config.Route{}
+	e := NewEngine(cfg, newValidator(false))
+
+	var wg sync.WaitGroup
+	for i := range 6 {
+		ep := config.Endpoint{
+			Group:      "g",
+			Protocol:   "http",
+			Request:    config.EndpointRequest{URL: srv.URL, Timeout: time.Second, Method: http.MethodGet},
+			Routes:     []string{"r"},
+			Validation: &config.EndpointValidation{StatusCode: http.StatusOK},
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			e.probeOnce(context.Background(), fmt.Sprintf("ep%d", i), ep)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(2), peak.Load(), "at most max-workers-count probes at once")
+	st := e.Stats()
+	assert.Zero(t, st.ProbesQueued)
+	assert.Zero(t, st.ProbesInFlight)
+	assert.Equal(t, uint64(6), st.WorkerWaits)
+	assert.Positive(t, st.WorkerWaitTotal)
+
+	// a stopping loop gives up waiting instead of recording a result
+	release, ok := e.acquireWorker(context.Background())
+	assert.True(t, ok)
+	e.setWorkers(1)
+	release2, _ := e.acquireWorker(context.Background())
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, ok = e.acquireWorker(ctx)
+	assert.False(t, ok)
+	release()
+	release2()
+}
+
+func TestTokenBucket_Reserve(t *testing.T) {
+	b := newTokenBucket(2, 3) // 2 per second, burst 3
+	now := time.Unix(1700000000, 0)
+	for i := range 3 {
+		assert.Zero(t, b.reserve(now), "burst token #%d", i)
+	}
+	assert.Equal(t, 500*time.Millisecond, b.reserve(now))
+	assert.Equal(t, time.Second, b.reserve(now), "reservations queue up")
+	b.cancel()
+	assert.Equal(t, time.Second, b.reserve(now), "a cancelled reservation frees its slot")
+	assert.Zero(t, b.reserve(now.Add(10*time.Second)), "refilled, up to the burst")
+}
+
+func TestEngine_RateLimitPerHost(t *testing.T) {
+	cfg := makeCfg(time.Second)
+	cfg.Settings.RateLimit = &config.RateLimitConfig{PerHost: 20, PerHostBurst: 1}
+	e := NewEngine(cfg, newValidator(false))
+
+	start := time.Now()
+	for range 3 {
+		assert.True(t, e.waitRateLimit(context.Background(), "https://a.example.com/health"))
+	}
+	assert.True(t, e.waitRateLimit(context.Background(), "https://b.example.com/"), "another host has its own bucket")
+	assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond, "two probes of a.example.com waited 50ms each")
+	assert.Equal(t, uint64(2), e.Stats().RateLimitWaits)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.False(t, e.waitRateLimit(ctx, "https://a.example.com/"), "a stopping loop gives up")
+
+	e.setRateLimit(nil)
+	assert.True(t, e.waitRateLimit(ctx, "https://a.example.com/"), "no limit after a reload without rate-limit")
+}
+
+func TestEngine_RateLimitPerRenderedHost(t *testing.T) {
+	t.Setenv("WD_TEST_RL_HOST", "c.example.com")
+	cfg := makeCfg(time.Second)
+	cfg.Settings.RateLimit = &config.RateLimitConfig{PerHost: 20, PerHostBurst: 1}
+	e := NewEngine(cfg, newValidator(false))
+	endpoint := config.Endpoint{Request: config.EndpointRequest{URL: `https://{{ env "WD_TEST_RL_HOST" }}/health`}}
+
+	_, ok := e.withRetries(context.Background(), endpoint, func() Result { return Result{Status: status.Valid} })
+	assert.True(t, ok)
+	assert.Equal(t, []string{"c.example.com"}, slices.Collect(maps.Keys(e.limiter.hosts)), "the bucket of the rendered host")
+}
+
+func TestEngine_ForgetIPsDropsVanishedAddresses(t *testing.T) {
+	cfg := makeCfg(time.Second)
+	ep := config.Endpoint{Group: "g", Protocol: "http", Request: config.EndpointRequest{URL: "http://a"}}
+	e := NewEngine(cfg, newValidator(false))
+	sub := &removalSub{removed: make(chan Result, 10)}
+	e.Subscribe(sub)
+
+	for _, ip := range []string{"10.0.0.1", "10.0.0.2"} {
+		e.record(Result{Group: "g", Endpoint: "ep", Protocol: "http", URL: "http://a", Route: "r", IP: ip, Status: status.Valid})
+	}
+	e.forgetIPs("ep", ep, "r", []string{"10.0.0.1", "10.0.0.2"})
+	assert.Len(t, e.store.Snapshot(), 2)
+	assert.Empty(t, sub.removed)
+
+	e.forgetIPs("ep", ep, "r", []string{"10.0.0.1"})
+	snap := e.store.Snapshot()
+	if assert.Len(t, snap, 1) {
+		assert.Equal(t, "10.0.0.1", snap[0].IP)
+	}
+	gone := <-sub.removed
+	assert.Equal(t, "10.0.0.2", gone.IP)
+	assert.Equal(t, "r", gone.Route)
+}
+
+func TestEngine_RouteCanaries(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close() // nothing listens there any more: a dead proxy
+
+	cfg := makeCfg(10 * time.Millisecond)
+	canary := &config.RouteCanary{URL: srv.URL, StatusCode: http.StatusOK, Timeout: 200 * time.Millisecond}
+	cfg.Routes["direct"] = config.Route{Canary: canary}
+	cfg.Routes["via-proxy"] = config.Route{ProxyUrl: closed.URL, Canary: canary}
+	cfg.Routes["plain"] = config.Route{}
+
+	e := NewEngine(cfg, newValidator(false))
+	sub := &routeSub{routes: make(chan RouteResult, 100)}
+	e.Subscribe(sub)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go e.Start(ctx)
+
+	up := map[string]bool{}
+	deadline := time.After(2 * time.Second)
+	for len(up) < 2 {
+		select {
+		case r := <-sub.routes:
+			up[r.Route] = r.Up()
+		case <-deadline:
+			t.Fatalf("route canaries not reported, got %v", up)
+		}
+	}
+	assert.True(t, up["direct"])
+	assert.False(t, up["via-proxy"])
+	_, probed := up["plain"]
+	assert.False(t, probed, "routes without a canary are not probed")
+}
+
+func TestEngine_ReloadStartsAndStopsLoops(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+	endpoint := func() config.Endpoint {
+		return config.Endpoint{Group: "g", Protocol: "http", Routes: []string{"direct"},
+			Request:    config.EndpointRequest{Method: http.MethodGet, URL: srv.URL, Timeout: time.Second},
+			Validation: &config.EndpointValidation{StatusCode: http.StatusOK}}
+	}
+	cfg := makeCfg(10 * time.Millisecond)
+	cfg.Routes["direct"] = config.Route{}
+	cfg.Endpoints["a"] = endpoint()
+
+	e := NewEngine(cfg, newValidator(false))
+	results := &chanSub{ch: make(chan Result, 100)}
+	removed := &removalSub{removed: make(chan Result, 10)}
+	e.Subscribe(results)
+	e.Subscribe(removed)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go e.Start(ctx)
+
+	waitFor := func(endpointName string) {
+		deadline := time.After(2 * time.Second)
+		for {
+			select {
+			case r := <-results.ch:
+				if r.Endpoint == endpointName {
+					return
+				}
+			case <-deadline:
+				t.Fatalf("no result for %s", endpointName)
+			}
+		}
+	}
+	waitFor("a")
+
+	next := makeCfg(10 * time.Millisecond)
+	next.Routes["direct"] = config.Route{}
+	next.Endpoints["b"] = endpoint()
+	e.Reload(next)
+	waitFor("b")
+
+	gone := <-removed.removed
+	assert.Equal(t, "a", gone.Endpoint)
+	for _, r := range e.store.Snapshot() {
+		assert.Equal(t, "b", r.Endpoint, "results of removed endpoints are dropped")
+	}
+	e.muLoops.Lock()
+	_, running := e.endpointLoops["a"]
+	e.muLoops.Unlock()
+	assert.False(t, running)
+}
+
+func TestEngine_ReloadParksDisabledEndpoints(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+	endpoint := func(enabled bool) config.Endpoint {
+		return config.Endpoint{Group: "g", Protocol: "http", Routes: []string{"direct"}, Enabled: &enabled,
+			Request: config.EndpointRequest{Method: http.MethodGet, URL: srv.URL, Timeout: time.Second}}
+	}
+	cfg := makeCfg(10 * time.Millisecond)
+	cfg.Routes["direct"] = config.Route{}
+	cfg.Endpoints["a"] = endpoint(true)
+	cfg.Endpoints["parked"] = endpoint(false)
+
+	e := NewEngine(cfg, newValidator(false))
+	results := &chanSub{ch: make(chan Result, 100)}
+	removed := &removalSub{removed: make(chan Result, 10)}
+	e.Subscribe(results)
+	e.Subscribe(removed)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go e.Start(ctx)
+
+	select {
+	case r := <-results.ch:
+		assert.Equal(t, "a", r.Endpoint, "disabled endpoints are not probed")
+	case <-time.After(2 * time.Second):
+		t.Fatal("no result")
+	}
+	_, err := e.ProbeEndpoint(ctx, "parked", "")
+	assert.EqualError(t, err, `endpoint "parked" is disabled`)
+
+	next := makeCfg(10 * time.Millisecond)
+	next.Routes["direct"] = config.Route{}
+	next.Endpoints["a"] = endpoint(false)
+	next.Endpoints["parked"] = endpoint(false)
+	e.Reload(next)
+
+	gone := <-removed.removed
+	assert.Equal(t, "a", gone.Endpoint, "disabling an endpoint drops its results")
+	assert.Empty(t, e.store.Snapshot())
+	e.muLoops.Lock()
+	assert.Empty(t, e.endpointLoops)
+	e.muLoops.Unlock()
+}
+
+func TestProduces(t *testing.T) {
+	cfg := makeCfg(time.Second)
+	cfg.Endpoints["ep"] = config.Endpoint{Group: "g", Protocol: "http", Routes: []string{"r"}, Request: config.EndpointRequest{URL: "http://a"}}
+	r := Result{Group: "g", Endpoint: "ep", Protocol: "http", URL: "http://a", Route: "r"}
+	assert.True(t, produces(cfg, r))
+
+	moved := r
+	moved.URL = "http://b"
+	assert.False(t, produces(cfg, moved), "URL changed")
+	dropped := r
+	dropped.Route = "other"
+	assert.False(t, produces(cfg, dropped), "route removed from the endpoint")
+	perIP := r
+	perIP.IP = "10.0.0.1"
+	assert.False(t, produces(cfg, perIP), "probe-all-ips switched off")
+}
+
+func TestEngine_ProbeEndpointDoesNotRecord(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+	cfg := makeCfg(time.Minute)
+	cfg.Routes["direct"] = config.Route{}
+	cfg.Routes["other"] = config.Route{}
+	cfg.Endpoints["ep"] = config.Endpoint{Group: "g", Protocol: "http", Routes: []string{"direct", "other"},
+		Request:    config.EndpointRequest{Method: http.MethodGet, URL: srv.URL, Timeout: time.Second},
+		Validation: &config.EndpointValidation{StatusCode: http.StatusOK}}
+	e := NewEngine(cfg, newValidator(false))
+	sub := &chanSub{ch: make(chan Result, 10)}
+	e.Subscribe(sub)
+
+	results, err := e.ProbeEndpoint(context.Background(), "ep", "direct")
+	assert.NoError(t, err)
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, "direct", results[0].Route)
+		assert.Equal(t, status.Valid, results[0].Status)
+		assert.Equal(t, results[0].At, results[0].StatusSince)
+	}
+	results, err = e.ProbeEndpoint(context.Background(), "ep", "")
+	assert.NoError(t, err)
+	assert.Len(t, results, 2, "all routes without a route parameter")
+	assert.Empty(t, e.store.Snapshot())
+	assert.Empty(t, sub.ch)
+
+	_, err = e.ProbeEndpoint(context.Background(), "nope", "")
+	assert.EqualError(t, err, `unknown endpoint "nope"`)
+	_, err = e.ProbeEndpoint(context.Background(), "ep", "missing")
+	assert.EqualError(t, err, `endpoint "ep" has no route "missing"`)
+
+	// on-demand probes wait for a worker like scheduled ones
+	e.setWorkers(1)
+	release, ok := e.AcquireWorker(context.Background())
+	assert.True(t, ok)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = e.ProbeEndpoint(ctx, "ep", "direct")
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "the only worker is taken")
+	release()
+	_, err = e.ProbeEndpoint(context.Background(), "ep", "direct")
+	assert.NoError(t, err)
+}
+
 // --- helpers ---
 
 func makeCfg(interval time.Duration) *config.WatchDogConfig {
@@ -286,3 +940,82 @@ func (c *chanSub) OnResult(r Result) {
 		// drop on overflow to avoid deadlocks in tests
 	}
 }
+
+type suppressionSub struct {
+	chanSub
+	suppr chan Suppression
+}
+
+type routeSub struct {
+	routes chan RouteResult
+}
+
+func (s *routeSub) OnResult(Result) {}
+
+func (s *routeSub) OnRouteResult(r RouteResult) {
+	select {
+	case s.routes <- r:
+	default:
+	}
+}
+
+type removalSub struct {
+	removed chan Result
+}
+
+func (s *removalSub) OnResult(Result) {}
+
+func (s *removalSub) OnRemoved(r Result) {
+	s.removed <- r
+}
+
+func (s *suppressionSub) OnSuppressed(sp Suppression) {
+	select {
+	case s.suppr <- sp:
+	default:
+	}
+}
+
+func TestEngine_ProbeSpread(t *testing.T) {
+	cfg := makeCfg(time.Minute)
+	for _, name := range []string{"d", "b", "a", "c"} {
+		cfg.Endpoints[name] = config.Endpoint{}
+	}
+	cfg.Endpoints["slow"] = config.Endpoint{Interval: 5 * time.Minute}
+	cfg.Endpoints["nightly"] = config.Endpoint{Schedule: "@daily"}
+	e := NewEngine(cfg, newValidator(false))
+
+	cfg.Settings.ProbeSpread = config.SpreadEven
+	for i, name := range []string{"a", "b", "c", "d"} {
+		assert.Equal(t, time.Duration(i)*15*time.Second, e.offsetOf(name, time.Minute), "endpoint %s", name)
+	}
+	assert.Zero(t, e.offsetOf("slow", 5*time.Minute), "alone in its interval")
+
+	cfg.Settings.ProbeSpread = config.SpreadNone
+	assert.Zero(t, e.offsetOf("c", time.Minute))
+	cfg.Settings.ProbeSpread = config.SpreadHash
+	assert.Equal(t, startOffset("c", time.Minute), e.offsetOf("c", time.Minute))
+}
+
+func TestStartOffset_DeterministicAndSpread(t *testing.T) {
+	interval := time.Minute
+	if startOffset("a", interval) != startOffset("a", interval) {
+		t.Fatal("expected a stable offset per endpoint")
+	}
+	buckets := make(map[int]int)
+	for i := 0; i < 600; i++ {
+		off := startOffset(fmt.Sprintf("endpoint-%d", i), interval)
+		if off < 0 || off >= interval {
+			t.Fatalf("offset %v out of [0, %v)", off, interval)
+		}
+		buckets[int(off/(6*time.Second))]++
+	}
+	for b := 0; b < 10; b++ {
+		if buckets[b] < 30 || buckets[b] > 90 {
+			t.Errorf("expected ~60 endpoints per tenth of the interval, bucket %d has %d", b, buckets[b])
+		}
+	}
+	if startOffset("a", 0) != 0 {
+		t.Error("expected no offset without an interval")
+	}
+}
@@ -0,0 +1,70 @@
+package prober
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/kinjelom/watchdog_exporter/status"
+	"github.com/kinjelom/watchdog_exporter/validator"
+)
+
+// ProbeEndpoint probes an endpoint right away over one route (all of its routes when routeName is "") and returns
+// the results without recording them: scheduled probes, stored results, state and subscribers are not affected.
+func (e *Engine) ProbeEndpoint(ctx context.Context, endpointName, routeName string) ([]Result, error) {
+	cfg := e.config()
+	endpoint, ok := cfg.Endpoints[endpointName]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownEndpoint, endpointName)
+	}
+	if endpoint.Disabled() {
+		return nil, fmt.Errorf("endpoint %q is disabled", endpointName)
+	}
+	routes := endpoint.Routes
+	if routeName != "" {
+		if !slices.Contains(routes, routeName) {
+			return nil, fmt.Errorf("endpoint %q has no route %q", endpointName, routeName)
+		}
+		routes = []string{routeName}
+	}
+
+	var results []Result
+	for _, routeKey := range routes {
+		route := cfg.Routes[routeKey]
+		if !probesAllIPs(endpoint, route) {
+			res, ok := e.withRetries(ctx, endpoint, func() Result {
+				rep, err := e.probe(endpointName, endpoint, routeKey, route)
+				return e.resultOf(endpointName, endpoint, routeKey, "", rep, err)
+			})
+			if !ok {
+				return nil, ctx.Err()
+			}
+			results = append(results, e.onDemand(res))
+			continue
+		}
+		ips, err := e.validator.ResolveHost(ctx, endpoint.Request)
+		if err != nil {
+			results = append(results, e.onDemand(e.resultOf(endpointName, endpoint, routeKey, "", validator.Report{Status: status.InvalidRequestExecution}, err)))
+			continue
+		}
+		for _, ip := range ips {
+			res, ok := e.withRetries(ctx, endpoint, func() Result {
+				return e.probeIP(endpointName, endpoint, routeKey, route, ip)
+			})
+			if !ok {
+				return nil, ctx.Err()
+			}
+			results = append(results, e.onDemand(res))
+		}
+	}
+	return results, nil
+}
+
+// onDemand fills what trackTransition would, as if the result were the first of its key.
+func (e *Engine) onDemand(r Result) Result {
+	r.StatusSince = r.At
+	if r.Failed() {
+		r.ConsecutiveFailures = 1
+	}
+	return r
+}
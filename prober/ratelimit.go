@@ -0,0 +1,133 @@
+package prober
+
+import (
+	"context"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+)
+
+// tokenBucket refills rate tokens per second up to burst; a probe takes one.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	return &tokenBucket{rate: rate, burst: float64(max(burst, 1)), tokens: float64(max(burst, 1))}
+}
+
+// reserve takes a token and returns how long to wait until it is covered (0 = now).
+func (b *tokenBucket) reserve(now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.last.IsZero() {
+		b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	}
+	b.last = now
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// cancel gives back a token taken by reserve whose probe did not run.
+func (b *tokenBucket) cancel() {
+	b.mu.Lock()
+	b.tokens = min(b.burst, b.tokens+1)
+	b.mu.Unlock()
+}
+
+// rateLimiter holds the buckets of settings.rate-limit.
+type rateLimiter struct {
+	cfg    config.RateLimitConfig
+	global *tokenBucket // nil = unlimited
+
+	mu    sync.Mutex
+	hosts map[string]*tokenBucket
+}
+
+func newRateLimiter(cfg *config.RateLimitConfig) *rateLimiter {
+	if cfg == nil || (cfg.Global <= 0 && cfg.PerHost <= 0) {
+		return nil
+	}
+	l := &rateLimiter{cfg: *cfg, hosts: make(map[string]*tokenBucket)}
+	if cfg.Global > 0 {
+		l.global = newTokenBucket(cfg.Global, cfg.Burst)
+	}
+	return l
+}
+
+// buckets returns the buckets a probe of host takes a token from.
+func (l *rateLimiter) buckets(host string) []*tokenBucket {
+	var out []*tokenBucket
+	if l.global != nil {
+		out = append(out, l.global)
+	}
+	if l.cfg.PerHost > 0 && host != "" {
+		l.mu.Lock()
+		b, ok := l.hosts[host]
+		if !ok {
+			b = newTokenBucket(l.cfg.PerHost, l.cfg.PerHostBurst)
+			l.hosts[host] = b
+		}
+		l.mu.Unlock()
+		out = append(out, b)
+	}
+	return out
+}
+
+// setRateLimit replaces the limiter when settings.rate-limit changed (a reload resets the buckets).
+func (e *Engine) setRateLimit(cfg *config.RateLimitConfig) {
+	e.muLimit.Lock()
+	defer e.muLimit.Unlock()
+	var cur *config.RateLimitConfig
+	if e.limiter != nil {
+		cur = &e.limiter.cfg
+	}
+	if (cur == nil) != (cfg == nil) || (cur != nil && *cur != *cfg) {
+		e.limiter = newRateLimiter(cfg)
+	}
+}
+
+// waitRateLimit waits until a probe of rawURL's host may start under settings.rate-limit; false when ctx is done
+// first.
+func (e *Engine) waitRateLimit(ctx context.Context, rawURL string) bool {
+	e.muLimit.Lock()
+	l := e.limiter
+	e.muLimit.Unlock()
+	if l == nil {
+		return true
+	}
+	host := rawURL
+	if u, err := url.Parse(rawURL); err == nil && u.Host != "" {
+		host = u.Hostname()
+	}
+	buckets := l.buckets(host)
+	now := time.Now()
+	var wait time.Duration
+	for _, b := range buckets {
+		wait = max(wait, b.reserve(now))
+	}
+	if wait == 0 {
+		return true
+	}
+	e.stats.observeRateLimitWait(wait)
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		for _, b := range buckets {
+			b.cancel()
+		}
+		return false
+	}
+}
@@ -0,0 +1,227 @@
+package prober
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+)
+
+// RouteRemovalSubscriber is an optional Subscriber extension notified when a route canary is removed by a reload.
+type RouteRemovalSubscriber interface {
+	OnRouteRemoved(routeName string)
+}
+
+// loopHandle stops one running probe loop.
+type loopHandle struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// stop cancels the loop and waits for its in-flight probe to finish.
+func (h loopHandle) stop() {
+	h.cancel()
+	<-h.done
+}
+
+func hasCanary(route config.Route) bool {
+	return route.Canary != nil && route.Canary.URL != ""
+}
+
+// spawn runs a loop under the Start context; callers hold muLoops.
+func (e *Engine) spawn(run func(context.Context)) loopHandle {
+	ctx, cancel := context.WithCancel(e.runCtx)
+	done := make(chan struct{})
+	e.loops.Add(1)
+	go func() {
+		defer e.loops.Done()
+		defer close(done)
+		run(ctx)
+	}()
+	return loopHandle{cancel: cancel, done: done}
+}
+
+func (e *Engine) startEndpointLoop(endpointName string, endpoint config.Endpoint) {
+	e.endpointLoops[endpointName] = e.spawn(func(ctx context.Context) {
+		e.runEndpointLoop(ctx, endpointName, endpoint)
+	})
+}
+
+func (e *Engine) startRouteLoop(routeName string, route config.Route) {
+	e.routeLoops[routeName] = e.spawn(func(ctx context.Context) {
+		e.runRouteLoop(ctx, routeName, route)
+	})
+}
+
+// Reload switches the engine to cfg without a restart: loops of removed or changed endpoints (and route canaries)
+// are stopped, new and changed ones started, and the results of keys cfg no longer produces are dropped
+// (RemovalSubscriber is notified). Unchanged endpoints keep their loops and state.
+func (e *Engine) Reload(cfg *config.WatchDogConfig) {
+	e.muCfg.Lock()
+	old := e.cfg
+	e.cfg = cfg
+	e.muCfg.Unlock()
+	e.setWorkers(cfg.Settings.MaxWorkersCount)
+	e.setRateLimit(cfg.Settings.RateLimit)
+
+	e.muLoops.Lock()
+	defer e.muLoops.Unlock()
+	if e.runCtx == nil {
+		return // not started: Start picks up cfg
+	}
+	intervalChanged := old.Settings.ProbeInterval != cfg.Settings.ProbeInterval
+
+	// disabled endpoints are handled like removed ones
+	active := cfg.ActiveEndpoints()
+	var stopped, started []string
+	for name, h := range e.endpointLoops {
+		ep, ok := active[name]
+		if ok && !intervalChanged && !endpointChanged(old, cfg, name) {
+			continue
+		}
+		h.stop()
+		delete(e.endpointLoops, name)
+		e.resetEndpoint(name, old.Endpoints[name])
+		// series carry the labels: results with the old values must go
+		if !ok || ep.ProbeAllIPs != old.Endpoints[name].ProbeAllIPs || !maps.Equal(ep.Labels, old.Endpoints[name].Labels) {
+			e.forgetEndpoint(name)
+		}
+		if !ok {
+			stopped = append(stopped, name)
+		}
+		if _, configured := cfg.Endpoints[name]; !configured {
+			e.unpause(name)
+		}
+	}
+	for name, ep := range active {
+		if _, running := e.endpointLoops[name]; !running {
+			if _, existed := old.ActiveEndpoints()[name]; !existed {
+				started = append(started, name)
+			}
+			e.startEndpointLoop(name, ep)
+		}
+	}
+
+	for name, h := range e.routeLoops {
+		route, ok := cfg.Routes[name]
+		if ok && hasCanary(route) && !intervalChanged && reflect.DeepEqual(old.Routes[name], route) {
+			continue
+		}
+		h.stop()
+		delete(e.routeLoops, name)
+		e.validator.Forget(routeLoopName(name))
+		if !ok || !hasCanary(route) {
+			e.muRoutes.Lock()
+			delete(e.routeUp, name)
+			e.muRoutes.Unlock()
+			e.notifyRouteRemoved(name)
+		}
+	}
+	for name, route := range cfg.Routes {
+		if _, running := e.routeLoops[name]; !running && hasCanary(route) {
+			e.startRouteLoop(name, route)
+		}
+	}
+
+	e.dropStale(cfg)
+	slices.Sort(stopped)
+	slices.Sort(started)
+	slog.Info("configuration reloaded", "endpoints", len(active), "added", started, "removed", stopped)
+}
+
+// endpointChanged reports whether the endpoint or any of its routes (canaries aside) differ between the configs.
+func endpointChanged(old, cfg *config.WatchDogConfig, name string) bool {
+	ep := cfg.Endpoints[name]
+	if !reflect.DeepEqual(old.Endpoints[name], ep) {
+		return true
+	}
+	for _, routeKey := range ep.Routes {
+		if !reflect.DeepEqual(withoutCanary(old.Routes[routeKey]), withoutCanary(cfg.Routes[routeKey])) {
+			return true
+		}
+	}
+	return false
+}
+
+func withoutCanary(route config.Route) config.Route {
+	route.Canary = nil
+	return route
+}
+
+// resetEndpoint clears the runtime state of a stopped loop: suppression (subscribers see it resumed),
+// transports, probe-all-ips bookkeeping and scheduler stats.
+func (e *Engine) resetEndpoint(endpointName string, endpoint config.Endpoint) {
+	e.updateSuppression(endpointName, endpoint, "")
+	e.validator.Forget(endpointName)
+
+	prefix := endpointName + "\x00"
+	e.muIPs.Lock()
+	for id := range e.probedIPs {
+		if strings.HasPrefix(id, prefix) {
+			delete(e.probedIPs, id)
+		}
+	}
+	e.muIPs.Unlock()
+	e.stats.forget(endpointName)
+}
+
+// forgetEndpoint drops every result of an endpoint.
+func (e *Engine) forgetEndpoint(endpointName string) {
+	for _, r := range e.store.Snapshot() {
+		if r.Endpoint == endpointName {
+			e.forgetResult(r)
+		}
+	}
+}
+
+// dropStale drops the results whose key cfg no longer produces (renamed group, changed URL, removed route...).
+func (e *Engine) dropStale(cfg *config.WatchDogConfig) {
+	for _, r := range e.store.Snapshot() {
+		if !produces(cfg, r) {
+			e.forgetResult(r)
+		}
+	}
+}
+
+func produces(cfg *config.WatchDogConfig, r Result) bool {
+	ep, ok := cfg.Endpoints[r.Endpoint]
+	if !ok || ep.Disabled() {
+		return false
+	}
+	return ep.Group == r.Group && ep.Protocol == r.Protocol && ep.Request.URL == r.URL &&
+		slices.Contains(ep.Routes, r.Route) && (r.IP == "" || ep.ProbeAllIPs)
+}
+
+// forgetResult drops all state kept for the key of r and notifies RemovalSubscriber.
+func (e *Engine) forgetResult(r Result) {
+	key := e.keyOf(r)
+	e.muErr.Lock()
+	delete(e.lastResults, key)
+	e.muErr.Unlock()
+	e.sla.Forget(key)
+	e.store.Delete(r)
+	e.notifyRemoved(r)
+}
+
+func (e *Engine) notifyRouteRemoved(routeName string) {
+	e.muSubs.RLock()
+	defer e.muSubs.RUnlock()
+	for _, sub := range e.subs {
+		rs, ok := sub.(RouteRemovalSubscriber)
+		if !ok {
+			continue
+		}
+		func() {
+			defer func() {
+				if rec := recover(); rec != nil {
+					e.stats.subscriberPanics.Add(1)
+				}
+			}()
+			rs.OnRouteRemoved(routeName)
+		}()
+	}
+}
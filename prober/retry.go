@@ -0,0 +1,44 @@
+package prober
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+	"github.com/kinjelom/watchdog_exporter/status"
+)
+
+// withRetries runs attempt, each within the rate limit and on a worker, until it passes, the retries of the endpoint are used up or ctx is
+// done, waiting retry-backoff (doubled after every failure) in between. Only the last result counts; its Attempts
+// says how many tries it took. Definition errors are not retried: they fail the same way every time. It returns
+// false when ctx was done before the first attempt could start.
+func (e *Engine) withRetries(ctx context.Context, endpoint config.Endpoint, attempt func() Result) (Result, bool) {
+	backoff := endpoint.RetryBackoff
+	var res Result
+	for n := 1; ; n++ {
+		if !e.waitRateLimit(ctx, e.validator.RequestURL(endpoint.Request)) {
+			return res, n > 1
+		}
+		release, ok := e.acquireWorker(ctx)
+		if !ok {
+			return res, n > 1
+		}
+		res = attempt()
+		release()
+		res.Attempts = n
+		if !res.Failed() || n > endpoint.Retries || res.ErrorClass == status.ClassConfig {
+			return res, true
+		}
+		slog.Debug("probe failed, retrying", "endpoint", res.Endpoint, "route", res.Route, "ip", res.IP,
+			"attempt", n, "status", res.Status, "backoff", backoff)
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return res, true
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
@@ -0,0 +1,125 @@
+package prober
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+	"github.com/kinjelom/watchdog_exporter/status"
+	"github.com/kinjelom/watchdog_exporter/validator"
+)
+
+// RouteResult is the outcome of a route canary probe.
+type RouteResult struct {
+	Route    string
+	URL      string
+	Status   status.Status
+	Duration float64
+	Err      error
+	Error    string // sanitized Err
+	At       time.Time
+}
+
+// Up reports whether the canary passed, i.e. the route itself works.
+func (r RouteResult) Up() bool {
+	return r.Err == nil && r.Status == status.Valid
+}
+
+// RouteSubscriber is an optional Subscriber extension notified of route canary results.
+type RouteSubscriber interface {
+	OnRouteResult(RouteResult)
+}
+
+// routeLoopName keeps canary transports and debug switches apart from endpoints of the same name.
+func routeLoopName(routeName string) string {
+	return "route:" + routeName
+}
+
+func (e *Engine) runRouteLoop(ctx context.Context, routeName string, route config.Route) {
+	interval := e.config().Settings.ProbeInterval
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	offset := startOffset(routeLoopName(routeName), interval)
+	slog.Debug("route canary scheduled", "route", routeName, "interval", interval, "start_offset", offset)
+	timer := time.NewTimer(offset)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+			e.probeRoute(ctx, routeName, route)
+			timer.Reset(interval)
+		}
+	}
+}
+
+// probeRoute fetches the canary URL through the route and fans the result out.
+func (e *Engine) probeRoute(ctx context.Context, routeName string, route config.Route) {
+	canary := route.Canary
+	rc := config.EndpointRequest{Method: http.MethodGet, URL: canary.URL, Timeout: canary.Timeout}
+	validation := &config.EndpointValidation{StatusCode: canary.StatusCode}
+
+	if !e.waitRateLimit(ctx, canary.URL) {
+		return
+	}
+	release, ok := e.acquireWorker(ctx)
+	if !ok {
+		return
+	}
+	rep, err := e.validator.Probe(routeLoopName(routeName), rc, routeName, route, validation, false)
+	release()
+
+	res := RouteResult{
+		Route:    routeName,
+		URL:      canary.URL,
+		Status:   validator.ResolveStatus(rep.Status, err, rep.TLS),
+		Duration: rep.Duration,
+		Err:      err,
+		Error:    validator.SanitizeError(err),
+		At:       time.Now(),
+	}
+	e.trackRoute(res)
+	e.notifyRoute(res)
+}
+
+// trackRoute logs route canary transitions (first probe, down, recovered).
+func (e *Engine) trackRoute(r RouteResult) {
+	e.muRoutes.Lock()
+	wasUp, seen := e.routeUp[r.Route]
+	e.routeUp[r.Route] = r.Up()
+	e.muRoutes.Unlock()
+
+	attrs := []any{"route", r.Route, "url", r.URL, "status", r.Status}
+	switch {
+	case !seen:
+		slog.Info("route canary started", append(attrs, "err", r.Error)...)
+	case wasUp && !r.Up():
+		slog.Warn("route canary failed", append(attrs, "err", r.Error)...)
+	case !wasUp && r.Up():
+		slog.Info("route canary recovered", attrs...)
+	}
+}
+
+func (e *Engine) notifyRoute(r RouteResult) {
+	e.muSubs.RLock()
+	defer e.muSubs.RUnlock()
+	for _, sub := range e.subs {
+		rs, ok := sub.(RouteSubscriber)
+		if !ok {
+			continue
+		}
+		func() {
+			defer func() {
+				if rec := recover(); rec != nil {
+					e.stats.subscriberPanics.Add(1)
+				}
+			}()
+			rs.OnRouteResult(r)
+		}()
+	}
+}
@@ -0,0 +1,116 @@
+package prober
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// slaBucketsPerWindow is the resolution of every sliding window.
+const slaBucketsPerWindow = 60
+
+// SLATracker keeps per-key success/total counters over sliding windows.
+// Each window is split into slaBucketsPerWindow fixed buckets, so memory is
+// constant per key regardless of the probe interval.
+type SLATracker struct {
+	mu      sync.Mutex
+	windows []time.Duration
+	items   map[string][]*slaRing // key -> one ring per window
+}
+
+type slaBucket struct {
+	start int64 // bucket start (unix nanos), 0 = unused
+	total int
+	ok    int
+}
+
+type slaRing struct {
+	size    time.Duration
+	buckets [slaBucketsPerWindow]slaBucket
+}
+
+func NewSLATracker(windows []time.Duration) *SLATracker {
+	return &SLATracker{
+		windows: windows,
+		items:   make(map[string][]*slaRing),
+	}
+}
+
+// Forget drops the counters of key.
+func (t *SLATracker) Forget(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.items, key)
+}
+
+// Record adds r to all windows of its key and returns the availability ratio
+// (0..1) per window label, e.g. {"1h": 0.98, "24h": 0.995, "30d": 0.999}.
+func (t *SLATracker) Record(key string, r Result) map[string]float64 {
+	if len(t.windows) == 0 {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	rings, ok := t.items[key]
+	if !ok {
+		rings = make([]*slaRing, len(t.windows))
+		for i, w := range t.windows {
+			size := w / slaBucketsPerWindow
+			if size <= 0 {
+				size = 1
+			}
+			rings[i] = &slaRing{size: size}
+		}
+		t.items[key] = rings
+	}
+
+	out := make(map[string]float64, len(t.windows))
+	for i, ring := range rings {
+		ring.add(r.At, !r.Failed())
+		out[WindowLabel(t.windows[i])] = ring.ratio(r.At)
+	}
+	return out
+}
+
+func (g *slaRing) add(at time.Time, success bool) {
+	start := at.Truncate(g.size).UnixNano()
+	b := &g.buckets[(start/int64(g.size))%slaBucketsPerWindow]
+	if b.start != start {
+		*b = slaBucket{start: start}
+	}
+	b.total++
+	if success {
+		b.ok++
+	}
+}
+
+func (g *slaRing) ratio(now time.Time) float64 {
+	oldest := now.Truncate(g.size).UnixNano() - int64(g.size)*(slaBucketsPerWindow-1)
+	total, ok := 0, 0
+	for _, b := range g.buckets {
+		if b.start == 0 || b.start < oldest {
+			continue
+		}
+		total += b.total
+		ok += b.ok
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(ok) / float64(total)
+}
+
+// WindowLabel renders a window duration as a short label: 30m, 1h, 24h, 30d.
+func WindowLabel(d time.Duration) string {
+	switch {
+	case d >= 48*time.Hour && d%(24*time.Hour) == 0:
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	case d%time.Minute == 0:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	default:
+		return d.String()
+	}
+}
@@ -0,0 +1,37 @@
+package prober
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kinjelom/watchdog_exporter/status"
+)
+
+func TestSLATracker_RatioPerWindow(t *testing.T) {
+	tr := NewSLATracker([]time.Duration{time.Hour, 24 * time.Hour})
+	start := time.Unix(1700000000, 0)
+
+	// 3 ok + 1 failure within the first hour
+	for i, st := range []status.Status{status.Valid, status.Valid, status.RequestExecutionTimeout, status.Valid} {
+		tr.Record("k", Result{Status: st, At: start.Add(time.Duration(i) * time.Minute)})
+	}
+
+	// 2h later the failure has left the 1h window but is still in the 24h one
+	got := tr.Record("k", Result{Status: "valid", At: start.Add(2 * time.Hour)})
+	assert.InDelta(t, 1.0, got["1h"], 1e-9)
+	assert.InDelta(t, 0.8, got["24h"], 1e-9)
+
+	// keys are independent
+	other := tr.Record("other", Result{Status: "unexpected-status-code", At: start})
+	assert.InDelta(t, 0.0, other["1h"], 1e-9)
+}
+
+func TestWindowLabel(t *testing.T) {
+	assert.Equal(t, "30m", WindowLabel(30*time.Minute))
+	assert.Equal(t, "1h", WindowLabel(time.Hour))
+	assert.Equal(t, "24h", WindowLabel(24*time.Hour))
+	assert.Equal(t, "30d", WindowLabel(30*24*time.Hour))
+	assert.Equal(t, "1m30s", WindowLabel(90*time.Second))
+}
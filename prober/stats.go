@@ -0,0 +1,108 @@
+package prober
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Stats is a point-in-time view of the engine internals (self-observability).
+type Stats struct {
+	ProbesInFlight   int
+	MaxWorkers       int
+	ProbesQueued     int           // probes waiting for a free worker
+	WorkerWaits      uint64        // probes that got a worker
+	WorkerWaitTotal  time.Duration // time those probes waited for it
+	RateLimitWaits   uint64        // probes delayed by settings.rate-limit
+	RateLimitWait    time.Duration // total delay
+	StoreSize        int
+	SubscriberPanics uint64
+	SchedulerLag     map[string]time.Duration // endpoint -> lag of its last scheduled probe
+	StartOffset      map[string]time.Duration // endpoint -> delay of its first probe within the interval
+}
+
+// StatsProvider exposes engine internals for self-observability exporters.
+type StatsProvider interface {
+	Stats() Stats
+}
+
+// engineStats holds the counters behind Engine.Stats.
+type engineStats struct {
+	inFlight         atomic.Int64
+	queued           atomic.Int64
+	waits            atomic.Uint64
+	waitNanos        atomic.Int64
+	limitWaits       atomic.Uint64
+	limitNanos       atomic.Int64
+	subscriberPanics atomic.Uint64
+
+	muLag  sync.Mutex
+	lag    map[string]time.Duration
+	offset map[string]time.Duration
+}
+
+func (s *engineStats) observeWait(wait time.Duration) {
+	s.waits.Add(1)
+	s.waitNanos.Add(int64(wait))
+}
+
+func (s *engineStats) observeRateLimitWait(wait time.Duration) {
+	s.limitWaits.Add(1)
+	s.limitNanos.Add(int64(wait))
+}
+
+func (s *engineStats) setOffset(endpointName string, offset time.Duration) {
+	s.muLag.Lock()
+	if s.offset == nil {
+		s.offset = make(map[string]time.Duration)
+	}
+	s.offset[endpointName] = offset
+	s.muLag.Unlock()
+}
+
+func (s *engineStats) setLag(endpointName string, lag time.Duration) {
+	if lag < 0 {
+		lag = 0
+	}
+	s.muLag.Lock()
+	if s.lag == nil {
+		s.lag = make(map[string]time.Duration)
+	}
+	s.lag[endpointName] = lag
+	s.muLag.Unlock()
+}
+
+// forget drops the scheduler stats of an endpoint whose loop was stopped.
+func (s *engineStats) forget(endpointName string) {
+	s.muLag.Lock()
+	delete(s.lag, endpointName)
+	delete(s.offset, endpointName)
+	s.muLag.Unlock()
+}
+
+func (e *Engine) Stats() Stats {
+	e.stats.muLag.Lock()
+	lag := make(map[string]time.Duration, len(e.stats.lag))
+	for k, v := range e.stats.lag {
+		lag[k] = v
+	}
+	offset := make(map[string]time.Duration, len(e.stats.offset))
+	for k, v := range e.stats.offset {
+		offset[k] = v
+	}
+	e.stats.muLag.Unlock()
+
+	return Stats{
+		ProbesInFlight:   int(e.stats.inFlight.Load()),
+		MaxWorkers:       e.config().Settings.MaxWorkersCount,
+		ProbesQueued:     int(e.stats.queued.Load()),
+		WorkerWaits:      e.stats.waits.Load(),
+		WorkerWaitTotal:  time.Duration(e.stats.waitNanos.Load()),
+		RateLimitWaits:   e.stats.limitWaits.Load(),
+		RateLimitWait:    time.Duration(e.stats.limitNanos.Load()),
+		StoreSize:        e.store.Len(),
+		SubscriberPanics: e.stats.subscriberPanics.Load(),
+		SchedulerLag:     lag,
+		StartOffset:      offset,
+	}
+}
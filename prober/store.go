@@ -0,0 +1,96 @@
+package prober
+
+import "sync"
+
+// storeShards spreads keys over independent locks, so writers on different endpoints don't serialize
+// and a Snapshot only ever holds one shard at a time.
+const storeShards = 32
+
+// Store keeps the latest result per (group, endpoint, route, url, protocol).
+type Store struct {
+	shards []storeShard
+}
+
+type storeShard struct {
+	mu    sync.RWMutex
+	items map[string]Result // key -> last result
+}
+
+func NewStore() *Store {
+	return newStore(storeShards)
+}
+
+func newStore(shards int) *Store {
+	s := &Store{shards: make([]storeShard, shards)}
+	for i := range s.shards {
+		s.shards[i].items = make(map[string]Result)
+	}
+	return s
+}
+
+func (s *Store) keyOf(r Result) string {
+	// Stable small cardinality key
+	return r.Group + "\x00" + r.Endpoint + "\x00" + r.Route + "\x00" + r.Protocol + "\x00" + r.URL + "\x00" + r.IP
+}
+
+// shardOf picks the shard by FNV-1a hash of the key.
+func (s *Store) shardOf(key string) *storeShard {
+	h := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= 16777619
+	}
+	return &s.shards[h%uint32(len(s.shards))]
+}
+
+func (s *Store) Put(r Result) {
+	key := s.keyOf(r)
+	sh := s.shardOf(key)
+	sh.mu.Lock()
+	sh.items[key] = r
+	sh.mu.Unlock()
+}
+
+// Get returns the result stored under r's key.
+func (s *Store) Get(r Result) (Result, bool) {
+	key := s.keyOf(r)
+	sh := s.shardOf(key)
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+	res, ok := sh.items[key]
+	return res, ok
+}
+
+// Delete removes the result stored under r's key.
+func (s *Store) Delete(r Result) {
+	key := s.keyOf(r)
+	sh := s.shardOf(key)
+	sh.mu.Lock()
+	delete(sh.items, key)
+	sh.mu.Unlock()
+}
+
+func (s *Store) Len() int {
+	n := 0
+	for i := range s.shards {
+		sh := &s.shards[i]
+		sh.mu.RLock()
+		n += len(sh.items)
+		sh.mu.RUnlock()
+	}
+	return n
+}
+
+// Snapshot copies the results shard by shard; it is consistent per key, not across the whole store.
+func (s *Store) Snapshot() []Result {
+	out := make([]Result, 0, s.Len())
+	for i := range s.shards {
+		sh := &s.shards[i]
+		sh.mu.RLock()
+		for _, v := range sh.items {
+			out = append(out, v)
+		}
+		sh.mu.RUnlock()
+	}
+	return out
+}
@@ -0,0 +1,59 @@
+package prober
+
+import (
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+func TestStore_SpreadsOverShards(t *testing.T) {
+	s := NewStore()
+	for i := 0; i < 1000; i++ {
+		s.Put(Result{Group: "g", Endpoint: "ep-" + strconv.Itoa(i), Route: "direct"})
+	}
+	used := 0
+	for i := range s.shards {
+		if len(s.shards[i].items) > 0 {
+			used++
+		}
+	}
+	if used != storeShards {
+		t.Fatalf("expected all %d shards used, got %d", storeShards, used)
+	}
+	if s.Len() != 1000 || len(s.Snapshot()) != 1000 {
+		t.Fatalf("expected 1000 results, got Len=%d Snapshot=%d", s.Len(), len(s.Snapshot()))
+	}
+}
+
+// BenchmarkStore_PutWithSnapshots runs parallel writers while every 1000th operation takes a Snapshot
+// (RebuildAll, the results API), comparing a single lock with the sharded store.
+func BenchmarkStore_PutWithSnapshots(b *testing.B) {
+	const endpoints = 5000
+	results := make([]Result, endpoints)
+	for i := range results {
+		results[i] = Result{Group: "g", Endpoint: "ep-" + strconv.Itoa(i), Route: "direct", Protocol: "http", URL: "https://example.com/" + strconv.Itoa(i)}
+	}
+	for _, bc := range []struct {
+		name   string
+		shards int
+	}{{"single-lock", 1}, {"sharded", storeShards}} {
+		b.Run(bc.name, func(b *testing.B) {
+			s := newStore(bc.shards)
+			for _, r := range results {
+				s.Put(r)
+			}
+			var n atomic.Int64
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					i := n.Add(1)
+					if i%1000 == 0 {
+						_ = s.Snapshot()
+						continue
+					}
+					s.Put(results[i%endpoints])
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,179 @@
+package prober
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+)
+
+// Reasons why an endpoint is not probed.
+const (
+	SuppressedMaintenance = "maintenance"
+	SuppressedPaused      = "paused"
+	SuppressedDependency  = "dependency"
+)
+
+// ErrUnknownEndpoint is returned for an endpoint name the config does not define.
+var ErrUnknownEndpoint = errors.New("unknown endpoint")
+
+// Suppressor decides whether an endpoint probe should be skipped; it returns the reason or "".
+type Suppressor func(endpointName string, ep config.Endpoint, now time.Time) string
+
+// Suppression reports that probes of an endpoint+route are skipped (Reason == "" means resumed).
+type Suppression struct {
+	Group    string
+	Endpoint string
+	Protocol string
+	URL      string
+	Route    string
+	Labels   map[string]string
+	Reason   string
+}
+
+// SuppressionSubscriber is an optional Subscriber extension notified on suppression changes.
+type SuppressionSubscriber interface {
+	OnSuppressed(Suppression)
+}
+
+// AddSuppressor registers a suppression rule, evaluated before every probe cycle.
+func (e *Engine) AddSuppressor(s Suppressor) {
+	e.muSuppr.Lock()
+	defer e.muSuppr.Unlock()
+	e.suppressors = append(e.suppressors, s)
+}
+
+// Pause stops probing an endpoint, from its next cycle, until Resume is called; a pause outlives config reloads
+// that keep the endpoint.
+func (e *Engine) Pause(endpointName string) error {
+	if _, ok := e.config().Endpoints[endpointName]; !ok {
+		return fmt.Errorf("%w %q", ErrUnknownEndpoint, endpointName)
+	}
+	e.muSuppr.Lock()
+	defer e.muSuppr.Unlock()
+	e.paused[endpointName] = true
+	return nil
+}
+
+// Resume re-enables probing of a paused endpoint.
+func (e *Engine) Resume(endpointName string) error {
+	if _, ok := e.config().Endpoints[endpointName]; !ok {
+		return fmt.Errorf("%w %q", ErrUnknownEndpoint, endpointName)
+	}
+	e.unpause(endpointName)
+	return nil
+}
+
+func (e *Engine) unpause(endpointName string) {
+	e.muSuppr.Lock()
+	defer e.muSuppr.Unlock()
+	delete(e.paused, endpointName)
+}
+
+// suppressDependents skips an endpoint while one of its depends-on endpoints fails: every latest result of the
+// dependency failed. A dependency without results (not probed yet, disabled) or paused does not suppress. Like every
+// suppressor it runs with muSuppr held.
+func (e *Engine) suppressDependents(_ string, ep config.Endpoint, _ time.Time) string {
+	if len(ep.DependsOn) == 0 {
+		return ""
+	}
+	cfg := e.config()
+	for _, name := range ep.DependsOn {
+		dep, ok := cfg.Endpoints[name]
+		if ok && !e.paused[name] && e.dependencyDown(name, dep) {
+			return SuppressedDependency
+		}
+	}
+	return ""
+}
+
+// dependencyDown reports whether the endpoint has results and all of them failed; only its own keys are looked up.
+func (e *Engine) dependencyDown(endpointName string, endpoint config.Endpoint) bool {
+	down := false
+	for _, routeKey := range endpoint.Routes {
+		ips := []string{""}
+		e.muIPs.Lock()
+		if probed, ok := e.probedIPs[endpointName+"\x00"+routeKey]; ok {
+			ips = probed
+		}
+		e.muIPs.Unlock()
+		for _, ip := range ips {
+			r, ok := e.store.Get(Result{Group: endpoint.Group, Endpoint: endpointName, Protocol: endpoint.Protocol,
+				URL: endpoint.Request.URL, Route: routeKey, IP: ip})
+			if !ok {
+				continue
+			}
+			if !r.Failed() {
+				return false
+			}
+			down = true
+		}
+	}
+	return down
+}
+
+// suppressionReason evaluates pause state and suppressors ("" = probe now).
+func (e *Engine) suppressionReason(endpointName string, ep config.Endpoint, now time.Time) string {
+	e.muSuppr.Lock()
+	defer e.muSuppr.Unlock()
+	if e.paused[endpointName] {
+		return SuppressedPaused
+	}
+	if w := ep.MaintenanceAt(now); w != nil && w.Mode == config.MaintenanceSuppress {
+		return SuppressedMaintenance
+	}
+	for _, s := range e.suppressors {
+		if reason := s(endpointName, ep, now); reason != "" {
+			return reason
+		}
+	}
+	return ""
+}
+
+// updateSuppression notifies subscribers when the suppression reason of an endpoint changes.
+func (e *Engine) updateSuppression(endpointName string, ep config.Endpoint, reason string) {
+	e.muSuppr.Lock()
+	prev := e.suppressed[endpointName]
+	if prev == reason {
+		e.muSuppr.Unlock()
+		return
+	}
+	if reason == "" {
+		delete(e.suppressed, endpointName)
+	} else {
+		e.suppressed[endpointName] = reason
+	}
+	e.muSuppr.Unlock()
+
+	for _, routeKey := range ep.Routes {
+		e.notifySuppressed(Suppression{
+			Group:    ep.Group,
+			Endpoint: endpointName,
+			Protocol: ep.Protocol,
+			URL:      ep.Request.URL,
+			Route:    routeKey,
+			Labels:   ep.Labels,
+			Reason:   reason,
+		})
+	}
+}
+
+func (e *Engine) notifySuppressed(s Suppression) {
+	e.muSubs.RLock()
+	defer e.muSubs.RUnlock()
+	for _, sub := range e.subs {
+		ss, ok := sub.(SuppressionSubscriber)
+		if !ok {
+			continue
+		}
+		func() {
+			defer func() {
+				if rec := recover(); rec != nil {
+					e.stats.subscriberPanics.Add(1)
+				}
+			}()
+			ss.OnSuppressed(s)
+		}()
+	}
+}
@@ -0,0 +1,53 @@
+package prober
+
+import (
+	"context"
+	"time"
+)
+
+// workerSlots returns a semaphore of settings.max-workers-count slots (at least one).
+func workerSlots(maxWorkers int) chan struct{} {
+	return make(chan struct{}, max(maxWorkers, 1))
+}
+
+// setWorkers resizes the worker pool after a reload. Probes holding a slot of the old pool release it there, so
+// until they are done up to both limits may run.
+func (e *Engine) setWorkers(maxWorkers int) {
+	e.muWorkers.Lock()
+	defer e.muWorkers.Unlock()
+	if cap(e.workers) != max(maxWorkers, 1) {
+		e.workers = workerSlots(maxWorkers)
+	}
+}
+
+// AcquireWorker takes a worker slot for work done outside the engine on behalf of its probes (rendering an
+// on-demand probe), so it shares settings.max-workers-count with them.
+func (e *Engine) AcquireWorker(ctx context.Context) (func(), bool) { return e.acquireWorker(ctx) }
+
+// acquireWorker waits for a free worker, so at most max-workers-count probes run at once across all endpoints and
+// route canaries. It returns the release func, or false when ctx is done first.
+func (e *Engine) acquireWorker(ctx context.Context) (func(), bool) {
+	e.muWorkers.Lock()
+	slots := e.workers
+	e.muWorkers.Unlock()
+
+	start := time.Now()
+	select {
+	case slots <- struct{}{}:
+	default:
+		e.stats.queued.Add(1)
+		select {
+		case slots <- struct{}{}:
+			e.stats.queued.Add(-1)
+		case <-ctx.Done():
+			e.stats.queued.Add(-1)
+			return nil, false
+		}
+	}
+	e.stats.observeWait(time.Since(start))
+	e.stats.inFlight.Add(1)
+	return func() {
+		e.stats.inFlight.Add(-1)
+		<-slots
+	}, true
+}
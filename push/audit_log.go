@@ -0,0 +1,203 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kinjelom/watchdog_exporter/api"
+	"github.com/kinjelom/watchdog_exporter/config"
+	"github.com/kinjelom/watchdog_exporter/prober"
+)
+
+// Audit log formats.
+const (
+	AuditFormatJSONL = "jsonl"
+	AuditFormatCSV   = "csv"
+)
+
+// auditRotatedLayout is inserted before the file extension of rotated files: audit-20250101T120000.jsonl
+const auditRotatedLayout = "20060102T150405"
+
+var auditCSVHeader = []string{"at", "group", "endpoint", "route", "protocol", "url", "status", "error",
+	"duration_seconds", "consecutive_failures", "remote_ip", "redirects", "tls_version", "tls_leaf_not_after"}
+
+// AuditLog appends every result to a file as JSON Lines or CSV, rotating it by size and age.
+// Results are queued by OnResult and written by Run.
+type AuditLog struct {
+	queueDrops
+	cfg   config.AuditLogConfig
+	queue chan prober.Result
+
+	file   *os.File
+	size   int64
+	opened time.Time
+	now    func() time.Time
+}
+
+func NewAuditLog(cfg config.AuditLogConfig) (*AuditLog, error) {
+	if cfg.Format != AuditFormatJSONL && cfg.Format != AuditFormatCSV {
+		return nil, fmt.Errorf("unsupported format %q (jsonl or csv)", cfg.Format)
+	}
+	a := &AuditLog{cfg: cfg, queue: make(chan prober.Result, cfg.QueueSize), now: time.Now}
+	if err := a.open(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (a *AuditLog) OnResult(r prober.Result) {
+	select {
+	case a.queue <- r:
+	default:
+		a.queueDrops.n.Add(1)
+		slog.Warn("audit log queue full, result dropped", "endpoint", r.Endpoint, "route", r.Route)
+	}
+}
+
+// Run writes queued results until ctx is done, then closes the file.
+func (a *AuditLog) Run(ctx context.Context) {
+	defer func() { _ = a.file.Close() }()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case r := <-a.queue:
+			if err := a.write(r); err != nil {
+				slog.Warn("audit log write failed", "err", err)
+			}
+		}
+	}
+}
+
+func (a *AuditLog) write(r prober.Result) error {
+	line, err := a.encode(r)
+	if err != nil {
+		return err
+	}
+	if a.needsRotation(int64(len(line))) {
+		if err = a.rotate(); err != nil {
+			return fmt.Errorf("rotate: %w", err)
+		}
+	}
+	n, err := a.file.Write(line)
+	a.size += int64(n)
+	return err
+}
+
+func (a *AuditLog) encode(r prober.Result) ([]byte, error) {
+	v := api.NewResultView(r)
+	if a.cfg.Format == AuditFormatJSONL {
+		b, err := json.Marshal(v)
+		return append(b, '\n'), err
+	}
+	var tlsVersion, notAfter string
+	if v.TLS != nil {
+		tlsVersion = v.TLS.Version
+		if len(v.TLS.Certificates) > 0 {
+			notAfter = v.TLS.Certificates[0].NotAfter.UTC().Format(time.RFC3339)
+		}
+	}
+	return csvLine([]string{
+		v.At.UTC().Format(time.RFC3339Nano), v.Group, v.Endpoint, v.Route, v.Protocol, v.URL, string(v.Status), v.Error,
+		strconv.FormatFloat(v.DurationSeconds, 'f', -1, 64), strconv.Itoa(v.ConsecutiveFailures), v.RemoteIP,
+		strconv.Itoa(v.Redirects), tlsVersion, notAfter,
+	})
+}
+
+func csvLine(fields []string) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write(fields); err != nil {
+		return nil, err
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+func (a *AuditLog) needsRotation(next int64) bool {
+	if a.size == 0 {
+		return false
+	}
+	if a.cfg.MaxSize > 0 && a.size+next > a.cfg.MaxSize {
+		return true
+	}
+	return a.cfg.MaxAge > 0 && a.now().Sub(a.opened) >= a.cfg.MaxAge
+}
+
+// open opens (appending to) the current file, writing the CSV header into a new one.
+func (a *AuditLog) open() error {
+	if err := os.MkdirAll(filepath.Dir(a.cfg.Path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(a.cfg.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return err
+	}
+	st, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	a.file, a.size, a.opened = f, st.Size(), a.now()
+	if a.size > 0 {
+		// Continue an existing file: its age counts from its last change.
+		a.opened = st.ModTime()
+	}
+	if a.size == 0 && a.cfg.Format == AuditFormatCSV {
+		header, _ := csvLine(auditCSVHeader)
+		n, err := f.Write(header)
+		a.size += int64(n)
+		return err
+	}
+	return nil
+}
+
+func (a *AuditLog) rotate() error {
+	if err := a.file.Close(); err != nil {
+		return err
+	}
+	ext := filepath.Ext(a.cfg.Path)
+	base := strings.TrimSuffix(a.cfg.Path, ext)
+	stamp := a.now().UTC().Format(auditRotatedLayout)
+	rotated := base + "-" + stamp + ext
+	for i := 1; fileExists(rotated); i++ {
+		rotated = fmt.Sprintf("%s-%s.%d%s", base, stamp, i, ext)
+	}
+	if err := os.Rename(a.cfg.Path, rotated); err != nil {
+		return err
+	}
+	a.prune(base, ext)
+	return a.open()
+}
+
+// prune deletes the oldest rotated files above max-backups (0 keeps all).
+func (a *AuditLog) prune(base, ext string) {
+	if a.cfg.MaxBackups <= 0 {
+		return
+	}
+	matches, err := filepath.Glob(base + "-*" + ext)
+	if err != nil || len(matches) <= a.cfg.MaxBackups {
+		return
+	}
+	sort.Strings(matches) // the timestamp layout sorts chronologically
+	for _, old := range matches[:len(matches)-a.cfg.MaxBackups] {
+		if err := os.Remove(old); err != nil {
+			slog.Warn("audit log write failed", "err", err)
+		}
+	}
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,119 @@
+package push
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kinjelom/watchdog_exporter/api"
+	"github.com/kinjelom/watchdog_exporter/config"
+	"github.com/kinjelom/watchdog_exporter/prober"
+)
+
+func TestAuditLog_JSONL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	a, err := NewAuditLog(config.AuditLogConfig{Path: path, Format: AuditFormatJSONL, QueueSize: 1})
+	assert.NoError(t, err)
+	assert.NoError(t, a.write(prober.Result{Endpoint: "a", Status: "valid"}))
+	assert.NoError(t, a.write(prober.Result{Endpoint: "b", Status: "request-execution-error", Err: errors.New("refused")}))
+	_ = a.file.Close()
+
+	data, _ := os.ReadFile(path)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if assert.Len(t, lines, 2) {
+		var v api.ResultView
+		assert.NoError(t, json.Unmarshal([]byte(lines[1]), &v))
+		assert.Equal(t, "b", v.Endpoint)
+		assert.Equal(t, "refused", v.Error)
+	}
+}
+
+func TestAuditLog_CSVWithHeader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.csv")
+	a, err := NewAuditLog(config.AuditLogConfig{Path: path, Format: AuditFormatCSV, QueueSize: 1})
+	assert.NoError(t, err)
+	assert.NoError(t, a.write(prober.Result{Endpoint: "a,b", Status: "valid", Duration: 0.5, At: time.Unix(0, 0)}))
+	_ = a.file.Close()
+
+	// Reopening an existing file must not repeat the header.
+	a, err = NewAuditLog(config.AuditLogConfig{Path: path, Format: AuditFormatCSV, QueueSize: 1})
+	assert.NoError(t, err)
+	assert.NoError(t, a.write(prober.Result{Endpoint: "c", Status: "valid"}))
+	_ = a.file.Close()
+
+	f, _ := os.Open(path)
+	defer func() { _ = f.Close() }()
+	records, err := csv.NewReader(f).ReadAll()
+	assert.NoError(t, err)
+	if assert.Len(t, records, 3) {
+		assert.Equal(t, auditCSVHeader, records[0])
+		assert.Equal(t, "1970-01-01T00:00:00Z", records[1][0])
+		assert.Equal(t, "a,b", records[1][2])
+		assert.Equal(t, "0.5", records[1][8])
+		assert.Equal(t, "c", records[2][2])
+	}
+}
+
+func TestAuditLog_RotatesBySizeAndPrunes(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "audit.jsonl")
+	a, err := NewAuditLog(config.AuditLogConfig{Path: path, Format: AuditFormatJSONL, MaxSize: 100, MaxBackups: 2, QueueSize: 1})
+	assert.NoError(t, err)
+	clock := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	a.now = func() time.Time { clock = clock.Add(time.Second); return clock }
+
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, a.write(prober.Result{Endpoint: "ep", Status: "valid"}))
+	}
+	_ = a.file.Close()
+
+	rotated, _ := filepath.Glob(filepath.Join(dir, "audit-*.jsonl"))
+	assert.Len(t, rotated, 2)
+	data, _ := os.ReadFile(path)
+	assert.Equal(t, 1, strings.Count(string(data), "\n"))
+}
+
+func TestAuditLog_RotatesByAge(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "audit.csv")
+	clock := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	a, err := NewAuditLog(config.AuditLogConfig{Path: path, Format: AuditFormatCSV, MaxAge: time.Hour, QueueSize: 1})
+	assert.NoError(t, err)
+	a.opened = clock
+	a.now = func() time.Time { return clock }
+
+	assert.NoError(t, a.write(prober.Result{Endpoint: "a", Status: "valid"}))
+	clock = clock.Add(2 * time.Hour)
+	assert.NoError(t, a.write(prober.Result{Endpoint: "b", Status: "valid"}))
+	_ = a.file.Close()
+
+	rotated, _ := filepath.Glob(filepath.Join(dir, "audit-*.csv"))
+	assert.Equal(t, []string{filepath.Join(dir, "audit-20250101T020000.csv")}, rotated)
+	data, _ := os.ReadFile(path)
+	assert.True(t, strings.HasPrefix(string(data), "at,group"), "new file starts with the header")
+}
+
+func TestAuditLog_CountsQueueDrops(t *testing.T) {
+	a, err := NewAuditLog(config.AuditLogConfig{Path: filepath.Join(t.TempDir(), "audit.jsonl"), Format: AuditFormatJSONL, QueueSize: 1})
+	assert.NoError(t, err)
+	defer func() { _ = a.file.Close() }()
+
+	// Not running: only the first result fits into the queue.
+	for _, name := range []string{"a", "b", "c"} {
+		a.OnResult(prober.Result{Endpoint: name, Status: "valid"})
+	}
+	assert.Len(t, a.queue, 1)
+	assert.Equal(t, uint64(2), a.QueueDrops())
+}
+
+func TestAuditLog_UnknownFormat(t *testing.T) {
+	_, err := NewAuditLog(config.AuditLogConfig{Path: filepath.Join(t.TempDir(), "x"), Format: "xml"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,150 @@
+package push
+
+import (
+	"sync"
+	"time"
+	"unsafe"
+
+	"github.com/kinjelom/watchdog_exporter/prober"
+)
+
+// resultBuffer is a FIFO of results kept within a memory budget: above max results, max bytes or max age the
+// oldest results (by probe time) are evicted first, so a stalled output degrades to losing old results instead
+// of growing until the process is killed. A zero limit is not enforced.
+type resultBuffer struct {
+	maxResults int
+	maxBytes   int64
+	maxAge     time.Duration
+	now        func() time.Time
+
+	mu      sync.Mutex
+	items   []bufferedResult
+	bytes   int64
+	evicted uint64
+
+	ready chan struct{} // signalled (non-blocking) when results are added
+}
+
+type bufferedResult struct {
+	r    prober.Result
+	size int64
+	at   time.Time // probe time, or the time it was buffered
+}
+
+func (b *resultBuffer) item(r prober.Result) bufferedResult {
+	at := r.At
+	if at.IsZero() {
+		at = b.now()
+	}
+	return bufferedResult{r: r, size: resultSize(r), at: at}
+}
+
+func newResultBuffer(maxResults int, maxBytes int64, maxAge time.Duration) *resultBuffer {
+	return &resultBuffer{
+		maxResults: maxResults,
+		maxBytes:   maxBytes,
+		maxAge:     maxAge,
+		now:        time.Now,
+		ready:      make(chan struct{}, 1),
+	}
+}
+
+// push appends r and returns the number of results evicted to stay within the budget.
+func (b *resultBuffer) push(r prober.Result) int {
+	b.mu.Lock()
+	item := b.item(r)
+	b.items = append(b.items, item)
+	b.bytes += item.size
+	n := b.evictLocked()
+	b.mu.Unlock()
+
+	select {
+	case b.ready <- struct{}{}:
+	default:
+	}
+	return n
+}
+
+// takeAll removes and returns every buffered result, oldest first.
+func (b *resultBuffer) takeAll() []prober.Result {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.evictLocked()
+	out := make([]prober.Result, len(b.items))
+	for i, item := range b.items {
+		out[i] = item.r
+	}
+	b.items, b.bytes = nil, 0
+	return out
+}
+
+// requeue puts back results that could not be written, ahead of the newer ones.
+func (b *resultBuffer) requeue(results []prober.Result) int {
+	if len(results) == 0 {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	items := make([]bufferedResult, 0, len(results)+len(b.items))
+	for _, r := range results {
+		item := b.item(r)
+		items = append(items, item)
+		b.bytes += item.size
+	}
+	b.items = append(items, b.items...)
+	return b.evictLocked()
+}
+
+// evictLocked drops the oldest results until all limits hold.
+func (b *resultBuffer) evictLocked() int {
+	n := 0
+	for len(b.items) > 0 {
+		oldest := b.items[0]
+		over := (b.maxResults > 0 && len(b.items) > b.maxResults) ||
+			(b.maxBytes > 0 && b.bytes > b.maxBytes) ||
+			(b.maxAge > 0 && b.now().Sub(oldest.at) > b.maxAge)
+		if !over {
+			break
+		}
+		b.items[0] = bufferedResult{} // release references for the GC
+		b.items = b.items[1:]
+		b.bytes -= oldest.size
+		n++
+	}
+	b.evicted += uint64(n)
+	return n
+}
+
+// BufferUsage reports the buffered results, their approximate size in bytes and the evictions so far.
+func (b *resultBuffer) BufferUsage() (results int, bytes int64, evicted uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.items), b.bytes, b.evicted
+}
+
+// resultSize approximates the memory held by r: the struct plus the strings and TLS details it references.
+func resultSize(r prober.Result) int64 {
+	size := int64(unsafe.Sizeof(r))
+	size += int64(len(r.Group) + len(r.Endpoint) + len(r.Protocol) + len(r.URL) + len(r.Route) + len(r.Status) +
+		len(r.PrevStatus) + len(r.RemoteIP))
+	size += int64(len(r.Error))
+	for name, value := range r.Headers {
+		size += int64(len(name)+len(value)) + 16
+	}
+	for _, answer := range r.Answers {
+		size += int64(len(answer)) + 16
+	}
+	for window := range r.Availability {
+		size += int64(len(window)) + 16
+	}
+	if r.TLS != nil {
+		size += int64(unsafe.Sizeof(*r.TLS)) + int64(len(r.TLS.Version)+len(r.TLS.CipherSuite)+len(r.TLS.ALPN))
+		for _, c := range r.TLS.Certificates {
+			size += int64(unsafe.Sizeof(c)) + int64(len(c.SerialHex)+len(c.CommonName)+len(c.IssuerCN))
+			for _, san := range c.SubjectAltNames {
+				size += int64(len(san)) + 16
+			}
+		}
+	}
+	return size
+}
@@ -0,0 +1,70 @@
+package push
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kinjelom/watchdog_exporter/prober"
+)
+
+func TestResultBuffer_EvictsOldestAboveMaxResults(t *testing.T) {
+	b := newResultBuffer(2, 0, 0)
+	at := time.Now()
+	assert.Equal(t, 0, b.push(prober.Result{Endpoint: "a", At: at}))
+	assert.Equal(t, 0, b.push(prober.Result{Endpoint: "b", At: at}))
+	assert.Equal(t, 1, b.push(prober.Result{Endpoint: "c", At: at}))
+
+	got := b.takeAll()
+	if assert.Len(t, got, 2) {
+		assert.Equal(t, "b", got[0].Endpoint)
+		assert.Equal(t, "c", got[1].Endpoint)
+	}
+	results, bytes, evicted := b.BufferUsage()
+	assert.Equal(t, 0, results)
+	assert.Equal(t, int64(0), bytes)
+	assert.Equal(t, uint64(1), evicted)
+}
+
+func TestResultBuffer_EvictsAboveMaxBytes(t *testing.T) {
+	r := prober.Result{Endpoint: "ep", At: time.Now()}
+	b := newResultBuffer(0, 2*resultSize(r), 0)
+	for i := 0; i < 5; i++ {
+		b.push(r)
+	}
+	results, bytes, evicted := b.BufferUsage()
+	assert.Equal(t, 2, results)
+	assert.Equal(t, 2*resultSize(r), bytes)
+	assert.Equal(t, uint64(3), evicted)
+}
+
+func TestResultBuffer_EvictsByAge(t *testing.T) {
+	now := time.Now()
+	b := newResultBuffer(0, 0, time.Minute)
+	b.now = func() time.Time { return now }
+	b.push(prober.Result{Endpoint: "old", At: now.Add(-2 * time.Minute)})
+	b.push(prober.Result{Endpoint: "new", At: now})
+
+	got := b.takeAll()
+	if assert.Len(t, got, 1) {
+		assert.Equal(t, "new", got[0].Endpoint)
+	}
+}
+
+func TestResultBuffer_RequeueKeepsOrderWithinBudget(t *testing.T) {
+	at := time.Now()
+	b := newResultBuffer(3, 0, 0)
+	b.push(prober.Result{Endpoint: "a", At: at})
+	b.push(prober.Result{Endpoint: "b", At: at})
+	failed := b.takeAll()
+	b.push(prober.Result{Endpoint: "c", At: at})
+	b.push(prober.Result{Endpoint: "d", At: at})
+
+	assert.Equal(t, 1, b.requeue(failed))
+	var endpoints []string
+	for _, r := range b.takeAll() {
+		endpoints = append(endpoints, r.Endpoint)
+	}
+	assert.Equal(t, []string{"b", "c", "d"}, endpoints)
+}
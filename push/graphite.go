@@ -0,0 +1,114 @@
+package push
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+	"github.com/kinjelom/watchdog_exporter/prober"
+)
+
+// graphiteMaxPending caps the buffer while carbon is unreachable.
+const graphiteMaxPending = 10000
+
+// GraphiteWriter sends probe results to carbon using the plaintext protocol over TCP.
+// Lines are buffered by OnResult and written in one connection every flush-interval.
+type GraphiteWriter struct {
+	cfg   config.GraphiteConfig
+	dirty atomic.Bool
+
+	mu      sync.Mutex
+	pending []string
+}
+
+func NewGraphiteWriter(cfg config.GraphiteConfig) *GraphiteWriter {
+	return &GraphiteWriter{cfg: cfg}
+}
+
+func (w *GraphiteWriter) OnResult(r prober.Result) {
+	lines := w.lines(r)
+	w.mu.Lock()
+	w.pending = append(w.pending, lines...)
+	if over := len(w.pending) - graphiteMaxPending; over > 0 {
+		w.pending = w.pending[over:]
+	}
+	w.mu.Unlock()
+	w.dirty.Store(true)
+}
+
+// Run flushes buffered lines every flush-interval until ctx is done.
+func (w *GraphiteWriter) Run(ctx context.Context) {
+	flushLoop(ctx, "graphite", w.cfg.FlushInterval, &w.dirty, w.Flush)
+}
+
+// Flush writes all buffered lines; on failure they are kept for the next flush.
+func (w *GraphiteWriter) Flush(ctx context.Context) error {
+	w.mu.Lock()
+	lines := w.pending
+	w.pending = nil
+	w.mu.Unlock()
+	if len(lines) == 0 {
+		return nil
+	}
+
+	if err := w.write(ctx, lines); err != nil {
+		w.mu.Lock()
+		w.pending = append(lines, w.pending...)
+		if over := len(w.pending) - graphiteMaxPending; over > 0 {
+			w.pending = w.pending[over:]
+		}
+		w.mu.Unlock()
+		w.dirty.Store(true)
+		return err
+	}
+	return nil
+}
+
+func (w *GraphiteWriter) write(ctx context.Context, lines []string) error {
+	d := net.Dialer{Timeout: w.cfg.Timeout}
+	conn, err := d.DialContext(ctx, "tcp", w.cfg.Address)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = conn.Close() }()
+	_ = conn.SetWriteDeadline(time.Now().Add(w.cfg.Timeout))
+	_, err = conn.Write([]byte(strings.Join(lines, "\n") + "\n"))
+	return err
+}
+
+// lines renders r as "<prefix>.<group>.<endpoint>.<route>.<metric> <value> <unix-seconds>".
+func (w *GraphiteWriter) lines(r prober.Result) []string {
+	repl := '_'
+	if w.cfg.Replacement != "" {
+		repl = []rune(w.cfg.Replacement)[0]
+	}
+	parts := make([]string, 0, 4)
+	if w.cfg.Prefix != "" {
+		parts = append(parts, strings.Trim(w.cfg.Prefix, "."))
+	}
+	for _, p := range []string{r.Group, r.Endpoint, r.Route} {
+		p = sanitizeMetricPartWith(p, repl)
+		if w.cfg.Lowercase {
+			p = strings.ToLower(p)
+		}
+		parts = append(parts, p)
+	}
+	base := strings.Join(parts, ".")
+
+	up := 1
+	if r.Failed() {
+		up = 0
+	}
+	ts := r.At.Unix()
+	return []string{
+		fmt.Sprintf("%s.up %d %d", base, up, ts),
+		fmt.Sprintf("%s.duration_seconds %s %d", base, strconv.FormatFloat(r.Duration, 'f', -1, 64), ts),
+		fmt.Sprintf("%s.consecutive_failures %d %d", base, r.ConsecutiveFailures, ts),
+	}
+}
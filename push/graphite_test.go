@@ -0,0 +1,69 @@
+package push
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+	"github.com/kinjelom/watchdog_exporter/prober"
+)
+
+func TestGraphiteWriter_Lines(t *testing.T) {
+	w := NewGraphiteWriter(config.GraphiteConfig{Prefix: "wd.", Replacement: "-", Lowercase: true})
+	got := w.lines(prober.Result{Group: "Ops", Endpoint: "Example.com", Route: "direct", Status: "valid", Duration: 0.5, At: time.Unix(100, 0)})
+
+	assert.Equal(t, []string{
+		"wd.ops.example-com.direct.up 1 100",
+		"wd.ops.example-com.direct.duration_seconds 0.5 100",
+		"wd.ops.example-com.direct.consecutive_failures 0 100",
+	}, got)
+}
+
+func TestGraphiteWriter_FlushTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer func() { _ = ln.Close() }()
+
+	received := make(chan []string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		var lines []string
+		sc := bufio.NewScanner(conn)
+		for sc.Scan() {
+			lines = append(lines, sc.Text())
+		}
+		received <- lines
+	}()
+
+	w := NewGraphiteWriter(config.GraphiteConfig{Address: ln.Addr().String(), Timeout: time.Second})
+	w.OnResult(prober.Result{Group: "g", Endpoint: "ep", Route: "r", Status: "request-execution-timeout", ConsecutiveFailures: 3, At: time.Unix(5, 0)})
+	assert.NoError(t, w.Flush(context.Background()))
+
+	select {
+	case lines := <-received:
+		assert.Equal(t, []string{"g.ep.r.up 0 5", "g.ep.r.duration_seconds 0 5", "g.ep.r.consecutive_failures 3 5"}, lines)
+	case <-time.After(2 * time.Second):
+		t.Fatal("no data received")
+	}
+	assert.Empty(t, w.pending)
+}
+
+func TestGraphiteWriter_FlushKeepsLinesOnError(t *testing.T) {
+	ln, _ := net.Listen("tcp", "127.0.0.1:0")
+	addr := ln.Addr().String()
+	_ = ln.Close()
+
+	w := NewGraphiteWriter(config.GraphiteConfig{Address: addr, Timeout: time.Second})
+	w.OnResult(prober.Result{Endpoint: "ep", Status: "valid"})
+	assert.Error(t, w.Flush(context.Background()))
+	assert.Len(t, w.pending, 3)
+}
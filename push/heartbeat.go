@@ -0,0 +1,145 @@
+package push
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+	"github.com/kinjelom/watchdog_exporter/prober"
+)
+
+// Heartbeat pings a dead man's switch URL (healthchecks.io style) after every full probe cycle,
+// i.e. once every configured endpoint+route has reported a result since the previous ping.
+// Suppressed endpoints are left out of the cycle until they are resumed; endpoints on a cron schedule are left out
+// entirely, as they may not run for hours.
+type Heartbeat struct {
+	cfg    config.HeartbeatConfig
+	client *http.Client
+	pings  chan struct{}
+
+	mu        sync.Mutex
+	all       map[string]bool // every configured endpoint+route
+	expected  map[string]bool // all minus the suppressed ones
+	seen      map[string]bool
+	allPassed bool
+}
+
+func NewHeartbeat(cfg config.HeartbeatConfig, endpoints map[string]config.Endpoint) *Heartbeat {
+	h := &Heartbeat{
+		cfg:       cfg,
+		client:    &http.Client{Timeout: cfg.Timeout},
+		pings:     make(chan struct{}, 1),
+		seen:      make(map[string]bool),
+		allPassed: true,
+	}
+	h.SetEndpoints(endpoints)
+	return h
+}
+
+// SetEndpoints replaces the endpoints a cycle waits for (after a config reload); suppressed ones stay left out.
+func (h *Heartbeat) SetEndpoints(endpoints map[string]config.Endpoint) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	all := make(map[string]bool)
+	expected := make(map[string]bool)
+	for name, ep := range endpoints {
+		if ep.Schedule != "" {
+			continue
+		}
+		for _, route := range ep.Routes {
+			key := heartbeatKey(name, route)
+			all[key] = true
+			if !h.all[key] || h.expected[key] {
+				expected[key] = true
+			}
+		}
+	}
+	for key := range h.seen {
+		if !expected[key] {
+			delete(h.seen, key)
+		}
+	}
+	h.all, h.expected = all, expected
+	h.completeCycle()
+}
+
+func heartbeatKey(endpoint, route string) string {
+	return endpoint + "\x00" + route
+}
+
+func (h *Heartbeat) OnResult(r prober.Result) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	key := heartbeatKey(r.Endpoint, r.Route)
+	if !h.expected[key] {
+		return
+	}
+	h.seen[key] = true
+	h.allPassed = h.allPassed && !r.Failed()
+	h.completeCycle()
+}
+
+func (h *Heartbeat) OnSuppressed(s prober.Suppression) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	key := heartbeatKey(s.Endpoint, s.Route)
+	if !h.all[key] {
+		return
+	}
+	if s.Reason != "" {
+		delete(h.expected, key)
+		delete(h.seen, key)
+		h.completeCycle()
+		return
+	}
+	h.expected[key] = true
+}
+
+// completeCycle schedules a ping once all expected results are in; callers hold h.mu.
+func (h *Heartbeat) completeCycle() {
+	if len(h.expected) == 0 || len(h.seen) < len(h.expected) {
+		return
+	}
+	if h.allPassed || !h.cfg.RequireHealthy {
+		select {
+		case h.pings <- struct{}{}:
+		default: // a ping is already pending
+		}
+	}
+	h.seen = make(map[string]bool, len(h.expected))
+	h.allPassed = true
+}
+
+// Run sends the scheduled pings until ctx is done.
+func (h *Heartbeat) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-h.pings:
+			if err := h.ping(ctx); err != nil {
+				slog.Warn("heartbeat ping failed", "err", err)
+			}
+		}
+	}
+}
+
+func (h *Heartbeat) ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, h.cfg.Method, h.cfg.URL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func(Body io.ReadCloser) { _ = Body.Close() }(resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
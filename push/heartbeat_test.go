@@ -0,0 +1,108 @@
+package push
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+	"github.com/kinjelom/watchdog_exporter/prober"
+)
+
+func heartbeatEndpoints() map[string]config.Endpoint {
+	return map[string]config.Endpoint{
+		"a": {Routes: []string{"direct", "proxy"}},
+		"b": {Routes: []string{"direct"}},
+	}
+}
+
+func pending(h *Heartbeat) int {
+	return len(h.pings)
+}
+
+func TestHeartbeat_PingsAfterFullCycle(t *testing.T) {
+	h := NewHeartbeat(config.HeartbeatConfig{}, heartbeatEndpoints())
+	h.OnResult(prober.Result{Endpoint: "a", Route: "direct", Status: "valid"})
+	h.OnResult(prober.Result{Endpoint: "a", Route: "direct", Status: "valid"}) // repeated, still incomplete
+	h.OnResult(prober.Result{Endpoint: "b", Route: "direct", Status: "status-code-mismatch"})
+	assert.Equal(t, 0, pending(h))
+
+	h.OnResult(prober.Result{Endpoint: "a", Route: "proxy", Status: "valid"})
+	assert.Equal(t, 1, pending(h))
+	assert.Empty(t, h.seen, "a new cycle starts")
+}
+
+func TestHeartbeat_RequireHealthy(t *testing.T) {
+	h := NewHeartbeat(config.HeartbeatConfig{RequireHealthy: true}, heartbeatEndpoints())
+	h.OnResult(prober.Result{Endpoint: "a", Route: "direct", Status: "valid"})
+	h.OnResult(prober.Result{Endpoint: "a", Route: "proxy", Status: "valid"})
+	h.OnResult(prober.Result{Endpoint: "b", Route: "direct", Status: "status-code-mismatch"})
+	assert.Equal(t, 0, pending(h))
+
+	for _, key := range [][2]string{{"a", "direct"}, {"a", "proxy"}, {"b", "direct"}} {
+		h.OnResult(prober.Result{Endpoint: key[0], Route: key[1], Status: "valid"})
+	}
+	assert.Equal(t, 1, pending(h))
+}
+
+func TestHeartbeat_SuppressedEndpointsLeaveTheCycle(t *testing.T) {
+	h := NewHeartbeat(config.HeartbeatConfig{}, heartbeatEndpoints())
+	h.OnResult(prober.Result{Endpoint: "a", Route: "direct", Status: "valid"})
+	h.OnResult(prober.Result{Endpoint: "a", Route: "proxy", Status: "valid"})
+	h.OnSuppressed(prober.Suppression{Endpoint: "b", Route: "direct", Reason: prober.SuppressedMaintenance})
+	assert.Equal(t, 1, pending(h))
+
+	<-h.pings
+	h.OnSuppressed(prober.Suppression{Endpoint: "b", Route: "direct"})
+	h.OnResult(prober.Result{Endpoint: "a", Route: "direct", Status: "valid"})
+	h.OnResult(prober.Result{Endpoint: "a", Route: "proxy", Status: "valid"})
+	assert.Equal(t, 0, pending(h), "b is expected again")
+}
+
+func TestHeartbeat_SetEndpoints(t *testing.T) {
+	h := NewHeartbeat(config.HeartbeatConfig{}, heartbeatEndpoints())
+	h.OnSuppressed(prober.Suppression{Endpoint: "b", Route: "direct", Reason: prober.SuppressedPaused})
+	h.OnResult(prober.Result{Endpoint: "a", Route: "direct", Status: "valid"})
+
+	h.SetEndpoints(map[string]config.Endpoint{
+		"a": {Routes: []string{"direct"}},
+		"b": {Routes: []string{"direct"}},
+		"c": {Routes: []string{"direct"}},
+	})
+	assert.Equal(t, 0, pending(h), "c joins the cycle, b stays suppressed")
+	h.OnSuppressed(prober.Suppression{Endpoint: "x", Route: "direct"})
+	h.OnResult(prober.Result{Endpoint: "c", Route: "direct", Status: "valid"})
+	assert.Equal(t, 1, pending(h), "a.proxy is gone, unknown resumes are ignored")
+}
+
+func TestHeartbeat_ScheduledEndpointsLeaveTheCycle(t *testing.T) {
+	h := NewHeartbeat(config.HeartbeatConfig{}, map[string]config.Endpoint{
+		"a":       {Routes: []string{"direct"}},
+		"nightly": {Routes: []string{"direct"}, Schedule: "@daily"},
+	})
+	h.OnResult(prober.Result{Endpoint: "a", Route: "direct", Status: "valid"})
+	assert.Equal(t, 1, pending(h), "a scheduled endpoint does not hold up the cycle")
+}
+
+func TestHeartbeat_RunPings(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		hits.Add(1)
+	}))
+	defer srv.Close()
+
+	h := NewHeartbeat(config.HeartbeatConfig{URL: srv.URL, Method: http.MethodPost, Timeout: time.Second},
+		map[string]config.Endpoint{"a": {Routes: []string{"direct"}}})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go h.Run(ctx)
+
+	h.OnResult(prober.Result{Endpoint: "a", Route: "direct", Status: "valid"})
+	assert.Eventually(t, func() bool { return hits.Load() == 1 }, 2*time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,249 @@
+package push
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"time"
+
+	_ "github.com/lib/pq"  // registers "postgres"
+	_ "modernc.org/sqlite" // registers "sqlite" (pure Go)
+
+	"github.com/kinjelom/watchdog_exporter/config"
+	"github.com/kinjelom/watchdog_exporter/prober"
+)
+
+// History drivers.
+const (
+	HistoryDriverSQLite   = "sqlite"
+	HistoryDriverPostgres = "postgres"
+)
+
+// historyCleanupInterval is how often rows older than the retention are deleted.
+const historyCleanupInterval = time.Hour
+
+// historyTimeLayout is a fixed-width UTC layout, so SQLite text timestamps sort and compare correctly.
+const historyTimeLayout = "2006-01-02T15:04:05.000000000Z"
+
+var historyTableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// historyRetryInterval is how often buffered results are retried after a failed write when no new result arrives.
+const historyRetryInterval = 10 * time.Second
+
+// HistoryWriter appends every result to a SQL table (SQLite or PostgreSQL) and deletes rows past the retention.
+// Results are buffered by OnResult, within the configured memory budget, and inserted in batches by Run.
+type HistoryWriter struct {
+	cfg    config.HistoryConfig
+	db     *sql.DB
+	buffer *resultBuffer
+
+	insert string
+}
+
+func NewHistoryWriter(cfg config.HistoryConfig) (*HistoryWriter, error) {
+	if cfg.Driver != HistoryDriverSQLite && cfg.Driver != HistoryDriverPostgres {
+		return nil, fmt.Errorf("unsupported driver %q (sqlite or postgres)", cfg.Driver)
+	}
+	if !historyTableName.MatchString(cfg.Table) {
+		return nil, fmt.Errorf("invalid table name %q", cfg.Table)
+	}
+	db, err := sql.Open(cfg.Driver, cfg.DSN)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Driver == HistoryDriverSQLite {
+		// One writer at a time avoids "database is locked" errors.
+		db.SetMaxOpenConns(1)
+	}
+	w := &HistoryWriter{cfg: cfg, db: db, buffer: newResultBuffer(cfg.QueueSize, cfg.MaxBytes, cfg.MaxAge)}
+	if err = w.migrate(); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("create table: %w", err)
+	}
+	w.insert = w.insertStatement()
+	return w, nil
+}
+
+func (w *HistoryWriter) Close() error {
+	return w.db.Close()
+}
+
+func (w *HistoryWriter) OnResult(r prober.Result) {
+	if n := w.buffer.push(r); n > 0 {
+		slog.Warn("history buffer full, oldest results dropped", "dropped", n, "endpoint", r.Endpoint, "route", r.Route)
+	}
+}
+
+// BufferUsage reports the results waiting to be written, their approximate size and the evictions so far.
+func (w *HistoryWriter) BufferUsage() (results int, bytes int64, evicted uint64) {
+	return w.buffer.BufferUsage()
+}
+
+// Run inserts buffered results and applies the retention until ctx is done.
+// A failed batch goes back to the buffer and is retried with the next result or after historyRetryInterval.
+func (w *HistoryWriter) Run(ctx context.Context) {
+	w.cleanup(ctx)
+	cleanup := time.NewTicker(historyCleanupInterval)
+	defer cleanup.Stop()
+	retry := time.NewTicker(historyRetryInterval)
+	defer retry.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-cleanup.C:
+			w.cleanup(ctx)
+		case <-w.buffer.ready:
+			w.flush(ctx)
+		case <-retry.C:
+			w.flush(ctx)
+		}
+	}
+}
+
+// flush writes everything buffered in one transaction.
+func (w *HistoryWriter) flush(ctx context.Context) {
+	batch := w.buffer.takeAll()
+	if len(batch) == 0 {
+		return
+	}
+	if err := w.write(ctx, batch); err != nil {
+		n := w.buffer.requeue(batch)
+		slog.Warn("history write failed, results kept for retry", "err", err, "results", len(batch)-n, "dropped", n)
+	}
+}
+
+func (w *HistoryWriter) migrate() error {
+	idCol, atCol, boolCol := "INTEGER PRIMARY KEY AUTOINCREMENT", "TEXT", "INTEGER"
+	if w.cfg.Driver == HistoryDriverPostgres {
+		idCol, atCol, boolCol = "BIGSERIAL PRIMARY KEY", "TIMESTAMPTZ", "BOOLEAN"
+	}
+	ddl := []string{
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	id %s,
+	at %s NOT NULL,
+	group_name TEXT NOT NULL,
+	endpoint TEXT NOT NULL,
+	route TEXT NOT NULL,
+	protocol TEXT NOT NULL,
+	url TEXT NOT NULL,
+	status TEXT NOT NULL,
+	error TEXT,
+	duration_seconds DOUBLE PRECISION NOT NULL,
+	consecutive_failures INTEGER NOT NULL,
+	remote_ip TEXT,
+	redirects INTEGER NOT NULL,
+	tls_version TEXT,
+	tls_cipher_suite TEXT,
+	tls_chain_valid %s,
+	tls_leaf_not_after %s,
+	tls_leaf_days_left DOUBLE PRECISION
+)`, w.cfg.Table, idCol, atCol, boolCol, atCol),
+		fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %s_at_idx ON %s (at)`, w.cfg.Table, w.cfg.Table),
+		fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %s_endpoint_at_idx ON %s (endpoint, at)`, w.cfg.Table, w.cfg.Table),
+	}
+	for _, stmt := range ddl {
+		if _, err := w.db.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *HistoryWriter) insertStatement() string {
+	cols := []string{"at", "group_name", "endpoint", "route", "protocol", "url", "status", "error", "duration_seconds",
+		"consecutive_failures", "remote_ip", "redirects", "tls_version", "tls_cipher_suite", "tls_chain_valid",
+		"tls_leaf_not_after", "tls_leaf_days_left"}
+	marks := make([]string, len(cols))
+	for i := range cols {
+		marks[i] = w.placeholder(i + 1)
+	}
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", w.cfg.Table, strings.Join(cols, ", "), strings.Join(marks, ", "))
+}
+
+func (w *HistoryWriter) placeholder(n int) string {
+	if w.cfg.Driver == HistoryDriverPostgres {
+		return fmt.Sprintf("$%d", n)
+	}
+	return "?"
+}
+
+// timeArg converts t to the column representation of the driver.
+func (w *HistoryWriter) timeArg(t time.Time) any {
+	if w.cfg.Driver == HistoryDriverSQLite {
+		return t.UTC().Format(historyTimeLayout)
+	}
+	return t.UTC()
+}
+
+func (w *HistoryWriter) write(ctx context.Context, batch []prober.Result) error {
+	tx, err := w.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.PrepareContext(ctx, w.insert)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	defer func() { _ = stmt.Close() }()
+	for _, r := range batch {
+		if _, err = stmt.ExecContext(ctx, w.args(r)...); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (w *HistoryWriter) args(r prober.Result) []any {
+	var errText, remoteIP, tlsVersion, tlsCipher, chainValid, notAfter, daysLeft any
+	if text := r.ErrorText(); text != "" {
+		errText = text
+	}
+	if r.RemoteIP != "" {
+		remoteIP = r.RemoteIP
+	}
+	if r.TLS != nil && r.TLS.HadTLS {
+		tlsVersion, tlsCipher, chainValid = r.TLS.Version, r.TLS.CipherSuite, r.TLS.ChainValid
+		if w.cfg.Driver == HistoryDriverSQLite {
+			chainValid = boolInt(r.TLS.ChainValid)
+		}
+		if len(r.TLS.Certificates) > 0 {
+			leaf := r.TLS.Certificates[0]
+			notAfter, daysLeft = w.timeArg(leaf.NotAfter), leaf.DaysLeft
+		}
+	}
+	at := r.At
+	if at.IsZero() {
+		at = time.Now()
+	}
+	return []any{w.timeArg(at), r.Group, r.Endpoint, r.Route, r.Protocol, r.URL, r.Status, errText, r.Duration,
+		r.ConsecutiveFailures, remoteIP, r.Redirects, tlsVersion, tlsCipher, chainValid, notAfter, daysLeft}
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// cleanup deletes rows older than the retention (0 keeps everything).
+func (w *HistoryWriter) cleanup(ctx context.Context) {
+	if w.cfg.Retention <= 0 {
+		return
+	}
+	query := fmt.Sprintf("DELETE FROM %s WHERE at < %s", w.cfg.Table, w.placeholder(1))
+	res, err := w.db.ExecContext(ctx, query, w.timeArg(time.Now().Add(-w.cfg.Retention)))
+	if err != nil {
+		slog.Warn("history retention failed", "err", err)
+		return
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		slog.Info("history retention applied", "deleted_rows", n, "retention", w.cfg.Retention)
+	}
+}
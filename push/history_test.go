@@ -0,0 +1,131 @@
+package push
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+	"github.com/kinjelom/watchdog_exporter/prober"
+	"github.com/kinjelom/watchdog_exporter/validator"
+)
+
+func newSQLiteHistory(t *testing.T, retention time.Duration) *HistoryWriter {
+	t.Helper()
+	w, err := NewHistoryWriter(config.HistoryConfig{
+		Driver: HistoryDriverSQLite, DSN: filepath.Join(t.TempDir(), "history.db"),
+		Table: "probe_results", Retention: retention, QueueSize: 10,
+	})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() { _ = w.Close() })
+	return w
+}
+
+func TestHistoryWriter_WritesResults(t *testing.T) {
+	w := newSQLiteHistory(t, 0)
+	notAfter := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	err := w.write(context.Background(), []prober.Result{
+		{Group: "g", Endpoint: "ep", Route: "r", Protocol: "http", URL: "https://ep", Status: "valid", Duration: 0.2, At: time.Now(),
+			TLS: &validator.CertsReport{HadTLS: true, ChainValid: true, Version: "TLS 1.3",
+				Certificates: []validator.CertInfo{{NotAfter: notAfter, DaysLeft: 100}}}},
+		{Group: "g", Endpoint: "ep", Route: "r", Status: "request-execution-error", Err: errors.New("refused"), At: time.Now()},
+	})
+	assert.NoError(t, err)
+
+	rows, err := w.db.Query("SELECT status, error, tls_version, tls_chain_valid, tls_leaf_not_after FROM probe_results ORDER BY id")
+	assert.NoError(t, err)
+	defer func() { _ = rows.Close() }()
+
+	type row struct {
+		status                    string
+		errText, version, notAftr *string
+		chainValid                *int
+	}
+	var got []row
+	for rows.Next() {
+		var r row
+		assert.NoError(t, rows.Scan(&r.status, &r.errText, &r.version, &r.chainValid, &r.notAftr))
+		got = append(got, r)
+	}
+	if assert.Len(t, got, 2) {
+		assert.Equal(t, "valid", got[0].status)
+		assert.Nil(t, got[0].errText)
+		assert.Equal(t, "TLS 1.3", *got[0].version)
+		assert.Equal(t, 1, *got[0].chainValid)
+		assert.Equal(t, "2030-01-01T00:00:00.000000000Z", *got[0].notAftr)
+		assert.Equal(t, "refused", *got[1].errText)
+		assert.Nil(t, got[1].version)
+	}
+}
+
+func TestHistoryWriter_Retention(t *testing.T) {
+	w := newSQLiteHistory(t, time.Hour)
+	assert.NoError(t, w.write(context.Background(), []prober.Result{
+		{Endpoint: "old", Status: "valid", At: time.Now().Add(-2 * time.Hour)},
+		{Endpoint: "new", Status: "valid", At: time.Now()},
+	}))
+	w.cleanup(context.Background())
+
+	var endpoint string
+	var n int
+	assert.NoError(t, w.db.QueryRow("SELECT COUNT(*), MAX(endpoint) FROM probe_results").Scan(&n, &endpoint))
+	assert.Equal(t, 1, n)
+	assert.Equal(t, "new", endpoint)
+}
+
+func TestHistoryWriter_RunDrainsQueue(t *testing.T) {
+	w := newSQLiteHistory(t, 0)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.Run(ctx)
+	for i := 0; i < 3; i++ {
+		w.OnResult(prober.Result{Endpoint: "ep", Status: "valid", At: time.Now()})
+	}
+	assert.Eventually(t, func() bool {
+		var n int
+		_ = w.db.QueryRow("SELECT COUNT(*) FROM probe_results").Scan(&n)
+		return n == 3
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestHistoryWriter_InvalidConfig(t *testing.T) {
+	_, err := NewHistoryWriter(config.HistoryConfig{Driver: "mysql", Table: "t"})
+	assert.Error(t, err)
+	_, err = NewHistoryWriter(config.HistoryConfig{Driver: HistoryDriverSQLite, Table: "t; DROP TABLE x"})
+	assert.Error(t, err)
+}
+
+func TestHistoryWriter_PostgresStatements(t *testing.T) {
+	w := &HistoryWriter{cfg: config.HistoryConfig{Driver: HistoryDriverPostgres, Table: "t"}}
+	stmt := w.insertStatement()
+	assert.Contains(t, stmt, "INSERT INTO t (at, group_name")
+	assert.Contains(t, stmt, "$1, $2")
+	assert.Contains(t, stmt, "$17)")
+	at := time.Date(2025, 1, 1, 1, 0, 0, 0, time.FixedZone("x", 3600))
+	assert.Equal(t, at.UTC(), w.timeArg(at))
+}
+
+func TestHistoryWriter_FailedWriteIsRetried(t *testing.T) {
+	w := newSQLiteHistory(t, 0)
+	_, err := w.db.Exec("ALTER TABLE probe_results RENAME TO moved")
+	assert.NoError(t, err)
+	w.OnResult(prober.Result{Endpoint: "ep", Status: "valid", At: time.Now()})
+	w.flush(context.Background())
+	results, _, _ := w.BufferUsage()
+	assert.Equal(t, 1, results, "failed batch must stay buffered")
+
+	_, err = w.db.Exec("ALTER TABLE moved RENAME TO probe_results")
+	assert.NoError(t, err)
+	w.flush(context.Background())
+	var n int
+	assert.NoError(t, w.db.QueryRow("SELECT COUNT(*) FROM probe_results").Scan(&n))
+	assert.Equal(t, 1, n)
+	results, _, _ = w.BufferUsage()
+	assert.Equal(t, 0, results)
+}
@@ -0,0 +1,186 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+	"github.com/kinjelom/watchdog_exporter/prober"
+)
+
+// influxMaxPending caps the HTTP buffer, so a down InfluxDB cannot grow memory without bound.
+const influxMaxPending = 10000
+
+// defaultInfluxTags maps result fields to tag keys when tag-keys is not configured.
+var defaultInfluxTags = map[string]string{
+	"group":    "group",
+	"endpoint": "endpoint",
+	"route":    "route",
+	"protocol": "protocol",
+	"status":   "status",
+	"ip":       "ip", // only set with probe-all-ips
+}
+
+// InfluxWriter writes every probe result as one point in InfluxDB line protocol.
+// Over UDP a point is sent right away; over the HTTP v2 API points are buffered and written in batches.
+type InfluxWriter struct {
+	cfg    config.InfluxDBConfig
+	client *http.Client
+	conn   net.Conn
+	dirty  atomic.Bool
+
+	mu      sync.Mutex
+	pending []string
+}
+
+func NewInfluxWriter(cfg config.InfluxDBConfig) (*InfluxWriter, error) {
+	w := &InfluxWriter{cfg: cfg}
+	if cfg.UDPAddress != "" {
+		conn, err := net.Dial("udp", cfg.UDPAddress)
+		if err != nil {
+			return nil, err
+		}
+		w.conn = conn
+		return w, nil
+	}
+	w.client = &http.Client{Timeout: cfg.Timeout}
+	return w, nil
+}
+
+func (w *InfluxWriter) Close() error {
+	if w.conn != nil {
+		return w.conn.Close()
+	}
+	return nil
+}
+
+func (w *InfluxWriter) OnResult(r prober.Result) {
+	line := w.line(r)
+	if w.conn != nil {
+		_, _ = w.conn.Write([]byte(line))
+		return
+	}
+	w.mu.Lock()
+	if len(w.pending) >= influxMaxPending {
+		w.pending = w.pending[1:]
+	}
+	w.pending = append(w.pending, line)
+	w.mu.Unlock()
+	w.dirty.Store(true)
+}
+
+// Run flushes buffered points every flush-interval until ctx is done (HTTP only).
+func (w *InfluxWriter) Run(ctx context.Context) {
+	if w.conn != nil {
+		return
+	}
+	flushLoop(ctx, "influxdb", w.cfg.FlushInterval, &w.dirty, w.Flush)
+}
+
+// Flush writes all buffered points with one request to /api/v2/write.
+// On failure the points are kept for the next flush.
+func (w *InfluxWriter) Flush(ctx context.Context) error {
+	w.mu.Lock()
+	lines := w.pending
+	w.pending = nil
+	w.mu.Unlock()
+	if len(lines) == 0 {
+		return nil
+	}
+
+	if err := w.write(ctx, lines); err != nil {
+		w.mu.Lock()
+		w.pending = append(lines, w.pending...)
+		if over := len(w.pending) - influxMaxPending; over > 0 {
+			w.pending = w.pending[over:]
+		}
+		w.mu.Unlock()
+		w.dirty.Store(true)
+		return err
+	}
+	return nil
+}
+
+func (w *InfluxWriter) write(ctx context.Context, lines []string) error {
+	q := url.Values{}
+	q.Set("org", w.cfg.Org)
+	q.Set("bucket", w.cfg.Bucket)
+	q.Set("precision", "ns")
+	target := strings.TrimSuffix(w.cfg.URL, "/") + "/api/v2/write?" + q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, strings.NewReader(strings.Join(lines, "\n")))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if w.cfg.Token != "" {
+		req.Header.Set("Authorization", "Token "+w.cfg.Token)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func(Body io.ReadCloser) { _ = Body.Close() }(resp.Body)
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// line renders r as: <measurement>,<tags> up=<0|1>i,duration_seconds=<f>,consecutive_failures=<n>i,redirects=<n>i <ts>
+func (w *InfluxWriter) line(r prober.Result) string {
+	fields := map[string]string{
+		"group":    r.Group,
+		"endpoint": r.Endpoint,
+		"route":    r.Route,
+		"protocol": r.Protocol,
+		"url":      r.URL,
+		"status":   string(r.Status),
+		"ip":       r.IP,
+	}
+	mapping := w.cfg.TagKeys
+	if len(mapping) == 0 {
+		mapping = defaultInfluxTags
+	}
+	tags := make([]string, 0, len(mapping)+len(w.cfg.Tags))
+	for field, key := range mapping {
+		if v := fields[field]; key != "" && v != "" {
+			tags = append(tags, influxEscape(key)+"="+influxEscape(v))
+		}
+	}
+	for k, v := range w.cfg.Tags {
+		tags = append(tags, influxEscape(k)+"="+influxEscape(v))
+	}
+	sort.Strings(tags)
+
+	up := 1
+	if r.Failed() {
+		up = 0
+	}
+	var b strings.Builder
+	b.WriteString(influxEscape(w.cfg.Measurement))
+	for _, t := range tags {
+		b.WriteByte(',')
+		b.WriteString(t)
+	}
+	fmt.Fprintf(&b, " up=%di,duration_seconds=%s,consecutive_failures=%di,redirects=%di %d",
+		up, strconv.FormatFloat(r.Duration, 'f', -1, 64), r.ConsecutiveFailures, r.Redirects, r.At.UnixNano())
+	return b.String()
+}
+
+// influxEscape escapes commas, equal signs and spaces in measurement names, tag keys and tag values.
+func influxEscape(v string) string {
+	return strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `, "\n", `\n`).Replace(v)
+}
@@ -0,0 +1,85 @@
+package push
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+	"github.com/kinjelom/watchdog_exporter/prober"
+)
+
+func TestInfluxWriter_Line(t *testing.T) {
+	w := &InfluxWriter{cfg: config.InfluxDBConfig{Measurement: "watchdog_probe", Tags: map[string]string{"env": "dev"}}}
+	at := time.Unix(1700000000, 0)
+	got := w.line(prober.Result{Group: "g 1", Endpoint: "example.com", Route: "direct", Protocol: "http", Status: "valid", Duration: 0.25, At: at})
+
+	assert.Equal(t, `watchdog_probe,endpoint=example.com,env=dev,group=g\ 1,protocol=http,route=direct,status=valid `+
+		`up=1i,duration_seconds=0.25,consecutive_failures=0i,redirects=0i 1700000000000000000`, got)
+}
+
+func TestInfluxWriter_TagKeysMapping(t *testing.T) {
+	w := &InfluxWriter{cfg: config.InfluxDBConfig{Measurement: "m", TagKeys: map[string]string{"endpoint": "host", "url": "url"}}}
+	got := w.line(prober.Result{Endpoint: "ep", URL: "http://x", Status: "status-code-mismatch", ConsecutiveFailures: 2, At: time.Unix(1, 0)})
+
+	assert.Equal(t, "m,host=ep,url=http://x up=0i,duration_seconds=0,consecutive_failures=2i,redirects=0i 1000000000", got)
+}
+
+func TestInfluxWriter_FlushHTTP(t *testing.T) {
+	var body, auth, query string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		body, auth, query = string(b), r.Header.Get("Authorization"), r.URL.RawQuery
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	w, err := NewInfluxWriter(config.InfluxDBConfig{URL: srv.URL, Org: "o", Bucket: "b", Token: "t", Measurement: "m", Timeout: time.Second})
+	assert.NoError(t, err)
+	w.OnResult(prober.Result{Endpoint: "a", Status: "valid", At: time.Unix(1, 0)})
+	w.OnResult(prober.Result{Endpoint: "b", Status: "valid", At: time.Unix(2, 0)})
+	assert.NoError(t, w.Flush(context.Background()))
+
+	assert.Equal(t, "Token t", auth)
+	assert.Contains(t, query, "bucket=b")
+	assert.Contains(t, query, "org=o")
+	assert.Len(t, strings.Split(body, "\n"), 2)
+	assert.Empty(t, w.pending)
+}
+
+func TestInfluxWriter_FlushKeepsPointsOnError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	w, _ := NewInfluxWriter(config.InfluxDBConfig{URL: srv.URL, Measurement: "m", Timeout: time.Second})
+	w.OnResult(prober.Result{Endpoint: "a", Status: "valid"})
+	assert.Error(t, w.Flush(context.Background()))
+	assert.Len(t, w.pending, 1)
+	assert.True(t, w.dirty.Load())
+}
+
+func TestInfluxWriter_UDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer func() { _ = pc.Close() }()
+
+	w, err := NewInfluxWriter(config.InfluxDBConfig{UDPAddress: pc.LocalAddr().String(), Measurement: "m"})
+	assert.NoError(t, err)
+	defer func() { _ = w.Close() }()
+	w.OnResult(prober.Result{Endpoint: "ep", Status: "valid"})
+
+	buf := make([]byte, 1024)
+	_ = pc.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := pc.ReadFrom(buf)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(buf[:n]), "m,endpoint=ep,status=valid up=1i"))
+}
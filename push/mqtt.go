@@ -0,0 +1,271 @@
+package push
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/kinjelom/watchdog_exporter/api"
+	"github.com/kinjelom/watchdog_exporter/config"
+	"github.com/kinjelom/watchdog_exporter/prober"
+)
+
+// MQTTPublisher publishes every result as JSON to an MQTT 3.1.1 broker (QoS 0 or 1).
+// Results are queued by OnResult and published by Run, which (re)connects as needed.
+type MQTTPublisher struct {
+	queueDrops
+	cfg   config.MQTTConfig
+	topic *template.Template
+	queue chan api.ResultView
+}
+
+func NewMQTTPublisher(cfg config.MQTTConfig) (*MQTTPublisher, error) {
+	if cfg.QoS < 0 || cfg.QoS > 1 {
+		return nil, fmt.Errorf("unsupported qos %d (0 or 1)", cfg.QoS)
+	}
+	topic, err := template.New("topic").Parse(cfg.Topic)
+	if err != nil {
+		return nil, fmt.Errorf("topic: %w", err)
+	}
+	return &MQTTPublisher{cfg: cfg, topic: topic, queue: make(chan api.ResultView, cfg.QueueSize)}, nil
+}
+
+func (p *MQTTPublisher) OnResult(r prober.Result) {
+	select {
+	case p.queue <- api.NewResultView(r):
+	default:
+		p.queueDrops.n.Add(1)
+		slog.Warn("mqtt queue full, result dropped", "endpoint", r.Endpoint, "route", r.Route)
+	}
+}
+
+// Run publishes queued results until ctx is done, reconnecting after errors; see streamLoop.
+func (p *MQTTPublisher) Run(ctx context.Context) {
+	streamLoop(ctx, "mqtt", p.queue, &p.queueDrops, p.cfg.KeepAlive/2, p.connect, p.publish)
+}
+
+func (p *MQTTPublisher) publish(c *mqttConn, v api.ResultView) error {
+	var topic bytes.Buffer
+	if err := p.topic.Execute(&topic, v); err != nil {
+		return fmt.Errorf("%w: topic: %w", errUnencodable, err)
+	}
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("%w: %w", errUnencodable, err)
+	}
+	return c.publish(sanitizeTopic(topic.String()), payload, p.cfg.QoS, p.cfg.Retain)
+}
+
+// sanitizeTopic removes the wildcard characters that are not allowed in published topics.
+func sanitizeTopic(t string) string {
+	return strings.NewReplacer("+", "_", "#", "_", "\x00", "_").Replace(t)
+}
+
+func (p *MQTTPublisher) connect(ctx context.Context) (*mqttConn, error) {
+	u, err := url.Parse(p.cfg.Broker)
+	if err != nil {
+		return nil, err
+	}
+	d := net.Dialer{Timeout: p.cfg.Timeout}
+	var nc net.Conn
+	switch u.Scheme {
+	case "tcp", "mqtt":
+		nc, err = d.DialContext(ctx, "tcp", u.Host)
+	case "tls", "ssl", "mqtts":
+		td := tls.Dialer{NetDialer: &d, Config: &tls.Config{ServerName: u.Hostname()}}
+		nc, err = td.DialContext(ctx, "tcp", u.Host)
+	default:
+		return nil, fmt.Errorf("unsupported broker scheme %q", u.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c := &mqttConn{conn: nc, r: bufio.NewReader(nc), timeout: p.cfg.Timeout}
+	if err = c.handshake(p.cfg.ClientID, p.cfg.Username, p.cfg.Password, p.cfg.KeepAlive); err != nil {
+		c.close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// MQTT 3.1.1 control packet types (upper nibble of the fixed header).
+const (
+	mqttConnect    = 0x10
+	mqttConnAck    = 0x20
+	mqttPublish    = 0x30
+	mqttPubAck     = 0x40
+	mqttPingReq    = 0xC0
+	mqttPingResp   = 0xD0
+	mqttDisconnect = 0xE0
+)
+
+// mqttConn is a minimal MQTT 3.1.1 client connection: publish only, used from one goroutine.
+type mqttConn struct {
+	conn     net.Conn
+	r        *bufio.Reader
+	timeout  time.Duration
+	packetID uint16
+}
+
+func (c *mqttConn) handshake(clientID, username, password string, keepAlive time.Duration) error {
+	var vh bytes.Buffer
+	writeMQTTString(&vh, "MQTT")
+	vh.WriteByte(4)     // protocol level 3.1.1
+	flags := byte(0x02) // clean session
+	if username != "" {
+		flags |= 0x80
+		if password != "" {
+			flags |= 0x40
+		}
+	}
+	vh.WriteByte(flags)
+	_ = binary.Write(&vh, binary.BigEndian, uint16(keepAlive/time.Second))
+	writeMQTTString(&vh, clientID)
+	if username != "" {
+		writeMQTTString(&vh, username)
+		if password != "" {
+			writeMQTTString(&vh, password)
+		}
+	}
+	if err := c.write(mqttConnect, vh.Bytes()); err != nil {
+		return err
+	}
+
+	typ, body, err := c.read()
+	if err != nil {
+		return err
+	}
+	if typ != mqttConnAck || len(body) != 2 {
+		return fmt.Errorf("unexpected packet 0x%02x instead of CONNACK", typ)
+	}
+	if body[1] != 0 {
+		return fmt.Errorf("connection refused, return code %d", body[1])
+	}
+	return nil
+}
+
+func (c *mqttConn) publish(topic string, payload []byte, qos int, retain bool) error {
+	header := byte(mqttPublish) | byte(qos<<1)
+	if retain {
+		header |= 0x01
+	}
+	var b bytes.Buffer
+	writeMQTTString(&b, topic)
+	var id uint16
+	if qos > 0 {
+		c.packetID++
+		if c.packetID == 0 {
+			c.packetID = 1
+		}
+		id = c.packetID
+		_ = binary.Write(&b, binary.BigEndian, id)
+	}
+	b.Write(payload)
+	if err := c.write(header, b.Bytes()); err != nil {
+		return err
+	}
+	if qos == 0 {
+		return nil
+	}
+	return c.await(mqttPubAck, func(body []byte) bool {
+		return len(body) == 2 && binary.BigEndian.Uint16(body) == id
+	})
+}
+
+func (c *mqttConn) ping() error {
+	if err := c.write(mqttPingReq, nil); err != nil {
+		return err
+	}
+	return c.await(mqttPingResp, func([]byte) bool { return true })
+}
+
+// await reads packets until one of type typ matching ok arrives (others are ignored).
+func (c *mqttConn) await(typ byte, ok func(body []byte) bool) error {
+	for {
+		got, body, err := c.read()
+		if err != nil {
+			return err
+		}
+		if got == typ && ok(body) {
+			return nil
+		}
+	}
+}
+
+func (c *mqttConn) close() {
+	_ = c.write(mqttDisconnect, nil)
+	_ = c.conn.Close()
+}
+
+func (c *mqttConn) write(header byte, body []byte) error {
+	_ = c.conn.SetWriteDeadline(time.Now().Add(c.timeout))
+	pkt := append([]byte{header}, encodeMQTTLength(len(body))...)
+	_, err := c.conn.Write(append(pkt, body...))
+	return err
+}
+
+func (c *mqttConn) read() (byte, []byte, error) {
+	_ = c.conn.SetReadDeadline(time.Now().Add(c.timeout))
+	header, err := c.r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	n, err := decodeMQTTLength(c.r)
+	if err != nil {
+		return 0, nil, err
+	}
+	body := make([]byte, n)
+	if _, err = io.ReadFull(c.r, body); err != nil {
+		return 0, nil, err
+	}
+	return header & 0xF0, body, nil
+}
+
+func writeMQTTString(b *bytes.Buffer, s string) {
+	_ = binary.Write(b, binary.BigEndian, uint16(len(s)))
+	b.WriteString(s)
+}
+
+// encodeMQTTLength encodes the "remaining length" variable byte integer.
+func encodeMQTTLength(n int) []byte {
+	var out []byte
+	for {
+		b := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			b |= 0x80
+		}
+		out = append(out, b)
+		if n == 0 {
+			return out
+		}
+	}
+}
+
+func decodeMQTTLength(r io.ByteReader) (int, error) {
+	n, mul := 0, 1
+	for i := 0; i < 4; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		n += int(b&0x7F) * mul
+		if b&0x80 == 0 {
+			return n, nil
+		}
+		mul *= 128
+	}
+	return 0, errors.New("malformed remaining length")
+}
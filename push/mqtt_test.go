@@ -0,0 +1,234 @@
+package push
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kinjelom/watchdog_exporter/api"
+	"github.com/kinjelom/watchdog_exporter/config"
+	"github.com/kinjelom/watchdog_exporter/prober"
+	"github.com/kinjelom/watchdog_exporter/status"
+)
+
+type mqttMessage struct {
+	topic   string
+	qos     int
+	retain  bool
+	payload []byte
+}
+
+// fakeBroker accepts connections, acknowledges CONNECT/PUBLISH and reports the publishes.
+func fakeBroker(t *testing.T) (string, <-chan mqttMessage, <-chan []byte) {
+	return fakeRefusingBroker(t, 0)
+}
+
+// fakeRefusingBroker is fakeBroker refusing the first refusals connections with CONNACK return code 3.
+func fakeRefusingBroker(t *testing.T, refusals int) (string, <-chan mqttMessage, <-chan []byte) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+
+	msgs := make(chan mqttMessage, 10)
+	connects := make(chan []byte, 10)
+	go func() {
+		for i := 0; ; i++ {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveMQTT(conn, i < refusals, msgs, connects)
+		}
+	}()
+	return "tcp://" + ln.Addr().String(), msgs, connects
+}
+
+func serveMQTT(conn net.Conn, refuse bool, msgs chan<- mqttMessage, connects chan<- []byte) {
+	defer func() { _ = conn.Close() }()
+	r := bufio.NewReader(conn)
+	for {
+		header, body, err := readMQTTPacket(r)
+		if err != nil {
+			return
+		}
+		switch header & 0xF0 {
+		case mqttConnect:
+			connects <- body
+			if refuse {
+				_, _ = conn.Write([]byte{mqttConnAck, 2, 0, 3}) // server unavailable
+				return
+			}
+			_, _ = conn.Write([]byte{mqttConnAck, 2, 0, 0})
+		case mqttPublish:
+			qos := int(header>>1) & 0x03
+			tl := int(binary.BigEndian.Uint16(body))
+			m := mqttMessage{topic: string(body[2 : 2+tl]), qos: qos, retain: header&0x01 != 0}
+			rest := body[2+tl:]
+			if qos > 0 {
+				_, _ = conn.Write([]byte{mqttPubAck, 2, rest[0], rest[1]})
+				rest = rest[2:]
+			}
+			m.payload = rest
+			msgs <- m
+		case mqttPingReq:
+			_, _ = conn.Write([]byte{mqttPingResp, 0})
+		}
+	}
+}
+
+func readMQTTPacket(r *bufio.Reader) (byte, []byte, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	n, err := decodeMQTTLength(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	body := make([]byte, n)
+	_, err = io.ReadFull(r, body)
+	return header, body, err
+}
+
+func TestMQTTPublisher_PublishesJSON(t *testing.T) {
+	broker, msgs, connects := fakeBroker(t)
+	p, err := NewMQTTPublisher(config.MQTTConfig{
+		Broker: broker, ClientID: "wd", Username: "u", Password: "p",
+		Topic: "watchdog/{{.Group}}/{{.Endpoint}}", QoS: 1, Retain: true,
+		KeepAlive: time.Minute, Timeout: time.Second, QueueSize: 10,
+	})
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go p.Run(ctx)
+	p.OnResult(prober.Result{Group: "home", Endpoint: "nas+1", Route: "direct", Status: "valid", Duration: 0.2})
+
+	select {
+	case body := <-connects:
+		assert.True(t, bytes.Contains(body, []byte("MQTT")))
+		assert.Equal(t, byte(0xC2), body[7]) // username, password, clean session
+	case <-time.After(2 * time.Second):
+		t.Fatal("no CONNECT")
+	}
+	select {
+	case m := <-msgs:
+		assert.Equal(t, "watchdog/home/nas_1", m.topic)
+		assert.Equal(t, 1, m.qos)
+		assert.True(t, m.retain)
+		var v api.ResultView
+		assert.NoError(t, json.Unmarshal(m.payload, &v))
+		assert.Equal(t, status.Valid, v.Status)
+		assert.Equal(t, "nas+1", v.Endpoint)
+	case <-time.After(2 * time.Second):
+		t.Fatal("no PUBLISH")
+	}
+}
+
+func TestMQTTPublisher_CountsQueueDrops(t *testing.T) {
+	p, err := NewMQTTPublisher(config.MQTTConfig{Topic: "wd", QueueSize: 1})
+	assert.NoError(t, err)
+	p.OnResult(prober.Result{Endpoint: "a", Status: "valid"})
+	p.OnResult(prober.Result{Endpoint: "b", Status: "valid"})
+	assert.Equal(t, uint64(1), p.QueueDrops())
+}
+
+func TestMQTTPublisher_RejectsQoS2(t *testing.T) {
+	_, err := NewMQTTPublisher(config.MQTTConfig{QoS: 2})
+	assert.Error(t, err)
+}
+
+func TestMQTTLength(t *testing.T) {
+	for _, n := range []int{0, 127, 128, 16383, 16384, 2097151, 268435455} {
+		got, err := decodeMQTTLength(bytes.NewReader(encodeMQTTLength(n)))
+		assert.NoError(t, err)
+		assert.Equal(t, n, got)
+	}
+	assert.Equal(t, []byte{0x80, 0x01}, encodeMQTTLength(128))
+}
+
+func TestMQTTConn_HandshakeFailures(t *testing.T) {
+	tests := []struct {
+		name    string
+		reply   []byte
+		wantErr string
+	}{
+		{"refused", []byte{mqttConnAck, 2, 0, 5}, "connection refused, return code 5"},
+		{"not a CONNACK", []byte{mqttPubAck, 2, 0, 1}, "instead of CONNACK"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, server := net.Pipe()
+			defer func() { _ = client.Close() }()
+			go func() {
+				defer func() { _ = server.Close() }()
+				if _, _, err := readMQTTPacket(bufio.NewReader(server)); err == nil {
+					_, _ = server.Write(tt.reply)
+				}
+			}()
+			c := &mqttConn{conn: client, r: bufio.NewReader(client), timeout: time.Second}
+			err := c.handshake("wd", "", "", time.Minute)
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestMQTTConn_PublishWaitsForItsPubAck(t *testing.T) {
+	client, server := net.Pipe()
+	defer func() { _ = client.Close() }()
+	go func() {
+		defer func() { _ = server.Close() }()
+		if _, _, err := readMQTTPacket(bufio.NewReader(server)); err == nil {
+			_, _ = server.Write([]byte{mqttPubAck, 2, 0, 9}) // another packet id, then nothing
+		}
+	}()
+	c := &mqttConn{conn: client, r: bufio.NewReader(client), timeout: 200 * time.Millisecond}
+	assert.Error(t, c.publish("wd", []byte("{}"), 1, false), "no PUBACK for the packet")
+}
+
+func TestMQTTPublisher_RetriesUndeliveredResult(t *testing.T) {
+	broker, msgs, connects := fakeRefusingBroker(t, 1)
+	p, err := NewMQTTPublisher(config.MQTTConfig{Broker: broker, Topic: "wd/{{.Endpoint}}", QoS: 1,
+		KeepAlive: time.Minute, Timeout: time.Second, QueueSize: 10})
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go p.Run(ctx)
+	p.OnResult(prober.Result{Endpoint: "nas", Status: "valid"})
+
+	select {
+	case m := <-msgs:
+		assert.Equal(t, "wd/nas", m.topic, "the result is kept across the refused connection")
+	case <-time.After(3 * time.Second):
+		t.Fatal("no PUBLISH after the refused connection")
+	}
+	assert.Len(t, connects, 2, "refused, then accepted after the backoff")
+	assert.Equal(t, uint64(0), p.QueueDrops())
+}
+
+func TestMQTTPublisher_DropsUnencodableResult(t *testing.T) {
+	broker, msgs, _ := fakeBroker(t)
+	p, err := NewMQTTPublisher(config.MQTTConfig{Broker: broker, Topic: "wd/{{.Endpoint.Missing}}",
+		KeepAlive: time.Minute, Timeout: time.Second, QueueSize: 10})
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go p.Run(ctx)
+	p.OnResult(prober.Result{Endpoint: "nas", Status: "valid"})
+
+	assert.Eventually(t, func() bool { return p.QueueDrops() == 1 }, 2*time.Second, 10*time.Millisecond)
+	assert.Len(t, msgs, 0)
+}
@@ -0,0 +1,274 @@
+package push
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/kinjelom/watchdog_exporter/api"
+	"github.com/kinjelom/watchdog_exporter/config"
+	"github.com/kinjelom/watchdog_exporter/prober"
+)
+
+// natsPingInterval keeps idle connections alive and detects dead ones.
+const natsPingInterval = time.Minute
+
+// NATSPublisher publishes every result as JSON to a NATS subject.
+// With JetStream enabled every publish waits for the stream's ack, so results persisted in the stream can be replayed.
+type NATSPublisher struct {
+	queueDrops
+	cfg     config.NATSConfig
+	subject *template.Template
+	queue   chan api.ResultView
+}
+
+func NewNATSPublisher(cfg config.NATSConfig) (*NATSPublisher, error) {
+	subject, err := template.New("subject").Parse(cfg.Subject)
+	if err != nil {
+		return nil, fmt.Errorf("subject: %w", err)
+	}
+	return &NATSPublisher{cfg: cfg, subject: subject, queue: make(chan api.ResultView, cfg.QueueSize)}, nil
+}
+
+func (p *NATSPublisher) OnResult(r prober.Result) {
+	select {
+	case p.queue <- api.NewResultView(r):
+	default:
+		p.queueDrops.n.Add(1)
+		slog.Warn("nats queue full, result dropped", "endpoint", r.Endpoint, "route", r.Route)
+	}
+}
+
+// Run publishes queued results until ctx is done, reconnecting after errors; see streamLoop.
+func (p *NATSPublisher) Run(ctx context.Context) {
+	streamLoop(ctx, "nats", p.queue, &p.queueDrops, natsPingInterval, p.connect, p.publish)
+}
+
+func (p *NATSPublisher) publish(c *natsConn, v api.ResultView) error {
+	// Subject tokens are separated by dots, so the values are made dot-free first.
+	tv := v
+	tv.Group, tv.Endpoint, tv.Route = natsToken(v.Group), natsToken(v.Endpoint), natsToken(v.Route)
+	var subject bytes.Buffer
+	if err := p.subject.Execute(&subject, tv); err != nil {
+		return fmt.Errorf("%w: subject: %w", errUnencodable, err)
+	}
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("%w: %w", errUnencodable, err)
+	}
+	return c.publish(subject.String(), payload, p.cfg.JetStream)
+}
+
+// natsToken makes v usable as one subject token.
+func natsToken(v string) string {
+	if v == "" {
+		return "none"
+	}
+	return strings.NewReplacer(".", "_", " ", "_", "\t", "_", "*", "_", ">", "_").Replace(v)
+}
+
+func (p *NATSPublisher) connect(ctx context.Context) (*natsConn, error) {
+	u, err := url.Parse(p.cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+	d := net.Dialer{Timeout: p.cfg.Timeout}
+	nc, err := d.DialContext(ctx, "tcp", u.Host)
+	if err != nil {
+		return nil, err
+	}
+	c := &natsConn{conn: nc, r: bufio.NewReader(nc), timeout: p.cfg.Timeout}
+
+	// The server speaks first: INFO {...}
+	line, err := c.readLine()
+	if err != nil {
+		c.close()
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "INFO ") {
+		c.close()
+		return nil, fmt.Errorf("unexpected greeting %q", line)
+	}
+	var info struct {
+		TLSRequired bool `json:"tls_required"`
+	}
+	_ = json.Unmarshal([]byte(strings.TrimPrefix(line, "INFO ")), &info)
+	if u.Scheme == "tls" || info.TLSRequired {
+		tc := tls.Client(nc, &tls.Config{ServerName: u.Hostname()})
+		if err = tc.HandshakeContext(ctx); err != nil {
+			c.close()
+			return nil, err
+		}
+		c.conn, c.r = tc, bufio.NewReader(tc)
+	}
+
+	opts := natsConnectOptions{Verbose: false, Pedantic: false, Name: "watchdog_exporter", Lang: "go", Version: "1", Protocol: 1,
+		User: p.cfg.Username, Pass: p.cfg.Password, AuthToken: p.cfg.Token}
+	if u.User != nil && opts.User == "" {
+		opts.User = u.User.Username()
+		opts.Pass, _ = u.User.Password()
+	}
+	connect, _ := json.Marshal(opts)
+	if err = c.write("CONNECT " + string(connect) + "\r\n"); err != nil {
+		c.close()
+		return nil, err
+	}
+	// PING/PONG confirms the CONNECT was accepted (an auth failure arrives as -ERR first).
+	if err = c.ping(); err != nil {
+		c.close()
+		return nil, err
+	}
+	if p.cfg.JetStream {
+		c.inbox = "_INBOX." + randomToken()
+		if err = c.write("SUB " + c.inbox + ".* 1\r\n"); err != nil {
+			c.close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+type natsConnectOptions struct {
+	Verbose   bool   `json:"verbose"`
+	Pedantic  bool   `json:"pedantic"`
+	Name      string `json:"name"`
+	Lang      string `json:"lang"`
+	Version   string `json:"version"`
+	Protocol  int    `json:"protocol"`
+	User      string `json:"user,omitempty"`
+	Pass      string `json:"pass,omitempty"`
+	AuthToken string `json:"auth_token,omitempty"`
+}
+
+// natsConn is a minimal NATS client connection: publish only, used from one goroutine.
+type natsConn struct {
+	conn    net.Conn
+	r       *bufio.Reader
+	timeout time.Duration
+	inbox   string // JetStream ack inbox prefix ("" without JetStream)
+	seq     int
+}
+
+func (c *natsConn) publish(subject string, payload []byte, jetStream bool) error {
+	if !jetStream {
+		return c.write(fmt.Sprintf("PUB %s %d\r\n%s\r\n", subject, len(payload), payload))
+	}
+	c.seq++
+	reply := c.inbox + "." + strconv.Itoa(c.seq)
+	if err := c.write(fmt.Sprintf("PUB %s %s %d\r\n%s\r\n", subject, reply, len(payload), payload)); err != nil {
+		return err
+	}
+	for {
+		line, err := c.next()
+		if err != nil {
+			return err
+		}
+		if !strings.HasPrefix(line, "MSG ") {
+			continue
+		}
+		// MSG <subject> <sid> <bytes>
+		fields := strings.Fields(line)
+		n, err := strconv.Atoi(fields[len(fields)-1])
+		if err != nil {
+			return fmt.Errorf("malformed %q", line)
+		}
+		body := make([]byte, n+2)
+		if _, err = io.ReadFull(c.r, body); err != nil {
+			return err
+		}
+		if fields[1] != reply {
+			continue // late ack of an earlier, timed out publish
+		}
+		var ack struct {
+			Stream string `json:"stream"`
+			Error  *struct {
+				Description string `json:"description"`
+			} `json:"error"`
+		}
+		if err = json.Unmarshal(body[:n], &ack); err != nil {
+			return fmt.Errorf("jetstream ack: %w", err)
+		}
+		if ack.Error != nil {
+			return fmt.Errorf("jetstream: %s", ack.Error.Description)
+		}
+		if ack.Stream == "" {
+			return errors.New("jetstream: no stream is bound to the subject")
+		}
+		return nil
+	}
+}
+
+func (c *natsConn) ping() error {
+	if err := c.write("PING\r\n"); err != nil {
+		return err
+	}
+	for {
+		line, err := c.next()
+		if err != nil {
+			return err
+		}
+		if line == "PONG" {
+			return nil
+		}
+	}
+}
+
+// next returns the next protocol line, answering server PINGs and turning -ERR into an error.
+func (c *natsConn) next() (string, error) {
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return "", err
+		}
+		switch {
+		case line == "PING":
+			if err = c.write("PONG\r\n"); err != nil {
+				return "", err
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return "", fmt.Errorf("server: %s", strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+		case line == "+OK" || strings.HasPrefix(line, "INFO "):
+		default:
+			return line, nil
+		}
+	}
+}
+
+func (c *natsConn) readLine() (string, error) {
+	_ = c.conn.SetReadDeadline(time.Now().Add(c.timeout))
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func (c *natsConn) write(s string) error {
+	_ = c.conn.SetWriteDeadline(time.Now().Add(c.timeout))
+	_, err := io.WriteString(c.conn, s)
+	return err
+}
+
+func (c *natsConn) close() {
+	_ = c.conn.Close()
+}
+
+func randomToken() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,169 @@
+package push
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kinjelom/watchdog_exporter/api"
+	"github.com/kinjelom/watchdog_exporter/config"
+	"github.com/kinjelom/watchdog_exporter/prober"
+)
+
+type natsMessage struct {
+	subject, reply string
+	payload        []byte
+}
+
+// fakeNATS accepts connections and records publishes; with jetStream it acks them on the reply subject.
+func fakeNATS(t *testing.T, jetStream bool) (string, <-chan natsMessage, <-chan string) {
+	return fakeNATSDropping(t, jetStream, 0)
+}
+
+// fakeNATSDropping is fakeNATS closing the first drops connections on their first PUB, without an ack.
+func fakeNATSDropping(t *testing.T, jetStream bool, drops int) (string, <-chan natsMessage, <-chan string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+
+	msgs := make(chan natsMessage, 10)
+	connects := make(chan string, 10)
+	go func() {
+		for i := 0; ; i++ {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveNATS(conn, jetStream, i < drops, msgs, connects)
+		}
+	}()
+	return "nats://u:p@" + ln.Addr().String(), msgs, connects
+}
+
+func serveNATS(conn net.Conn, jetStream, drop bool, msgs chan<- natsMessage, connects chan<- string) {
+	defer func() { _ = conn.Close() }()
+	r := bufio.NewReader(conn)
+	_, _ = io.WriteString(conn, `INFO {"server_id":"fake","max_payload":1048576}`+"\r\n")
+	seq := 0
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		fields := strings.Fields(line)
+		switch fields[0] {
+		case "CONNECT":
+			connects <- strings.TrimPrefix(line, "CONNECT ")
+		case "PING":
+			_, _ = io.WriteString(conn, "PONG\r\n")
+		case "PUB":
+			if drop {
+				return
+			}
+			n, _ := strconv.Atoi(fields[len(fields)-1])
+			body := make([]byte, n+2)
+			_, _ = io.ReadFull(r, body)
+			m := natsMessage{subject: fields[1], payload: body[:n]}
+			if len(fields) == 4 {
+				m.reply = fields[2]
+			}
+			if jetStream && m.reply != "" {
+				seq++
+				ack := fmt.Sprintf(`{"stream":"WATCHDOG","seq":%d}`, seq)
+				_, _ = io.WriteString(conn, fmt.Sprintf("PING\r\nMSG %s 1 %d\r\n%s\r\n", m.reply, len(ack), ack))
+			}
+			msgs <- m
+		}
+	}
+}
+
+func runNATS(t *testing.T, cfg config.NATSConfig) *NATSPublisher {
+	t.Helper()
+	p, err := NewNATSPublisher(cfg)
+	assert.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go p.Run(ctx)
+	return p
+}
+
+func TestNATSPublisher_Publish(t *testing.T) {
+	url, msgs, connects := fakeNATS(t, false)
+	p := runNATS(t, config.NATSConfig{URL: url, Subject: "watchdog.{{.Group}}.{{.Endpoint}}", Timeout: time.Second, QueueSize: 10})
+	p.OnResult(prober.Result{Group: "g1", Endpoint: "example.com", Status: "valid"})
+
+	select {
+	case opts := <-connects:
+		var o natsConnectOptions
+		assert.NoError(t, json.Unmarshal([]byte(opts), &o))
+		assert.Equal(t, "u", o.User)
+		assert.Equal(t, "p", o.Pass)
+	case <-time.After(2 * time.Second):
+		t.Fatal("no CONNECT")
+	}
+	select {
+	case m := <-msgs:
+		assert.Equal(t, "watchdog.g1.example_com", m.subject)
+		assert.Empty(t, m.reply)
+		var v api.ResultView
+		assert.NoError(t, json.Unmarshal(m.payload, &v))
+		assert.Equal(t, "example.com", v.Endpoint)
+	case <-time.After(2 * time.Second):
+		t.Fatal("no PUB")
+	}
+}
+
+func TestNATSPublisher_JetStreamAck(t *testing.T) {
+	url, msgs, _ := fakeNATS(t, true)
+	p := runNATS(t, config.NATSConfig{URL: url, Subject: "watchdog.results", JetStream: true, Timeout: time.Second, QueueSize: 10})
+	p.OnResult(prober.Result{Endpoint: "a", Status: "valid"})
+	p.OnResult(prober.Result{Endpoint: "b", Status: "valid"})
+
+	for _, want := range []string{"a", "b"} {
+		select {
+		case m := <-msgs:
+			assert.True(t, strings.HasPrefix(m.reply, "_INBOX."))
+			var v api.ResultView
+			assert.NoError(t, json.Unmarshal(m.payload, &v))
+			assert.Equal(t, want, v.Endpoint)
+		case <-time.After(2 * time.Second):
+			t.Fatal("no PUB")
+		}
+	}
+}
+
+func TestNATSPublisher_RedeliversUnackedResult(t *testing.T) {
+	url, msgs, connects := fakeNATSDropping(t, true, 1)
+	p := runNATS(t, config.NATSConfig{URL: url, Subject: "watchdog.results", JetStream: true, Timeout: time.Second, QueueSize: 10})
+	p.OnResult(prober.Result{Endpoint: "a", Status: "valid"})
+
+	select {
+	case m := <-msgs:
+		var v api.ResultView
+		assert.NoError(t, json.Unmarshal(m.payload, &v))
+		assert.Equal(t, "a", v.Endpoint, "the result is kept across the broken connection")
+	case <-time.After(3 * time.Second):
+		t.Fatal("no PUB after the broken connection")
+	}
+	assert.Len(t, connects, 2, "dropped, then reconnected after the backoff")
+	assert.Equal(t, uint64(0), p.QueueDrops())
+}
+
+func TestNATSToken(t *testing.T) {
+	assert.Equal(t, "example_com", natsToken("example.com"))
+	assert.Equal(t, "a_b_c_", natsToken("a b*c>"))
+	assert.Equal(t, "none", natsToken(""))
+}
@@ -0,0 +1,270 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+	"github.com/kinjelom/watchdog_exporter/prober"
+)
+
+// OTLPExporter sends the gathered series to an OpenTelemetry collector using OTLP/HTTP with JSON encoding.
+// Like RemoteWriter, results only mark the state dirty and are sent in batches.
+type OTLPExporter struct {
+	cfg      config.OTLPConfig
+	gatherer prometheus.Gatherer
+	client   *http.Client
+	dirty    atomic.Bool
+
+	scopeName    string
+	scopeVersion string
+	startTime    time.Time
+}
+
+func NewOTLPExporter(cfg config.OTLPConfig, gatherer prometheus.Gatherer, programName, programVersion string) *OTLPExporter {
+	return &OTLPExporter{
+		cfg:          cfg,
+		gatherer:     gatherer,
+		client:       &http.Client{Timeout: cfg.Timeout},
+		scopeName:    programName,
+		scopeVersion: programVersion,
+		startTime:    time.Now(),
+	}
+}
+
+func (o *OTLPExporter) OnResult(prober.Result) {
+	o.dirty.Store(true)
+}
+
+// Run flushes pending changes every flush-interval until ctx is done.
+func (o *OTLPExporter) Run(ctx context.Context) {
+	flushLoop(ctx, "otlp", o.cfg.FlushInterval, &o.dirty, o.Flush)
+}
+
+// Flush gathers all series and exports them in one ExportMetricsServiceRequest.
+func (o *OTLPExporter) Flush(ctx context.Context) error {
+	mfs, err := o.gatherer.Gather()
+	if err != nil {
+		return fmt.Errorf("gather: %w", err)
+	}
+	body, err := json.Marshal(o.buildRequest(mfs, time.Now()))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.cfg.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range o.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func(Body io.ReadCloser) { _ = Body.Close() }(resp.Body)
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// OTLP JSON model (subset of opentelemetry-proto metrics/v1 used here).
+// 64-bit integers are encoded as strings, as required by the OTLP JSON mapping.
+
+type otlpRequest struct {
+	ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+}
+
+type otlpResourceMetrics struct {
+	Resource     otlpResource       `json:"resource"`
+	ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeMetrics struct {
+	Scope   otlpScope    `json:"scope"`
+	Metrics []otlpMetric `json:"metrics"`
+}
+
+type otlpScope struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type otlpMetric struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Gauge       *otlpGauge     `json:"gauge,omitempty"`
+	Sum         *otlpSum       `json:"sum,omitempty"`
+	Summary     *otlpSummary   `json:"summary,omitempty"`
+	Histogram   *otlpHistogram `json:"histogram,omitempty"`
+}
+
+type otlpGauge struct {
+	DataPoints []otlpNumberPoint `json:"dataPoints"`
+}
+
+type otlpSum struct {
+	DataPoints             []otlpNumberPoint `json:"dataPoints"`
+	AggregationTemporality int               `json:"aggregationTemporality"`
+	IsMonotonic            bool              `json:"isMonotonic"`
+}
+
+type otlpSummary struct {
+	DataPoints []otlpSummaryPoint `json:"dataPoints"`
+}
+
+type otlpHistogram struct {
+	DataPoints             []otlpHistogramPoint `json:"dataPoints"`
+	AggregationTemporality int                  `json:"aggregationTemporality"`
+}
+
+type otlpNumberPoint struct {
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano,omitempty"`
+	TimeUnixNano      string         `json:"timeUnixNano"`
+	AsDouble          float64        `json:"asDouble"`
+}
+
+type otlpSummaryPoint struct {
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	TimeUnixNano      string         `json:"timeUnixNano"`
+	Count             string         `json:"count"`
+	Sum               float64        `json:"sum"`
+	QuantileValues    []otlpQuantile `json:"quantileValues,omitempty"`
+}
+
+type otlpQuantile struct {
+	Quantile float64 `json:"quantile"`
+	Value    float64 `json:"value"`
+}
+
+type otlpHistogramPoint struct {
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	TimeUnixNano      string         `json:"timeUnixNano"`
+	Count             string         `json:"count"`
+	Sum               float64        `json:"sum"`
+	BucketCounts      []string       `json:"bucketCounts,omitempty"`
+	ExplicitBounds    []float64      `json:"explicitBounds,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+// aggregationTemporalityCumulative is AGGREGATION_TEMPORALITY_CUMULATIVE.
+const aggregationTemporalityCumulative = 2
+
+func (o *OTLPExporter) buildRequest(mfs []*dto.MetricFamily, now time.Time) otlpRequest {
+	nowNano := strconv.FormatInt(now.UnixNano(), 10)
+	startNano := strconv.FormatInt(o.startTime.UnixNano(), 10)
+
+	metrics := make([]otlpMetric, 0, len(mfs))
+	for _, mf := range mfs {
+		om := otlpMetric{Name: mf.GetName(), Description: mf.GetHelp()}
+		switch mf.GetType() {
+		case dto.MetricType_GAUGE, dto.MetricType_UNTYPED:
+			om.Gauge = &otlpGauge{}
+			for _, m := range mf.GetMetric() {
+				v := m.GetGauge().GetValue()
+				if mf.GetType() == dto.MetricType_UNTYPED {
+					v = m.GetUntyped().GetValue()
+				}
+				om.Gauge.DataPoints = append(om.Gauge.DataPoints, otlpNumberPoint{
+					Attributes: attributesOf(m), TimeUnixNano: nowNano, AsDouble: v,
+				})
+			}
+		case dto.MetricType_COUNTER:
+			om.Sum = &otlpSum{AggregationTemporality: aggregationTemporalityCumulative, IsMonotonic: true}
+			for _, m := range mf.GetMetric() {
+				om.Sum.DataPoints = append(om.Sum.DataPoints, otlpNumberPoint{
+					Attributes: attributesOf(m), StartTimeUnixNano: startNano, TimeUnixNano: nowNano, AsDouble: m.GetCounter().GetValue(),
+				})
+			}
+		case dto.MetricType_SUMMARY:
+			om.Summary = &otlpSummary{}
+			for _, m := range mf.GetMetric() {
+				s := m.GetSummary()
+				p := otlpSummaryPoint{
+					Attributes: attributesOf(m), StartTimeUnixNano: startNano, TimeUnixNano: nowNano,
+					Count: strconv.FormatUint(s.GetSampleCount(), 10), Sum: s.GetSampleSum(),
+				}
+				for _, q := range s.GetQuantile() {
+					p.QuantileValues = append(p.QuantileValues, otlpQuantile{Quantile: q.GetQuantile(), Value: q.GetValue()})
+				}
+				om.Summary.DataPoints = append(om.Summary.DataPoints, p)
+			}
+		case dto.MetricType_HISTOGRAM:
+			om.Histogram = &otlpHistogram{AggregationTemporality: aggregationTemporalityCumulative}
+			for _, m := range mf.GetMetric() {
+				h := m.GetHistogram()
+				p := otlpHistogramPoint{
+					Attributes: attributesOf(m), StartTimeUnixNano: startNano, TimeUnixNano: nowNano,
+					Count: strconv.FormatUint(h.GetSampleCount(), 10), Sum: h.GetSampleSum(),
+				}
+				// Prometheus buckets are cumulative; OTLP expects per-bucket counts plus an overflow bucket.
+				if len(h.GetBucket()) > 0 {
+					var prev uint64
+					for _, b := range h.GetBucket() {
+						p.ExplicitBounds = append(p.ExplicitBounds, b.GetUpperBound())
+						p.BucketCounts = append(p.BucketCounts, strconv.FormatUint(b.GetCumulativeCount()-prev, 10))
+						prev = b.GetCumulativeCount()
+					}
+					p.BucketCounts = append(p.BucketCounts, strconv.FormatUint(h.GetSampleCount()-prev, 10))
+				}
+				om.Histogram.DataPoints = append(om.Histogram.DataPoints, p)
+			}
+		default:
+			continue
+		}
+		metrics = append(metrics, om)
+	}
+
+	resAttrs := []otlpKeyValue{{Key: "service.name", Value: otlpAnyValue{StringValue: o.scopeName}}}
+	for k, v := range o.cfg.ResourceAttributes {
+		resAttrs = append(resAttrs, otlpKeyValue{Key: k, Value: otlpAnyValue{StringValue: v}})
+	}
+	sort.Slice(resAttrs[1:], func(i, j int) bool { return resAttrs[i+1].Key < resAttrs[j+1].Key })
+
+	return otlpRequest{ResourceMetrics: []otlpResourceMetrics{{
+		Resource: otlpResource{Attributes: resAttrs},
+		ScopeMetrics: []otlpScopeMetrics{{
+			Scope:   otlpScope{Name: o.scopeName, Version: o.scopeVersion},
+			Metrics: metrics,
+		}},
+	}}}
+}
+
+func attributesOf(m *dto.Metric) []otlpKeyValue {
+	out := make([]otlpKeyValue, 0, len(m.GetLabel()))
+	for _, lp := range m.GetLabel() {
+		out = append(out, otlpKeyValue{Key: lp.GetName(), Value: otlpAnyValue{StringValue: lp.GetValue()}})
+	}
+	return out
+}
@@ -0,0 +1,71 @@
+package push
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+)
+
+func TestOTLPExporter_FlushSendsJSON(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	g := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "wd_endpoint_validation", Help: "validation"}, []string{"endpoint"})
+	c := prometheus.NewCounter(prometheus.CounterOpts{Name: "wd_transitions_total", Help: "transitions"})
+	h := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "wd_duration_seconds", Help: "duration", Buckets: []float64{0.1, 1}})
+	reg.MustRegister(g, c, h)
+	g.WithLabelValues("ep1").Set(1)
+	c.Add(3)
+	h.Observe(0.05)
+	h.Observe(0.5)
+	h.Observe(5)
+
+	var got otlpRequest
+	var contentType string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		_ = json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+
+	o := NewOTLPExporter(config.OTLPConfig{
+		Endpoint:           srv.URL,
+		Timeout:            time.Second,
+		ResourceAttributes: map[string]string{"deployment.environment": "dev"},
+	}, reg, "watchdog_exporter", "1.0.0")
+	assert.NoError(t, o.Flush(context.Background()))
+	assert.Equal(t, "application/json", contentType)
+
+	if !assert.Len(t, got.ResourceMetrics, 1) {
+		return
+	}
+	rm := got.ResourceMetrics[0]
+	assert.Equal(t, "service.name", rm.Resource.Attributes[0].Key)
+	assert.Equal(t, "watchdog_exporter", rm.Resource.Attributes[0].Value.StringValue)
+	assert.Equal(t, "deployment.environment", rm.Resource.Attributes[1].Key)
+
+	byName := map[string]otlpMetric{}
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		byName[m.Name] = m
+	}
+	if gauge := byName["wd_endpoint_validation"].Gauge; assert.NotNil(t, gauge) {
+		assert.Equal(t, 1.0, gauge.DataPoints[0].AsDouble)
+		assert.Equal(t, "ep1", gauge.DataPoints[0].Attributes[0].Value.StringValue)
+	}
+	if sum := byName["wd_transitions_total"].Sum; assert.NotNil(t, sum) {
+		assert.True(t, sum.IsMonotonic)
+		assert.Equal(t, 3.0, sum.DataPoints[0].AsDouble)
+	}
+	if hist := byName["wd_duration_seconds"].Histogram; assert.NotNil(t, hist) {
+		p := hist.DataPoints[0]
+		assert.Equal(t, "3", p.Count)
+		assert.Equal(t, []float64{0.1, 1}, p.ExplicitBounds)
+		assert.Equal(t, []string{"1", "1", "1"}, p.BucketCounts)
+	}
+}
@@ -0,0 +1,134 @@
+// Package push contains outputs that send probe results to external systems
+// instead of (or in addition to) being scraped.
+package push
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/kinjelom/watchdog_exporter/api"
+)
+
+// flushLoop calls flush every interval while dirty is set, until ctx is done.
+func flushLoop(ctx context.Context, name string, interval time.Duration, dirty *atomic.Bool, flush func(context.Context) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !dirty.Swap(false) {
+				continue
+			}
+			if err := flush(ctx); err != nil {
+				slog.Warn("push flush failed", "output", name, "err", err)
+			}
+		}
+	}
+}
+
+// Reconnect backoff of the streaming outputs (MQTT, NATS): doubled after every failed delivery, up to the maximum.
+const (
+	streamMinBackoff = time.Second
+	streamMaxBackoff = time.Minute
+)
+
+// errUnencodable marks a result that no retry can deliver (e.g. its topic template fails); it is dropped.
+var errUnencodable = errors.New("cannot encode result")
+
+// streamConn is a connection of a streaming output.
+type streamConn interface {
+	ping() error
+	close()
+}
+
+// streamLoop publishes queued results over one connection until ctx is done. It connects when there is something
+// to send, pings the connection every pingInterval and drops it on errors. A result that could not be delivered is
+// kept and retried after a capped backoff; meanwhile new results wait in the queue.
+func streamLoop[C streamConn](ctx context.Context, name string, queue <-chan api.ResultView, drops *queueDrops,
+	pingInterval time.Duration, connect func(context.Context) (C, error), publish func(C, api.ResultView) error) {
+	var (
+		conn      C
+		connected bool
+		pending   *api.ResultView
+		backoff   time.Duration
+	)
+	disconnect := func() {
+		if connected {
+			conn.close()
+			connected = false
+		}
+	}
+	defer disconnect()
+	ping := time.NewTicker(pingInterval)
+	defer ping.Stop()
+	retry := time.NewTimer(0)
+	defer retry.Stop()
+	<-retry.C
+
+	for {
+		next, retryC := queue, (<-chan time.Time)(nil)
+		if pending != nil {
+			next, retryC = nil, retry.C
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ping.C:
+			if !connected {
+				continue
+			}
+			if err := conn.ping(); err != nil {
+				slog.Warn(name+" ping failed", "err", err)
+				disconnect()
+			}
+			continue
+		case v := <-next:
+			pending = &v
+		case <-retryC:
+		}
+
+		var err error
+		if !connected {
+			if conn, err = connect(ctx); err != nil {
+				slog.Warn(name+" connect failed", "err", err, "retry_in", nextBackoff(backoff))
+			}
+			connected = err == nil
+		}
+		if connected {
+			if err = publish(conn, *pending); errors.Is(err, errUnencodable) {
+				drops.n.Add(1)
+				slog.Warn(name+" result dropped", "endpoint", pending.Endpoint, "route", pending.Route, "err", err)
+				pending, err = nil, nil
+			} else if err != nil {
+				slog.Warn(name+" publish failed", "err", err, "retry_in", nextBackoff(backoff))
+				disconnect()
+			}
+		}
+		if err != nil {
+			backoff = nextBackoff(backoff)
+			retry.Reset(backoff)
+			continue
+		}
+		pending, backoff = nil, 0
+	}
+}
+
+// nextBackoff doubles the reconnect backoff, within streamMinBackoff and streamMaxBackoff.
+func nextBackoff(d time.Duration) time.Duration {
+	return min(max(2*d, streamMinBackoff), streamMaxBackoff)
+}
+
+// queueDrops counts the results an output dropped because its queue was full (or they could not be encoded).
+type queueDrops struct {
+	n atomic.Uint64
+}
+
+// QueueDrops returns the number of results dropped so far.
+func (d *queueDrops) QueueDrops() uint64 {
+	return d.n.Load()
+}
@@ -0,0 +1,175 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+	"github.com/kinjelom/watchdog_exporter/prober"
+)
+
+// RemoteWriter pushes the gathered series to a Prometheus remote_write endpoint.
+// Probe results only mark the state dirty; series are sent in batches every flush-interval.
+type RemoteWriter struct {
+	cfg      config.RemoteWriteConfig
+	gatherer prometheus.Gatherer
+	client   *http.Client
+	dirty    atomic.Bool
+}
+
+func NewRemoteWriter(cfg config.RemoteWriteConfig, gatherer prometheus.Gatherer) *RemoteWriter {
+	return &RemoteWriter{
+		cfg:      cfg,
+		gatherer: gatherer,
+		client:   &http.Client{Timeout: cfg.Timeout},
+	}
+}
+
+func (w *RemoteWriter) OnResult(prober.Result) {
+	w.dirty.Store(true)
+}
+
+// Run flushes pending changes every flush-interval until ctx is done.
+func (w *RemoteWriter) Run(ctx context.Context) {
+	flushLoop(ctx, "remote-write", w.cfg.FlushInterval, &w.dirty, w.Flush)
+}
+
+// Flush gathers all series and sends them in one write request.
+func (w *RemoteWriter) Flush(ctx context.Context) error {
+	mfs, err := w.gatherer.Gather()
+	if err != nil {
+		return fmt.Errorf("gather: %w", err)
+	}
+	body := snappy.Encode(nil, encodeWriteRequest(mfs, time.Now()))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	for k, v := range w.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func(Body io.ReadCloser) { _ = Body.Close() }(resp.Body)
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// Remote write protobuf (prometheus.WriteRequest) field numbers.
+const (
+	fieldWriteRequestTimeseries = 1
+	fieldTimeSeriesLabels       = 1
+	fieldTimeSeriesSamples      = 2
+	fieldLabelName              = 1
+	fieldLabelValue             = 2
+	fieldSampleValue            = 1
+	fieldSampleTimestamp        = 2
+)
+
+type label struct{ name, value string }
+
+// encodeWriteRequest converts metric families into a serialized WriteRequest.
+// Summaries and histograms are flattened the same way Prometheus does on scrape.
+func encodeWriteRequest(mfs []*dto.MetricFamily, now time.Time) []byte {
+	var buf []byte
+	nowMs := now.UnixMilli()
+	for _, mf := range mfs {
+		name := mf.GetName()
+		for _, m := range mf.GetMetric() {
+			ts := nowMs
+			if m.TimestampMs != nil {
+				ts = m.GetTimestampMs()
+			}
+			base := make([]label, 0, len(m.GetLabel())+2)
+			for _, lp := range m.GetLabel() {
+				base = append(base, label{lp.GetName(), lp.GetValue()})
+			}
+			add := func(suffix string, v float64, extra ...label) {
+				lbls := append(append([]label{{"__name__", name + suffix}}, base...), extra...)
+				buf = protowire.AppendTag(buf, fieldWriteRequestTimeseries, protowire.BytesType)
+				buf = protowire.AppendBytes(buf, encodeTimeSeries(lbls, v, ts))
+			}
+
+			switch mf.GetType() {
+			case dto.MetricType_COUNTER:
+				add("", m.GetCounter().GetValue())
+			case dto.MetricType_GAUGE:
+				add("", m.GetGauge().GetValue())
+			case dto.MetricType_UNTYPED:
+				add("", m.GetUntyped().GetValue())
+			case dto.MetricType_SUMMARY:
+				s := m.GetSummary()
+				for _, q := range s.GetQuantile() {
+					add("", q.GetValue(), label{"quantile", formatFloat(q.GetQuantile())})
+				}
+				add("_sum", s.GetSampleSum())
+				add("_count", float64(s.GetSampleCount()))
+			case dto.MetricType_HISTOGRAM, dto.MetricType_GAUGE_HISTOGRAM:
+				h := m.GetHistogram()
+				for _, b := range h.GetBucket() {
+					add("_bucket", float64(b.GetCumulativeCount()), label{"le", formatFloat(b.GetUpperBound())})
+				}
+				if len(h.GetBucket()) > 0 {
+					add("_bucket", float64(h.GetSampleCount()), label{"le", "+Inf"})
+				}
+				add("_sum", h.GetSampleSum())
+				add("_count", float64(h.GetSampleCount()))
+			}
+		}
+	}
+	return buf
+}
+
+func encodeTimeSeries(lbls []label, v float64, ts int64) []byte {
+	// Remote write requires labels sorted by name.
+	sort.Slice(lbls, func(i, j int) bool { return lbls[i].name < lbls[j].name })
+
+	var b []byte
+	for _, l := range lbls {
+		var lb []byte
+		lb = protowire.AppendTag(lb, fieldLabelName, protowire.BytesType)
+		lb = protowire.AppendString(lb, l.name)
+		lb = protowire.AppendTag(lb, fieldLabelValue, protowire.BytesType)
+		lb = protowire.AppendString(lb, l.value)
+		b = protowire.AppendTag(b, fieldTimeSeriesLabels, protowire.BytesType)
+		b = protowire.AppendBytes(b, lb)
+	}
+	var sb []byte
+	sb = protowire.AppendTag(sb, fieldSampleValue, protowire.Fixed64Type)
+	sb = protowire.AppendFixed64(sb, math.Float64bits(v))
+	sb = protowire.AppendTag(sb, fieldSampleTimestamp, protowire.VarintType)
+	sb = protowire.AppendVarint(sb, uint64(ts))
+	b = protowire.AppendTag(b, fieldTimeSeriesSamples, protowire.BytesType)
+	b = protowire.AppendBytes(b, sb)
+	return b
+}
+
+func formatFloat(f float64) string {
+	if math.IsInf(f, +1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,84 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+	"github.com/kinjelom/watchdog_exporter/prober"
+)
+
+func TestRemoteWriter_FlushSendsSnappyProtobuf(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	g := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "wd_endpoint_validation"}, []string{"endpoint"})
+	reg.MustRegister(g)
+	g.WithLabelValues("ep1").Set(1)
+
+	var got []byte
+	var headers http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header.Clone()
+		compressed, _ := io.ReadAll(r.Body)
+		got, _ = snappy.Decode(nil, compressed)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	w := NewRemoteWriter(config.RemoteWriteConfig{
+		URL:     srv.URL,
+		Timeout: time.Second,
+		Headers: map[string]string{"X-Scope-OrgID": "tenant-1"},
+	}, reg)
+	assert.NoError(t, w.Flush(context.Background()))
+
+	assert.Equal(t, "snappy", headers.Get("Content-Encoding"))
+	assert.Equal(t, "application/x-protobuf", headers.Get("Content-Type"))
+	assert.Equal(t, "tenant-1", headers.Get("X-Scope-OrgID"))
+	assert.True(t, bytes.Contains(got, []byte("wd_endpoint_validation")), "metric name encoded")
+	assert.True(t, bytes.Contains(got, []byte("ep1")), "label value encoded")
+}
+
+func TestRemoteWriter_FlushReportsHTTPErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "out of order sample", http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	w := NewRemoteWriter(config.RemoteWriteConfig{URL: srv.URL, Timeout: time.Second}, prometheus.NewRegistry())
+	err := w.Flush(context.Background())
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "out of order sample")
+	}
+}
+
+func TestRemoteWriter_RunFlushesOnlyWhenDirty(t *testing.T) {
+	calls := make(chan struct{}, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls <- struct{}{}
+	}))
+	defer srv.Close()
+
+	w := NewRemoteWriter(config.RemoteWriteConfig{URL: srv.URL, Timeout: time.Second, FlushInterval: 10 * time.Millisecond}, prometheus.NewRegistry())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.Run(ctx)
+
+	time.Sleep(50 * time.Millisecond)
+	assert.Len(t, calls, 0, "nothing to flush without results")
+
+	w.OnResult(prober.Result{})
+	select {
+	case <-calls:
+	case <-time.After(time.Second):
+		t.Fatal("expected a flush after a result")
+	}
+}
@@ -0,0 +1,105 @@
+package push
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+	"github.com/kinjelom/watchdog_exporter/prober"
+)
+
+// StatsD flavors.
+const (
+	StatsDFlavorStatsD    = "statsd"
+	StatsDFlavorDogStatsD = "dogstatsd"
+)
+
+// StatsDSink emits every probe result as StatsD (or DogStatsD with tags) over UDP.
+// All lines of a result go out in one datagram, so OnResult never blocks on the network.
+type StatsDSink struct {
+	cfg  config.StatsDConfig
+	conn net.Conn
+}
+
+func NewStatsDSink(cfg config.StatsDConfig) (*StatsDSink, error) {
+	conn, err := net.Dial("udp", cfg.Address)
+	if err != nil {
+		return nil, err
+	}
+	return &StatsDSink{cfg: cfg, conn: conn}, nil
+}
+
+func (s *StatsDSink) Close() error {
+	return s.conn.Close()
+}
+
+func (s *StatsDSink) OnResult(r prober.Result) {
+	_, _ = s.conn.Write([]byte(s.format(r)))
+}
+
+// format renders the lines for one result:
+// endpoint.up (gauge 1/0), endpoint.duration (timer, ms) and endpoint.probes (counter, tagged with status).
+func (s *StatsDSink) format(r prober.Result) string {
+	up := 1
+	if r.Failed() {
+		up = 0
+	}
+	durationMs := r.Duration * 1000
+
+	if s.cfg.Flavor == StatsDFlavorDogStatsD {
+		tags := s.tags(r)
+		return fmt.Sprintf("%sendpoint.up:%d|g|#%s\n%sendpoint.duration:%g|ms|#%s\n%sendpoint.probes:1|c|#%s,status:%s",
+			s.cfg.Prefix, up, tags,
+			s.cfg.Prefix, durationMs, tags,
+			s.cfg.Prefix, tags, sanitizeTagValue(string(r.Status)))
+	}
+
+	// Plain StatsD has no tags: the endpoint identity becomes part of the name.
+	name := s.cfg.Prefix + strings.Join([]string{
+		sanitizeMetricPart(r.Group), sanitizeMetricPart(r.Endpoint), sanitizeMetricPart(r.Route),
+	}, ".")
+	return fmt.Sprintf("%s.up:%d|g\n%s.duration:%g|ms\n%s.probes.%s:1|c",
+		name, up, name, durationMs, name, sanitizeMetricPart(string(r.Status)))
+}
+
+func (s *StatsDSink) tags(r prober.Result) string {
+	tags := []string{
+		"group:" + sanitizeTagValue(r.Group),
+		"endpoint:" + sanitizeTagValue(r.Endpoint),
+		"route:" + sanitizeTagValue(r.Route),
+		"protocol:" + sanitizeTagValue(r.Protocol),
+	}
+	constant := make([]string, 0, len(s.cfg.Tags))
+	for k, v := range s.cfg.Tags {
+		constant = append(constant, sanitizeTagValue(k)+":"+sanitizeTagValue(v))
+	}
+	sort.Strings(constant)
+	return strings.Join(append(tags, constant...), ",")
+}
+
+// sanitizeMetricPart makes a value safe as one dot-separated metric name segment (StatsD, Graphite).
+func sanitizeMetricPart(v string) string {
+	return sanitizeMetricPartWith(v, '_')
+}
+
+// sanitizeMetricPartWith replaces every character outside [A-Za-z0-9_-] with repl.
+func sanitizeMetricPartWith(v string, repl rune) string {
+	if v == "" {
+		return "none"
+	}
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		default:
+			return repl
+		}
+	}, v)
+}
+
+// sanitizeTagValue strips characters with a meaning in the DogStatsD line format.
+func sanitizeTagValue(v string) string {
+	return strings.NewReplacer("|", "_", ",", "_", "#", "_", "\n", "_", ":", "_").Replace(v)
+}
@@ -0,0 +1,53 @@
+package push
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+	"github.com/kinjelom/watchdog_exporter/prober"
+)
+
+func TestStatsDSink_DogStatsDFormat(t *testing.T) {
+	s := &StatsDSink{cfg: config.StatsDConfig{Prefix: "wd.", Flavor: StatsDFlavorDogStatsD, Tags: map[string]string{"env": "dev"}}}
+	got := s.format(prober.Result{Group: "g1", Endpoint: "example.com", Route: "direct", Protocol: "http", Status: "valid", Duration: 0.25})
+
+	assert.Equal(t, strings.Join([]string{
+		"wd.endpoint.up:1|g|#group:g1,endpoint:example.com,route:direct,protocol:http,env:dev",
+		"wd.endpoint.duration:250|ms|#group:g1,endpoint:example.com,route:direct,protocol:http,env:dev",
+		"wd.endpoint.probes:1|c|#group:g1,endpoint:example.com,route:direct,protocol:http,env:dev,status:valid",
+	}, "\n"), got)
+}
+
+func TestStatsDSink_PlainStatsDFormat(t *testing.T) {
+	s := &StatsDSink{cfg: config.StatsDConfig{Prefix: "wd.", Flavor: StatsDFlavorStatsD}}
+	got := s.format(prober.Result{Group: "g1", Endpoint: "example.com", Route: "direct", Status: "request-execution-timeout", Duration: 1})
+
+	assert.Equal(t, strings.Join([]string{
+		"wd.g1.example_com.direct.up:0|g",
+		"wd.g1.example_com.direct.duration:1000|ms",
+		"wd.g1.example_com.direct.probes.request-execution-timeout:1|c",
+	}, "\n"), got)
+}
+
+func TestStatsDSink_SendsUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer func() { _ = pc.Close() }()
+
+	s, err := NewStatsDSink(config.StatsDConfig{Address: pc.LocalAddr().String(), Flavor: StatsDFlavorDogStatsD})
+	assert.NoError(t, err)
+	defer func() { _ = s.Close() }()
+
+	s.OnResult(prober.Result{Group: "g", Endpoint: "ep", Route: "r", Status: "valid"})
+
+	buf := make([]byte, 1024)
+	_ = pc.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := pc.ReadFrom(buf)
+	assert.NoError(t, err)
+	assert.Contains(t, string(buf[:n]), "endpoint.up:1|g|#group:g,endpoint:ep")
+}
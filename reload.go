@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+	"github.com/kinjelom/watchdog_exporter/metrics"
+	"github.com/kinjelom/watchdog_exporter/prober"
+	"github.com/kinjelom/watchdog_exporter/push"
+	"github.com/kinjelom/watchdog_exporter/validator"
+)
+
+// reloader re-reads the config file and applies it to the running engine (endpoints, routes, intervals, debug).
+// Options that shape listeners, metric labels or outputs are reported and keep their values until a restart.
+type reloader struct {
+	path      string
+	engine    *prober.Engine
+	metrics   *metrics.WDMetrics
+	self      *metrics.SelfMetrics
+	debug     *validator.DebugSwitch
+	heartbeat *push.Heartbeat // nil without a heartbeat
+
+	started *config.WatchDogConfig // restart-only options are compared with the config the process runs on
+
+	mu  sync.Mutex
+	cfg *config.WatchDogConfig
+}
+
+// Reload validates and applies the config file; on error the running config is kept.
+func (r *reloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	err := r.apply()
+	if err != nil {
+		slog.Error("configuration reload failed", "err", err)
+	}
+	return err
+}
+
+// apply does the reload; callers hold r.mu.
+func (r *reloader) apply() error {
+	next, err := config.LoadConfig(r.path)
+	if err == nil {
+		err = next.Validate()
+	}
+	if err != nil {
+		r.self.SetConfigLoaded(false, "", time.Now())
+		return fmt.Errorf("cannot load --config=%s: %w", r.path, err)
+	}
+	pending := r.started.RestartRequired(next)
+	for _, name := range pending {
+		if name == "endpoints.probe-all-ips" || name == "endpoints.labels (names)" {
+			// The ip and endpoint labels are part of the registered metric schema.
+			r.self.SetConfigLoaded(false, "", time.Now())
+			return fmt.Errorf("cannot reload --config=%s: %w: %s changes the metric labels", r.path, config.ErrRestartRequired, name)
+		}
+	}
+	if next.Hash == r.cfg.Hash {
+		r.self.SetConfigLoaded(true, next.Hash, time.Now())
+		slog.Info("configuration unchanged", "hash", next.Hash)
+		return nil
+	}
+
+	r.engine.Reload(next)
+	r.debug.SetConfig(next)
+	if r.heartbeat != nil {
+		r.heartbeat.SetEndpoints(next.ActiveEndpoints())
+	}
+	r.metrics.RebuildAll()
+	r.self.SetConfigLoaded(true, next.Hash, time.Now())
+	next.LogWarnings()
+	if len(pending) > 0 {
+		slog.Warn("configuration changes need a restart to take effect", "options", pending)
+	}
+	r.cfg = next
+	return nil
+}
+
+// watchSIGHUP reloads the config on every SIGHUP until ctx is done.
+func (r *reloader) watchSIGHUP(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			slog.Info("SIGHUP received, reloading configuration", "config", r.path)
+			_ = r.Reload() // logged
+		}
+	}
+}
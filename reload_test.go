@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+	"github.com/kinjelom/watchdog_exporter/metrics"
+	"github.com/kinjelom/watchdog_exporter/prober"
+)
+
+const reloadBaseConfig = `
+routes:
+  direct: {}
+endpoints:
+  shop:
+    routes: [direct]
+    request: { url: "https://shop.example.com" }
+    labels: { team: payments }
+`
+
+// newTestReloader loads the config written to a temp file and wires a reloader as main does, on a private registry.
+func newTestReloader(t *testing.T, content string) *reloader {
+	t.Helper()
+	reg := prometheus.NewRegistry()
+	defaultRegisterer := prometheus.DefaultRegisterer
+	prometheus.DefaultRegisterer = reg
+	t.Cleanup(func() { prometheus.DefaultRegisterer = defaultRegisterer })
+
+	path := filepath.Join(t.TempDir(), "config.yml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.LoadConfig(path)
+	if err == nil {
+		err = cfg.Validate()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	wdv, debugSwitch, err := newValidator(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine := prober.NewEngine(cfg, wdv)
+	wdm := metrics.NewWDMetrics(metrics.BuildInfo{ProgramName: ProgramName}, cfg, engine.Provider())
+	self := metrics.NewSelfMetrics(cfg, engine)
+	return &reloader{path: path, engine: engine, metrics: wdm, self: self, debug: debugSwitch, started: cfg, cfg: cfg}
+}
+
+func (r *reloader) rewrite(t *testing.T, content string) {
+	t.Helper()
+	if err := os.WriteFile(r.path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReloader_AppliesChanges(t *testing.T) {
+	r := newTestReloader(t, reloadBaseConfig)
+	started := r.cfg
+
+	assert.NoError(t, r.Reload(), "an unchanged file reloads")
+	assert.Same(t, started, r.cfg, "an unchanged hash keeps the running config")
+
+	r.rewrite(t, reloadBaseConfig+`
+  cart:
+    routes: [direct]
+    request: { url: "https://cart.example.com" }
+`)
+	assert.NoError(t, r.Reload())
+	assert.Contains(t, r.cfg.Endpoints, "cart")
+	assert.Same(t, r.cfg, r.engine.Config(), "/probe reads the config through the engine")
+	assert.NotEqual(t, started.Hash, r.cfg.Hash)
+
+	// changed label values are applied, only the names are part of the metric schema
+	r.rewrite(t, strings.Replace(reloadBaseConfig, "team: payments", "team: checkout", 1))
+	assert.NoError(t, r.Reload())
+	assert.Equal(t, "checkout", r.cfg.Endpoints["shop"].Labels["team"])
+	assert.NotContains(t, r.cfg.Endpoints, "cart")
+}
+
+func TestReloader_RejectsChanges(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr error
+		want    string
+	}{
+		{
+			name:    "invalid config",
+			content: strings.Replace(reloadBaseConfig, "routes: [direct]", "routes: [missing]", 1),
+			wantErr: config.ErrInvalidConfig,
+			want:    `route "missing" is not defined`,
+		},
+		{
+			name:    "unreadable yaml",
+			content: "endpoints: [",
+			want:    "cannot load --config=",
+		},
+		{
+			name:    "probe-all-ips",
+			content: strings.Replace(reloadBaseConfig, "routes: [direct]", "routes: [direct]\n    probe-all-ips: true", 1),
+			wantErr: config.ErrRestartRequired,
+			want:    "endpoints.probe-all-ips changes the metric labels",
+		},
+		{
+			name:    "label names",
+			content: strings.Replace(reloadBaseConfig, "team: payments", "team: payments, tier: critical", 1),
+			wantErr: config.ErrRestartRequired,
+			want:    "endpoints.labels (names) changes the metric labels",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestReloader(t, reloadBaseConfig)
+			started := r.cfg
+			r.rewrite(t, tt.content)
+
+			err := r.Reload()
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tt.want)
+			}
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			}
+			assert.Same(t, started, r.cfg, "the running config stays active")
+		})
+	}
+}
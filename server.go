@@ -0,0 +1,105 @@
+package main
+
+import (
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/prometheus/exporter-toolkit/web"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+)
+
+const unixScheme = "unix://"
+
+// listen opens a TCP listener, or a unix socket for "unix:///path/to.sock" (a stale socket file is replaced).
+func listen(address string) (net.Listener, error) {
+	if path, ok := strings.CutPrefix(address, unixScheme); ok {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		return net.Listen("unix", path)
+	}
+	return net.Listen("tcp", address)
+}
+
+// newServer applies settings.server to an http.Server for handler.
+func newServer(s config.ServerSettings, name string, handler http.Handler) *http.Server {
+	if s.AccessLog {
+		handler = accessLog(name, handler)
+	}
+	return &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: s.ReadHeaderTimeout,
+		ReadTimeout:       s.ReadTimeout,
+		WriteTimeout:      s.WriteTimeout,
+		IdleTimeout:       s.IdleTimeout,
+		MaxHeaderBytes:    s.MaxHeaderBytes,
+	}
+}
+
+// serveAdmin serves the ops endpoints on settings.admin-listen-address, with the same web config as /metrics.
+func serveAdmin(s config.ProgramSettings, handler http.Handler, webConfigFile *string) error {
+	l, err := listen(s.AdminListenAddress)
+	if err != nil {
+		return err
+	}
+	logger := slog.Default().With("listener", "admin")
+	return web.Serve(l, newServer(s.Server, "admin", handler), &web.FlagConfig{WebConfigFile: webConfigFile}, logger)
+}
+
+// statusRecorder captures what the handler wrote, for the access log.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += n
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer (flush, deadlines).
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+func accessLog(listener string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, req)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		slog.Info("http request", "listener", listener, "method", req.Method, "path", req.URL.Path,
+			"status", rec.status, "bytes", rec.bytes, "duration", time.Since(start), "remote_addr", req.RemoteAddr,
+			"user_agent", req.UserAgent())
+	})
+}
+
+// pprofHandler mounts net/http/pprof on a dedicated mux (the package's init registers on http.DefaultServeMux,
+// which the exporter doesn't serve).
+func pprofHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return mux
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+)
+
+func TestListen(t *testing.T) {
+	l, err := listen("127.0.0.1:0")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "tcp", l.Addr().Network())
+		_ = l.Close()
+	}
+
+	path := filepath.Join(t.TempDir(), "wd.sock")
+	// a stale socket file left by a crashed process is replaced
+	assert.NoError(t, os.WriteFile(path, nil, 0o600))
+	l, err = listen("unix://" + path)
+	if assert.NoError(t, err) {
+		defer func() { _ = l.Close() }()
+		assert.Equal(t, "unix", l.Addr().Network())
+		assert.Equal(t, path, l.Addr().String())
+		conn, dErr := net.Dial("unix", path)
+		if assert.NoError(t, dErr) {
+			_ = conn.Close()
+		}
+	}
+
+	_, err = listen("unix://" + filepath.Join(t.TempDir(), "missing", "wd.sock"))
+	assert.Error(t, err)
+}
+
+func TestNewServer(t *testing.T) {
+	cfg, err := config.Parse([]byte("settings: { server: { read-timeout: 5s, max-header-bytes: 1024 } }\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := http.NotFoundHandler()
+	srv := newServer(cfg.Settings.Server, "metrics", handler)
+	assert.Equal(t, 10*time.Second, srv.ReadHeaderTimeout)
+	assert.Equal(t, 5*time.Second, srv.ReadTimeout)
+	assert.Equal(t, time.Minute, srv.WriteTimeout)
+	assert.Equal(t, 2*time.Minute, srv.IdleTimeout)
+	assert.Equal(t, 1024, srv.MaxHeaderBytes)
+
+	logs := captureLogs(t)
+	srv.Handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Empty(t, logs.String(), "no access log by default")
+	srv = newServer(config.ServerSettings{AccessLog: true}, "metrics", handler)
+	srv.Handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Contains(t, logs.String(), `msg="http request" listener=metrics`)
+}
+
+// captureLogs sends the default logger to the returned buffer until the test ends.
+func captureLogs(t *testing.T) *bytes.Buffer {
+	var logs bytes.Buffer
+	defaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+	t.Cleanup(func() { slog.SetDefault(defaultLogger) })
+	return &logs
+}
+
+func TestAccessLog(t *testing.T) {
+	logs := captureLogs(t)
+	h := accessLog("metrics", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/missing", nil))
+
+	out := logs.String()
+	assert.Contains(t, out, `msg="http request" listener=metrics method=GET path=/metrics status=200 bytes=2`)
+	assert.Contains(t, out, `path=/missing status=404`)
+}
+
+func TestPprofHandler(t *testing.T) {
+	rec := httptest.NewRecorder()
+	pprofHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "goroutine")
+}
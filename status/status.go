@@ -0,0 +1,132 @@
+// Package status defines the probe outcome taxonomy shared by the validator, the prober and every consumer of
+// results (metrics, API, notifiers, push outputs). The string values are a stable contract: they appear as the
+// status label, in JSON and in notification templates.
+package status
+
+// Status is the outcome of a single probe.
+type Status string
+
+const (
+	Valid Status = "valid" // every check passed
+	// ValidInsecureTLS: every check passed, but the server certificate was not verified (tls-insecure-skip-verify).
+	ValidInsecureTLS Status = "valid-insecure-tls"
+	// Maintenance: the probe failed inside a maintenance window with mode probe (planned downtime, not a failure).
+	Maintenance Status = "maintenance"
+
+	// Endpoint or route definition errors (no request was sent).
+	InvalidURL               Status = "invalid-url"
+	InvalidProxyDefinition   Status = "invalid-proxy-definition"
+	InvalidRequestDefinition Status = "invalid-request-definition"
+
+	// Request execution.
+	InvalidRequestExecution Status = "invalid-request-execution" // the request failed (connect, DNS, protocol)
+	RequestExecutionError   Status = "request-execution-error"   // the response body could not be read
+	RequestExecutionTimeout Status = "request-execution-timeout"
+
+	// Response validation.
+	UnexpectedStatusCode    Status = "unexpected-status-code"
+	UnexpectedHTTPVersion   Status = "unexpected-http-version" // the response protocol is not validation.expected-alpn
+	UnexpectedFinalURL      Status = "unexpected-final-url"    // the redirects ended elsewhere than validation.expected-final-url-regex
+	UnexpectedHeaderValue   Status = "unexpected-header-value"
+	UnexpectedHeaderPresent Status = "unexpected-header-present" // a validation.headers-absent header is in the response
+	UnexpectedBodyRegex     Status = "unexpected-body-regex"
+	ForbiddenBodyRegex      Status = "forbidden-body-regex" // the body matches validation.body-not-regex
+	UnexpectedJSONPath      Status = "unexpected-json-path" // a validation.json-path assertion failed or the body is not JSON
+	StaleContent            Status = "stale-content"        // the content timestamp is older than validation.freshness.max-age
+	TooSlow                 Status = "too-slow"             // passed, but took longer than validation.max-duration
+	UnexpectedBanner        Status = "unexpected-banner"    // the server greeting does not match (protocol: smtp)
+	UnexpectedResult        Status = "unexpected-result"    // no row, or the query result does not match (protocol: postgres, mysql)
+
+	// DNS (protocol: dns).
+	DNSNXDomain        Status = "dns-nxdomain"         // the server answered NXDOMAIN
+	DNSUnexpectedRcode Status = "dns-unexpected-rcode" // any other error rcode (SERVFAIL, REFUSED, ...)
+	DNSTimeout         Status = "dns-timeout"
+	UnexpectedAnswer   Status = "unexpected-answer" // no answer, or not the expected one
+
+	// TLS.
+	InvalidTLSMissing          Status = "invalid-tls-missing" // HTTPS expected but no TLS observed
+	InvalidTLSChain            Status = "invalid-tls-chain"
+	InvalidTLSHostname         Status = "invalid-tls-hostname"
+	InvalidTLSCertificate      Status = "invalid-tls-certificate"
+	InvalidTLSUnknownAuthority Status = "invalid-tls-unknown-authority"
+	InvalidTLSHandshake        Status = "invalid-tls-handshake"
+	InvalidTLSOther            Status = "invalid-tls-other"
+	ExpiredCertLeaf            Status = "expired-cert-leaf"
+	WeakCipherSuite            Status = "weak-cipher-suite"      // the negotiated suite breaks the endpoint's cipher policy
+	CertPinMismatch            Status = "cert-pin-mismatch"      // the leaf key matches none of validation.pin-sha256
+	UnexpectedCertIssuer       Status = "unexpected-cert-issuer" // the leaf issuer or subject CN does not match validation
+	CertExpiringSoon           Status = "cert-expiring-soon"     // the leaf expires within validation.min-cert-days-left
+
+	UnknownError Status = "unknown-error" // failed without a more specific status
+)
+
+// All lists every status in documentation order.
+var All = []Status{
+	Valid, ValidInsecureTLS, Maintenance,
+	InvalidURL, InvalidProxyDefinition, InvalidRequestDefinition,
+	InvalidRequestExecution, RequestExecutionError, RequestExecutionTimeout,
+	UnexpectedStatusCode, UnexpectedFinalURL, UnexpectedHTTPVersion, UnexpectedHeaderValue, UnexpectedHeaderPresent,
+	UnexpectedBodyRegex, ForbiddenBodyRegex, UnexpectedJSONPath, StaleContent, TooSlow, UnexpectedBanner, UnexpectedResult,
+	DNSNXDomain, DNSUnexpectedRcode, DNSTimeout, UnexpectedAnswer,
+	InvalidTLSMissing, InvalidTLSChain, InvalidTLSHostname, InvalidTLSCertificate, InvalidTLSUnknownAuthority,
+	InvalidTLSHandshake, InvalidTLSOther, ExpiredCertLeaf, WeakCipherSuite, CertPinMismatch, UnexpectedCertIssuer,
+	CertExpiringSoon,
+	UnknownError,
+}
+
+// Failed reports whether s is anything but Valid, ValidInsecureTLS or Maintenance. The empty status (no probe yet) is
+// not a failure.
+func (s Status) Failed() bool {
+	return s != "" && s != Valid && s != ValidInsecureTLS && s != Maintenance
+}
+
+// Known reports whether s is one of the statuses defined here.
+func (s Status) Known() bool {
+	for _, k := range All {
+		if s == k {
+			return true
+		}
+	}
+	return false
+}
+
+func (s Status) String() string {
+	return string(s)
+}
+
+// ErrorClass is a low-cardinality grouping of failures, usable as a metric label.
+type ErrorClass string
+
+const (
+	ClassNone    ErrorClass = ""        // the probe passed
+	ClassConfig  ErrorClass = "config"  // endpoint or route definition error
+	ClassDNS     ErrorClass = "dns"     // name resolution failed
+	ClassConnect ErrorClass = "connect" // TCP connect failed (refused, unreachable, reset)
+	ClassTLS     ErrorClass = "tls"     // handshake or certificate problem
+	ClassTimeout ErrorClass = "timeout" // request or body read timed out
+	ClassHTTP    ErrorClass = "http"    // a response arrived but failed validation, or the exchange broke
+	ClassOther   ErrorClass = "other"
+)
+
+// Class returns the error class implied by s alone; dns and connect need the error (see validator.ClassifyError).
+func (s Status) Class() ErrorClass {
+	switch s {
+	case "", Valid, ValidInsecureTLS, Maintenance:
+		return ClassNone
+	case InvalidURL, InvalidProxyDefinition, InvalidRequestDefinition:
+		return ClassConfig
+	case RequestExecutionTimeout, DNSTimeout:
+		return ClassTimeout
+	case DNSNXDomain, DNSUnexpectedRcode, UnexpectedAnswer:
+		return ClassDNS
+	case UnexpectedStatusCode, UnexpectedFinalURL, UnexpectedHTTPVersion, UnexpectedHeaderValue, UnexpectedHeaderPresent, UnexpectedBodyRegex,
+		ForbiddenBodyRegex, UnexpectedJSONPath, StaleContent, TooSlow, UnexpectedBanner, UnexpectedResult, RequestExecutionError:
+		return ClassHTTP
+	case InvalidTLSMissing, InvalidTLSChain, InvalidTLSHostname, InvalidTLSCertificate, InvalidTLSUnknownAuthority,
+		InvalidTLSHandshake, InvalidTLSOther, ExpiredCertLeaf, WeakCipherSuite, CertPinMismatch, UnexpectedCertIssuer,
+		CertExpiringSoon:
+		return ClassTLS
+	default:
+		return ClassOther
+	}
+}
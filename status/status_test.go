@@ -0,0 +1,37 @@
+package status
+
+import "testing"
+
+func TestStatus_Failed(t *testing.T) {
+	if Valid.Failed() {
+		t.Fatal("valid must not be a failure")
+	}
+	if ValidInsecureTLS.Failed() {
+		t.Fatal("valid-insecure-tls must not be a failure")
+	}
+	if Maintenance.Failed() {
+		t.Fatal("maintenance must not be a failure")
+	}
+	if Status("").Failed() {
+		t.Fatal("no status yet must not be a failure")
+	}
+	if !RequestExecutionTimeout.Failed() {
+		t.Fatal("timeout must be a failure")
+	}
+}
+
+func TestStatus_Known(t *testing.T) {
+	seen := map[Status]bool{}
+	for _, s := range All {
+		if seen[s] {
+			t.Fatalf("duplicate status %q", s)
+		}
+		seen[s] = true
+		if !s.Known() {
+			t.Fatalf("%q must be known", s)
+		}
+	}
+	if Status("ok").Known() {
+		t.Fatal(`"ok" is not a defined status`)
+	}
+}
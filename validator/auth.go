@@ -0,0 +1,188 @@
+package validator
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+)
+
+// readPassword reads a password file, without the trailing line break.
+func readPassword(file string) (string, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// digestTransport answers a 401 digest challenge (RFC 7616, qop=auth) by repeating the request with credentials.
+// A 401 without a digest challenge is returned as it is, for the response checks.
+type digestTransport struct {
+	base     http.RoundTripper
+	username string
+	password string
+}
+
+func (t *digestTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	challenge, ok := digestChallenge(resp.Header)
+	if !ok {
+		return resp, nil
+	}
+	authorization, err := digestAuthorization(challenge, req.Method, req.URL.RequestURI(), t.username, t.password, newCNonce())
+	if err != nil {
+		_ = resp.Body.Close()
+		return nil, err
+	}
+	retry := req.Clone(req.Context())
+	if req.Body != nil && req.GetBody != nil {
+		if retry.Body, err = req.GetBody(); err != nil {
+			_ = resp.Body.Close()
+			return nil, err
+		}
+	}
+	retry.Header.Set("Authorization", authorization)
+	_ = resp.Body.Close()
+	return t.base.RoundTrip(retry)
+}
+
+// digestChallenge returns the parameters of the first Digest challenge with a supported algorithm.
+func digestChallenge(h http.Header) (map[string]string, bool) {
+	for _, value := range h.Values("WWW-Authenticate") {
+		scheme, rest, _ := strings.Cut(strings.TrimSpace(value), " ")
+		if !strings.EqualFold(scheme, "Digest") {
+			continue
+		}
+		params := parseAuthParams(rest)
+		if _, ok := digestHash(params["algorithm"]); ok {
+			return params, true
+		}
+	}
+	return nil, false
+}
+
+// parseAuthParams splits `key=value, key="quoted, value"` pairs; keys are lower-cased.
+func parseAuthParams(s string) map[string]string {
+	params := make(map[string]string)
+	for s = strings.TrimSpace(s); s != ""; s = strings.TrimLeft(s, ", \t") {
+		key, rest, found := strings.Cut(s, "=")
+		if !found {
+			break
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			var sb strings.Builder
+			i := 1
+			for ; i < len(rest) && rest[i] != '"'; i++ {
+				if rest[i] == '\\' && i+1 < len(rest) {
+					i++
+				}
+				sb.WriteByte(rest[i])
+			}
+			value, s = sb.String(), rest[min(i+1, len(rest)):]
+		} else {
+			value, s, _ = strings.Cut(rest, ",")
+			value = strings.TrimSpace(value)
+		}
+		params[key] = value
+	}
+	return params
+}
+
+// digestHash maps a challenge algorithm (MD5 when absent) to its hash; the -sess variants use the same one.
+func digestHash(algorithm string) (func() hash.Hash, bool) {
+	switch strings.TrimSuffix(strings.ToUpper(algorithm), "-SESS") {
+	case "", "MD5":
+		return md5.New, true
+	case "SHA-256":
+		return sha256.New, true
+	}
+	return nil, false
+}
+
+// digestAuthorization computes the Authorization header answering challenge for one request.
+func digestAuthorization(challenge map[string]string, method, uri, username, password, cnonce string) (string, error) {
+	algorithm := challenge["algorithm"]
+	newHash, ok := digestHash(algorithm)
+	if !ok {
+		return "", fmt.Errorf("unsupported digest algorithm %q", algorithm)
+	}
+	h := func(s string) string {
+		sum := newHash()
+		sum.Write([]byte(s))
+		return hex.EncodeToString(sum.Sum(nil))
+	}
+	realm, nonce := challenge["realm"], challenge["nonce"]
+	ha1 := h(username + ":" + realm + ":" + password)
+	if strings.HasSuffix(strings.ToUpper(algorithm), "-SESS") {
+		ha1 = h(ha1 + ":" + nonce + ":" + cnonce)
+	}
+	ha2 := h(method + ":" + uri)
+
+	const nc = "00000001"
+	var response, qop string
+	if offered, present := challenge["qop"]; present {
+		for _, q := range strings.Split(offered, ",") {
+			if strings.TrimSpace(q) == "auth" {
+				qop = "auth"
+			}
+		}
+		if qop == "" {
+			return "", errors.New("digest challenge does not offer qop=auth")
+		}
+		response = h(ha1 + ":" + nonce + ":" + nc + ":" + cnonce + ":" + qop + ":" + ha2)
+	} else {
+		response = h(ha1 + ":" + nonce + ":" + ha2)
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, `Digest username=%q, realm=%q, nonce=%q, uri=%q, response=%q`, username, realm, nonce, uri, response)
+	if algorithm != "" {
+		fmt.Fprintf(&sb, `, algorithm=%s`, algorithm)
+	}
+	if qop != "" {
+		fmt.Fprintf(&sb, `, qop=%s, nc=%s, cnonce=%q`, qop, nc, cnonce)
+	}
+	if opaque, present := challenge["opaque"]; present {
+		fmt.Fprintf(&sb, `, opaque=%q`, opaque)
+	}
+	return sb.String(), nil
+}
+
+// newCNonce returns a random client nonce.
+func newCNonce() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// applyAuth sets basic credentials on req, or wraps the transport to answer digest challenges.
+func applyAuth(client *http.Client, req *http.Request, auth *config.RequestAuth) error {
+	password := auth.Password
+	if auth.PasswordFile != "" {
+		var err error
+		if password, err = readPassword(auth.PasswordFile); err != nil {
+			return fmt.Errorf("cannot read request.auth.password-file: %w", err)
+		}
+	}
+	switch auth.Type {
+	case config.AuthDigest:
+		client.Transport = &digestTransport{base: client.Transport, username: auth.Username, password: password}
+	default:
+		req.SetBasicAuth(auth.Username, password)
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package validator
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+	"github.com/kinjelom/watchdog_exporter/status"
+)
+
+func TestDigestAuthorization_RFC2617Example(t *testing.T) {
+	challenge, ok := digestChallenge(http.Header{"Www-Authenticate": {
+		`Basic realm="fallback"`,
+		`Digest realm="testrealm@host.com", qop="auth,auth-int", nonce="dcd98b7102dd2f0e8b11d0f600bfb0c093", opaque="5ccc069c403ebaf9f0171e9517f40e41"`,
+	}})
+	assert.True(t, ok)
+
+	got, err := digestAuthorization(challenge, http.MethodGet, "/dir/index.html", "Mufasa", "Circle Of Life", "0a4f113b")
+	assert.NoError(t, err)
+	assert.Contains(t, got, `response="6629fae49393a05397450978507c4ef1"`)
+	assert.Contains(t, got, `qop=auth, nc=00000001, cnonce="0a4f113b"`)
+	assert.Contains(t, got, `opaque="5ccc069c403ebaf9f0171e9517f40e41"`)
+
+	_, ok = digestChallenge(http.Header{"Www-Authenticate": {`Digest realm="r", nonce="n", algorithm=SHA-512-256`}})
+	assert.False(t, ok, "unsupported algorithm")
+}
+
+func writePassword(t *testing.T, password string) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "password")
+	if err := os.WriteFile(file, []byte(password+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestProbe_BasicAuth(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "probe" || pass != "s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer srv.Close()
+
+	v := NewWatchDogValidator(NewDefaultTLSChecker(false), NewDefaultHTTPResponseChecker(false), false)
+	validation := &config.EndpointValidation{StatusCode: http.StatusOK}
+	req := config.EndpointRequest{URL: srv.URL, Method: http.MethodGet, Timeout: 2 * time.Second,
+		Auth: &config.RequestAuth{Type: config.AuthBasic, Username: "probe", PasswordFile: writePassword(t, "s3cret")}}
+
+	rep, err := v.Probe("ep", req, "rt", config.Route{}, validation, false)
+	assert.NoError(t, err)
+	assert.Equal(t, status.Valid, rep.Status)
+
+	req.Auth.PasswordFile = writePassword(t, "wrong")
+	rep, err = v.Probe("ep", req, "rt", config.Route{}, validation, false)
+	assert.NoError(t, err)
+	assert.Equal(t, status.UnexpectedStatusCode, rep.Status)
+
+	req.Auth.PasswordFile = filepath.Join(t.TempDir(), "missing")
+	rep, err = v.Probe("ep", req, "rt", config.Route{}, validation, false)
+	assert.Error(t, err)
+	assert.Equal(t, status.InvalidRequestDefinition, rep.Status)
+
+	// password instead of password-file, rendered per probe
+	v.SetSecretSource(fakeSecrets{"secret/data/watchdog/api": {"password": "s3cret"}})
+	req.Auth.PasswordFile, req.Auth.Password = "", `{{ vault "secret/data/watchdog/api" "password" }}`
+	rep, err = v.Probe("ep", req, "rt", config.Route{}, validation, false)
+	assert.NoError(t, err)
+	assert.Equal(t, status.Valid, rep.Status)
+}
+
+func TestProbe_DigestAuth(t *testing.T) {
+	const realm, nonce = "probes@example.com", "6f1c3a"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scheme, rest, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+		if scheme == "Digest" {
+			got := parseAuthParams(rest)
+			want, _ := digestAuthorization(map[string]string{"realm": realm, "nonce": nonce, "qop": "auth", "algorithm": "SHA-256"},
+				r.Method, r.URL.RequestURI(), "probe", "s3cret", got["cnonce"])
+			if got["response"] == parseAuthParams(strings.TrimPrefix(want, "Digest "))["response"] {
+				return
+			}
+		}
+		w.Header().Set("WWW-Authenticate", `Digest realm="`+realm+`", nonce="`+nonce+`", qop="auth", algorithm=SHA-256`)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	v := NewWatchDogValidator(NewDefaultTLSChecker(false), NewDefaultHTTPResponseChecker(false), false)
+	validation := &config.EndpointValidation{StatusCode: http.StatusOK}
+	req := config.EndpointRequest{URL: srv.URL + "/status?full=1", Method: http.MethodPost, Body: `{"probe":true}`, Timeout: 2 * time.Second,
+		Auth: &config.RequestAuth{Type: config.AuthDigest, Username: "probe", PasswordFile: writePassword(t, "s3cret")}}
+
+	rep, err := v.Probe("ep", req, "rt", config.Route{}, validation, false)
+	assert.NoError(t, err)
+	assert.Equal(t, status.Valid, rep.Status)
+
+	req.Auth.PasswordFile = writePassword(t, "wrong")
+	rep, err = v.Probe("ep", req, "rt", config.Route{}, validation, false)
+	assert.NoError(t, err)
+	assert.Equal(t, status.UnexpectedStatusCode, rep.Status)
+}
@@ -0,0 +1,120 @@
+package validator
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+)
+
+// DebugSwitch decides per endpoint whether the checks log their details (failure reasons, response bodies).
+// It starts from the config (settings.debug, settings.debug-groups, endpoint debug) and can be flipped at runtime.
+type DebugSwitch struct {
+	mu        sync.RWMutex
+	all       bool
+	groups    map[string]bool
+	endpoints map[string]bool
+	groupOf   map[string]string // endpoint -> group
+}
+
+func NewDebugSwitch(cfg *config.WatchDogConfig) *DebugSwitch {
+	d := &DebugSwitch{}
+	d.SetConfig(cfg)
+	return d
+}
+
+// SetConfig resets the switch to cfg (after a config reload); runtime changes are dropped as on a restart.
+func (d *DebugSwitch) SetConfig(cfg *config.WatchDogConfig) {
+	groups := make(map[string]bool)
+	endpoints := make(map[string]bool)
+	groupOf := make(map[string]string, len(cfg.Endpoints))
+	for _, g := range cfg.Settings.DebugGroups {
+		groups[g] = true
+	}
+	for name, ep := range cfg.Endpoints {
+		groupOf[name] = ep.Group
+		if ep.Debug {
+			endpoints[name] = true
+		}
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.all = cfg.Settings.Debug
+	d.groups, d.endpoints, d.groupOf = groups, endpoints, groupOf
+}
+
+// Enabled reports whether the endpoint's probes log details; a nil switch is always off.
+func (d *DebugSwitch) Enabled(endpoint string) bool {
+	if d == nil {
+		return false
+	}
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.all || d.endpoints[endpoint] || d.groups[d.groupOf[endpoint]]
+}
+
+func (d *DebugSwitch) SetAll(on bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.all = on
+}
+
+func (d *DebugSwitch) SetGroup(group string, on bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	setOrDelete(d.groups, group, on)
+}
+
+func (d *DebugSwitch) SetEndpoint(endpoint string, on bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	setOrDelete(d.endpoints, endpoint, on)
+}
+
+// DebugState is a snapshot of what is switched on.
+type DebugState struct {
+	All       bool     `json:"all"`
+	Groups    []string `json:"groups"`
+	Endpoints []string `json:"endpoints"`
+}
+
+func (d *DebugSwitch) State() DebugState {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return DebugState{All: d.all, Groups: sortedKeys(d.groups), Endpoints: sortedKeys(d.endpoints)}
+}
+
+func setOrDelete(m map[string]bool, key string, on bool) {
+	if on {
+		m[key] = true
+	} else {
+		delete(m, key)
+	}
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+type debugKey struct{}
+
+// withDebug marks a probe request so the response checker knows to log details.
+func withDebug(ctx context.Context, on bool) context.Context {
+	return context.WithValue(ctx, debugKey{}, on)
+}
+
+// debugRequested reports whether req was marked by withDebug.
+func debugRequested(req *http.Request) bool {
+	if req == nil {
+		return false
+	}
+	on, _ := req.Context().Value(debugKey{}).(bool)
+	return on
+}
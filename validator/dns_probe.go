@@ -0,0 +1,239 @@
+package validator
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+	"github.com/kinjelom/watchdog_exporter/status"
+)
+
+// dnsTypes are the query types a dns endpoint can ask for.
+var dnsTypes = map[string]dnsmessage.Type{
+	"A":     dnsmessage.TypeA,
+	"AAAA":  dnsmessage.TypeAAAA,
+	"CNAME": dnsmessage.TypeCNAME,
+	"MX":    dnsmessage.TypeMX,
+	"NS":    dnsmessage.TypeNS,
+	"PTR":   dnsmessage.TypePTR,
+	"SOA":   dnsmessage.TypeSOA,
+	"SRV":   dnsmessage.TypeSRV,
+	"TXT":   dnsmessage.TypeTXT,
+}
+
+// ProbeDNS sends the endpoint's query to the server in rc.URL (dns://host[:port], the route's target-ip replaces
+// the host) and validates the rcode and the answers of the queried type.
+func (m *WatchDogValidator) ProbeDNS(endpointName string, rc config.EndpointRequest, routeName string, route config.Route, q config.DNSQuery) (Report, error) {
+	debug := m.debug || m.debugSwitch.Enabled(endpointName)
+	server, err := dnsServer(rc.URL, route.TargetIP)
+	if err != nil {
+		slog.Error("failed to parse DNS server URL", "status", status.InvalidURL, "url", rc.URL, "err", err)
+		return Report{Status: status.InvalidURL}, err
+	}
+	if route.ProxyUrl != "" || route.TargetSocket != "" {
+		err = errors.New("dns probes cannot use a proxy or a target-socket")
+		slog.Error("invalid route for a dns endpoint", "status", status.InvalidProxyDefinition, "route", routeName, "err", err)
+		return Report{Status: status.InvalidProxyDefinition}, err
+	}
+	qtype, ok := dnsTypes[strings.ToUpper(q.Type)]
+	if !ok {
+		err = fmt.Errorf("unsupported dns query-type %q", q.Type)
+	}
+	var name dnsmessage.Name
+	if err == nil {
+		name, err = dnsmessage.NewName(strings.TrimSuffix(q.Name, ".") + ".")
+	}
+	if err == nil && q.Transport != "udp" && q.Transport != "tcp" {
+		err = fmt.Errorf("unsupported dns transport %q", q.Transport)
+	}
+	if err != nil {
+		slog.Error("failed to prepare DNS query", "status", status.InvalidRequestDefinition, "endpoint", endpointName, "err", err)
+		return Report{Status: status.InvalidRequestDefinition}, err
+	}
+	msg := dnsmessage.Message{
+		Header:    dnsmessage.Header{RecursionDesired: q.RecursionDesired == nil || *q.RecursionDesired},
+		Questions: []dnsmessage.Question{{Name: name, Type: qtype, Class: dnsmessage.ClassINET}},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), rc.Timeout)
+	defer cancel()
+	start := time.Now()
+	resp, remoteIP, err := dnsExchange(ctx, q.Transport, server, msg)
+	rep := Report{Duration: time.Since(start).Seconds(), RemoteIP: remoteIP}
+	if err != nil {
+		rep.Status = status.InvalidRequestExecution
+		if isTimeoutErr(err) {
+			rep.Status = status.DNSTimeout
+		}
+		if debug {
+			slog.Info("dns query failed", "status", rep.Status, "server", server, "route", routeName, "err", err)
+		}
+		return rep, err
+	}
+	if resp.RCode != dnsmessage.RCodeSuccess {
+		rep.Status = status.DNSUnexpectedRcode
+		if resp.RCode == dnsmessage.RCodeNameError {
+			rep.Status = status.DNSNXDomain
+		}
+		return rep, fmt.Errorf("dns: %s %s: %s", q.Name, qtype, resp.RCode)
+	}
+
+	for _, ans := range resp.Answers {
+		if ans.Header.Type != qtype {
+			continue // CNAME chain
+		}
+		if text, known := answerText(ans.Body); known {
+			rep.Answers = append(rep.Answers, text)
+		}
+	}
+	rep.Status, err = checkAnswers(rep.Answers, q)
+	if debug && rep.Status != status.Valid {
+		slog.Info("unexpected dns answer", "status", rep.Status, "server", server, "route", routeName, "query", q.Name,
+			"type", q.Type, "answers", rep.Answers, "expected", q.ExpectedAnswers, "regex", q.AnswerRegex, "err", err)
+	}
+	return rep, err
+}
+
+// dnsServer is the host:port to query, port 53 unless the URL has one.
+func dnsServer(rawURL, targetIP string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != config.ProtocolDNS || u.Hostname() == "" {
+		return "", fmt.Errorf("expected dns://host[:port], got %q", rawURL)
+	}
+	host, port := u.Hostname(), u.Port()
+	if targetIP != "" {
+		host = targetIP
+	}
+	if port == "" {
+		port = "53"
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+// checkAnswers requires at least one answer, each expected answer among them and every answer to match the regex.
+func checkAnswers(answers []string, q config.DNSQuery) (status.Status, error) {
+	if len(answers) == 0 {
+		return status.UnexpectedAnswer, nil
+	}
+	for _, want := range q.ExpectedAnswers {
+		found := false
+		for _, got := range answers {
+			if strings.EqualFold(got, want) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return status.UnexpectedAnswer, nil
+		}
+	}
+	if q.AnswerRegex != "" {
+		re, err := compiledRegex(q.AnswerRegex)
+		if err != nil {
+			return status.InvalidRequestDefinition, err
+		}
+		for _, got := range answers {
+			if !re.MatchString(got) {
+				return status.UnexpectedAnswer, nil
+			}
+		}
+	}
+	return status.Valid, nil
+}
+
+// answerText renders the record data in zone file notation (without name, TTL, class and type).
+func answerText(body dnsmessage.ResourceBody) (string, bool) {
+	switch b := body.(type) {
+	case *dnsmessage.AResource:
+		return net.IP(b.A[:]).String(), true
+	case *dnsmessage.AAAAResource:
+		return net.IP(b.AAAA[:]).String(), true
+	case *dnsmessage.CNAMEResource:
+		return b.CNAME.String(), true
+	case *dnsmessage.NSResource:
+		return b.NS.String(), true
+	case *dnsmessage.PTRResource:
+		return b.PTR.String(), true
+	case *dnsmessage.MXResource:
+		return strconv.Itoa(int(b.Pref)) + " " + b.MX.String(), true
+	case *dnsmessage.SRVResource:
+		return fmt.Sprintf("%d %d %d %s", b.Priority, b.Weight, b.Port, b.Target), true
+	case *dnsmessage.SOAResource:
+		return fmt.Sprintf("%s %s %d %d %d %d %d", b.NS, b.MBox, b.Serial, b.Refresh, b.Retry, b.Expire, b.MinTTL), true
+	case *dnsmessage.TXTResource:
+		return strings.Join(b.TXT, ""), true
+	}
+	return "", false
+}
+
+// dnsExchange sends msg with a fresh ID over udp or tcp (length-prefixed) and returns the response and the
+// address of the server that answered.
+func dnsExchange(ctx context.Context, network, server string, msg dnsmessage.Message) (dnsmessage.Message, string, error) {
+	var resp dnsmessage.Message
+	msg.ID = uint16(time.Now().UnixNano())
+	packed, err := msg.Pack()
+	if err != nil {
+		return resp, "", err
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, network, server)
+	if err != nil {
+		return resp, "", err
+	}
+	defer func() { _ = conn.Close() }()
+	remoteIP, _, _ := net.SplitHostPort(conn.RemoteAddr().String())
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(5 * time.Second)
+	}
+	_ = conn.SetDeadline(deadline)
+
+	var buf []byte
+	if network == "tcp" {
+		framed := binary.BigEndian.AppendUint16(make([]byte, 0, 2+len(packed)), uint16(len(packed)))
+		if _, err = conn.Write(append(framed, packed...)); err != nil {
+			return resp, remoteIP, err
+		}
+		var size [2]byte
+		if _, err = io.ReadFull(conn, size[:]); err != nil {
+			return resp, remoteIP, err
+		}
+		buf = make([]byte, binary.BigEndian.Uint16(size[:]))
+		if _, err = io.ReadFull(conn, buf); err != nil {
+			return resp, remoteIP, err
+		}
+	} else {
+		if _, err = conn.Write(packed); err != nil {
+			return resp, remoteIP, err
+		}
+		buf = make([]byte, 1232)
+		n, rErr := conn.Read(buf)
+		if rErr != nil {
+			return resp, remoteIP, rErr
+		}
+		buf = buf[:n]
+	}
+
+	if err = resp.Unpack(buf); err != nil {
+		return resp, remoteIP, err
+	}
+	if resp.ID != msg.ID {
+		return resp, remoteIP, errors.New("dns: mismatched response id")
+	}
+	return resp, remoteIP, nil
+}
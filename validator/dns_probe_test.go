@@ -0,0 +1,152 @@
+package validator
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+	"github.com/kinjelom/watchdog_exporter/status"
+)
+
+// answerDNS answers example.test. A with 192.0.2.1 and 192.0.2.2, and NXDOMAIN for every other name.
+func answerDNS(t *testing.T, query []byte) []byte {
+	var req dnsmessage.Message
+	if err := req.Unpack(query); err != nil {
+		t.Errorf("bad query: %v", err)
+		return nil
+	}
+	q := req.Questions[0]
+	resp := dnsmessage.Message{Header: dnsmessage.Header{ID: req.ID, Response: true, Authoritative: true}, Questions: req.Questions}
+	if q.Name.String() != "example.test." {
+		resp.RCode = dnsmessage.RCodeNameError
+	} else if q.Type == dnsmessage.TypeA {
+		for _, ip := range [][4]byte{{192, 0, 2, 1}, {192, 0, 2, 2}} {
+			resp.Answers = append(resp.Answers, dnsmessage.Resource{
+				Header: dnsmessage.ResourceHeader{Name: q.Name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET, TTL: 60},
+				Body:   &dnsmessage.AResource{A: ip},
+			})
+		}
+	}
+	packed, err := resp.Pack()
+	if err != nil {
+		t.Errorf("cannot pack: %v", err)
+	}
+	return packed
+}
+
+func startDNSServer(t *testing.T) (udpAddr, tcpAddr string) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = pc.Close(); _ = l.Close() })
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, rErr := pc.ReadFrom(buf)
+			if rErr != nil {
+				return
+			}
+			_, _ = pc.WriteTo(answerDNS(t, buf[:n]), addr)
+		}
+	}()
+	go func() {
+		for {
+			conn, aErr := l.Accept()
+			if aErr != nil {
+				return
+			}
+			var size [2]byte
+			if _, rErr := io.ReadFull(conn, size[:]); rErr == nil {
+				query := make([]byte, binary.BigEndian.Uint16(size[:]))
+				if _, rErr = io.ReadFull(conn, query); rErr == nil {
+					resp := answerDNS(t, query)
+					_, _ = conn.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(resp))), resp...))
+				}
+			}
+			_ = conn.Close()
+		}
+	}()
+	return pc.LocalAddr().String(), l.Addr().String()
+}
+
+func TestProbeDNS(t *testing.T) {
+	udpAddr, tcpAddr := startDNSServer(t)
+	v := NewWatchDogValidator(NewDefaultTLSChecker(false), NewDefaultHTTPResponseChecker(false), false)
+	rc := config.EndpointRequest{URL: "dns://" + udpAddr, Timeout: time.Second}
+	probe := func(rc config.EndpointRequest, route config.Route, q config.DNSQuery) (Report, error) {
+		if q.Type == "" {
+			q.Type = "A"
+		}
+		if q.Transport == "" {
+			q.Transport = "udp"
+		}
+		return v.ProbeDNS("ns", rc, "direct", route, q)
+	}
+
+	rep, err := probe(rc, config.Route{}, config.DNSQuery{Name: "example.test", ExpectedAnswers: []string{"192.0.2.2"}, AnswerRegex: `^192\.0\.2\.\d+$`})
+	assert.NoError(t, err)
+	assert.Equal(t, status.Valid, rep.Status)
+	assert.Equal(t, []string{"192.0.2.1", "192.0.2.2"}, rep.Answers)
+	assert.Equal(t, "127.0.0.1", rep.RemoteIP)
+
+	rep, _ = probe(rc, config.Route{}, config.DNSQuery{Name: "example.test", ExpectedAnswers: []string{"192.0.2.9"}})
+	assert.Equal(t, status.UnexpectedAnswer, rep.Status)
+	rep, _ = probe(rc, config.Route{}, config.DNSQuery{Name: "example.test", Type: "AAAA"})
+	assert.Equal(t, status.UnexpectedAnswer, rep.Status, "no answer of the queried type")
+
+	rep, err = probe(rc, config.Route{}, config.DNSQuery{Name: "missing.test"})
+	assert.Equal(t, status.DNSNXDomain, rep.Status)
+	assert.ErrorContains(t, err, "NameError")
+
+	tcp := rc
+	tcp.URL = "dns://" + tcpAddr
+	rep, err = probe(tcp, config.Route{}, config.DNSQuery{Name: "example.test", Transport: "tcp"})
+	assert.NoError(t, err)
+	assert.Equal(t, status.Valid, rep.Status)
+
+	_, port, _ := net.SplitHostPort(udpAddr)
+	rep, err = probe(config.EndpointRequest{URL: "dns://ns.invalid:" + port, Timeout: time.Second}, config.Route{TargetIP: "127.0.0.1"}, config.DNSQuery{Name: "example.test"})
+	assert.NoError(t, err, "target-ip replaces the server host")
+	assert.Equal(t, status.Valid, rep.Status)
+
+	rep, _ = probe(config.EndpointRequest{URL: "https://example.test", Timeout: time.Second}, config.Route{}, config.DNSQuery{Name: "example.test"})
+	assert.Equal(t, status.InvalidURL, rep.Status)
+	rep, _ = probe(rc, config.Route{}, config.DNSQuery{Name: "example.test", Type: "HINFO"})
+	assert.Equal(t, status.InvalidRequestDefinition, rep.Status)
+}
+
+func TestProbeDNS_Timeout(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0") // never answers
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = pc.Close() }()
+	v := NewWatchDogValidator(NewDefaultTLSChecker(false), NewDefaultHTTPResponseChecker(false), false)
+	rc := config.EndpointRequest{URL: "dns://" + pc.LocalAddr().String(), Timeout: 100 * time.Millisecond}
+	rep, err := v.ProbeDNS("ns", rc, "direct", config.Route{}, config.DNSQuery{Name: "example.test", Type: "A", Transport: "udp"})
+	assert.Equal(t, status.DNSTimeout, rep.Status)
+	assert.Equal(t, status.ClassTimeout, ClassifyError(rep.Status, err))
+}
+
+func TestAnswerText(t *testing.T) {
+	name := dnsmessage.MustNewName("mx.example.test.")
+	text, ok := answerText(&dnsmessage.MXResource{Pref: 10, MX: name})
+	assert.True(t, ok)
+	assert.Equal(t, "10 mx.example.test.", text)
+	text, _ = answerText(&dnsmessage.TXTResource{TXT: []string{"v=spf1 ", "-all"}})
+	assert.Equal(t, "v=spf1 -all", text)
+	text, _ = answerText(&dnsmessage.SRVResource{Priority: 1, Weight: 5, Port: 443, Target: name})
+	assert.True(t, strings.HasSuffix(text, "443 mx.example.test."))
+}
@@ -0,0 +1,279 @@
+package validator
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+)
+
+// DNSCache resolves probe hostnames once per TTL (clamped to min/max) instead of on every dial.
+// It asks the nameservers directly to learn the TTLs; when they can't be reached it falls back to the
+// system resolver and keeps that answer for the minimum TTL.
+type DNSCache struct {
+	cfg     config.DNSCacheConfig
+	servers []string
+	now     func() time.Time
+	query   func(ctx context.Context, host string) ([]net.IP, time.Duration, error)
+
+	mu      sync.Mutex
+	entries map[string]dnsEntry
+}
+
+type dnsEntry struct {
+	ips     []net.IP
+	expires time.Time
+}
+
+func NewDNSCache(cfg config.DNSCacheConfig) (*DNSCache, error) {
+	servers := append([]string(nil), cfg.Servers...)
+	if len(servers) == 0 {
+		var err error
+		if servers, err = systemNameservers("/etc/resolv.conf"); err != nil {
+			return nil, err
+		}
+	}
+	for i, s := range servers {
+		if _, _, err := net.SplitHostPort(s); err != nil {
+			servers[i] = net.JoinHostPort(s, "53")
+		}
+	}
+	c := &DNSCache{cfg: cfg, servers: servers, now: time.Now, entries: make(map[string]dnsEntry)}
+	c.query = c.queryServers
+	return c, nil
+}
+
+// LookupIP returns the cached addresses of host, resolving it when missing or expired.
+func (c *DNSCache) LookupIP(ctx context.Context, host string) ([]net.IP, error) {
+	c.mu.Lock()
+	e, ok := c.entries[host]
+	c.mu.Unlock()
+	if ok && c.now().Before(e.expires) {
+		return e.ips, nil
+	}
+
+	ips, ttl, err := c.query(ctx, host)
+	if err != nil || len(ips) == 0 {
+		ips, err = net.DefaultResolver.LookupIP(ctx, "ip", host)
+		if err != nil {
+			return nil, err
+		}
+		ttl = c.cfg.MinTTL
+	}
+	ttl = max(c.cfg.MinTTL, min(ttl, c.cfg.MaxTTL))
+	c.mu.Lock()
+	c.entries[host] = dnsEntry{ips: ips, expires: c.now().Add(ttl)}
+	c.mu.Unlock()
+	return ips, nil
+}
+
+// queryServers asks for A and AAAA records; the TTL is the lowest one among the answers.
+func (c *DNSCache) queryServers(ctx context.Context, host string) ([]net.IP, time.Duration, error) {
+	var lastErr error
+	for _, server := range c.servers {
+		var ips []net.IP
+		ttl := time.Duration(-1)
+		for _, qtype := range []dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA} {
+			got, t, err := exchange(ctx, server, host, qtype)
+			if err != nil {
+				lastErr = err
+				ips = nil
+				break
+			}
+			ips = append(ips, got...)
+			if len(got) > 0 && (ttl < 0 || t < ttl) {
+				ttl = t
+			}
+		}
+		if len(ips) > 0 {
+			return ips, ttl, nil
+		}
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no addresses for %s", host)
+	}
+	return nil, 0, lastErr
+}
+
+func exchange(ctx context.Context, server, host string, qtype dnsmessage.Type) ([]net.IP, time.Duration, error) {
+	name, err := dnsmessage.NewName(strings.TrimSuffix(host, ".") + ".")
+	if err != nil {
+		return nil, 0, err
+	}
+	msg := dnsmessage.Message{
+		Header:    dnsmessage.Header{RecursionDesired: true},
+		Questions: []dnsmessage.Question{{Name: name, Type: qtype, Class: dnsmessage.ClassINET}},
+	}
+	resp, _, err := dnsExchange(ctx, "udp", server, msg)
+	if err != nil {
+		return nil, 0, err
+	}
+	if resp.RCode != dnsmessage.RCodeSuccess {
+		return nil, 0, fmt.Errorf("dns: %s %s: %s", host, qtype, resp.RCode)
+	}
+	var ips []net.IP
+	ttl := time.Duration(-1)
+	for _, ans := range resp.Answers {
+		var ip net.IP
+		switch body := ans.Body.(type) {
+		case *dnsmessage.AResource:
+			ip = net.IP(body.A[:])
+		case *dnsmessage.AAAAResource:
+			ip = net.IP(body.AAAA[:])
+		default:
+			continue // CNAME chain
+		}
+		ips = append(ips, ip)
+		if t := time.Duration(ans.Header.TTL) * time.Second; ttl < 0 || t < ttl {
+			ttl = t
+		}
+	}
+	return ips, ttl, nil
+}
+
+// systemNameservers reads the nameserver lines of a resolv.conf file.
+func systemNameservers(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("dns-cache: cannot read nameservers (set dns-cache.servers): %v", err)
+	}
+	defer func() { _ = f.Close() }()
+	var servers []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			servers = append(servers, fields[1])
+		}
+	}
+	if len(servers) == 0 {
+		return nil, fmt.Errorf("dns-cache: no nameserver in %s (set dns-cache.servers)", path)
+	}
+	return servers, sc.Err()
+}
+
+// dialCached dials the cached addresses of host the way net.Dialer dials a resolved name: IPv6 addresses first,
+// IPv4 ones raced after dialer.FallbackDelay (happy eyeballs) or, when it is negative, tried after them.
+func dialCached(ctx context.Context, cache *DNSCache, dialer *net.Dialer, network, host, port string) (net.Conn, error) {
+	ips, err := cache.LookupIP(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	var primaries, fallbacks []string
+	for _, ip := range ips {
+		addr := net.JoinHostPort(ip.String(), port)
+		if ip.To4() == nil {
+			if network != "tcp4" {
+				primaries = append(primaries, addr)
+			}
+		} else if network != "tcp6" {
+			fallbacks = append(fallbacks, addr)
+		}
+	}
+	if dialer.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, dialer.Timeout)
+		defer cancel()
+	}
+	d := *dialer
+	d.Timeout = 0 // the deadline is on ctx, shared among the addresses
+	if len(primaries) == 0 || len(fallbacks) == 0 || d.FallbackDelay < 0 {
+		return dialSerial(ctx, &d, network, host, append(primaries, fallbacks...))
+	}
+	return dialParallel(ctx, &d, network, host, primaries, fallbacks)
+}
+
+// dialParallel races the IPv4 addresses against the IPv6 ones, starting them after the fallback delay or as soon
+// as the IPv6 ones failed; the first connection wins.
+func dialParallel(ctx context.Context, dialer *net.Dialer, network, host string, primaries, fallbacks []string) (net.Conn, error) {
+	type dialResult struct {
+		conn    net.Conn
+		err     error
+		primary bool
+	}
+	returned := make(chan struct{})
+	defer close(returned)
+	results := make(chan dialResult)
+	start := func(ctx context.Context, addrs []string, primary bool) {
+		conn, err := dialSerial(ctx, dialer, network, host, addrs)
+		select {
+		case results <- dialResult{conn: conn, err: err, primary: primary}:
+		case <-returned:
+			if conn != nil {
+				_ = conn.Close()
+			}
+		}
+	}
+
+	primaryCtx, primaryCancel := context.WithCancel(ctx)
+	defer primaryCancel()
+	go start(primaryCtx, primaries, true)
+
+	fallbackCtx, fallbackCancel := context.WithCancel(ctx)
+	defer fallbackCancel()
+	delay := dialer.FallbackDelay
+	if delay == 0 {
+		delay = 300 * time.Millisecond // net.Dialer's default
+	}
+	fallbackTimer := time.NewTimer(delay)
+	defer fallbackTimer.Stop()
+
+	var primaryErr error
+	pending := 2
+	for {
+		select {
+		case <-fallbackTimer.C:
+			go start(fallbackCtx, fallbacks, false)
+		case res := <-results:
+			if res.err == nil {
+				return res.conn, nil
+			}
+			if res.primary {
+				primaryErr = res.err
+				if fallbackTimer.Stop() {
+					fallbackTimer.Reset(0)
+				}
+			}
+			if pending--; pending == 0 {
+				return nil, primaryErr
+			}
+		}
+	}
+}
+
+// dialSerial tries the addresses in order; like net.Dialer, each gets an equal share of the time left.
+func dialSerial(ctx context.Context, dialer *net.Dialer, network, host string, addrs []string) (net.Conn, error) {
+	if len(addrs) == 0 {
+		return nil, &net.AddrError{Err: "no suitable address found", Addr: host}
+	}
+	var lastErr error
+	for i, addr := range addrs {
+		dialCtx, cancel := ctx, context.CancelFunc(func() {})
+		if deadline, ok := ctx.Deadline(); ok {
+			left := time.Until(deadline)
+			share := left / time.Duration(len(addrs)-i)
+			if share < 2*time.Second {
+				share = min(2*time.Second, left)
+			}
+			dialCtx, cancel = context.WithTimeout(ctx, share)
+		}
+		conn, err := dialer.DialContext(dialCtx, network, addr)
+		cancel()
+		if err == nil {
+			return conn, nil
+		}
+		lastErr = err
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, lastErr
+}
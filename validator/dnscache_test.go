@@ -0,0 +1,164 @@
+package validator
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+)
+
+// fakeDNS answers A queries with 127.0.0.1 and the given TTL, AAAA with nothing.
+func fakeDNS(t *testing.T, ttl uint32) (string, *atomic.Int32) {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { _ = pc.Close() })
+	var queries atomic.Int32
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, rErr := pc.ReadFrom(buf)
+			if rErr != nil {
+				return
+			}
+			var req dnsmessage.Message
+			if req.Unpack(buf[:n]) != nil || len(req.Questions) != 1 {
+				continue
+			}
+			q := req.Questions[0]
+			resp := dnsmessage.Message{
+				Header:    dnsmessage.Header{ID: req.ID, Response: true, RecursionAvailable: true},
+				Questions: req.Questions,
+			}
+			if q.Type == dnsmessage.TypeA {
+				queries.Add(1)
+				resp.Answers = []dnsmessage.Resource{{
+					Header: dnsmessage.ResourceHeader{Name: q.Name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET, TTL: ttl},
+					Body:   &dnsmessage.AResource{A: [4]byte{127, 0, 0, 1}},
+				}}
+			}
+			out, _ := resp.Pack()
+			_, _ = pc.WriteTo(out, addr)
+		}
+	}()
+	return pc.LocalAddr().String(), &queries
+}
+
+func TestDNSCache_CachesForClampedTTL(t *testing.T) {
+	server, queries := fakeDNS(t, 3600)
+	c, err := NewDNSCache(config.DNSCacheConfig{MinTTL: time.Second, MaxTTL: time.Minute, Servers: []string{server}})
+	assert.NoError(t, err)
+	now := time.Unix(1000, 0)
+	c.now = func() time.Time { return now }
+
+	ips, err := c.LookupIP(context.Background(), "probe.test")
+	assert.NoError(t, err)
+	assert.Equal(t, "127.0.0.1", ips[0].String())
+	_, _ = c.LookupIP(context.Background(), "probe.test")
+	assert.Equal(t, int32(1), queries.Load(), "served from cache")
+
+	now = now.Add(59 * time.Second)
+	_, _ = c.LookupIP(context.Background(), "probe.test")
+	assert.Equal(t, int32(1), queries.Load())
+
+	now = now.Add(2 * time.Second) // past max-ttl even though the record says 1h
+	_, _ = c.LookupIP(context.Background(), "probe.test")
+	assert.Equal(t, int32(2), queries.Load())
+}
+
+func TestDNSCache_MinTTL(t *testing.T) {
+	server, queries := fakeDNS(t, 0)
+	c, err := NewDNSCache(config.DNSCacheConfig{MinTTL: 10 * time.Second, MaxTTL: time.Minute, Servers: []string{server}})
+	assert.NoError(t, err)
+	now := time.Unix(1000, 0)
+	c.now = func() time.Time { return now }
+
+	_, _ = c.LookupIP(context.Background(), "probe.test")
+	now = now.Add(9 * time.Second)
+	_, _ = c.LookupIP(context.Background(), "probe.test")
+	assert.Equal(t, int32(1), queries.Load(), "a zero TTL is raised to min-ttl")
+}
+
+func TestSystemNameservers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "resolv.conf")
+	assert.NoError(t, os.WriteFile(path, []byte("# comment\nsearch example.com\nnameserver 10.0.0.1\nnameserver ::1\n"), 0o644))
+	servers, err := systemNameservers(path)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.1", "::1"}, servers)
+
+	assert.NoError(t, os.WriteFile(path, []byte("search example.com\n"), 0o644))
+	_, err = systemNameservers(path)
+	assert.Error(t, err)
+}
+
+func TestDialCached_DualStack(t *testing.T) {
+	v6, err := net.Listen("tcp6", "[::1]:0")
+	if err != nil {
+		t.Skip("no IPv6 loopback")
+	}
+	defer func() { _ = v6.Close() }()
+	_, v6Port, _ := net.SplitHostPort(v6.Addr().String())
+	v4, err := net.Listen("tcp4", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer func() { _ = v4.Close() }()
+	_, v4Port, _ := net.SplitHostPort(v4.Addr().String())
+
+	cache, err := NewDNSCache(config.DNSCacheConfig{MinTTL: time.Second, MaxTTL: time.Minute, Servers: []string{"127.0.0.1:1"}})
+	assert.NoError(t, err)
+	cache.query = func(_ context.Context, _ string) ([]net.IP, time.Duration, error) {
+		// A records first, as queryServers returns them
+		return []net.IP{net.ParseIP("127.0.0.1"), net.ParseIP("::1")}, time.Minute, nil
+	}
+
+	tests := []struct {
+		name          string
+		port          string
+		fallbackDelay time.Duration
+		wantNetworks  []string
+		wantIP        string
+	}{
+		{"happy eyeballs: IPv6 first", v6Port, 0, []string{"tcp6"}, "::1"},
+		{"happy eyeballs off: IPv6 first", v6Port, -1, []string{"tcp6"}, "::1"},
+		{"IPv6 refused: IPv4 started at once", v4Port, time.Minute, []string{"tcp6", "tcp4"}, "127.0.0.1"},
+		{"happy eyeballs off: IPv4 after IPv6", v4Port, -1, []string{"tcp6", "tcp4"}, "127.0.0.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var networks []string
+			dialer := &net.Dialer{
+				Timeout:       5 * time.Second,
+				FallbackDelay: tt.fallbackDelay,
+				ControlContext: func(_ context.Context, network, _ string, _ syscall.RawConn) error {
+					mu.Lock()
+					defer mu.Unlock()
+					networks = append(networks, network)
+					return nil
+				},
+			}
+			conn, err := dialCached(context.Background(), cache, dialer, "tcp", "dual.test", tt.port)
+			assert.NoError(t, err)
+			if err != nil {
+				return
+			}
+			defer func() { _ = conn.Close() }()
+			host, _, _ := net.SplitHostPort(conn.RemoteAddr().String())
+			assert.Equal(t, tt.wantIP, host)
+			mu.Lock()
+			defer mu.Unlock()
+			assert.Equal(t, tt.wantNetworks, networks)
+		})
+	}
+}
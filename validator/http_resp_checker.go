@@ -1,17 +1,32 @@
 package validator
 
 import (
+	"bufio"
+	"bytes"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"regexp"
-	"watchdog_exporter/config"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+	"github.com/kinjelom/watchdog_exporter/status"
 )
 
 // HTTPResponseChecker is responsible for validating HTTP response
 // (status code, headers, optional body regex). It MUST NOT close resp.Body;
 type HTTPResponseChecker interface {
-	ValidateResponse(reqURL, routeName string, resp *http.Response, responseBodyLimit int64, v config.EndpointValidation) (status string, err error)
+	ValidateResponse(reqURL, routeName string, resp *http.Response, responseBodyLimit int64, v config.EndpointValidation) (st status.Status, matches ResponseMatches, err error)
+}
+
+// ResponseMatches reports the outcome of each content check independently of the
+// consolidated status. A nil field means the check is not configured.
+type ResponseMatches struct {
+	Header *bool
+	Body   *bool
 }
 
 // DefaultHTTPResponseChecker is a production-ready implementation
@@ -26,47 +41,262 @@ func NewDefaultHTTPResponseChecker(debug bool) *DefaultHTTPResponseChecker {
 	}
 }
 
-func (c *DefaultHTTPResponseChecker) ValidateResponse(reqURL, routeName string, resp *http.Response, responseBodyLimit int64, v config.EndpointValidation) (string, error) {
+// ValidateResponse evaluates every configured check (so matches are always complete)
+// and returns the status of the first failing one: status code, final URL, HTTP version, headers, body,
+// forbidden body content, JSON paths, freshness.
+func (c *DefaultHTTPResponseChecker) ValidateResponse(reqURL, routeName string, resp *http.Response, responseBodyLimit int64, v config.EndpointValidation) (status.Status, ResponseMatches, error) {
+	var matches ResponseMatches
+	st := status.Valid
+	debug := c.Debug || debugRequested(resp.Request)
+
 	if resp.StatusCode != v.StatusCode {
-		if c.Debug {
-			log.Printf("unexpected-status-code: %s / '%s', expected '%d', got '%d'", reqURL, routeName, v.StatusCode, resp.StatusCode)
+		if debug {
+			slog.Info("unexpected status code", "status", status.UnexpectedStatusCode, "url", reqURL, "route", routeName, "expected", v.StatusCode, "got", resp.StatusCode)
 		}
-		return "unexpected-status-code", nil
+		st = status.UnexpectedStatusCode
 	}
 
-	for k, expected := range v.Headers {
-		got := resp.Header.Get(k)
-		if got != expected {
-			if c.Debug {
-				log.Printf("unexpected-header-value: %s / '%s', expected '%s', got '%s'", reqURL, routeName, expected, got)
+	if v.ExpectedFinalURLRegex != "" {
+		final := reqURL
+		if resp.Request != nil && resp.Request.URL != nil {
+			final = resp.Request.URL.String()
+		}
+		if re, reErr := compiledRegex(v.ExpectedFinalURLRegex); reErr != nil || !re.MatchString(final) {
+			if debug {
+				slog.Info("unexpected final URL", "status", status.UnexpectedFinalURL, "url", reqURL, "route", routeName, "regex", v.ExpectedFinalURLRegex, "got", final)
+			}
+			if st == status.Valid {
+				st = status.UnexpectedFinalURL
 			}
-			return "unexpected-header-value", nil
 		}
 	}
 
-	if v.BodyRegex != "" {
-		reader := io.LimitReader(resp.Body, responseBodyLimit)
-		body, readErr := io.ReadAll(reader)
-		if readErr != nil {
-			if isTimeoutErr(readErr) {
-				if c.Debug {
-					log.Printf("request-execution-timeout: %s / '%s', body read error: %v", reqURL, routeName, readErr)
+	if v.ExpectedALPN != "" {
+		if got := negotiatedProtocol(resp); got != v.ExpectedALPN {
+			if debug {
+				slog.Info("unexpected HTTP version", "status", status.UnexpectedHTTPVersion, "url", reqURL, "route", routeName, "expected", v.ExpectedALPN, "got", got)
+			}
+			if st == status.Valid {
+				st = status.UnexpectedHTTPVersion
+			}
+		}
+	}
+
+	if len(v.Headers) > 0 || len(v.HeadersRegex) > 0 || len(v.HeadersAbsent) > 0 {
+		headerOK := true
+		headerSt := status.UnexpectedHeaderValue
+		for k, expected := range v.Headers {
+			got := resp.Header.Get(k)
+			if got != expected {
+				if debug {
+					slog.Info("unexpected header value", "status", status.UnexpectedHeaderValue, "url", reqURL, "route", routeName, "header", k, "expected", expected, "got", got)
 				}
-				return "request-execution-timeout", readErr
+				headerOK = false
+				break
 			}
-			if c.Debug {
-				log.Printf("request-execution-error: %s / '%s', body read error: %v", reqURL, routeName, readErr)
+		}
+		for k, pattern := range v.HeadersRegex {
+			if !headerOK {
+				break
+			}
+			// a missing header never matches, not even a pattern that accepts the empty string
+			got, present := resp.Header[http.CanonicalHeaderKey(k)]
+			re, reErr := compiledRegex(pattern)
+			if !present || reErr != nil || !re.MatchString(got[0]) {
+				if debug {
+					slog.Info("unexpected header value", "status", status.UnexpectedHeaderValue, "url", reqURL, "route", routeName, "header", k, "regex", pattern, "got", resp.Header.Get(k))
+				}
+				headerOK = false
+			}
+		}
+		for _, k := range v.HeadersAbsent {
+			if !headerOK {
+				break
+			}
+			if got, present := resp.Header[http.CanonicalHeaderKey(k)]; present {
+				if debug {
+					slog.Info("unexpected header present", "status", status.UnexpectedHeaderPresent, "url", reqURL, "route", routeName, "header", k, "got", got)
+				}
+				headerOK = false
+				headerSt = status.UnexpectedHeaderPresent
 			}
-			return "request-execution-error", readErr
 		}
-		matched, _ := regexp.Match(v.BodyRegex, body)
+		matches.Header = &headerOK
+		if !headerOK && st == status.Valid {
+			st = headerSt
+		}
+	}
+
+	// Forbidden content, JSON assertions and a freshness timestamp from the body need the whole (limited) body: then it
+	// is read once and kept.
+	var bodyText []byte
+	buffered := v.BodyNotRegex != "" || len(v.JSONPath) > 0 || (v.Freshness != nil && v.Freshness.Source == config.FreshnessBody)
+	if buffered {
+		body := &errReader{r: io.LimitReader(resp.Body, responseBodyLimit)}
+		bodyText, _ = io.ReadAll(body)
+		if body.err != nil {
+			return readFailure(debug, reqURL, routeName, body.err), matches, body.err
+		}
+	}
+
+	if v.BodyRegex != "" {
+		// Stream the (limited) body through the regex instead of buffering it; only debug output keeps a copy.
+		body := &errReader{r: io.LimitReader(resp.Body, responseBodyLimit)}
+		if buffered {
+			body = &errReader{r: bytes.NewReader(bodyText)}
+		}
+		var seen *bytes.Buffer
+		var src io.Reader = body
+		if debug {
+			seen = new(bytes.Buffer)
+			src = io.TeeReader(body, seen)
+		}
+		matched := false
+		if re, reErr := compiledRegex(v.BodyRegex); reErr == nil {
+			matched = re.MatchReader(bufio.NewReaderSize(src, bodyReadBufferSize))
+		}
+		if readErr := body.err; readErr != nil {
+			return readFailure(debug, reqURL, routeName, readErr), matches, readErr
+		}
+		matches.Body = &matched
 		if !matched {
-			if c.Debug {
-				log.Printf("unexpected-body-regex: %s / '%s', expected regex '%s', got ---\n%s\n---", reqURL, routeName, v.BodyRegex, body)
+			if debug {
+				slog.Info("body does not match", "status", status.UnexpectedBodyRegex, "url", reqURL, "route", routeName, "regex", v.BodyRegex, "body", seen.String())
+			}
+			if st == status.Valid {
+				st = status.UnexpectedBodyRegex
 			}
-			return "unexpected-body-regex", nil
 		}
 	}
 
-	return "valid", nil
+	if v.BodyNotRegex != "" {
+		if re, reErr := compiledRegex(v.BodyNotRegex); reErr == nil {
+			if loc := re.FindIndex(bodyText); loc != nil {
+				if debug {
+					slog.Info("body contains forbidden content", "status", status.ForbiddenBodyRegex, "url", reqURL, "route", routeName, "regex", v.BodyNotRegex, "match", string(bodyText[loc[0]:loc[1]]))
+				}
+				if st == status.Valid {
+					st = status.ForbiddenBodyRegex
+				}
+			}
+		}
+	}
+
+	if len(v.JSONPath) > 0 {
+		failed, found, jsonErr := checkJSONPaths(bodyText, v.JSONPath)
+		if failed != nil || jsonErr != nil {
+			if debug {
+				attrs := []any{"status", status.UnexpectedJSONPath, "url", reqURL, "route", routeName}
+				if failed != nil {
+					attrs = append(attrs, "path", failed.Path, "found", found)
+				}
+				slog.Info("json path assertion failed", append(attrs, "err", jsonErr)...)
+			}
+			if st == status.Valid {
+				st = status.UnexpectedJSONPath
+			}
+		}
+	}
+
+	if fresh := v.Freshness; fresh != nil && fresh.MaxAge > 0 {
+		ts, ok := contentTime(resp.Header, bodyText, *fresh)
+		if !ok || time.Since(ts) > fresh.MaxAge {
+			if debug {
+				slog.Info("stale content", "status", status.StaleContent, "url", reqURL, "route", routeName, "source", fresh.Source, "timestamp_found", ok, "timestamp", ts, "max_age", fresh.MaxAge)
+			}
+			if st == status.Valid {
+				st = status.StaleContent
+			}
+		}
+	}
+
+	return st, matches, nil
+}
+
+// negotiatedProtocol names the response protocol as an ALPN identifier: the TLS negotiated one when set,
+// otherwise derived from the protocol version (no ALPN means HTTP/1.1).
+func negotiatedProtocol(resp *http.Response) string {
+	if resp.TLS != nil && resp.TLS.NegotiatedProtocol != "" {
+		return resp.TLS.NegotiatedProtocol
+	}
+	if resp.ProtoMajor == 2 {
+		return config.ALPNHTTP2
+	}
+	return config.ALPNHTTP11
+}
+
+// readFailure logs and classifies an error while reading the body.
+func readFailure(debug bool, reqURL, routeName string, err error) status.Status {
+	if isTimeoutErr(err) {
+		if debug {
+			slog.Info("body read timed out", "status", status.RequestExecutionTimeout, "url", reqURL, "route", routeName, "err", err)
+		}
+		return status.RequestExecutionTimeout
+	}
+	if debug {
+		slog.Info("body read failed", "status", status.RequestExecutionError, "url", reqURL, "route", routeName, "err", err)
+	}
+	return status.RequestExecutionError
+}
+
+// contentTime extracts the content timestamp named by the freshness source; false when it is missing or unparsable.
+func contentTime(h http.Header, body []byte, f config.Freshness) (time.Time, bool) {
+	switch f.Source {
+	case config.FreshnessLastModified, config.FreshnessDate:
+		name := "Last-Modified"
+		if f.Source == config.FreshnessDate {
+			name = "Date"
+		}
+		ts, err := http.ParseTime(h.Get(name))
+		return ts, err == nil
+	case config.FreshnessBody:
+		re, err := compiledRegex(f.BodyRegex)
+		if err != nil {
+			return time.Time{}, false
+		}
+		m := re.FindSubmatch(body)
+		if len(m) < 2 {
+			return time.Time{}, false
+		}
+		raw := strings.TrimSpace(string(m[1]))
+		if f.Layout == "unix" {
+			sec, pErr := strconv.ParseInt(raw, 10, 64)
+			return time.Unix(sec, 0), pErr == nil
+		}
+		ts, pErr := time.Parse(f.Layout, raw)
+		return ts, pErr == nil
+	}
+	return time.Time{}, false
+}
+
+// bodyReadBufferSize is the window the body regex reads through.
+const bodyReadBufferSize = 4 << 10
+
+// errReader remembers the first read error other than io.EOF (regexp.MatchReader treats any error as the end).
+type errReader struct {
+	r   io.Reader
+	err error
+}
+
+func (e *errReader) Read(p []byte) (int, error) {
+	n, err := e.r.Read(p)
+	if err != nil && err != io.EOF && e.err == nil {
+		e.err = err
+	}
+	return n, err
+}
+
+var regexCache sync.Map // pattern -> *regexp.Regexp
+
+// compiledRegex compiles each distinct pattern once.
+func compiledRegex(pattern string) (*regexp.Regexp, error) {
+	if re, ok := regexCache.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	regexCache.Store(pattern, re)
+	return re, nil
 }
@@ -0,0 +1,154 @@
+package validator
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+)
+
+// jsonStep is one segment of a parsed path: an object key, or an array index when isIndex.
+type jsonStep struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+var jsonPathCache sync.Map // path -> []jsonStep
+
+// parseJSONPath supports the subset health checks need: $, .key, ['key'] / ["key"] and [index] (negative counts
+// from the end). Wildcards, slices and filters are rejected.
+func parseJSONPath(path string) ([]jsonStep, error) {
+	if steps, ok := jsonPathCache.Load(path); ok {
+		return steps.([]jsonStep), nil
+	}
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("json path %q must start with $", path)
+	}
+	var steps []jsonStep
+	rest := path[1:]
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			end := strings.IndexAny(rest[1:], ".[")
+			if end < 0 {
+				end = len(rest) - 1
+			}
+			key := rest[1 : 1+end]
+			if key == "" || key == "*" {
+				return nil, fmt.Errorf("json path %q: empty or wildcard key", path)
+			}
+			steps = append(steps, jsonStep{key: key})
+			rest = rest[1+end:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("json path %q: unclosed [", path)
+			}
+			inner := rest[1:end]
+			if len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0] {
+				steps = append(steps, jsonStep{key: inner[1 : len(inner)-1]})
+			} else if n, err := strconv.Atoi(inner); err == nil {
+				steps = append(steps, jsonStep{index: n, isIndex: true})
+			} else {
+				return nil, fmt.Errorf("json path %q: unsupported selector [%s]", path, inner)
+			}
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("json path %q: unexpected %q", path, rest[0])
+		}
+	}
+	jsonPathCache.Store(path, steps)
+	return steps, nil
+}
+
+// lookupJSON walks the steps through a document decoded with UseNumber; false when a step does not exist.
+func lookupJSON(doc any, steps []jsonStep) (any, bool) {
+	cur := doc
+	for _, s := range steps {
+		switch node := cur.(type) {
+		case map[string]any:
+			if s.isIndex {
+				return nil, false
+			}
+			v, ok := node[s.key]
+			if !ok {
+				return nil, false
+			}
+			cur = v
+		case []any:
+			if !s.isIndex {
+				return nil, false
+			}
+			i := s.index
+			if i < 0 {
+				i += len(node)
+			}
+			if i < 0 || i >= len(node) {
+				return nil, false
+			}
+			cur = node[i]
+		default:
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+// jsonText renders a value for comparison: strings as they are, numbers as written, everything else as compact JSON.
+func jsonText(v any) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case json.Number:
+		return t.String()
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// checkJSONPaths evaluates the assertions against body in order. It returns the first failing one with what was
+// found there (nil when all pass), and an error when the body is not JSON or a path or regex is invalid.
+func checkJSONPaths(body []byte, assertions []config.JSONPathAssertion) (failed *config.JSONPathAssertion, found string, err error) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var doc any
+	if err = dec.Decode(&doc); err != nil {
+		return nil, "", fmt.Errorf("response body is not JSON: %w", err)
+	}
+	for i := range assertions {
+		a := &assertions[i]
+		steps, pErr := parseJSONPath(a.Path)
+		if pErr != nil {
+			return a, "", pErr
+		}
+		v, exists := lookupJSON(doc, steps)
+		if a.Exists != nil && !*a.Exists {
+			if exists {
+				return a, jsonText(v), nil
+			}
+			continue
+		}
+		if !exists {
+			return a, "<missing>", nil
+		}
+		text := jsonText(v)
+		if a.Value != nil && text != *a.Value {
+			return a, text, nil
+		}
+		if a.Regex != "" {
+			re, reErr := compiledRegex(a.Regex)
+			if reErr != nil {
+				return a, text, reErr
+			}
+			if !re.MatchString(text) {
+				return a, text, nil
+			}
+		}
+	}
+	return nil, "", nil
+}
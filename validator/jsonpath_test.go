@@ -0,0 +1,89 @@
+package validator
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+	"github.com/kinjelom/watchdog_exporter/status"
+)
+
+const actuatorHealth = `{"status":"UP","components":{"db":{"status":"UP","details":{"database":"PostgreSQL"}},
+"disk Space":{"status":"UP","details":{"free":1073741824}}},"groups":["liveness","readiness"],"degraded":false}`
+
+func TestParseJSONPath(t *testing.T) {
+	steps, err := parseJSONPath(`$.components['disk Space'].details["free"]`)
+	assert.NoError(t, err)
+	assert.Equal(t, []jsonStep{{key: "components"}, {key: "disk Space"}, {key: "details"}, {key: "free"}}, steps)
+
+	steps, err = parseJSONPath(`$.groups[-1]`)
+	assert.NoError(t, err)
+	assert.Equal(t, []jsonStep{{key: "groups"}, {index: -1, isIndex: true}}, steps)
+
+	for _, bad := range []string{"status", "$.items[*]", "$.a..b", "$.items[?(@.x)]", "$.a[0"} {
+		_, err = parseJSONPath(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestCheckJSONPaths(t *testing.T) {
+	str := func(s string) *string { return &s }
+	no := false
+	tests := []struct {
+		name       string
+		assertion  config.JSONPathAssertion
+		wantFailed bool
+		wantFound  string
+	}{
+		{"equals string", config.JSONPathAssertion{Path: "$.status", Value: str("UP")}, false, ""},
+		{"differs", config.JSONPathAssertion{Path: "$.components.db.status", Value: str("DOWN")}, true, "UP"},
+		{"number as written", config.JSONPathAssertion{Path: "$.components['disk Space'].details.free", Value: str("1073741824")}, false, ""},
+		{"bool", config.JSONPathAssertion{Path: "$.degraded", Value: str("false")}, false, ""},
+		{"regex", config.JSONPathAssertion{Path: "$.components.db.details.database", Regex: "^Postgre"}, false, ""},
+		{"array index", config.JSONPathAssertion{Path: "$.groups[1]", Value: str("readiness")}, false, ""},
+		{"exists implied", config.JSONPathAssertion{Path: "$.components.redis"}, true, "<missing>"},
+		{"must be absent", config.JSONPathAssertion{Path: "$.error", Exists: &no}, false, ""},
+		{"present but must be absent", config.JSONPathAssertion{Path: "$.groups", Exists: &no}, true, `["liveness","readiness"]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			failed, found, err := checkJSONPaths([]byte(actuatorHealth), []config.JSONPathAssertion{tt.assertion})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantFailed, failed != nil)
+			assert.Equal(t, tt.wantFound, found)
+		})
+	}
+
+	_, _, err := checkJSONPaths([]byte("<html>maintenance</html>"), []config.JSONPathAssertion{{Path: "$.status"}})
+	assert.Error(t, err)
+}
+
+func TestHTTPResponseChecker_JSONPath(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, actuatorHealth)
+	}))
+	defer srv.Close()
+	up, down := "UP", "DOWN"
+
+	for _, tt := range []struct {
+		value *string
+		want  status.Status
+	}{{&up, status.Valid}, {&down, status.UnexpectedJSONPath}} {
+		resp, err := http.Get(srv.URL)
+		assert.NoError(t, err)
+		st, matches, err := NewDefaultHTTPResponseChecker(false).ValidateResponse(srv.URL, "r1", resp, 4096, config.EndpointValidation{
+			StatusCode: http.StatusOK,
+			BodyRegex:  `"status"`,
+			JSONPath:   []config.JSONPathAssertion{{Path: "$.status", Value: tt.value}},
+		})
+		_ = resp.Body.Close()
+		assert.NoError(t, err)
+		assert.Equal(t, tt.want, st)
+		assert.True(t, *matches.Body, "the body regex sees the buffered body")
+	}
+}
@@ -0,0 +1,87 @@
+package validator
+
+import (
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// Phases are the durations of an HTTP probe's phases in seconds. A phase the probe did not go through (a reused
+// connection, a cached address, plain HTTP) is 0; with redirects, the first request's phases are kept.
+type Phases struct {
+	DNS     float64 // name resolution
+	Connect float64 // TCP connection (the first attempt to the established one, with happy eyeballs)
+	TLS     float64 // TLS handshake
+	TTFB    float64 // from sending the request to the first response byte, the above included
+}
+
+// phaseTrace records Phases from the httptrace callbacks, which the dialer may run concurrently.
+type phaseTrace struct {
+	mu                               sync.Mutex
+	start                            time.Time
+	dnsStart, connectStart, tlsStart time.Time
+	phases                           Phases
+}
+
+// hook adds the phase callbacks to trace; start is when the request is sent.
+func (p *phaseTrace) hook(trace *httptrace.ClientTrace, start time.Time) {
+	p.start = start
+	since := func(t time.Time) float64 { return time.Since(t).Seconds() }
+	trace.DNSStart = func(httptrace.DNSStartInfo) {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if p.dnsStart.IsZero() {
+			p.dnsStart = time.Now()
+		}
+	}
+	trace.DNSDone = func(httptrace.DNSDoneInfo) {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if p.phases.DNS == 0 && !p.dnsStart.IsZero() {
+			p.phases.DNS = since(p.dnsStart)
+		}
+	}
+	trace.ConnectStart = func(string, string) {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if p.connectStart.IsZero() {
+			p.connectStart = time.Now()
+		}
+	}
+	trace.ConnectDone = func(_, _ string, err error) {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if err == nil && p.phases.Connect == 0 && !p.connectStart.IsZero() {
+			p.phases.Connect = since(p.connectStart)
+		}
+	}
+	trace.TLSHandshakeStart = func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if p.tlsStart.IsZero() {
+			p.tlsStart = time.Now()
+		}
+	}
+	trace.TLSHandshakeDone = func(_ tls.ConnectionState, err error) {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if err == nil && p.phases.TLS == 0 && !p.tlsStart.IsZero() {
+			p.phases.TLS = since(p.tlsStart)
+		}
+	}
+	trace.GotFirstResponseByte = func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if p.phases.TTFB == 0 {
+			p.phases.TTFB = since(p.start)
+		}
+	}
+}
+
+// result returns the phases recorded so far.
+func (p *phaseTrace) result() Phases {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.phases
+}
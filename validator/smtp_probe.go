@@ -0,0 +1,199 @@
+package validator
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+	"github.com/kinjelom/watchdog_exporter/status"
+)
+
+// ProbeSMTP connects to the mail server in rc.URL (smtp://host[:port], or smtps:// for implicit TLS; the route's
+// target-ip replaces the host for dialing), validates the banner, says EHLO and upgrades with STARTTLS when
+// required. The negotiated chain goes through TLSChecker.Inspect like an HTTPS response.
+func (m *WatchDogValidator) ProbeSMTP(endpointName string, rc config.EndpointRequest, routeName string, route config.Route, sp config.SMTPProbe, checkCerts bool) (Report, error) {
+	debug := m.debug || m.debugSwitch.Enabled(endpointName)
+	u, err := url.Parse(rc.URL)
+	if err == nil && ((u.Scheme != "smtp" && u.Scheme != "smtps") || u.Hostname() == "") {
+		err = fmt.Errorf("expected smtp://host[:port] or smtps://host[:port], got %q", rc.URL)
+	}
+	if err != nil {
+		slog.Error("failed to parse SMTP server URL", "status", status.InvalidURL, "url", rc.URL, "err", err)
+		return Report{Status: status.InvalidURL}, err
+	}
+	if route.ProxyUrl != "" || route.TargetSocket != "" {
+		err = errors.New("smtp probes cannot use a proxy or a target-socket")
+		slog.Error("invalid route for an smtp endpoint", "status", status.InvalidProxyDefinition, "route", routeName, "err", err)
+		return Report{Status: status.InvalidProxyDefinition}, err
+	}
+	implicitTLS := u.Scheme == "smtps"
+	host, port := u.Hostname(), u.Port()
+	if port == "" {
+		port = "25"
+		if implicitTLS {
+			port = "465"
+		}
+	}
+	dialHost := host
+	if route.TargetIP != "" {
+		dialHost = route.TargetIP
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), rc.Timeout)
+	defer cancel()
+	start := time.Now()
+	var rep Report
+	fail := func(st status.Status, err error) (Report, error) {
+		rep.Duration = time.Since(start).Seconds()
+		if st == "" {
+			st = status.InvalidRequestExecution
+			if isTimeoutErr(err) {
+				st = status.RequestExecutionTimeout
+			}
+		}
+		if debug {
+			slog.Info("smtp probe failed", "status", st, "url", rc.URL, "route", routeName, "err", err)
+		}
+		rep.Status = st
+		return rep, err
+	}
+
+	tlsConfig, err := m.tlsClientConfig(rc, host)
+	if err != nil {
+		return fail(status.InvalidRequestDefinition, err)
+	}
+	conn, err := m.dialTCP(ctx, rc, dialHost, port)
+	if err != nil {
+		return fail("", err)
+	}
+	defer func() { _ = conn.Close() }()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	if remote, _, splitErr := net.SplitHostPort(conn.RemoteAddr().String()); splitErr == nil {
+		rep.RemoteIP = remote
+	}
+
+	var tlsConn *tls.Conn
+	handshake := func() error {
+		tlsConn = tls.Client(conn, tlsConfig)
+		return tlsConn.HandshakeContext(ctx)
+	}
+	if implicitTLS {
+		if err = handshake(); err != nil {
+			return fail(m.tlsFailure(err), err)
+		}
+		conn = tlsConn
+	}
+
+	tp := textproto.NewConn(conn)
+	_, banner, err := tp.ReadResponse(220)
+	if err != nil {
+		return fail(smtpReplyFailure(err), err)
+	}
+	st := status.Valid
+	if sp.BannerRegex != "" {
+		re, reErr := compiledRegex(sp.BannerRegex)
+		if reErr != nil {
+			return fail(status.InvalidRequestDefinition, reErr)
+		}
+		if !re.MatchString(banner) {
+			if debug {
+				slog.Info("unexpected smtp banner", "status", status.UnexpectedBanner, "url", rc.URL, "route", routeName, "regex", sp.BannerRegex, "banner", banner)
+			}
+			st = status.UnexpectedBanner
+		}
+	}
+	_, extensions, err := smtpCmd(tp, 250, "EHLO %s", sp.EHLO)
+	if err != nil {
+		return fail(smtpReplyFailure(err), err)
+	}
+
+	if !implicitTLS && sp.StartTLSRequired() {
+		if !smtpHasExtension(extensions, "STARTTLS") {
+			rep.TLS = &CertsReport{HadTLS: false}
+			return fail(status.InvalidTLSMissing, errors.New("smtp: STARTTLS not offered"))
+		}
+		if _, _, err = smtpCmd(tp, 220, "STARTTLS"); err != nil {
+			return fail(smtpReplyFailure(err), err)
+		}
+		if err = handshake(); err != nil {
+			return fail(m.tlsFailure(err), err)
+		}
+		tp = textproto.NewConn(tlsConn)
+	}
+	if tlsConn != nil && checkCerts {
+		state := tlsConn.ConnectionState()
+		// Inspect reads the handshake facts from the response, as for HTTPS.
+		certs := m.tlsChecker.Inspect(&http.Response{TLS: &state})
+		certs.Insecure = rc.TLSInsecureSkipVerify
+		rep.TLS = &certs
+	}
+	if st == status.Valid && tlsConn != nil && rc.TLSInsecureSkipVerify {
+		st = status.ValidInsecureTLS
+	}
+	_, _, _ = smtpCmd(tp, 221, "QUIT")
+
+	rep.Status = st
+	rep.Duration = time.Since(start).Seconds()
+	return rep, nil
+}
+
+// dialTCP connects to host:port honoring the dual-stack settings and the DNS cache.
+func (m *WatchDogValidator) dialTCP(ctx context.Context, rc config.EndpointRequest, host, port string) (net.Conn, error) {
+	dialer := &net.Dialer{FallbackDelay: rc.DialFallbackDelay()}
+	if m.dnsCache != nil && !rc.DNSCacheBypass && net.ParseIP(host) == nil {
+		return dialCached(ctx, m.dnsCache, dialer, "tcp", host, port)
+	}
+	return dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, port))
+}
+
+// tlsFailure classifies a failed TLS handshake ("" leaves it to the caller's defaults).
+func (m *WatchDogValidator) tlsFailure(err error) status.Status {
+	if st, ok := m.tlsChecker.CheckHandshakeError(err); ok {
+		return st
+	}
+	if isTimeoutErr(err) {
+		return status.RequestExecutionTimeout
+	}
+	return status.InvalidTLSHandshake
+}
+
+// smtpReplyFailure maps an unexpected reply code to unexpected-status-code; other errors get the defaults.
+func smtpReplyFailure(err error) status.Status {
+	if errors.As(err, new(*textproto.Error)) {
+		return status.UnexpectedStatusCode
+	}
+	return ""
+}
+
+// smtpCmd sends a command and reads its reply, which must have the expected code.
+func smtpCmd(tp *textproto.Conn, expectCode int, format string, args ...any) (int, string, error) {
+	id, err := tp.Cmd(format, args...)
+	if err != nil {
+		return 0, "", err
+	}
+	tp.StartResponse(id)
+	defer tp.EndResponse(id)
+	return tp.ReadResponse(expectCode)
+}
+
+// smtpHasExtension looks for an EHLO keyword (the reply lines after the greeting line).
+func smtpHasExtension(ehloReply, keyword string) bool {
+	lines := strings.Split(ehloReply, "\n")
+	for _, line := range lines[min(1, len(lines)):] {
+		if fields := strings.Fields(line); len(fields) > 0 && strings.EqualFold(fields[0], keyword) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,132 @@
+package validator
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+	"github.com/kinjelom/watchdog_exporter/status"
+)
+
+// startSMTPServer serves a minimal ESMTP dialogue; STARTTLS is offered when cert is not nil.
+func startSMTPServer(t *testing.T, banner string, cert *tls.Certificate) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+	go func() {
+		for {
+			conn, aErr := l.Accept()
+			if aErr != nil {
+				return
+			}
+			go serveSMTP(conn, banner, cert)
+		}
+	}()
+	return l.Addr().String()
+}
+
+func serveSMTP(conn net.Conn, banner string, cert *tls.Certificate) {
+	defer func() { _ = conn.Close() }()
+	tp := textproto.NewConn(conn)
+	_ = tp.PrintfLine("220 %s", banner)
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		switch verb := strings.ToUpper(strings.Fields(line + " x")[0]); {
+		case verb == "EHLO" && cert != nil:
+			_ = tp.PrintfLine("250-mail.test greets you")
+			_ = tp.PrintfLine("250 STARTTLS")
+		case verb == "EHLO":
+			_ = tp.PrintfLine("250 mail.test greets you")
+		case verb == "STARTTLS" && cert != nil:
+			_ = tp.PrintfLine("220 go ahead")
+			tlsConn := tls.Server(conn, &tls.Config{Certificates: []tls.Certificate{*cert}})
+			if tlsConn.Handshake() != nil {
+				return
+			}
+			conn, tp, cert = tlsConn, textproto.NewConn(tlsConn), nil
+		case verb == "QUIT":
+			_ = tp.PrintfLine("221 bye")
+			return
+		default:
+			_ = tp.PrintfLine("502 unknown command")
+		}
+	}
+}
+
+// smtpTLSFixture returns the httptest certificate (valid for 127.0.0.1) and a checker trusting it.
+func smtpTLSFixture(t *testing.T) (*tls.Certificate, *testTLSChecker) {
+	srv := httptest.NewTLSServer(http.NotFoundHandler())
+	t.Cleanup(srv.Close)
+	cert := srv.TLS.Certificates[0]
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+	return &cert, &testTLSChecker{rootCAs: pool, delegate: NewDefaultTLSChecker(false)}
+}
+
+func smtpRequest(addr string) config.EndpointRequest {
+	return config.EndpointRequest{URL: "smtp://" + addr, Timeout: 2 * time.Second}
+}
+
+func TestProbeSMTP_StartTLS(t *testing.T) {
+	cert, tc := smtpTLSFixture(t)
+	addr := startSMTPServer(t, "mail.test ESMTP ready", cert)
+	v := NewWatchDogValidator(tc, NewDefaultHTTPResponseChecker(false), false)
+
+	rep, err := v.ProbeSMTP("ep", smtpRequest(addr), "rt", config.Route{}, config.SMTPProbe{EHLO: "localhost", BannerRegex: "ESMTP"}, true)
+	assert.NoError(t, err)
+	assert.Equal(t, status.Valid, rep.Status)
+	assert.Equal(t, "127.0.0.1", rep.RemoteIP)
+	if assert.NotNil(t, rep.TLS) {
+		assert.True(t, rep.TLS.HadTLS)
+		assert.True(t, rep.TLS.ChainValid)
+	}
+}
+
+func TestProbeSMTP_UnexpectedBanner(t *testing.T) {
+	addr := startSMTPServer(t, "mail.test Postfix", nil)
+	v := NewWatchDogValidator(NewDefaultTLSChecker(false), NewDefaultHTTPResponseChecker(false), false)
+	noTLS := false
+
+	rep, err := v.ProbeSMTP("ep", smtpRequest(addr), "rt", config.Route{}, config.SMTPProbe{StartTLS: &noTLS, EHLO: "localhost", BannerRegex: "^mx[0-9]"}, false)
+	assert.NoError(t, err)
+	assert.Equal(t, status.UnexpectedBanner, rep.Status)
+	assert.Nil(t, rep.TLS)
+}
+
+func TestProbeSMTP_StartTLSNotOffered(t *testing.T) {
+	addr := startSMTPServer(t, "mail.test ESMTP", nil)
+	v := NewWatchDogValidator(NewDefaultTLSChecker(false), NewDefaultHTTPResponseChecker(false), false)
+
+	rep, err := v.ProbeSMTP("ep", smtpRequest(addr), "rt", config.Route{}, config.SMTPProbe{EHLO: "localhost"}, true)
+	assert.Error(t, err)
+	assert.Equal(t, status.InvalidTLSMissing, rep.Status)
+	if assert.NotNil(t, rep.TLS) {
+		assert.False(t, rep.TLS.HadTLS)
+	}
+}
+
+func TestProbeSMTP_InvalidURL(t *testing.T) {
+	v := NewWatchDogValidator(NewDefaultTLSChecker(false), NewDefaultHTTPResponseChecker(false), false)
+
+	rep, err := v.ProbeSMTP("ep", config.EndpointRequest{URL: "https://mail.test", Timeout: time.Second}, "rt", config.Route{}, config.SMTPProbe{}, false)
+	assert.Error(t, err)
+	assert.Equal(t, status.InvalidURL, rep.Status)
+}
@@ -0,0 +1,114 @@
+package validator
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql" // registers "mysql"
+	_ "github.com/lib/pq"              // registers "postgres"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+	"github.com/kinjelom/watchdog_exporter/status"
+)
+
+// ProbeSQL opens a fresh connection with the given driver (the endpoint protocol: postgres or mysql), runs q.Query
+// and validates the first column of the first row against q.ResultRegex. The connection is not pooled between
+// probes, so every probe covers connect and authentication.
+func (m *WatchDogValidator) ProbeSQL(endpointName, driver string, rc config.EndpointRequest, routeName string, route config.Route, q config.SQLProbe) (Report, error) {
+	debug := m.debug || m.debugSwitch.Enabled(endpointName)
+	if route.ProxyUrl != "" || route.TargetIP != "" || route.TargetSocket != "" {
+		err := errors.New("sql probes cannot use a proxy, a target-ip or a target-socket, the DSN names the server")
+		slog.Error("invalid route for an sql endpoint", "status", status.InvalidProxyDefinition, "route", routeName, "err", err)
+		return Report{Status: status.InvalidProxyDefinition}, err
+	}
+	dsn := q.DSN
+	if dsn == "" {
+		data, err := os.ReadFile(q.DSNFile)
+		if err != nil {
+			slog.Error("failed to read the DSN file", "status", status.InvalidRequestDefinition, "endpoint", endpointName, "err", err)
+			return Report{Status: status.InvalidRequestDefinition}, err
+		}
+		dsn = strings.TrimSpace(string(data))
+	}
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		slog.Error("invalid DSN", "status", status.InvalidRequestDefinition, "endpoint", endpointName, "err", err)
+		return Report{Status: status.InvalidRequestDefinition}, err
+	}
+	defer func() { _ = db.Close() }()
+	db.SetMaxIdleConns(0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), rc.Timeout)
+	defer cancel()
+	start := time.Now()
+	value, err := sqlFirstValue(ctx, db, q.Query)
+	rep := Report{Duration: time.Since(start).Seconds()}
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		rep.Status = status.UnexpectedResult
+		err = nil
+	case err != nil:
+		rep.Status = status.InvalidRequestExecution
+		if isTimeoutErr(err) || errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			rep.Status = status.RequestExecutionTimeout
+		}
+	case q.ResultRegex != "":
+		re, reErr := compiledRegex(q.ResultRegex)
+		if reErr != nil {
+			return Report{Status: status.InvalidRequestDefinition}, reErr
+		}
+		rep.Status = status.Valid
+		if !re.MatchString(value) {
+			rep.Status = status.UnexpectedResult
+		}
+	default:
+		rep.Status = status.Valid
+	}
+	if debug {
+		slog.Info("sql probe", "status", rep.Status, "endpoint", endpointName, "route", routeName, "result", value, "err", err)
+	}
+	return rep, err
+}
+
+// sqlFirstValue runs the query and returns the first column of the first row as text (NULL is "").
+func sqlFirstValue(ctx context.Context, db *sql.DB, query string) (string, error) {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = rows.Close() }()
+	cols, err := rows.Columns()
+	if err != nil {
+		return "", err
+	}
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return "", err
+		}
+		return "", sql.ErrNoRows
+	}
+	values := make([]any, len(cols))
+	for i := range values {
+		values[i] = new(any)
+	}
+	if err = rows.Scan(values...); err != nil {
+		return "", err
+	}
+	if len(values) == 0 {
+		return "", sql.ErrNoRows
+	}
+	switch v := (*values[0].(*any)).(type) {
+	case nil:
+		return "", nil
+	case []byte:
+		return string(v), nil
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
@@ -0,0 +1,104 @@
+package validator
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+	"github.com/kinjelom/watchdog_exporter/status"
+)
+
+// fakeSQL answers every query with the rows named by the DSN: "one" is a single row with "1", "version" a row with
+// "PostgreSQL 16.4", "empty" none; "down" refuses to connect.
+type fakeSQL struct{}
+
+type fakeSQLConn struct{ dsn string }
+
+type fakeSQLStmt struct{ dsn string }
+
+type fakeSQLRows struct{ values []string }
+
+func (fakeSQL) Open(dsn string) (driver.Conn, error) {
+	if dsn == "down" {
+		return nil, errors.New("dial tcp 127.0.0.1:5432: connect: connection refused")
+	}
+	return fakeSQLConn{dsn: dsn}, nil
+}
+
+func (c fakeSQLConn) Prepare(string) (driver.Stmt, error) { return fakeSQLStmt(c), nil }
+func (fakeSQLConn) Close() error                          { return nil }
+func (fakeSQLConn) Begin() (driver.Tx, error)             { return nil, errors.New("not supported") }
+
+func (fakeSQLStmt) Close() error  { return nil }
+func (fakeSQLStmt) NumInput() int { return 0 }
+func (fakeSQLStmt) Exec([]driver.Value) (driver.Result, error) {
+	return nil, errors.New("not supported")
+}
+func (s fakeSQLStmt) Query([]driver.Value) (driver.Rows, error) {
+	switch s.dsn {
+	case "one":
+		return &fakeSQLRows{values: []string{"1"}}, nil
+	case "version":
+		return &fakeSQLRows{values: []string{"PostgreSQL 16.4"}}, nil
+	}
+	return &fakeSQLRows{}, nil
+}
+
+func (*fakeSQLRows) Columns() []string { return []string{"value"} }
+func (*fakeSQLRows) Close() error      { return nil }
+func (r *fakeSQLRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	dest[0], r.values = []byte(r.values[0]), r.values[1:]
+	return nil
+}
+
+func init() {
+	sql.Register("fakesql", fakeSQL{})
+}
+
+func TestProbeSQL(t *testing.T) {
+	v := NewWatchDogValidator(NewDefaultTLSChecker(false), NewDefaultHTTPResponseChecker(false), false)
+	rc := config.EndpointRequest{Timeout: 2 * time.Second}
+	cases := []struct {
+		name    string
+		probe   config.SQLProbe
+		route   config.Route
+		want    status.Status
+		wantErr bool
+	}{
+		{"valid", config.SQLProbe{DSN: "one", Query: "SELECT 1"}, config.Route{}, status.Valid, false},
+		{"result matches", config.SQLProbe{DSN: "version", Query: "SELECT version()", ResultRegex: `^PostgreSQL 1[6-9]\.`}, config.Route{}, status.Valid, false},
+		{"result differs", config.SQLProbe{DSN: "version", Query: "SELECT version()", ResultRegex: `^PostgreSQL 17\.`}, config.Route{}, status.UnexpectedResult, false},
+		{"no rows", config.SQLProbe{DSN: "empty", Query: "SELECT 1 WHERE false"}, config.Route{}, status.UnexpectedResult, false},
+		{"connect fails", config.SQLProbe{DSN: "down", Query: "SELECT 1"}, config.Route{}, status.InvalidRequestExecution, true},
+		{"proxy route", config.SQLProbe{DSN: "one", Query: "SELECT 1"}, config.Route{ProxyUrl: "http://proxy:3128"}, status.InvalidProxyDefinition, true},
+		{"missing dsn file", config.SQLProbe{DSNFile: filepath.Join(t.TempDir(), "missing"), Query: "SELECT 1"}, config.Route{}, status.InvalidRequestDefinition, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rep, err := v.ProbeSQL("db", "fakesql", rc, "direct", tc.route, tc.probe)
+			assert.Equal(t, tc.want, rep.Status)
+			assert.Equal(t, tc.wantErr, err != nil, "err: %v", err)
+		})
+	}
+}
+
+func TestProbeSQL_DSNFile(t *testing.T) {
+	v := NewWatchDogValidator(NewDefaultTLSChecker(false), NewDefaultHTTPResponseChecker(false), false)
+	path := filepath.Join(t.TempDir(), "dsn")
+	assert.NoError(t, os.WriteFile(path, []byte("one\n"), 0o600))
+
+	rep, err := v.ProbeSQL("db", "fakesql", config.EndpointRequest{Timeout: time.Second}, "direct", config.Route{}, config.SQLProbe{DSNFile: path, Query: "SELECT 1"})
+	assert.NoError(t, err)
+	assert.Equal(t, status.Valid, rep.Status)
+}
@@ -0,0 +1,113 @@
+package validator
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"regexp"
+	"strings"
+
+	"github.com/kinjelom/watchdog_exporter/status"
+)
+
+// ResolveStatus is the single place where a probe outcome becomes its final status: the TLS facts take
+// precedence, then the status set by the checkers, then the class of the error.
+func ResolveStatus(st status.Status, err error, certs *CertsReport) status.Status {
+	if certs != nil {
+		if !certs.HadTLS {
+			return status.InvalidTLSMissing
+		}
+		if !certs.ChainValid && !certs.Insecure {
+			return status.InvalidTLSChain
+		}
+	}
+	if st != "" {
+		return st
+	}
+	if err != nil {
+		if tlsStatus, ok := classifyTLSError(err); ok {
+			return tlsStatus
+		}
+		return status.UnknownError
+	}
+	return status.Valid
+}
+
+// classifyTLSError maps TLS and x509 errors by type; a failed verification of another kind is InvalidTLSOther.
+func classifyTLSError(err error) (status.Status, bool) {
+	var cErr x509.CertificateInvalidError
+	if errors.As(err, &cErr) {
+		if cErr.Reason == x509.Expired {
+			return status.ExpiredCertLeaf, true
+		}
+		return status.InvalidTLSCertificate, true
+	}
+	if errors.As(err, new(x509.UnknownAuthorityError)) {
+		return status.InvalidTLSUnknownAuthority, true
+	}
+	if errors.As(err, new(x509.HostnameError)) {
+		return status.InvalidTLSHostname, true
+	}
+	if errors.As(err, new(tls.AlertError)) || errors.As(err, new(tls.RecordHeaderError)) {
+		return status.InvalidTLSHandshake, true
+	}
+	if errors.As(err, new(*tls.CertificateVerificationError)) {
+		return status.InvalidTLSOther, true
+	}
+	return "", false
+}
+
+// ClassifyError groups a failed probe into a status.ErrorClass, looking into err for DNS and connect failures.
+func ClassifyError(st status.Status, err error) status.ErrorClass {
+	class := st.Class()
+	if class == status.ClassNone && err != nil {
+		class = status.ClassOther
+	}
+	if class != status.ClassOther && class != status.ClassHTTP {
+		return class
+	}
+	if err == nil {
+		return class
+	}
+	if isTimeoutErr(err) {
+		return status.ClassTimeout
+	}
+	if errors.As(err, new(*net.DNSError)) {
+		return status.ClassDNS
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return status.ClassConnect
+	}
+	if st == status.InvalidRequestExecution {
+		return status.ClassHTTP
+	}
+	return class
+}
+
+// maxErrorLength caps the sanitized error text.
+const maxErrorLength = 256
+
+var (
+	urlUserinfo  = regexp.MustCompile(`(://)[^/@\s"]+@`)
+	urlQuery     = regexp.MustCompile(`(://[^\s"?]+)\?[^\s"]*`)
+	localAddress = regexp.MustCompile(`\S+:\d+->`)
+)
+
+// SanitizeError renders err for display and storage: credentials and query strings are removed from URLs,
+// the local ephemeral address of socket errors is dropped (so the same failure reads the same every probe)
+// and the text is capped at maxErrorLength.
+func SanitizeError(err error) string {
+	if err == nil {
+		return ""
+	}
+	msg := err.Error()
+	msg = urlUserinfo.ReplaceAllString(msg, "${1}redacted@")
+	msg = urlQuery.ReplaceAllString(msg, "${1}?redacted")
+	msg = localAddress.ReplaceAllString(msg, "->")
+	if len(msg) > maxErrorLength {
+		msg = strings.ToValidUTF8(msg[:maxErrorLength], "") + "..."
+	}
+	return msg
+}
@@ -0,0 +1,97 @@
+package validator
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+)
+
+// SecretSource provides the secrets of the vault template function and request.tls-client-vault (see package vault).
+type SecretSource interface {
+	Secret(path, field string) (string, error)
+}
+
+var errNoSecretSource = errors.New("vault is not configured")
+
+// requestTemplates renders request values; templates are parsed once and evaluated on every probe.
+type requestTemplates struct {
+	funcs template.FuncMap
+	cache sync.Map // text -> *template.Template
+}
+
+func newRequestTemplates(secret func(path, field string) (string, error)) *requestTemplates {
+	return &requestTemplates{funcs: template.FuncMap{
+		"now":   time.Now,
+		"uuid":  uuid.NewString,
+		"env":   os.Getenv,
+		"vault": secret,
+	}}
+}
+
+// expand renders text as a Go template; text without "{{" is returned as it is.
+func (t *requestTemplates) expand(text string) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+	var tmpl *template.Template
+	if cached, ok := t.cache.Load(text); ok {
+		tmpl = cached.(*template.Template)
+	} else {
+		parsed, err := template.New("request").Funcs(t.funcs).Option("missingkey=error").Parse(text)
+		if err != nil {
+			return "", err
+		}
+		t.cache.Store(text, parsed)
+		tmpl = parsed
+	}
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, nil); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// RequestURL returns the URL a probe of rc requests: a templated URL rendered, rc.URL when it cannot be.
+func (m *WatchDogValidator) RequestURL(rc config.EndpointRequest) string {
+	u, err := m.templates.expand(rc.URL)
+	if err != nil {
+		return rc.URL
+	}
+	return u
+}
+
+// expandRequest returns rc with its URL, header values, body and auth password rendered for one probe.
+func (t *requestTemplates) expandRequest(rc config.EndpointRequest) (config.EndpointRequest, error) {
+	var err error
+	if rc.URL, err = t.expand(rc.URL); err != nil {
+		return rc, fmt.Errorf("request.url template: %w", err)
+	}
+	if rc.Body, err = t.expand(rc.Body); err != nil {
+		return rc, fmt.Errorf("request.body template: %w", err)
+	}
+	if len(rc.Headers) > 0 {
+		headers := make(map[string]string, len(rc.Headers))
+		for k, v := range rc.Headers {
+			if headers[k], err = t.expand(v); err != nil {
+				return rc, fmt.Errorf("request.headers[%s] template: %w", k, err)
+			}
+		}
+		rc.Headers = headers
+	}
+	if rc.Auth != nil && rc.Auth.Password != "" {
+		auth := *rc.Auth
+		if auth.Password, err = t.expand(auth.Password); err != nil {
+			return rc, fmt.Errorf("request.auth.password template: %w", err)
+		}
+		rc.Auth = &auth
+	}
+	return rc, nil
+}
@@ -0,0 +1,91 @@
+package validator
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+	"github.com/kinjelom/watchdog_exporter/status"
+)
+
+func TestExpandTemplate(t *testing.T) {
+	t.Setenv("WD_TEST_TOKEN", "s3cret")
+
+	tmpl := newRequestTemplates(func(path, field string) (string, error) { return path + "#" + field, nil })
+	got, err := tmpl.expand("plain {value}")
+	assert.NoError(t, err)
+	assert.Equal(t, "plain {value}", got)
+
+	got, err = tmpl.expand(`Bearer {{ env "WD_TEST_TOKEN" }}`)
+	assert.NoError(t, err)
+	assert.Equal(t, "Bearer s3cret", got)
+
+	got, err = tmpl.expand("{{ now.Unix }}")
+	assert.NoError(t, err)
+	ts, err := strconv.ParseInt(got, 10, 64)
+	assert.NoError(t, err)
+	assert.InDelta(t, time.Now().Unix(), ts, 2)
+
+	first, err := tmpl.expand("{{ uuid }}")
+	assert.NoError(t, err)
+	second, _ := tmpl.expand("{{ uuid }}")
+	assert.Regexp(t, `^[0-9a-f-]{36}$`, first)
+	assert.NotEqual(t, first, second, "evaluated on every call")
+
+	got, err = tmpl.expand(`{{ vault "secret/data/watchdog/api" "token" }}`)
+	assert.NoError(t, err)
+	assert.Equal(t, "secret/data/watchdog/api#token", got)
+
+	_, err = tmpl.expand("{{ unknownFunc }}")
+	assert.Error(t, err)
+	_, err = tmpl.expand("{{ now")
+	assert.Error(t, err)
+}
+
+func TestRequestURL(t *testing.T) {
+	t.Setenv("WD_TEST_HOST", "a.example.com")
+	v := NewWatchDogValidator(NewDefaultTLSChecker(false), NewDefaultHTTPResponseChecker(false), false)
+
+	assert.Equal(t, "https://a.example.com/health", v.RequestURL(config.EndpointRequest{URL: `https://{{ env "WD_TEST_HOST" }}/health`}))
+	assert.Equal(t, "https://b.example.com/", v.RequestURL(config.EndpointRequest{URL: "https://b.example.com/"}))
+	assert.Equal(t, "https://{{ nope }}/", v.RequestURL(config.EndpointRequest{URL: "https://{{ nope }}/"}), "as it is when it cannot be rendered")
+}
+
+func TestProbe_ExpandsRequestTemplates(t *testing.T) {
+	t.Setenv("WD_TEST_TOKEN", "s3cret")
+	var gotQuery, gotAuth, gotBody string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotQuery, gotAuth = r.URL.RawQuery, r.Header.Get("Authorization")
+		b, _ := io.ReadAll(r.Body)
+		gotBody = string(b)
+	}))
+	defer srv.Close()
+
+	v := NewWatchDogValidator(NewDefaultTLSChecker(false), NewDefaultHTTPResponseChecker(false), false)
+	validation := &config.EndpointValidation{StatusCode: http.StatusOK}
+	req := config.EndpointRequest{
+		URL:     srv.URL + "/health?ts={{ now.Unix }}",
+		Method:  http.MethodPost,
+		Timeout: 2 * time.Second,
+		Headers: map[string]string{"Authorization": `Bearer {{ env "WD_TEST_TOKEN" }}`},
+		Body:    `{"nonce":"{{ uuid }}"}`,
+	}
+	rep, err := v.Probe("ep", req, "rt", config.Route{}, validation, false)
+	assert.NoError(t, err)
+	assert.Equal(t, status.Valid, rep.Status)
+	assert.Regexp(t, `^ts=\d{10}$`, gotQuery)
+	assert.Equal(t, "Bearer s3cret", gotAuth)
+	assert.Regexp(t, `^\{"nonce":"[0-9a-f-]{36}"\}$`, gotBody)
+	assert.Equal(t, `Bearer {{ env "WD_TEST_TOKEN" }}`, req.Headers["Authorization"], "the configured request is not modified")
+
+	req.Body = "{{ if }}"
+	rep, err = v.Probe("ep", req, "rt", config.Route{}, validation, false)
+	assert.Error(t, err)
+	assert.Equal(t, status.InvalidRequestDefinition, rep.Status)
+}
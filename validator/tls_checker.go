@@ -1,15 +1,22 @@
 package validator
 
 import (
+	"crypto/sha256"
 	"crypto/tls"
 	"crypto/x509"
+	"encoding/base64"
 	"encoding/hex"
 	"errors"
 	"math/big"
 	"net/http"
 	"net/url"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+	"github.com/kinjelom/watchdog_exporter/status"
 )
 
 // CertInfo captures basic facts about a certificate in the chain.
@@ -22,19 +29,24 @@ type CertInfo struct {
 	IsCA            bool      // whether BasicConstraints.CA is set
 	IssuerCN        string    // Issuer CN for readability
 	SubjectAltNames []string  // DNS names (subset for quick view)
+	SPKISHA256      string    // base64 SHA-256 of the public key info, as in validation.pin-sha256
 }
 
 // CertsReport is a summary of TLS facts for metrics and validation.
 type CertsReport struct {
 	HadTLS       bool
 	ChainValid   bool       // true if VerifiedChains present (hostname & chain validated)
+	Version      string     // negotiated TLS version, e.g. "TLS 1.3"
+	CipherSuite  string     // negotiated cipher suite name
+	ALPN         string     // negotiated application protocol ("" if none)
+	Insecure     bool       // verification was skipped (tls-insecure-skip-verify), so ChainValid is false by design
 	Certificates []CertInfo // ordered leaf -> ... -> (possibly) root
 }
 
 // TLSChecker defines TLS-related validation and inspection.
 type TLSChecker interface {
 	TLSClientConfigWithSNI(serverName string) *tls.Config
-	CheckHandshakeError(err error) (string, bool)
+	CheckHandshakeError(err error) (status.Status, bool)
 	Inspect(resp *http.Response) CertsReport
 }
 
@@ -48,23 +60,23 @@ func (d *DefaultTLSChecker) TLSClientConfigWithSNI(serverName string) *tls.Confi
 	return &tls.Config{ServerName: serverName}
 }
 
-func (d *DefaultTLSChecker) CheckHandshakeError(err error) (string, bool) {
+func (d *DefaultTLSChecker) CheckHandshakeError(err error) (status.Status, bool) {
 	var cErr x509.CertificateInvalidError
 	if errors.As(err, &cErr) {
 		if cErr.Reason == x509.Expired {
-			return "expired-cert-leaf", true
+			return status.ExpiredCertLeaf, true
 		}
-		return "invalid-tls-certificate", true
+		return status.InvalidTLSCertificate, true
 	}
 
 	var uaErr x509.UnknownAuthorityError
 	if errors.As(err, &uaErr) {
-		return "invalid-tls-chain", true
+		return status.InvalidTLSChain, true
 	}
 
 	var hnErr x509.HostnameError
 	if errors.As(err, &hnErr) {
-		return "invalid-tls-hostname", true
+		return status.InvalidTLSHostname, true
 	}
 
 	// Many TLS problems are wrapped under *url.Error during handshake.
@@ -72,7 +84,7 @@ func (d *DefaultTLSChecker) CheckHandshakeError(err error) (string, bool) {
 	if errors.As(err, &uErr) {
 		// Only treat as TLS-related if it wraps a known TLS/x509 error.
 		if isTLSError(uErr.Err) {
-			return "invalid-tls-chain", true
+			return status.InvalidTLSChain, true
 		}
 	}
 
@@ -86,7 +98,10 @@ func (d *DefaultTLSChecker) Inspect(resp *http.Response) CertsReport {
 		return CertsReport{HadTLS: false}
 	}
 	rep := CertsReport{
-		HadTLS: true,
+		HadTLS:      true,
+		Version:     tls.VersionName(cs.Version),
+		CipherSuite: tls.CipherSuiteName(cs.CipherSuite),
+		ALPN:        cs.NegotiatedProtocol,
 	}
 	now := time.Now()
 
@@ -115,6 +130,7 @@ func (d *DefaultTLSChecker) Inspect(resp *http.Response) CertsReport {
 			IsCA:            c.IsCA,
 			IssuerCN:        c.Issuer.CommonName,
 			SubjectAltNames: c.DNSNames,
+			SPKISHA256:      spkiSHA256(c),
 		}
 		rep.Certificates = append(rep.Certificates, ci)
 	}
@@ -122,6 +138,66 @@ func (d *DefaultTLSChecker) Inspect(resp *http.Response) CertsReport {
 	return rep
 }
 
+// checkTLSPolicy verifies the negotiated connection against the endpoint's TLS requirements. It returns the status
+// of the first broken one and what was observed, or "" when the connection complies.
+func checkTLSPolicy(cs *tls.ConnectionState, v config.EndpointValidation) (status.Status, string) {
+	if pinned := leafPinned(cs, v.PinSHA256); pinned != nil && !*pinned {
+		if len(cs.PeerCertificates) == 0 {
+			return status.CertPinMismatch, ""
+		}
+		return status.CertPinMismatch, spkiSHA256(cs.PeerCertificates[0])
+	}
+	if v.CertIssuerRegex != "" || v.CertCNRegex != "" {
+		if len(cs.PeerCertificates) == 0 {
+			return status.UnexpectedCertIssuer, ""
+		}
+		leaf := cs.PeerCertificates[0]
+		if issuer := leaf.Issuer.String(); !regexMatches(v.CertIssuerRegex, issuer) {
+			return status.UnexpectedCertIssuer, issuer
+		}
+		if cn := leaf.Subject.CommonName; !regexMatches(v.CertCNRegex, cn) {
+			return status.UnexpectedCertIssuer, cn
+		}
+	}
+	if v.MinCertDaysLeft > 0 && len(cs.PeerCertificates) > 0 {
+		if days := time.Until(cs.PeerCertificates[0].NotAfter).Hours() / 24; days < v.MinCertDaysLeft {
+			return status.CertExpiringSoon, strconv.FormatFloat(days, 'f', 1, 64) + " days left"
+		}
+	}
+	suite := tls.CipherSuiteName(cs.CipherSuite)
+	if len(v.AllowedCipherSuites) > 0 && !slices.Contains(v.AllowedCipherSuites, suite) {
+		return status.WeakCipherSuite, suite
+	}
+	if slices.Contains(v.ForbiddenCipherSuites, suite) {
+		return status.WeakCipherSuite, suite
+	}
+	return "", ""
+}
+
+// regexMatches reports whether s matches pattern; an empty pattern matches anything, an invalid one nothing.
+func regexMatches(pattern, s string) bool {
+	if pattern == "" {
+		return true
+	}
+	re, err := compiledRegex(pattern)
+	return err == nil && re.MatchString(s)
+}
+
+// leafPinned reports whether the leaf key is one of pins; nil when no pin is configured.
+func leafPinned(cs *tls.ConnectionState, pins []string) *bool {
+	if len(pins) == 0 {
+		return nil
+	}
+	ok := len(cs.PeerCertificates) > 0 && slices.Contains(pins, spkiSHA256(cs.PeerCertificates[0]))
+	return &ok
+}
+
+// spkiSHA256 is the base64 SHA-256 of the certificate's SubjectPublicKeyInfo.
+func spkiSHA256(c *x509.Certificate) string {
+	sum := sha256.Sum256(c.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
 // bigIntToUpperHex converts a *big.Int to an uppercase hex string (no 0x prefix).
 func bigIntToUpperHex(b *big.Int) string {
 	if b == nil {
@@ -140,9 +216,9 @@ func isTLSError(err error) bool {
 	case errors.As(err, new(x509.CertificateInvalidError)),
 		errors.As(err, new(x509.UnknownAuthorityError)),
 		errors.As(err, new(x509.HostnameError)),
-		strings.Contains(strings.ToLower(err.Error()), "tls"),
-		strings.Contains(strings.ToLower(err.Error()), "certificate"),
-		strings.Contains(strings.ToLower(err.Error()), "handshake"):
+		errors.As(err, new(tls.AlertError)),
+		errors.As(err, new(tls.RecordHeaderError)),
+		errors.As(err, new(*tls.CertificateVerificationError)):
 		return true
 	default:
 		return false
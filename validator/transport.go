@@ -0,0 +1,207 @@
+package validator
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/http/httpproxy"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+)
+
+// transportKey captures everything a cached transport was built from; a different key means the config changed.
+type transportKey struct {
+	proxyURL  string
+	targetIP  string
+	socket    string // route target-socket
+	sni       string
+	timeout   time.Duration
+	keepAlive bool
+	dnsCache  bool
+	fallback  time.Duration // dial fallback delay (happy eyeballs)
+	http2     bool          // offer h2 via ALPN
+	certFile  string        // mTLS client certificate
+	keyFile   string
+	certVault string
+	insecure  bool // tls-insecure-skip-verify
+}
+
+type cachedTransport struct {
+	key       transportKey
+	transport *http.Transport
+}
+
+// transportCache holds one transport per endpoint+route, so proxy parsing and TLS setup happen once
+// and connections can be reused in keep-alive mode.
+type transportCache struct {
+	mu    sync.Mutex
+	byKey map[string]cachedTransport
+}
+
+// get returns the transport for endpoint+route, rebuilding it (and closing the old one's idle connections)
+// when the settings it depends on changed.
+func (c *transportCache) get(endpointName, routeName string, key transportKey, build func() (*http.Transport, error)) (*http.Transport, error) {
+	id := endpointName + "\x00" + routeName
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cached, ok := c.byKey[id]; ok {
+		if cached.key == key {
+			return cached.transport, nil
+		}
+		cached.transport.CloseIdleConnections()
+	}
+	t, err := build()
+	if err != nil {
+		return nil, err
+	}
+	if c.byKey == nil {
+		c.byKey = make(map[string]cachedTransport)
+	}
+	c.byKey[id] = cachedTransport{key: key, transport: t}
+	return t, nil
+}
+
+// forget drops the transports of an endpoint (all its routes), closing their idle connections.
+func (c *transportCache) forget(endpointName string) {
+	prefix := endpointName + "\x00"
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id, cached := range c.byKey {
+		if strings.HasPrefix(id, prefix) {
+			cached.transport.CloseIdleConnections()
+			delete(c.byKey, id)
+		}
+	}
+}
+
+// newTransport builds the probe transport: optional proxy, target-socket or target-ip override (or the DNS cache) at dial time,
+// SNI of the original host (or request.sni). HTTP/2 is only offered with http2, the custom dialer disables it otherwise.
+func (m *WatchDogValidator) newTransport(rc config.EndpointRequest, route config.Route, originalHost string, dnsCache *DNSCache, http2 bool) (*http.Transport, error) {
+	tlsConfig, err := m.tlsClientConfig(rc, originalHost)
+	if err != nil {
+		return nil, err
+	}
+	var proxyFunc func(*http.Request) (*url.URL, error)
+	if route.ProxyUrl != "" {
+		proxyURL, err := url.Parse(route.ProxyUrl)
+		if err != nil {
+			return nil, err
+		}
+		proxyFunc = http.ProxyURL(proxyURL)
+	} else if m.useProxyEnv && route.TargetSocket == "" {
+		// read when the transport is built, not once per process like http.ProxyFromEnvironment
+		envProxy := httpproxy.FromEnvironment().ProxyFunc()
+		proxyFunc = func(req *http.Request) (*url.URL, error) {
+			return envProxy(req.URL)
+		}
+	}
+
+	dialer := &net.Dialer{
+		Timeout:        rc.Timeout,
+		KeepAlive:      30 * time.Second,
+		FallbackDelay:  rc.DialFallbackDelay(),
+		ControlContext: traceDialFamily,
+	}
+	return &http.Transport{
+		Proxy:             proxyFunc,
+		ForceAttemptHTTP2: http2,
+		DisableKeepAlives: !rc.KeepAlive,
+		IdleConnTimeout:   90 * time.Second,
+		TLSClientConfig:   tlsConfig,
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			if route.TargetSocket != "" {
+				return dialer.DialContext(ctx, "unix", route.TargetSocket)
+			}
+			host, port, splitErr := net.SplitHostPort(addr)
+			if splitErr != nil {
+				return dialer.DialContext(ctx, network, addr)
+			}
+			if route.TargetIP != "" {
+				host = route.TargetIP
+			} else if dnsCache != nil && net.ParseIP(host) == nil {
+				return dialCached(ctx, dnsCache, dialer, network, host, port)
+			}
+			return dialer.DialContext(ctx, network, net.JoinHostPort(host, port))
+		},
+	}, nil
+}
+
+// errClientCertificate marks a request.tls-client-cert/key pair that cannot be loaded.
+var errClientCertificate = errors.New("cannot load the TLS client certificate")
+
+// tlsClientConfig is the checker's config for serverName (request.sni when set) plus the endpoint's client certificate (mutual TLS) and
+// tls-insecure-skip-verify. The pair (files or Vault secret) is loaded once to fail early and then again on every
+// handshake, so rotated certificates are picked up.
+func (m *WatchDogValidator) tlsClientConfig(rc config.EndpointRequest, serverName string) (*tls.Config, error) {
+	if rc.SNI != "" {
+		serverName = rc.SNI
+	}
+	cfg := m.tlsChecker.TLSClientConfigWithSNI(serverName)
+	if rc.TLSInsecureSkipVerify {
+		cfg.InsecureSkipVerify = true
+	}
+	var load func() (tls.Certificate, error)
+	switch {
+	case rc.TLSClientVault != "":
+		path := rc.TLSClientVault
+		load = func() (tls.Certificate, error) {
+			certPEM, err := m.secret(path, "certificate")
+			if err != nil {
+				return tls.Certificate{}, err
+			}
+			keyPEM, err := m.secret(path, "private_key")
+			if err != nil {
+				return tls.Certificate{}, err
+			}
+			return tls.X509KeyPair([]byte(certPEM), []byte(keyPEM))
+		}
+	case rc.TLSClientCert != "" || rc.TLSClientKey != "":
+		certFile, keyFile := rc.TLSClientCert, rc.TLSClientKey
+		load = func() (tls.Certificate, error) {
+			return tls.LoadX509KeyPair(certFile, keyFile)
+		}
+	default:
+		return cfg, nil
+	}
+	if _, err := load(); err != nil {
+		return nil, fmt.Errorf("%w: %w", errClientCertificate, err)
+	}
+	cfg.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+		cert, err := load()
+		if err != nil {
+			return nil, err
+		}
+		return &cert, nil
+	}
+	return cfg, nil
+}
+
+// dialTrace collects what the dialer attempted for one probe.
+type dialTrace struct {
+	ipv6Attempted atomic.Bool
+}
+
+type dialTraceKey struct{}
+
+func withDialTrace(ctx context.Context, t *dialTrace) context.Context {
+	return context.WithValue(ctx, dialTraceKey{}, t)
+}
+
+// traceDialFamily is the dialer control hook: it notes IPv6 connection attempts, so a probe that ends up on IPv4
+// can be reported as an IPv6 -> IPv4 fallback.
+func traceDialFamily(ctx context.Context, network, _ string, _ syscall.RawConn) error {
+	if t, ok := ctx.Value(dialTraceKey{}).(*dialTrace); ok && network == "tcp6" {
+		t.ipv6Attempted.Store(true)
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package validator
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+	"github.com/kinjelom/watchdog_exporter/status"
+)
+
+func TestTransportCache_ReusedUntilConfigChanges(t *testing.T) {
+	var c transportCache
+	builds := 0
+	build := func() (*http.Transport, error) {
+		builds++
+		return &http.Transport{}, nil
+	}
+	key := transportKey{sni: "example.com", timeout: time.Second}
+
+	t1, _ := c.get("ep", "rt", key, build)
+	t2, _ := c.get("ep", "rt", key, build)
+	assert.Same(t, t1, t2)
+	assert.Equal(t, 1, builds)
+
+	t3, _ := c.get("ep", "other", key, build)
+	assert.NotSame(t, t1, t3, "one transport per endpoint+route")
+
+	key.timeout = 2 * time.Second
+	t4, _ := c.get("ep", "rt", key, build)
+	assert.NotSame(t, t1, t4, "rebuilt after a config change")
+	assert.Equal(t, 3, builds)
+}
+
+func TestProbe_KeepAliveReusesConnections(t *testing.T) {
+	var conns atomic.Int32
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("hello world"))
+	}))
+	srv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+
+	v := NewWatchDogValidator(NewDefaultTLSChecker(false), NewDefaultHTTPResponseChecker(false), false)
+	validation := &config.EndpointValidation{StatusCode: http.StatusOK}
+	probe := func(req config.EndpointRequest) {
+		rep, err := v.Probe("ep", req, "rt", config.Route{}, validation, false)
+		assert.NoError(t, err)
+		assert.Equal(t, status.Valid, rep.Status)
+	}
+
+	req := config.EndpointRequest{URL: srv.URL, Timeout: 2 * time.Second, Method: http.MethodGet, KeepAlive: true}
+	for i := 0; i < 3; i++ {
+		probe(req)
+	}
+	assert.Equal(t, int32(1), conns.Load(), "keep-alive probes share one connection")
+
+	req.KeepAlive = false
+	for i := 0; i < 2; i++ {
+		probe(req)
+	}
+	assert.Equal(t, int32(3), conns.Load(), "a new connection per probe by default")
+}
+
+func TestProbe_TargetSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "app.sock")
+	ln, err := net.Listen("unix", socket)
+	if !assert.NoError(t, err) {
+		return
+	}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Host != "app.internal" {
+			w.WriteHeader(http.StatusMisdirectedRequest)
+		}
+	}))
+	srv.Listener = ln
+	srv.Start()
+	defer srv.Close()
+
+	v := NewWatchDogValidator(NewDefaultTLSChecker(false), NewDefaultHTTPResponseChecker(false), false)
+	req := config.EndpointRequest{URL: "http://app.internal/health", Timeout: 2 * time.Second, Method: http.MethodGet}
+	rep, err := v.Probe("ep", req, "sidecar", config.Route{TargetSocket: socket}, &config.EndpointValidation{StatusCode: http.StatusOK}, false)
+	assert.NoError(t, err)
+	assert.Equal(t, status.Valid, rep.Status, "sent over the socket with the URL host")
+}
+
+func TestNewTransport_UseProxyEnv(t *testing.T) {
+	t.Setenv("HTTP_PROXY", "http://proxy.test:3128")
+	t.Setenv("HTTPS_PROXY", "http://proxy.test:3128")
+	t.Setenv("NO_PROXY", "internal.test")
+	v := NewWatchDogValidator(NewDefaultTLSChecker(false), NewDefaultHTTPResponseChecker(false), false)
+	rc := config.EndpointRequest{Timeout: time.Second}
+	proxyOf := func(tr *http.Transport, rawURL string) string {
+		if tr.Proxy == nil {
+			return ""
+		}
+		req, _ := http.NewRequest(http.MethodGet, rawURL, nil)
+		u, err := tr.Proxy(req)
+		assert.NoError(t, err)
+		if u == nil {
+			return ""
+		}
+		return u.String()
+	}
+
+	tr, err := v.newTransport(rc, config.Route{}, "example.test", nil, false)
+	assert.NoError(t, err)
+	assert.Empty(t, proxyOf(tr, "https://example.test"), "environment ignored by default")
+
+	v.SetUseProxyEnv(true)
+	tr, err = v.newTransport(rc, config.Route{}, "example.test", nil, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "http://proxy.test:3128", proxyOf(tr, "https://example.test"))
+	assert.Empty(t, proxyOf(tr, "https://internal.test"), "NO_PROXY applies")
+
+	tr, err = v.newTransport(rc, config.Route{ProxyUrl: "http://route-proxy.test:8080"}, "example.test", nil, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "http://route-proxy.test:8080", proxyOf(tr, "https://example.test"), "route proxy-url wins")
+}
+
+func TestProbe_ReportsIPv6Fallback(t *testing.T) {
+	if l, err := net.Listen("tcp6", "[::1]:0"); err != nil {
+		t.Skip("no IPv6 loopback")
+	} else {
+		_ = l.Close()
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+	port := srv.URL[strings.LastIndex(srv.URL, ":")+1:]
+
+	cache, err := NewDNSCache(config.DNSCacheConfig{MinTTL: time.Second, MaxTTL: time.Minute, Servers: []string{"127.0.0.1:1"}})
+	assert.NoError(t, err)
+	records := []net.IP{net.ParseIP("::1"), net.ParseIP("127.0.0.1")} // nothing listens on [::1]:port
+	cache.query = func(_ context.Context, _ string) ([]net.IP, time.Duration, error) {
+		return records, time.Minute, nil
+	}
+	v := NewWatchDogValidator(NewDefaultTLSChecker(false), NewDefaultHTTPResponseChecker(false), false)
+	v.SetDNSCache(cache)
+	validation := &config.EndpointValidation{StatusCode: http.StatusOK}
+	rc := config.EndpointRequest{Method: http.MethodGet, URL: "http://dual.test:" + port + "/", Timeout: time.Second}
+
+	rep, err := v.Probe("ep", rc, "direct", config.Route{}, validation, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "127.0.0.1", rep.RemoteIP)
+	assert.True(t, rep.IPv6Fallback, "IPv6 failed, IPv4 was used")
+
+	rep, err = v.Probe("ep-v4", config.EndpointRequest{Method: http.MethodGet, URL: srv.URL, Timeout: time.Second}, "direct", config.Route{}, validation, false)
+	assert.NoError(t, err)
+	assert.False(t, rep.IPv6Fallback, "IPv4 only, nothing to fall back from")
+}
@@ -1,71 +1,199 @@
+// Package validator runs a single HTTP(S) probe and checks its TLS and response.
 package validator
 
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net"
 	"net/http"
+	"net/http/httptrace"
 	"net/url"
+	"strings"
 	"time"
-	"watchdog_exporter/config"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+	"github.com/kinjelom/watchdog_exporter/status"
 )
 
+// keepAliveDrainLimit bounds how much of an unread body is discarded to keep a connection reusable.
+const keepAliveDrainLimit = 64 << 10
+
 type WatchDogValidator struct {
 	tlsChecker      TLSChecker
 	responseChecker HTTPResponseChecker
 	debug           bool
+	debugSwitch     *DebugSwitch
+	transports      transportCache
+	dnsCache        *DNSCache
+	useProxyEnv     bool
+	secrets         SecretSource
+	templates       *requestTemplates
 }
 
 func NewWatchDogValidator(tlsChecker TLSChecker, responseChecker HTTPResponseChecker, debug bool) *WatchDogValidator {
-	return &WatchDogValidator{
+	m := &WatchDogValidator{
 		tlsChecker:      tlsChecker,
 		responseChecker: responseChecker,
 		debug:           debug,
 	}
+	m.templates = newRequestTemplates(m.secret)
+	return m
+}
+
+// SetSecretSource enables the vault template function and request.tls-client-vault.
+func (m *WatchDogValidator) SetSecretSource(s SecretSource) {
+	m.secrets = s
+}
+
+// secret reads from the secret source, which may not be configured.
+func (m *WatchDogValidator) secret(path, field string) (string, error) {
+	if m.secrets == nil {
+		return "", errNoSecretSource
+	}
+	return m.secrets.Secret(path, field)
+}
+
+// SetDebugSwitch enables detail logging per endpoint, on top of the global debug flag.
+func (m *WatchDogValidator) SetDebugSwitch(d *DebugSwitch) {
+	m.debugSwitch = d
+}
+
+// SetDNSCache makes probes resolve through c, except for endpoints with request.dns-cache-bypass.
+func (m *WatchDogValidator) SetDNSCache(c *DNSCache) {
+	m.dnsCache = c
+}
+
+// SetUseProxyEnv makes routes without proxy-url use HTTP_PROXY, HTTPS_PROXY and NO_PROXY from the environment.
+func (m *WatchDogValidator) SetUseProxyEnv(on bool) {
+	m.useProxyEnv = on
+}
+
+// Forget releases the transports of an endpoint that is no longer probed (e.g. removed by a config reload).
+func (m *WatchDogValidator) Forget(endpointName string) {
+	m.transports.forget(endpointName)
+}
+
+// Report carries everything a single probe observed.
+type Report struct {
+	Status    status.Status
+	Duration  float64
+	TLS       *CertsReport
+	Matches   ResponseMatches
+	RemoteIP  string // address actually connected to (the proxy when one is used)
+	Redirects int    // redirects followed (only with follow-redirects)
+	// IPv6Fallback: an IPv6 connection was attempted but the probe connected over IPv4 (fresh connections only).
+	IPv6Fallback bool
+	// Headers holds the validation.capture-headers present in the response (canonical name -> first value).
+	Headers map[string]string
+	// Answers are the records of the queried type (protocol dns), in zone file notation.
+	Answers []string
+	// PinMatch reports whether the leaf key matched validation.pin-sha256 (nil = no pin configured or no TLS).
+	PinMatch *bool
+	// Phases are the phase timings of an HTTP probe.
+	Phases Phases
+}
+
+// Validate probes the endpoint over the route and returns the consolidated outcome.
+func (m *WatchDogValidator) Validate(endpointName string, rc config.EndpointRequest, routeName string, route config.Route, validation *config.EndpointValidation, checkCerts bool) (st status.Status, duration float64, certsRep *CertsReport, err error) {
+	rep, err := m.Probe(endpointName, rc, routeName, route, validation, checkCerts)
+	return rep.Status, rep.Duration, rep.TLS, err
+}
+
+// Probe is like Validate but returns the full Report.
+func (m *WatchDogValidator) Probe(endpointName string, rc config.EndpointRequest, routeName string, route config.Route, validation *config.EndpointValidation, checkCerts bool) (Report, error) {
+	return m.probe(endpointName, rc, routeName, routeName, route, validation, checkCerts)
+}
+
+// ProbeIP is like Probe but connects to ip instead of resolving the URL host (as the route's target-ip would),
+// with its own cached transport so every address of an endpoint keeps its connections.
+func (m *WatchDogValidator) ProbeIP(endpointName string, rc config.EndpointRequest, routeName string, route config.Route, ip string, validation *config.EndpointValidation, checkCerts bool) (Report, error) {
+	route.TargetIP = ip
+	return m.probe(endpointName, rc, routeName, routeName+"\x00"+ip, route, validation, checkCerts)
+}
+
+// ResolveHost returns every address of the request URL host (through the DNS cache unless bypassed), or the host
+// itself when it is already an IP.
+func (m *WatchDogValidator) ResolveHost(ctx context.Context, rc config.EndpointRequest) ([]string, error) {
+	u, err := url.Parse(rc.URL)
+	if err != nil {
+		return nil, err
+	}
+	host := u.Hostname()
+	if net.ParseIP(host) != nil {
+		return []string{host}, nil
+	}
+	var ips []net.IP
+	if m.dnsCache != nil && !rc.DNSCacheBypass {
+		ips, err = m.dnsCache.LookupIP(ctx, host)
+	} else {
+		ips, err = net.DefaultResolver.LookupIP(ctx, "ip", host)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(ips) == 0 {
+		return nil, &net.DNSError{Err: "no addresses", Name: host, IsNotFound: true}
+	}
+	out := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		out = append(out, ip.String())
+	}
+	return out, nil
 }
 
-func (m *WatchDogValidator) Validate(endpointName string, rc config.EndpointRequest, routeName string, route config.Route, validation *config.EndpointValidation, checkCerts bool) (status string, duration float64, certsRep *CertsReport, err error) {
+// probe runs one request; transportID keys the transport cache (the route name, plus the IP for ProbeIP).
+func (m *WatchDogValidator) probe(endpointName string, rc config.EndpointRequest, routeName, transportID string, route config.Route, validation *config.EndpointValidation, checkCerts bool) (Report, error) {
+	var rep Report
+	debug := m.debug || m.debugSwitch.Enabled(endpointName)
+	rc, err := m.templates.expandRequest(rc)
+	if err != nil {
+		slog.Error("failed to expand request template", "status", status.InvalidRequestDefinition, "endpoint", endpointName, "err", err)
+		return Report{Status: status.InvalidRequestDefinition}, err
+	}
 	client := &http.Client{
 		Timeout: rc.Timeout,
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			return http.ErrUseLastResponse
+			if !rc.FollowRedirects {
+				return http.ErrUseLastResponse
+			}
+			if limit := rc.MaxRedirects; limit != nil && len(via) >= *limit {
+				return fmt.Errorf("stopped after %d redirects", *limit)
+			}
+			rep.Redirects = len(via)
+			return nil
 		},
 	}
 	u, err := url.Parse(rc.URL)
 	if err != nil {
-		log.Printf("invalid-url: failed to parse URL %s - %v", rc.URL, err)
-		return "invalid-url", 0, nil, err
+		slog.Error("failed to parse URL", "status", status.InvalidURL, "url", rc.URL, "err", err)
+		return Report{Status: status.InvalidURL}, err
 	}
 	originalHost := u.Hostname()
 
-	var proxyFunc func(*http.Request) (*url.URL, error)
-	if route.ProxyUrl != "" {
-		proxyURL, pErr := url.Parse(route.ProxyUrl)
-		if pErr != nil {
-			log.Printf("invalid-proxy-definition: failed to parse proxy URL %s - %v", route.ProxyUrl, pErr)
-			return "invalid-proxy-definition", 0, nil, pErr
-		}
-		proxyFunc = http.ProxyURL(proxyURL)
+	dnsCache := m.dnsCache
+	if rc.DNSCacheBypass {
+		dnsCache = nil
 	}
-
-	dialer := &net.Dialer{Timeout: rc.Timeout, KeepAlive: 30 * time.Second}
-
-	transport := &http.Transport{
-		Proxy:             proxyFunc,
-		DisableKeepAlives: true,
-		TLSClientConfig:   m.tlsChecker.TLSClientConfigWithSNI(originalHost),
-		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
-			host, port, splitErr := net.SplitHostPort(addr)
-			if splitErr != nil {
-				return dialer.DialContext(ctx, network, addr)
-			}
-			if route.TargetIP != "" {
-				host = route.TargetIP
-			}
-			return dialer.DialContext(ctx, network, net.JoinHostPort(host, port))
-		},
+	http2 := validation != nil && validation.ExpectedALPN == config.ALPNHTTP2
+	sni := originalHost
+	if rc.SNI != "" {
+		sni = rc.SNI
+	}
+	key := transportKey{proxyURL: route.ProxyUrl, targetIP: route.TargetIP, socket: route.TargetSocket, sni: sni, timeout: rc.Timeout, keepAlive: rc.KeepAlive, dnsCache: dnsCache != nil, fallback: rc.DialFallbackDelay(), http2: http2,
+		certFile: rc.TLSClientCert, keyFile: rc.TLSClientKey, certVault: rc.TLSClientVault, insecure: rc.TLSInsecureSkipVerify}
+	transport, err := m.transports.get(endpointName, transportID, key, func() (*http.Transport, error) {
+		return m.newTransport(rc, route, originalHost, dnsCache, http2)
+	})
+	if errors.Is(err, errClientCertificate) {
+		slog.Error("failed to load the TLS client certificate", "status", status.InvalidRequestDefinition, "endpoint", endpointName, "cert", rc.TLSClientCert, "err", err)
+		return Report{Status: status.InvalidRequestDefinition}, err
+	}
+	if err != nil {
+		slog.Error("failed to parse proxy URL", "status", status.InvalidProxyDefinition, "route", routeName, "proxy_url", route.ProxyUrl, "err", err)
+		return Report{Status: status.InvalidProxyDefinition}, err
 	}
 	client.Transport = transport
 
@@ -83,10 +211,14 @@ func (m *WatchDogValidator) Validate(endpointName string, rc config.EndpointRequ
 		targetURL = u.String()
 	}
 
-	req, err := http.NewRequest(rc.Method, targetURL, nil)
+	var body io.Reader
+	if rc.Body != "" {
+		body = strings.NewReader(rc.Body)
+	}
+	req, err := http.NewRequest(rc.Method, targetURL, body)
 	if err != nil {
-		log.Printf("invalid-request-definition: failed to prepare rc for endpoint %s URL %s - %v", endpointName, targetURL, err)
-		return "invalid-request-definition", 0, nil, err
+		slog.Error("failed to prepare request", "status", status.InvalidRequestDefinition, "endpoint", endpointName, "url", targetURL, "err", err)
+		return Report{Status: status.InvalidRequestDefinition}, err
 	}
 	req.Host = originalHost
 	req.Header.Set("Cache-Control", "no-cache")
@@ -96,45 +228,96 @@ func (m *WatchDogValidator) Validate(endpointName string, rc config.EndpointRequ
 	if req.Header.Get("X-Local-Time") == "" {
 		req.Header.Set("X-Local-Time", time.Now().Format(time.RFC3339))
 	}
+	if rc.Auth != nil {
+		if err = applyAuth(client, req, rc.Auth); err != nil {
+			slog.Error("failed to set request credentials", "status", status.InvalidRequestDefinition, "endpoint", endpointName, "err", err)
+			return Report{Status: status.InvalidRequestDefinition}, err
+		}
+	}
 
+	dials := &dialTrace{}
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Conn == nil {
+				return
+			}
+			if host, _, splitErr := net.SplitHostPort(info.Conn.RemoteAddr().String()); splitErr == nil {
+				rep.RemoteIP = host
+				if ip := net.ParseIP(host); ip != nil && ip.To4() != nil && !info.Reused {
+					rep.IPv6Fallback = dials.ipv6Attempted.Load()
+				}
+			}
+		},
+	}
+	phases := &phaseTrace{}
 	start := time.Now()
+	phases.hook(trace, start)
+	req = req.WithContext(httptrace.WithClientTrace(withDialTrace(withDebug(req.Context(), debug), dials), trace))
+
 	resp, err := client.Do(req)
-	duration = time.Since(start).Seconds()
+	rep.Duration = time.Since(start).Seconds()
+	rep.Phases = phases.result()
 	if err == nil {
 		if checkCerts && req.URL.Scheme == "https" && resp != nil && resp.TLS != nil {
-			rep := m.tlsChecker.Inspect(resp)
-			certsRep = &rep
+			certsRep := m.tlsChecker.Inspect(resp)
+			certsRep.Insecure = rc.TLSInsecureSkipVerify
+			rep.TLS = &certsRep
 		}
 	} else {
 		if req.URL.Scheme == "https" {
 			if st, ok := m.tlsChecker.CheckHandshakeError(err); ok {
-				if m.debug {
-					log.Printf("%s: %s / '%s': %v", st, rc.URL, routeName, err)
+				if debug {
+					slog.Info("TLS handshake failed", "status", st, "url", rc.URL, "route", routeName, "err", err)
 				}
-				return st, duration, nil, err
+				rep.Status = st
+				return rep, err
 			}
 		}
 
 		if isTimeoutErr(err) {
-			if m.debug {
-				log.Printf("request-execution-timeout: %s / '%s': %v", rc.URL, routeName, err)
+			if debug {
+				slog.Info("request timed out", "status", status.RequestExecutionTimeout, "url", rc.URL, "route", routeName, "err", err)
 			}
-			return "request-execution-timeout", duration, nil, err
+			rep.Status = status.RequestExecutionTimeout
+			return rep, err
 		}
-		if m.debug {
-			log.Printf("invalid-request-execution: %s / '%s': %v", rc.URL, routeName, err)
+		if debug {
+			slog.Info("request failed", "status", status.InvalidRequestExecution, "url", rc.URL, "route", routeName, "err", err)
 		}
-		return "invalid-request-execution", duration, nil, err
+		rep.Status = status.InvalidRequestExecution
+		return rep, err
 	}
-	defer func(Body io.ReadCloser) { _ = Body.Close() }(resp.Body)
+	defer func(Body io.ReadCloser) {
+		if rc.KeepAlive {
+			// the connection is only reused when the body was read to the end
+			_, _ = io.Copy(io.Discard, io.LimitReader(Body, keepAliveDrainLimit))
+		}
+		_ = Body.Close()
+	}(resp.Body)
 
 	// HTTP response validation via injected checker
-	status = "valid"
+	rep.Status = status.Valid
 	if validation != nil {
-		status, err = m.responseChecker.ValidateResponse(rc.URL, routeName, resp, rc.ResponseBodyLimit, *validation)
+		rep.Headers = captureHeaders(resp.Header, validation.CaptureHeaders)
+		rep.Status, rep.Matches, err = m.responseChecker.ValidateResponse(rc.URL, routeName, resp, rc.ResponseBodyLimit, *validation)
+		if resp.TLS != nil {
+			rep.PinMatch = leafPinned(resp.TLS, validation.PinSHA256)
+		}
+		if resp.TLS != nil && err == nil {
+			// TLS findings take precedence over the content checks, as in ResolveStatus.
+			if st, observed := checkTLSPolicy(resp.TLS, *validation); st != "" {
+				if debug {
+					slog.Info("TLS policy violated", "status", st, "url", rc.URL, "route", routeName, "observed", observed)
+				}
+				rep.Status = st
+			}
+		}
 	}
-	duration = time.Since(start).Seconds()
-	return status, duration, certsRep, err
+	if rep.Status == status.Valid && resp.TLS != nil && rc.TLSInsecureSkipVerify {
+		rep.Status = status.ValidInsecureTLS
+	}
+	rep.Duration = time.Since(start).Seconds()
+	return rep, err
 }
 
 func isTimeoutErr(err error) bool {
@@ -154,3 +337,18 @@ func isTimeoutErr(err error) bool {
 	}
 	return false
 }
+
+// captureHeaders picks the allow-listed headers out of h; nil when none is present.
+func captureHeaders(h http.Header, names []string) map[string]string {
+	var out map[string]string
+	for _, name := range names {
+		key := http.CanonicalHeaderKey(name)
+		if values := h.Values(key); len(values) > 0 {
+			if out == nil {
+				out = make(map[string]string, len(names))
+			}
+			out[key] = values[0]
+		}
+	}
+	return out
+}
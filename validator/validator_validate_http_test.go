@@ -1,15 +1,20 @@
 package validator
 
 import (
+	"context"
 	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
-	"watchdog_exporter/config"
-
 	"github.com/stretchr/testify/assert"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+	"github.com/kinjelom/watchdog_exporter/status"
 )
 
 func TestValidate_HTTP_SimpleMatrix(t *testing.T) {
@@ -20,7 +25,7 @@ func TestValidate_HTTP_SimpleMatrix(t *testing.T) {
 		body         string
 		bodyLimit    int64
 		validation   *config.EndpointValidation
-		expectStatus string
+		expectStatus status.Status
 	}{
 		{
 			name:       "Success all match",
@@ -33,14 +38,14 @@ func TestValidate_HTTP_SimpleMatrix(t *testing.T) {
 				Headers:    map[string]string{"X-Test": "value"},
 				BodyRegex:  "hello",
 			},
-			expectStatus: "valid",
+			expectStatus: status.Valid,
 		},
 		{
 			name:         "unexpected-status-code",
 			statusCode:   http.StatusInternalServerError,
 			bodyLimit:    10,
 			validation:   &config.EndpointValidation{StatusCode: http.StatusOK},
-			expectStatus: "unexpected-status-code",
+			expectStatus: status.UnexpectedStatusCode,
 		},
 		{
 			name:         "invalid header value",
@@ -48,7 +53,7 @@ func TestValidate_HTTP_SimpleMatrix(t *testing.T) {
 			headers:      map[string]string{"X-Test": "bad"},
 			bodyLimit:    10,
 			validation:   &config.EndpointValidation{StatusCode: http.StatusOK, Headers: map[string]string{"X-Test": "good"}},
-			expectStatus: "unexpected-header-value",
+			expectStatus: status.UnexpectedHeaderValue,
 		},
 		{
 			name:         "Body regex mismatch",
@@ -56,7 +61,7 @@ func TestValidate_HTTP_SimpleMatrix(t *testing.T) {
 			body:         "abcdef",
 			bodyLimit:    100,
 			validation:   &config.EndpointValidation{StatusCode: http.StatusOK, BodyRegex: "xyz"},
-			expectStatus: "unexpected-body-regex",
+			expectStatus: status.UnexpectedBodyRegex,
 		},
 		{
 			name:         "Body limit prevents full match",
@@ -64,7 +69,7 @@ func TestValidate_HTTP_SimpleMatrix(t *testing.T) {
 			body:         "abcdef",
 			bodyLimit:    3,
 			validation:   &config.EndpointValidation{StatusCode: http.StatusOK, BodyRegex: "abcd"},
-			expectStatus: "unexpected-body-regex",
+			expectStatus: status.UnexpectedBodyRegex,
 		},
 		{
 			name:         "Body limit allows partial match",
@@ -72,7 +77,7 @@ func TestValidate_HTTP_SimpleMatrix(t *testing.T) {
 			body:         "abcdef",
 			bodyLimit:    3,
 			validation:   &config.EndpointValidation{StatusCode: http.StatusOK, BodyRegex: "abc"},
-			expectStatus: "valid",
+			expectStatus: status.Valid,
 		},
 	}
 
@@ -100,15 +105,83 @@ func TestValidate_HTTP_SimpleMatrix(t *testing.T) {
 			hc := NewDefaultHTTPResponseChecker(false)
 			v := NewWatchDogValidator(tc, hc, false)
 
-			status, duration, rep, err := v.Validate("ep", req, "rt", route, tt.validation, false)
+			st, duration, rep, err := v.Validate("ep", req, "rt", route, tt.validation, false)
 			assert.NoError(t, err)
-			assert.Equal(t, tt.expectStatus, status)
+			assert.Equal(t, tt.expectStatus, st)
 			assert.GreaterOrEqual(t, duration, 0.0)
 			assert.Nil(t, rep)
 		})
 	}
 }
 
+func TestHTTPResponseChecker_MatchesEvaluatedIndependently(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-A", "a")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = io.WriteString(w, "maintenance")
+	}))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	assert.NoError(t, err)
+	defer func(Body io.ReadCloser) {
+		_ = Body.Close()
+	}(resp.Body)
+
+	checker := NewDefaultHTTPResponseChecker(false)
+	st, matches, err := checker.ValidateResponse(srv.URL, "r1", resp, 1024, config.EndpointValidation{
+		StatusCode: http.StatusOK,
+		Headers:    map[string]string{"X-A": "a"},
+		BodyRegex:  "ok",
+	})
+	assert.NoError(t, err)
+	// status code wins the consolidated status, content checks are still reported
+	assert.Equal(t, status.UnexpectedStatusCode, st)
+	if assert.NotNil(t, matches.Header) && assert.NotNil(t, matches.Body) {
+		assert.True(t, *matches.Header)
+		assert.False(t, *matches.Body)
+	}
+}
+
+func TestProbe_RecordsRemoteIP(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	req := config.EndpointRequest{URL: srv.URL, Timeout: 2 * time.Second, Method: http.MethodGet}
+	v := NewWatchDogValidator(NewDefaultTLSChecker(false), NewDefaultHTTPResponseChecker(false), false)
+
+	rep, err := v.Probe("ep", req, "rt", config.Route{}, &config.EndpointValidation{StatusCode: http.StatusOK}, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "127.0.0.1", rep.RemoteIP)
+}
+
+func TestProbe_FollowRedirectsCountsHops(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/a", func(w http.ResponseWriter, r *http.Request) { http.Redirect(w, r, "/b", http.StatusFound) })
+	mux.HandleFunc("/b", func(w http.ResponseWriter, r *http.Request) { http.Redirect(w, r, "/c", http.StatusMovedPermanently) })
+	mux.HandleFunc("/c", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	v := NewWatchDogValidator(NewDefaultTLSChecker(false), NewDefaultHTTPResponseChecker(false), false)
+	validation := &config.EndpointValidation{StatusCode: http.StatusOK}
+
+	req := config.EndpointRequest{URL: srv.URL + "/a", Timeout: 2 * time.Second, Method: http.MethodGet, FollowRedirects: true}
+	rep, err := v.Probe("ep", req, "rt", config.Route{}, validation, false)
+	assert.NoError(t, err)
+	assert.Equal(t, status.Valid, rep.Status)
+	assert.Equal(t, 2, rep.Redirects)
+
+	// default: redirects are not followed
+	req.FollowRedirects = false
+	rep, err = v.Probe("ep", req, "rt", config.Route{}, validation, false)
+	assert.NoError(t, err)
+	assert.Equal(t, status.UnexpectedStatusCode, rep.Status)
+	assert.Equal(t, 0, rep.Redirects)
+}
+
 func TestValidateTimeoutOnHeadersDelay(t *testing.T) {
 	delay := 300 * time.Millisecond
 	timeout := 100 * time.Millisecond
@@ -131,9 +204,9 @@ func TestValidateTimeoutOnHeadersDelay(t *testing.T) {
 	hc := NewDefaultHTTPResponseChecker(false)
 	v := NewWatchDogValidator(tc, hc, false)
 
-	status, dur, rep, err := v.Validate("ep", req, "rt", route, &config.EndpointValidation{StatusCode: http.StatusOK}, false)
+	st, dur, rep, err := v.Validate("ep", req, "rt", route, &config.EndpointValidation{StatusCode: http.StatusOK}, false)
 	assert.Error(t, err)
-	assert.Equal(t, "request-execution-timeout", status)
+	assert.Equal(t, status.RequestExecutionTimeout, st)
 	upper := (timeout + 200*time.Millisecond).Seconds()
 	assert.LessOrEqual(t, dur, upper)
 	assert.Nil(t, rep)
@@ -165,9 +238,9 @@ func TestValidateTimeoutDuringBodyRead(t *testing.T) {
 	hc := NewDefaultHTTPResponseChecker(false)
 	v := NewWatchDogValidator(tc, hc, false)
 
-	status, dur, rep, err := v.Validate("ep", req, "rt", route, &config.EndpointValidation{StatusCode: http.StatusOK, BodyRegex: "hello"}, false)
+	st, dur, rep, err := v.Validate("ep", req, "rt", route, &config.EndpointValidation{StatusCode: http.StatusOK, BodyRegex: "hello"}, false)
 	assert.Error(t, err)
-	assert.Equal(t, "request-execution-timeout", status)
+	assert.Equal(t, status.RequestExecutionTimeout, st)
 	upper := (timeout + 200*time.Millisecond).Seconds()
 	assert.LessOrEqual(t, dur, upper)
 	assert.Nil(t, rep)
@@ -189,44 +262,353 @@ func TestHTTPResponseChecker_ValidateResponse(t *testing.T) {
 	checker := NewDefaultHTTPResponseChecker(false)
 
 	// status mismatch
-	st, err := checker.ValidateResponse(srv.URL, "r1", resp, 1024, config.EndpointValidation{StatusCode: http.StatusOK})
+	st, _, err := checker.ValidateResponse(srv.URL, "r1", resp, 1024, config.EndpointValidation{StatusCode: http.StatusOK})
 	assert.NoError(t, err)
-	assert.Equal(t, "unexpected-status-code", st)
+	assert.Equal(t, status.UnexpectedStatusCode, st)
 
 	// headers mismatch
 	resp2, _ := http.Get(srv.URL)
 	defer func(Body io.ReadCloser) {
 		_ = Body.Close()
 	}(resp2.Body)
-	st, err = checker.ValidateResponse(srv.URL, "r1", resp2, 1024, config.EndpointValidation{
+	st, _, err = checker.ValidateResponse(srv.URL, "r1", resp2, 1024, config.EndpointValidation{
 		StatusCode: http.StatusCreated,
 		Headers:    map[string]string{"X-A": "b"},
 	})
 	assert.NoError(t, err)
-	assert.Equal(t, "unexpected-header-value", st)
+	assert.Equal(t, status.UnexpectedHeaderValue, st)
 
 	// body regex mismatch
 	resp3, _ := http.Get(srv.URL)
 	defer func(Body io.ReadCloser) {
 		_ = Body.Close()
 	}(resp3.Body)
-	st, err = checker.ValidateResponse(srv.URL, "r1", resp3, 1024, config.EndpointValidation{
+	st, _, err = checker.ValidateResponse(srv.URL, "r1", resp3, 1024, config.EndpointValidation{
 		StatusCode: http.StatusCreated,
 		BodyRegex:  "xyz",
 	})
 	assert.NoError(t, err)
-	assert.Equal(t, "unexpected-body-regex", st)
+	assert.Equal(t, status.UnexpectedBodyRegex, st)
 
 	// all good
 	resp4, _ := http.Get(srv.URL)
 	defer func(Body io.ReadCloser) {
 		_ = Body.Close()
 	}(resp4.Body)
-	st, err = checker.ValidateResponse(srv.URL, "r1", resp4, 1024, config.EndpointValidation{
+	st, _, err = checker.ValidateResponse(srv.URL, "r1", resp4, 1024, config.EndpointValidation{
 		StatusCode: http.StatusCreated,
 		Headers:    map[string]string{"X-A": "a"},
 		BodyRegex:  "ping",
 	})
 	assert.NoError(t, err)
-	assert.Equal(t, "valid", st)
+	assert.Equal(t, status.Valid, st)
+}
+
+func TestHTTPResponseChecker_StreamsBodyRegex(t *testing.T) {
+	body := strings.Repeat("x", 100<<10) + "needle" + strings.Repeat("y", 10)
+	c := NewDefaultHTTPResponseChecker(false)
+	v := config.EndpointValidation{StatusCode: http.StatusOK, BodyRegex: "ne+dle"}
+	check := func(limit int64) status.Status {
+		resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(body))}
+		st, _, err := c.ValidateResponse("http://x", "rt", resp, limit, v)
+		assert.NoError(t, err)
+		return st
+	}
+	assert.Equal(t, status.Valid, check(200<<10), "match past the read window")
+	assert.Equal(t, status.UnexpectedBodyRegex, check(50<<10), "only the limited prefix is searched")
+}
+
+func BenchmarkHTTPResponseChecker_BodyRegex(b *testing.B) {
+	body := strings.Repeat("lorem ipsum dolor sit amet ", 40<<10) + "Example Domain"
+	c := NewDefaultHTTPResponseChecker(false)
+	v := config.EndpointValidation{StatusCode: http.StatusOK, BodyRegex: ".*Example Domain.*"}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(body))}
+		_, _, _ = c.ValidateResponse("http://x", "rt", resp, int64(len(body)), v)
+	}
+}
+
+func TestProbeIP_ProbesEveryResolvedAddress(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "backend.test", r.Host, "the original host is kept")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+	port := srv.URL[strings.LastIndex(srv.URL, ":")+1:]
+
+	cache, err := NewDNSCache(config.DNSCacheConfig{MinTTL: time.Second, MaxTTL: time.Minute, Servers: []string{"127.0.0.1:1"}})
+	assert.NoError(t, err)
+	cache.query = func(_ context.Context, _ string) ([]net.IP, time.Duration, error) {
+		// 127.0.0.2 is loopback too, but nothing listens there: a broken backend behind round-robin DNS
+		return []net.IP{net.ParseIP("127.0.0.1"), net.ParseIP("127.0.0.2")}, time.Minute, nil
+	}
+	v := NewWatchDogValidator(NewDefaultTLSChecker(false), NewDefaultHTTPResponseChecker(false), false)
+	v.SetDNSCache(cache)
+
+	rc := config.EndpointRequest{Method: http.MethodGet, URL: "http://backend.test:" + port + "/", Timeout: time.Second}
+	ips, err := v.ResolveHost(context.Background(), rc)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"127.0.0.1", "127.0.0.2"}, ips)
+
+	validation := &config.EndpointValidation{StatusCode: http.StatusOK}
+	rep, err := v.ProbeIP("ep", rc, "direct", config.Route{}, "127.0.0.1", validation, false)
+	assert.NoError(t, err)
+	assert.Equal(t, status.Valid, rep.Status)
+	assert.Equal(t, "127.0.0.1", rep.RemoteIP)
+
+	rep, err = v.ProbeIP("ep", rc, "direct", config.Route{}, "127.0.0.2", validation, false)
+	assert.Error(t, err)
+	assert.Equal(t, status.InvalidRequestExecution, rep.Status)
+}
+
+func TestResolveHost_IPLiteral(t *testing.T) {
+	v := NewWatchDogValidator(NewDefaultTLSChecker(false), NewDefaultHTTPResponseChecker(false), false)
+	ips, err := v.ResolveHost(context.Background(), config.EndpointRequest{URL: "https://[::1]:8443/health"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"::1"}, ips)
+}
+
+func TestProbe_CapturesAllowListedHeaders(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Build-Version", "1.4.2")
+		w.Header().Add("Via", "1.1 pop-waw")
+		w.Header().Add("Via", "1.1 origin")
+		w.Header().Set("Set-Cookie", "session=secret")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	v := NewWatchDogValidator(NewDefaultTLSChecker(false), NewDefaultHTTPResponseChecker(false), false)
+	req := config.EndpointRequest{URL: srv.URL, Timeout: 2 * time.Second, Method: http.MethodGet}
+	validation := &config.EndpointValidation{StatusCode: http.StatusOK, CaptureHeaders: []string{"x-build-version", "Via", "X-Missing"}}
+
+	rep, err := v.Probe("ep", req, "rt", config.Route{}, validation, false)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"X-Build-Version": "1.4.2", "Via": "1.1 pop-waw"}, rep.Headers,
+		"canonical names, first value, only listed headers")
+}
+
+func TestHTTPResponseChecker_Freshness(t *testing.T) {
+	now := time.Now().UTC()
+	recent, old := now.Add(-time.Minute), now.Add(-2*time.Hour)
+	tests := []struct {
+		name      string
+		headers   map[string]string
+		body      string
+		freshness config.Freshness
+		bodyRegex string
+		expect    status.Status
+	}{
+		{
+			name:      "recent last-modified",
+			headers:   map[string]string{"Last-Modified": recent.Format(http.TimeFormat)},
+			freshness: config.Freshness{MaxAge: time.Hour, Source: config.FreshnessLastModified},
+			expect:    status.Valid,
+		},
+		{
+			name:      "old last-modified",
+			headers:   map[string]string{"Last-Modified": old.Format(http.TimeFormat)},
+			freshness: config.Freshness{MaxAge: time.Hour, Source: config.FreshnessLastModified},
+			expect:    status.StaleContent,
+		},
+		{
+			name:      "missing header is stale",
+			freshness: config.Freshness{MaxAge: time.Hour, Source: config.FreshnessLastModified},
+			expect:    status.StaleContent,
+		},
+		{
+			name:      "cached date",
+			headers:   map[string]string{"Date": old.Format(http.TimeFormat)},
+			freshness: config.Freshness{MaxAge: time.Hour, Source: config.FreshnessDate},
+			expect:    status.StaleContent,
+		},
+		{
+			name:      "body RFC 3339",
+			body:      `{"status":"ok","updated":"` + recent.Format(time.RFC3339) + `"}`,
+			freshness: config.Freshness{MaxAge: time.Hour, Source: config.FreshnessBody, BodyRegex: `"updated":"([^"]+)"`, Layout: time.RFC3339},
+			bodyRegex: `"status":"ok"`,
+			expect:    status.Valid,
+		},
+		{
+			name:      "body unix seconds",
+			body:      "generated " + strconv.FormatInt(old.Unix(), 10),
+			freshness: config.Freshness{MaxAge: time.Hour, Source: config.FreshnessBody, BodyRegex: `generated (\d+)`, Layout: "unix"},
+			expect:    status.StaleContent,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				for k, v := range tt.headers {
+					w.Header()[k] = []string{v}
+				}
+				w.WriteHeader(http.StatusOK)
+				_, _ = io.WriteString(w, tt.body)
+			}))
+			defer srv.Close()
+			resp, err := http.Get(srv.URL)
+			assert.NoError(t, err)
+			defer func() { _ = resp.Body.Close() }()
+
+			fresh := tt.freshness
+			st, matches, err := NewDefaultHTTPResponseChecker(false).ValidateResponse(srv.URL, "r1", resp, 1024,
+				config.EndpointValidation{StatusCode: http.StatusOK, BodyRegex: tt.bodyRegex, Freshness: &fresh})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expect, st)
+			if tt.bodyRegex != "" {
+				assert.True(t, *matches.Body, "the body regex sees the buffered body")
+			}
+		})
+	}
+}
+
+func TestHTTPResponseChecker_BodyNotRegex(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		bodyRegex string
+		expect    status.Status
+	}{
+		{"clean page", "<h1>Welcome</h1>", "Welcome", status.Valid},
+		{"error page with 200", "<h1>Welcome</h1><pre>java.lang.NullPointerException\n\tat com.example</pre>", "Welcome", status.ForbiddenBodyRegex},
+		{"maintenance page", "Down for maintenance", "", status.ForbiddenBodyRegex},
+		{"body regex fails first", "Down for maintenance", "Welcome", status.UnexpectedBodyRegex},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = io.WriteString(w, tt.body)
+			}))
+			defer srv.Close()
+			resp, err := http.Get(srv.URL)
+			assert.NoError(t, err)
+			defer func() { _ = resp.Body.Close() }()
+
+			st, _, err := NewDefaultHTTPResponseChecker(false).ValidateResponse(srv.URL, "r1", resp, 1024, config.EndpointValidation{
+				StatusCode:   http.StatusOK,
+				BodyRegex:    tt.bodyRegex,
+				BodyNotRegex: `(?i)exception|stack trace|maintenance`,
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expect, st)
+		})
+	}
+}
+
+func TestHTTPResponseChecker_HeadersRegex(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Header().Set("X-App-Version", "2.14.3")
+		_, _ = io.WriteString(w, `{}`)
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name   string
+		regex  map[string]string
+		expect status.Status
+	}{
+		{"charset suffix", map[string]string{"content-type": `^application/json(;.*)?$`}, status.Valid},
+		{"version pattern", map[string]string{"X-App-Version": `^2\.\d+\.\d+$`}, status.Valid},
+		{"wrong version", map[string]string{"X-App-Version": `^3\.`}, status.UnexpectedHeaderValue},
+		{"missing header", map[string]string{"X-Missing": `.*`}, status.UnexpectedHeaderValue},
+		{"invalid regex", map[string]string{"X-App-Version": `(`}, status.UnexpectedHeaderValue},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Get(srv.URL)
+			assert.NoError(t, err)
+			defer func() { _ = resp.Body.Close() }()
+
+			st, matches, err := NewDefaultHTTPResponseChecker(false).ValidateResponse(srv.URL, "r1", resp, 1024, config.EndpointValidation{
+				StatusCode:   http.StatusOK,
+				HeadersRegex: tt.regex,
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expect, st)
+			if assert.NotNil(t, matches.Header) {
+				assert.Equal(t, tt.expect == status.Valid, *matches.Header)
+			}
+		})
+	}
+}
+
+func TestHTTPResponseChecker_HeadersAbsent(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Has("leak") {
+			w.Header().Set("X-Powered-By", "PHP/8.1.2")
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name    string
+		query   string
+		headers map[string]string
+		expect  status.Status
+	}{
+		{"stripped", "", nil, status.Valid},
+		{"leaked", "?leak", nil, status.UnexpectedHeaderPresent},
+		{"value check reported first", "?leak", map[string]string{"Content-Type": "application/json"}, status.UnexpectedHeaderValue},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Get(srv.URL + tt.query)
+			assert.NoError(t, err)
+			defer func() { _ = resp.Body.Close() }()
+
+			st, matches, err := NewDefaultHTTPResponseChecker(false).ValidateResponse(srv.URL, "r1", resp, 1024, config.EndpointValidation{
+				StatusCode:    http.StatusOK,
+				Headers:       tt.headers,
+				HeadersAbsent: []string{"server", "x-powered-by"},
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expect, st)
+			if assert.NotNil(t, matches.Header) {
+				assert.Equal(t, tt.expect == status.Valid, *matches.Header)
+			}
+		})
+	}
+}
+
+func TestProbe_ExpectedFinalURL(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/vanity", func(w http.ResponseWriter, r *http.Request) { http.Redirect(w, r, "/www", http.StatusMovedPermanently) })
+	mux.HandleFunc("/www", func(w http.ResponseWriter, r *http.Request) { http.Redirect(w, r, "/canonical/", http.StatusFound) })
+	mux.HandleFunc("/canonical/", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	mux.HandleFunc("/parked", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	v := NewWatchDogValidator(NewDefaultTLSChecker(false), NewDefaultHTTPResponseChecker(false), false)
+	validation := &config.EndpointValidation{StatusCode: http.StatusOK, ExpectedFinalURLRegex: `/canonical/$`}
+	req := config.EndpointRequest{URL: srv.URL + "/vanity", Timeout: 2 * time.Second, Method: http.MethodGet, FollowRedirects: true}
+
+	rep, err := v.Probe("ep", req, "rt", config.Route{}, validation, false)
+	assert.NoError(t, err)
+	assert.Equal(t, status.Valid, rep.Status)
+	assert.Equal(t, 2, rep.Redirects)
+
+	// the response arrived, but on the wrong page
+	req.URL = srv.URL + "/parked"
+	rep, err = v.Probe("ep", req, "rt", config.Route{}, validation, false)
+	assert.NoError(t, err)
+	assert.Equal(t, status.UnexpectedFinalURL, rep.Status)
+
+	// max-redirects caps the chain below the default
+	req.URL = srv.URL + "/vanity"
+	limit := 1
+	req.MaxRedirects = &limit
+	rep, err = v.Probe("ep", req, "rt", config.Route{}, validation, false)
+	assert.ErrorContains(t, err, "stopped after 1 redirects")
+	assert.Equal(t, status.InvalidRequestExecution, rep.Status)
+
+	// max-redirects: 0 allows no redirect at all
+	limit = 0
+	rep, err = v.Probe("ep", req, "rt", config.Route{}, validation, false)
+	assert.ErrorContains(t, err, "stopped after 0 redirects")
+	assert.Equal(t, status.InvalidRequestExecution, rep.Status)
 }
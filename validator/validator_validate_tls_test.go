@@ -1,17 +1,29 @@
 package validator
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"crypto/tls"
 	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
 	"io"
+	"math/big"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
-	"watchdog_exporter/config"
-
 	"github.com/stretchr/testify/assert"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+	"github.com/kinjelom/watchdog_exporter/status"
 )
 
 // testTLSChecker lets us inject a tls.Config with custom RootCAs + delegates Inspect/Check to DefaultTLSChecker.
@@ -32,7 +44,7 @@ func (t *testTLSChecker) TLSClientConfigWithSNI(serverName string) *tls.Config {
 	}
 }
 
-func (t *testTLSChecker) CheckHandshakeError(err error) (string, bool) {
+func (t *testTLSChecker) CheckHandshakeError(err error) (status.Status, bool) {
 	return t.delegate.CheckHandshakeError(err)
 }
 
@@ -61,9 +73,9 @@ func TestValidate_TLS_InvalidChain(t *testing.T) {
 	hc := NewDefaultHTTPResponseChecker(false)
 	v := NewWatchDogValidator(tc, hc, false)
 
-	status, duration, rep, err := v.Validate("ep", req, "rt", route, &config.EndpointValidation{StatusCode: http.StatusOK}, true)
+	st, duration, rep, err := v.Validate("ep", req, "rt", route, &config.EndpointValidation{StatusCode: http.StatusOK}, true)
 	assert.Error(t, err)
-	assert.Equal(t, "invalid-tls-chain", status)
+	assert.Equal(t, status.InvalidTLSChain, st)
 	assert.GreaterOrEqual(t, duration, 0.0)
 	assert.Nil(t, rep)
 }
@@ -102,9 +114,9 @@ func TestValidate_TLS_ChainValid_And_CertList(t *testing.T) {
 	route := config.Route{}
 
 	// Run validate with TLS check enabled so Inspect() is used.
-	status, duration, rep, err := v.Validate("ep", req, "rt", route, &config.EndpointValidation{StatusCode: http.StatusOK}, true)
+	st, duration, rep, err := v.Validate("ep", req, "rt", route, &config.EndpointValidation{StatusCode: http.StatusOK}, true)
 	assert.NoError(t, err)
-	assert.Equal(t, "valid", status)
+	assert.Equal(t, status.Valid, st)
 	assert.GreaterOrEqual(t, duration, 0.0)
 	assert.NotNil(t, rep)
 
@@ -123,3 +135,321 @@ func TestValidate_TLS_ChainValid_And_CertList(t *testing.T) {
 		assert.NotZero(t, leaf.NotAfter.Unix())
 	}
 }
+
+// trustingValidator returns a validator that trusts the certificate of srv.
+func trustingValidator(t *testing.T, srv *httptest.Server) *WatchDogValidator {
+	cert, err := x509.ParseCertificate(srv.TLS.Certificates[0].Certificate[0])
+	assert.NoError(t, err)
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return NewWatchDogValidator(&testTLSChecker{rootCAs: pool, delegate: NewDefaultTLSChecker(false)}, NewDefaultHTTPResponseChecker(false), false)
+}
+
+func TestValidate_ExpectedALPN(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	h2 := httptest.NewUnstartedServer(ok)
+	h2.EnableHTTP2 = true
+	h2.StartTLS()
+	defer h2.Close()
+	h1 := httptest.NewTLSServer(ok)
+	defer h1.Close()
+
+	cases := []struct {
+		name string
+		srv  *httptest.Server
+		alpn string
+		want status.Status
+	}{
+		{"h2 negotiated", h2, config.ALPNHTTP2, status.Valid},
+		{"downgraded to http/1.1", h1, config.ALPNHTTP2, status.UnexpectedHTTPVersion},
+		{"http/1.1 expected", h1, config.ALPNHTTP11, status.Valid},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			v := trustingValidator(t, tc.srv)
+			req := config.EndpointRequest{URL: tc.srv.URL, Timeout: 2 * time.Second, Method: http.MethodGet}
+			st, _, _, err := v.Validate("ep", req, "rt", config.Route{}, &config.EndpointValidation{StatusCode: http.StatusOK, ExpectedALPN: tc.alpn}, false)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, st)
+		})
+	}
+}
+
+// writeClientCert creates a self-signed client certificate and returns its PEM files and the certificate.
+func writeClientCert(t *testing.T) (certFile, keyFile string, cert *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "watchdog-probe"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	assert.NoError(t, err)
+	cert, err = x509.ParseCertificate(der)
+	assert.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+
+	dir := t.TempDir()
+	certFile, keyFile = filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key")
+	assert.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	assert.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return certFile, keyFile, cert
+}
+
+func TestValidate_ClientCertificate(t *testing.T) {
+	certFile, keyFile, clientCert := writeClientCert(t)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }))
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	srv.StartTLS()
+	defer srv.Close()
+	v := trustingValidator(t, srv)
+	validation := &config.EndpointValidation{StatusCode: http.StatusOK}
+
+	req := config.EndpointRequest{URL: srv.URL, Timeout: 2 * time.Second, Method: http.MethodGet, TLSClientCert: certFile, TLSClientKey: keyFile}
+	st, _, _, err := v.Validate("mtls", req, "rt", config.Route{}, validation, false)
+	assert.NoError(t, err)
+	assert.Equal(t, status.Valid, st)
+
+	req.TLSClientCert, req.TLSClientKey = "", ""
+	st, _, _, err = v.Validate("anonymous", req, "rt", config.Route{}, validation, false)
+	assert.Error(t, err)
+	assert.True(t, st.Failed())
+
+	req.TLSClientCert, req.TLSClientKey = keyFile, certFile
+	st, _, _, err = v.Validate("swapped", req, "rt", config.Route{}, validation, false)
+	assert.Error(t, err)
+	assert.Equal(t, status.InvalidRequestDefinition, st)
+
+	// the same pair from the secret source (request.tls-client-vault)
+	certPEM, err := os.ReadFile(certFile)
+	assert.NoError(t, err)
+	keyPEM, err := os.ReadFile(keyFile)
+	assert.NoError(t, err)
+	req.TLSClientCert, req.TLSClientKey, req.TLSClientVault = "", "", "secret/data/watchdog/mtls"
+	st, _, _, err = v.Validate("vault", req, "rt", config.Route{}, validation, false)
+	assert.Error(t, err, "no secret source")
+	assert.Equal(t, status.InvalidRequestDefinition, st)
+
+	v.SetSecretSource(fakeSecrets{"secret/data/watchdog/mtls": {"certificate": string(certPEM), "private_key": string(keyPEM)}})
+	st, _, _, err = v.Validate("vault", req, "rt", config.Route{}, validation, false)
+	assert.NoError(t, err)
+	assert.Equal(t, status.Valid, st)
+}
+
+// fakeSecrets is a SecretSource: path -> field -> value.
+type fakeSecrets map[string]map[string]string
+
+func (f fakeSecrets) Secret(path, field string) (string, error) {
+	value, ok := f[path][field]
+	if !ok {
+		return "", fmt.Errorf("no %s in %s", field, path)
+	}
+	return value, nil
+}
+
+func TestValidate_CipherSuitePolicy(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }))
+	srv.TLS = &tls.Config{MaxVersion: tls.VersionTLS12}
+	srv.StartTLS()
+	defer srv.Close()
+	v := trustingValidator(t, srv)
+	req := config.EndpointRequest{URL: srv.URL, Timeout: 2 * time.Second, Method: http.MethodGet}
+
+	_, _, certs, err := v.Validate("ep", req, "rt", config.Route{}, &config.EndpointValidation{StatusCode: http.StatusOK}, true)
+	assert.NoError(t, err)
+	negotiated := certs.CipherSuite
+	other := "TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256"
+	if negotiated == other {
+		other = "TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256"
+	}
+
+	cases := []struct {
+		name      string
+		allowed   []string
+		forbidden []string
+		want      status.Status
+	}{
+		{"allowed", []string{negotiated}, nil, status.Valid},
+		{"not allowed", []string{other}, nil, status.WeakCipherSuite},
+		{"forbidden", nil, []string{negotiated}, status.WeakCipherSuite},
+		{"other forbidden", nil, []string{other}, status.Valid},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			validation := &config.EndpointValidation{StatusCode: http.StatusOK, AllowedCipherSuites: tc.allowed, ForbiddenCipherSuites: tc.forbidden}
+			st, _, _, err := v.Validate("ep", req, "rt", config.Route{}, validation, false)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, st)
+		})
+	}
+}
+
+func TestValidate_PinSHA256(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }))
+	defer srv.Close()
+	leaf, err := x509.ParseCertificate(srv.TLS.Certificates[0].Certificate[0])
+	assert.NoError(t, err)
+	pin := spkiSHA256(leaf)
+	otherPin := "47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="
+	v := trustingValidator(t, srv)
+	req := config.EndpointRequest{URL: srv.URL, Timeout: 2 * time.Second, Method: http.MethodGet}
+
+	rep, err := v.Probe("ep", req, "rt", config.Route{}, &config.EndpointValidation{StatusCode: http.StatusOK, PinSHA256: []string{otherPin, pin}}, true)
+	assert.NoError(t, err)
+	assert.Equal(t, status.Valid, rep.Status)
+	if assert.NotNil(t, rep.PinMatch) {
+		assert.True(t, *rep.PinMatch)
+	}
+	assert.Equal(t, pin, rep.TLS.Certificates[0].SPKISHA256)
+
+	rep, err = v.Probe("ep", req, "rt", config.Route{}, &config.EndpointValidation{StatusCode: http.StatusOK, PinSHA256: []string{otherPin}}, false)
+	assert.NoError(t, err)
+	assert.Equal(t, status.CertPinMismatch, rep.Status)
+	if assert.NotNil(t, rep.PinMatch) {
+		assert.False(t, *rep.PinMatch)
+	}
+
+	rep, err = v.Probe("ep", req, "rt", config.Route{}, &config.EndpointValidation{StatusCode: http.StatusOK}, false)
+	assert.NoError(t, err)
+	assert.Nil(t, rep.PinMatch)
+}
+
+func TestValidate_CertIssuerAndCN(t *testing.T) {
+	// The httptest certificate is self-signed for "O=Acme Co", without a CN.
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }))
+	defer srv.Close()
+	v := trustingValidator(t, srv)
+	req := config.EndpointRequest{URL: srv.URL, Timeout: 2 * time.Second, Method: http.MethodGet}
+
+	cases := []struct {
+		name     string
+		issuerRe string
+		cnRe     string
+		want     status.Status
+	}{
+		{"issuer matches", `O=Acme Co`, "", status.Valid},
+		{"other CA", `O=Let's Encrypt`, "", status.UnexpectedCertIssuer},
+		{"cn mismatch", `Acme`, `^api\.example\.com$`, status.UnexpectedCertIssuer},
+		{"empty cn allowed", "", `^$`, status.Valid},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			validation := &config.EndpointValidation{StatusCode: http.StatusOK, CertIssuerRegex: tc.issuerRe, CertCNRegex: tc.cnRe}
+			st, _, _, err := v.Validate("ep", req, "rt", config.Route{}, validation, false)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, st)
+		})
+	}
+}
+
+func TestValidate_MinCertDaysLeft(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }))
+	defer srv.Close()
+	v := trustingValidator(t, srv)
+	req := config.EndpointRequest{URL: srv.URL, Timeout: 2 * time.Second, Method: http.MethodGet}
+
+	rep, err := v.Probe("ep", req, "rt", config.Route{}, &config.EndpointValidation{StatusCode: http.StatusOK, MinCertDaysLeft: 30}, true)
+	assert.NoError(t, err)
+	assert.Equal(t, status.Valid, rep.Status)
+
+	// The httptest certificate expires decades from now; a larger threshold flags it, the chain is still reported.
+	rep, err = v.Probe("ep", req, "rt", config.Route{}, &config.EndpointValidation{StatusCode: http.StatusOK, MinCertDaysLeft: 1e6}, true)
+	assert.NoError(t, err)
+	assert.Equal(t, status.CertExpiringSoon, rep.Status)
+	if assert.NotNil(t, rep.TLS) {
+		assert.NotEmpty(t, rep.TLS.Certificates)
+	}
+}
+
+func TestValidate_InsecureSkipVerify(t *testing.T) {
+	// Self-signed for the default roots: insecure-skip-verify still probes it, with a distinct status.
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }))
+	defer srv.Close()
+	v := NewWatchDogValidator(NewDefaultTLSChecker(false), NewDefaultHTTPResponseChecker(false), false)
+	req := config.EndpointRequest{URL: srv.URL, Timeout: 2 * time.Second, Method: http.MethodGet, TLSInsecureSkipVerify: true}
+
+	rep, err := v.Probe("dev", req, "rt", config.Route{}, &config.EndpointValidation{StatusCode: http.StatusOK}, true)
+	assert.NoError(t, err)
+	assert.Equal(t, status.ValidInsecureTLS, rep.Status)
+	if assert.NotNil(t, rep.TLS) {
+		assert.True(t, rep.TLS.Insecure)
+		assert.False(t, rep.TLS.ChainValid)
+		assert.NotEmpty(t, rep.TLS.Certificates)
+	}
+	assert.Equal(t, status.ValidInsecureTLS, ResolveStatus(rep.Status, err, rep.TLS))
+	assert.False(t, rep.Status.Failed())
+
+	rep, err = v.Probe("dev", req, "rt", config.Route{}, &config.EndpointValidation{StatusCode: http.StatusNoContent}, true)
+	assert.NoError(t, err)
+	assert.Equal(t, status.UnexpectedStatusCode, rep.Status)
+}
+
+func TestValidate_SNIOverride(t *testing.T) {
+	var seen string
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = r.TLS.ServerName
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+	validation := &config.EndpointValidation{StatusCode: http.StatusOK}
+
+	// The URL host is 127.0.0.1; the httptest certificate also covers example.com.
+	req := config.EndpointRequest{URL: srv.URL, Timeout: 2 * time.Second, Method: http.MethodGet, SNI: "example.com"}
+	st, _, _, err := trustingValidator(t, srv).Validate("ep", req, "rt", config.Route{}, validation, false)
+	assert.NoError(t, err)
+	assert.Equal(t, status.Valid, st)
+	assert.Equal(t, "example.com", seen)
+
+	req.SNI = "tenant.example.net"
+	st, _, _, err = trustingValidator(t, srv).Validate("ep", req, "rt", config.Route{}, validation, false)
+	assert.Error(t, err)
+	assert.Equal(t, status.InvalidTLSHostname, st)
+}
+
+func TestValidate_Phases(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+	v := trustingValidator(t, srv)
+	req := config.EndpointRequest{URL: srv.URL, Timeout: 2 * time.Second, Method: http.MethodGet}
+
+	rep, err := v.Probe("ep", req, "rt", config.Route{}, &config.EndpointValidation{StatusCode: http.StatusOK}, false)
+	assert.NoError(t, err)
+	assert.Equal(t, status.Valid, rep.Status)
+	assert.Zero(t, rep.Phases.DNS, "an IP address is not resolved")
+	assert.Positive(t, rep.Phases.Connect)
+	assert.Positive(t, rep.Phases.TLS)
+	assert.GreaterOrEqual(t, rep.Phases.TTFB, 0.02)
+	assert.Greater(t, rep.Phases.TTFB, rep.Phases.Connect+rep.Phases.TLS)
+	assert.LessOrEqual(t, rep.Phases.TTFB, rep.Duration)
+}
+
+func TestDefaultTLSChecker_CheckHandshakeError(t *testing.T) {
+	tc := NewDefaultTLSChecker(false)
+	tests := []struct {
+		name string
+		err  error
+		want status.Status
+		ok   bool
+	}{
+		{"alert", &url.Error{Op: "Get", URL: "https://h/", Err: tls.AlertError(40)}, status.InvalidTLSChain, true},
+		{"record header", &url.Error{Op: "Get", URL: "https://h/", Err: tls.RecordHeaderError{Msg: "not tls"}}, status.InvalidTLSChain, true},
+		{"tls only in text", &url.Error{Op: "Get", URL: "https://h/", Err: errors.New("tls: certificate handshake")}, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tc.CheckHandshakeError(tt.err)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
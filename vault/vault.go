@@ -0,0 +1,321 @@
+// Package vault reads endpoint secrets from HashiCorp Vault: it logs in, keeps its token renewed and caches every
+// secret it read until the secret's lease (or refresh-interval) ends, so rotated secrets are picked up without a restart.
+package vault
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+)
+
+// errPermissionDenied marks a 403, after which the client logs in again once.
+var errPermissionDenied = errors.New("vault: permission denied")
+
+// Client is safe for concurrent use by probes.
+type Client struct {
+	cfg  config.VaultConfig
+	http *http.Client
+
+	mu        sync.Mutex // guards the token
+	token     string
+	tokenTTL  time.Duration // 0 = does not expire
+	renewable bool
+
+	cacheMu sync.Mutex
+	cache   map[string]cachedSecret
+}
+
+type cachedSecret struct {
+	values  map[string]any
+	expires time.Time
+}
+
+// New builds a client; it logs in on first use.
+func New(cfg config.VaultConfig) (*Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in %s", cfg.CAFile)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	}
+	return &Client{
+		cfg:   cfg,
+		http:  &http.Client{Timeout: cfg.Timeout, Transport: transport},
+		cache: make(map[string]cachedSecret),
+	}, nil
+}
+
+// Secret returns one field of the secret at path (KV v1 or v2, e.g. secret/data/watchdog/api). Cached values are
+// served until they expire; when Vault cannot be reached then, the expired value is used and a warning logged.
+func (c *Client) Secret(path, field string) (string, error) {
+	path = strings.Trim(path, "/")
+	c.cacheMu.Lock()
+	cached, ok := c.cache[path]
+	c.cacheMu.Unlock()
+	if !ok || time.Now().After(cached.expires) {
+		values, ttl, err := c.read(context.Background(), path)
+		switch {
+		case err == nil:
+			cached = cachedSecret{values: values, expires: time.Now().Add(ttl)}
+			c.cacheMu.Lock()
+			c.cache[path] = cached
+			c.cacheMu.Unlock()
+		case ok:
+			slog.Warn("cannot refresh vault secret, using the cached value", "path", path, "err", err)
+		default:
+			return "", err
+		}
+	}
+	value, ok := cached.values[field]
+	if !ok {
+		return "", fmt.Errorf("vault: no field %q in %s", field, path)
+	}
+	if s, isString := value.(string); isString {
+		return s, nil
+	}
+	b, err := json.Marshal(value)
+	return string(b), err
+}
+
+// read fetches a secret, logging in first (and again once after a 403); ttl is its lease or refresh-interval.
+func (c *Client) read(ctx context.Context, path string) (map[string]any, time.Duration, error) {
+	var body struct {
+		LeaseDuration int            `json:"lease_duration"`
+		Data          map[string]any `json:"data"`
+	}
+	err := c.authorized(ctx, func(token string) error {
+		return c.do(ctx, http.MethodGet, "/v1/"+path, token, nil, &body)
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("vault: cannot read %s: %w", path, err)
+	}
+	values := body.Data
+	// KV v2 nests the fields next to the version metadata
+	if nested, ok := values["data"].(map[string]any); ok {
+		if _, hasMetadata := values["metadata"]; hasMetadata {
+			values = nested
+		}
+	}
+	if values == nil {
+		return nil, 0, fmt.Errorf("vault: no secret at %s", path)
+	}
+	ttl := c.cfg.RefreshInterval
+	if body.LeaseDuration > 0 {
+		ttl = time.Duration(body.LeaseDuration) * time.Second
+	}
+	return values, ttl, nil
+}
+
+// authorized runs call with a valid token, logging in when there is none or when the token was rejected.
+func (c *Client) authorized(ctx context.Context, call func(token string) error) error {
+	token, err := c.currentToken(ctx)
+	if err != nil {
+		return err
+	}
+	if err = call(token); !errors.Is(err, errPermissionDenied) {
+		return err
+	}
+	c.mu.Lock()
+	if c.token == token {
+		c.token = ""
+	}
+	c.mu.Unlock()
+	if token, err = c.currentToken(ctx); err != nil {
+		return err
+	}
+	return call(token)
+}
+
+func (c *Client) currentToken(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token == "" {
+		if err := c.login(ctx); err != nil {
+			return "", err
+		}
+	}
+	return c.token, nil
+}
+
+// login gets a token with the configured method; c.mu must be held.
+func (c *Client) login(ctx context.Context) error {
+	a := c.cfg.Auth
+	var auth struct {
+		ClientToken   string `json:"client_token"`
+		LeaseDuration int    `json:"lease_duration"`
+		Renewable     bool   `json:"renewable"`
+	}
+	switch a.Method {
+	case config.VaultAuthAppRole, config.VaultAuthKubernetes:
+		payload := map[string]string{"role_id": a.RoleID}
+		secretFile := a.SecretIDFile
+		if a.Method == config.VaultAuthKubernetes {
+			payload = map[string]string{"role": a.Role}
+			secretFile = a.JWTFile
+		}
+		secret, err := readFile(secretFile)
+		if err != nil {
+			return fmt.Errorf("vault: %w", err)
+		}
+		if a.Method == config.VaultAuthKubernetes {
+			payload["jwt"] = secret
+		} else {
+			payload["secret_id"] = secret
+		}
+		var body struct {
+			Auth *json.RawMessage `json:"auth"`
+		}
+		if err = c.do(ctx, http.MethodPost, "/v1/auth/"+strings.Trim(a.Mount, "/")+"/login", "", payload, &body); err != nil {
+			return fmt.Errorf("vault: %s login failed: %w", a.Method, err)
+		}
+		if body.Auth == nil {
+			return fmt.Errorf("vault: %s login returned no token", a.Method)
+		}
+		if err = json.Unmarshal(*body.Auth, &auth); err != nil {
+			return err
+		}
+	default:
+		token := a.Token
+		if a.TokenFile != "" {
+			var err error
+			if token, err = readFile(a.TokenFile); err != nil {
+				return fmt.Errorf("vault: %w", err)
+			}
+		}
+		// the lookup validates the token and tells whether (and when) it needs renewing
+		var body struct {
+			Data struct {
+				TTL       int  `json:"ttl"`
+				Renewable bool `json:"renewable"`
+			} `json:"data"`
+		}
+		if err := c.do(ctx, http.MethodGet, "/v1/auth/token/lookup-self", token, nil, &body); err != nil {
+			return fmt.Errorf("vault: token lookup failed: %w", err)
+		}
+		auth.ClientToken, auth.LeaseDuration, auth.Renewable = token, body.Data.TTL, body.Data.Renewable
+	}
+	c.token = auth.ClientToken
+	c.tokenTTL = time.Duration(auth.LeaseDuration) * time.Second
+	c.renewable = auth.Renewable
+	return nil
+}
+
+// Run keeps the token valid until ctx is done: it renews it when two thirds of its TTL passed, and logs in again
+// when it cannot be renewed.
+func (c *Client) Run(ctx context.Context) {
+	for {
+		wait := c.renewIn()
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+		if err := c.renew(ctx); err != nil {
+			slog.Warn("cannot renew the vault token", "err", err)
+		}
+	}
+}
+
+// renewIn is the time until the next renewal.
+func (c *Client) renewIn() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token == "" || c.tokenTTL == 0 {
+		return c.cfg.RefreshInterval
+	}
+	return max(c.tokenTTL*2/3, 5*time.Second)
+}
+
+// renew extends the token, or logs in again when it is not renewable (or the renewal failed).
+func (c *Client) renew(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token == "" || c.tokenTTL == 0 {
+		return nil
+	}
+	if c.renewable {
+		var body struct {
+			Auth struct {
+				LeaseDuration int  `json:"lease_duration"`
+				Renewable     bool `json:"renewable"`
+			} `json:"auth"`
+		}
+		err := c.do(ctx, http.MethodPost, "/v1/auth/token/renew-self", c.token, map[string]string{}, &body)
+		// a renewal that no longer extends the TTL (max TTL reached) needs a fresh login
+		if err == nil && time.Duration(body.Auth.LeaseDuration)*time.Second >= c.tokenTTL/2 {
+			c.tokenTTL = time.Duration(body.Auth.LeaseDuration) * time.Second
+			c.renewable = body.Auth.Renewable
+			return nil
+		}
+	}
+	return c.login(ctx)
+}
+
+// do sends a Vault API request and decodes the JSON answer into out.
+func (c *Client) do(ctx context.Context, method, path, token string, payload, out any) error {
+	var reqBody io.Reader
+	if payload != nil {
+		b, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimRight(c.cfg.Address, "/")+path, reqBody)
+	if err != nil {
+		return err
+	}
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	if c.cfg.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", c.cfg.Namespace)
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	switch {
+	case resp.StatusCode == http.StatusForbidden:
+		return errPermissionDenied
+	case resp.StatusCode >= 300:
+		var apiErr struct {
+			Errors []string `json:"errors"`
+		}
+		_ = json.Unmarshal(data, &apiErr)
+		return fmt.Errorf("%s %s: %s %s", method, path, resp.Status, strings.Join(apiErr.Errors, "; "))
+	}
+	return json.Unmarshal(data, out)
+}
+
+func readFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
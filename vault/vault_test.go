@@ -0,0 +1,160 @@
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kinjelom/watchdog_exporter/config"
+)
+
+// fakeVault serves an approle login, token renewal and one KV v2 secret.
+type fakeVault struct {
+	mu       sync.Mutex
+	logins   int
+	renewals int
+	reads    int
+	token    string
+	password string
+	down     bool
+}
+
+func (f *fakeVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.down {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	switch r.URL.Path {
+	case "/v1/auth/approle/login":
+		var body map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if body["role_id"] != "watchdog" || body["secret_id"] != "s3cret-id" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"errors":["invalid role or secret ID"]}`))
+			return
+		}
+		f.logins++
+		f.token = "tok-" + string(rune('0'+f.logins))
+		_ = json.NewEncoder(w).Encode(map[string]any{"auth": map[string]any{"client_token": f.token, "lease_duration": 60, "renewable": true}})
+	case "/v1/auth/token/renew-self":
+		f.renewals++
+		_ = json.NewEncoder(w).Encode(map[string]any{"auth": map[string]any{"lease_duration": 60, "renewable": true}})
+	case "/v1/secret/data/watchdog/api":
+		if r.Header.Get("X-Vault-Token") != f.token || r.Header.Get("X-Vault-Namespace") != "ops" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		f.reads++
+		_ = json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{
+			"data":     map[string]any{"password": f.password, "port": 8443},
+			"metadata": map[string]any{"version": 3},
+		}})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func newTestClient(t *testing.T, f *fakeVault) *Client {
+	t.Helper()
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	secretIDFile := filepath.Join(t.TempDir(), "secret-id")
+	assert.NoError(t, os.WriteFile(secretIDFile, []byte("s3cret-id\n"), 0o600))
+	c, err := New(config.VaultConfig{
+		Address:         srv.URL,
+		Namespace:       "ops",
+		RefreshInterval: time.Hour,
+		Timeout:         time.Second,
+		Auth:            config.VaultAuth{Method: config.VaultAuthAppRole, Mount: "approle", RoleID: "watchdog", SecretIDFile: secretIDFile},
+	})
+	assert.NoError(t, err)
+	return c
+}
+
+func TestClient_SecretIsCached(t *testing.T) {
+	f := &fakeVault{password: "p1"}
+	c := newTestClient(t, f)
+
+	got, err := c.Secret("secret/data/watchdog/api", "password")
+	assert.NoError(t, err)
+	assert.Equal(t, "p1", got)
+	got, err = c.Secret("/secret/data/watchdog/api/", "port")
+	assert.NoError(t, err)
+	assert.Equal(t, "8443", got, "non-string values as JSON")
+	assert.Equal(t, 1, f.logins)
+	assert.Equal(t, 1, f.reads, "served from the cache")
+
+	_, err = c.Secret("secret/data/watchdog/api", "missing")
+	assert.ErrorContains(t, err, `no field "missing"`)
+	_, err = c.Secret("secret/data/other", "password")
+	assert.Error(t, err)
+}
+
+func TestClient_ExpiredSecretIsReread(t *testing.T) {
+	f := &fakeVault{password: "p1"}
+	c := newTestClient(t, f)
+	_, err := c.Secret("secret/data/watchdog/api", "password")
+	assert.NoError(t, err)
+
+	expire := func() {
+		c.cacheMu.Lock()
+		entry := c.cache["secret/data/watchdog/api"]
+		entry.expires = time.Now().Add(-time.Second)
+		c.cache["secret/data/watchdog/api"] = entry
+		c.cacheMu.Unlock()
+	}
+	f.mu.Lock()
+	f.password = "p2"
+	f.mu.Unlock()
+	expire()
+	got, err := c.Secret("secret/data/watchdog/api", "password")
+	assert.NoError(t, err)
+	assert.Equal(t, "p2", got, "rotated secret picked up")
+
+	// Vault unreachable: the expired value keeps the probes going
+	f.mu.Lock()
+	f.down = true
+	f.mu.Unlock()
+	expire()
+	got, err = c.Secret("secret/data/watchdog/api", "password")
+	assert.NoError(t, err)
+	assert.Equal(t, "p2", got)
+}
+
+func TestClient_LogsInAgainAfterPermissionDenied(t *testing.T) {
+	f := &fakeVault{password: "p1"}
+	c := newTestClient(t, f)
+	c.token = "revoked"
+
+	got, err := c.Secret("secret/data/watchdog/api", "password")
+	assert.NoError(t, err)
+	assert.Equal(t, "p1", got)
+	assert.Equal(t, 1, f.logins)
+}
+
+func TestClient_Renew(t *testing.T) {
+	f := &fakeVault{password: "p1"}
+	c := newTestClient(t, f)
+	_, err := c.Secret("secret/data/watchdog/api", "password")
+	assert.NoError(t, err)
+	assert.Equal(t, 40*time.Second, c.renewIn(), "two thirds of the TTL")
+
+	assert.NoError(t, c.renew(context.Background()))
+	assert.Equal(t, 1, f.renewals)
+	assert.Equal(t, 1, f.logins)
+
+	// not renewable: a fresh login
+	c.renewable = false
+	assert.NoError(t, c.renew(context.Background()))
+	assert.Equal(t, 2, f.logins)
+}